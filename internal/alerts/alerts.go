@@ -0,0 +1,177 @@
+package alerts
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+const capNamespace = "urn:oasis:names:tc:emergency:cap:1.2"
+
+// Severity follows the CAP severity scale.
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityMinor
+	SeverityModerate
+	SeveritySevere
+	SeverityExtreme
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityMinor:
+		return "minor"
+	case SeverityModerate:
+		return "moderate"
+	case SeveritySevere:
+		return "severe"
+	case SeverityExtreme:
+		return "extreme"
+	}
+	return "unknown"
+}
+
+// ParseSeverity maps a CAP severity name to a Severity. Unknown names map to
+// SeverityUnknown.
+func ParseSeverity(name string) Severity {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "minor":
+		return SeverityMinor
+	case "moderate":
+		return SeverityModerate
+	case "severe":
+		return SeveritySevere
+	case "extreme":
+		return SeverityExtreme
+	}
+	return SeverityUnknown
+}
+
+type Alert struct {
+	Event    string
+	Area     string
+	Severity Severity
+	Onset    time.Time
+	Expires  time.Time
+}
+
+// Query selects which alerts from a CAP Atom feed (e.g. MeteoAlarm) are
+// relevant for the display.
+type Query struct {
+	FeedURL     string
+	Area        string
+	MinSeverity Severity
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string `xml:"title"`
+	Event     string `xml:"urn:oasis:names:tc:emergency:cap:1.2 event"`
+	AreaDesc  string `xml:"urn:oasis:names:tc:emergency:cap:1.2 areaDesc"`
+	Severity  string `xml:"urn:oasis:names:tc:emergency:cap:1.2 severity"`
+	Onset     string `xml:"urn:oasis:names:tc:emergency:cap:1.2 onset"`
+	Effective string `xml:"urn:oasis:names:tc:emergency:cap:1.2 effective"`
+	Expires   string `xml:"urn:oasis:names:tc:emergency:cap:1.2 expires"`
+}
+
+// Fetch downloads the feed and returns matching alerts, most severe first.
+func Fetch(ctx context.Context, q Query) ([]Alert, error) {
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", q.FeedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alerts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alerts feed returned status %d", resp.StatusCode)
+	}
+
+	var feed atomFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to decode alerts feed: %w", err)
+	}
+
+	var result []Alert
+	for _, entry := range feed.Entries {
+		alert, ok := parseEntry(entry)
+		if !ok {
+			continue
+		}
+		if alert.Severity < q.MinSeverity {
+			continue
+		}
+		if q.Area != "" && !strings.Contains(strings.ToLower(alert.Area), strings.ToLower(q.Area)) {
+			continue
+		}
+		result = append(result, alert)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Severity != result[j].Severity {
+			return result[i].Severity > result[j].Severity
+		}
+		return result[i].Onset.Before(result[j].Onset)
+	})
+
+	return result, nil
+}
+
+func parseEntry(entry atomEntry) (Alert, bool) {
+	expires, err := time.Parse(time.RFC3339, strings.TrimSpace(entry.Expires))
+	if err != nil {
+		return Alert{}, false
+	}
+
+	onsetStr := entry.Onset
+	if onsetStr == "" {
+		onsetStr = entry.Effective
+	}
+	onset, err := time.Parse(time.RFC3339, strings.TrimSpace(onsetStr))
+	if err != nil {
+		return Alert{}, false
+	}
+
+	event := strings.TrimSpace(entry.Event)
+	if event == "" {
+		event = strings.TrimSpace(entry.Title)
+	}
+
+	return Alert{
+		Event:    event,
+		Area:     strings.TrimSpace(entry.AreaDesc),
+		Severity: ParseSeverity(entry.Severity),
+		Onset:    onset,
+		Expires:  expires,
+	}, true
+}
+
+// Active returns the alerts that overlap the window [now, now+within).
+func Active(alerts []Alert, now time.Time, within time.Duration) []Alert {
+	windowEnd := now.Add(within)
+
+	var active []Alert
+	for _, a := range alerts {
+		if a.Onset.Before(windowEnd) && a.Expires.After(now) {
+			active = append(active, a)
+		}
+	}
+	return active
+}
@@ -2,126 +2,1082 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"log"
+	"math"
 	"os"
-	"os/exec"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/paveljanda/calvin/internal/alerts"
 	"github.com/paveljanda/calvin/internal/battery"
 	"github.com/paveljanda/calvin/internal/calendar"
 	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/locale"
+	"github.com/paveljanda/calvin/internal/lock"
+	"github.com/paveljanda/calvin/internal/mqtt"
+	"github.com/paveljanda/calvin/internal/power"
+	"github.com/paveljanda/calvin/internal/quote"
 	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/script"
+	"github.com/paveljanda/calvin/internal/sports"
+	"github.com/paveljanda/calvin/internal/spotprice"
+	"github.com/paveljanda/calvin/internal/state"
+	"github.com/paveljanda/calvin/internal/ticker"
+	"github.com/paveljanda/calvin/internal/version"
+	"github.com/paveljanda/calvin/internal/waste"
 	"github.com/paveljanda/calvin/internal/weather"
 )
 
-func Run(ctx context.Context, cfg *config.Config, noShutdown bool, noBattery bool) error {
-	log.Println("Connecting to Google Calendar API...")
-	calClient, err := calendar.NewClient(ctx, cfg.Calendar.CredentialsFile, cfg.Calendar.TokenFile, cfg.Weather.Timezone)
+// lowMemoryGCPercent makes the collector run more often in low-memory mode
+// so the heap stays well below what a 512MB Pi Zero can spare.
+const lowMemoryGCPercent = 25
+
+func Run(ctx context.Context, cfg *config.Config, opts ...Option) (err error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	lk, err := acquireLock(ctx, cfg)
+	if errors.Is(err, lock.ErrLocked) {
+		log.Printf("Skipping run: %v", err)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create calendar client: %w", err)
+		return err
+	}
+	defer lk.Release()
+
+	// The alarm and shutdown follow every run, failed or not, so an error
+	// never leaves the frame asleep until someone powers it on.
+	var wake time.Time
+	defer func() {
+		if finishErr := finishRun(ctx, cfg, o, wake); finishErr != nil {
+			err = errors.Join(err, finishErr)
+		}
+	}()
+
+	err = checkPower(ctx, cfg, o)
+	if err == nil {
+		wake, err = runRecovered(ctx, cfg, o)
+	}
+	if err != nil && o.errorRenderer != nil {
+		o.errorRenderer(cfg, err)
+	}
+
+	return err
+}
+
+// run renders and returns when the PiSugar should wake the Pi next, zero
+// when the run failed before deciding.
+func run(ctx context.Context, cfg *config.Config, o options) (time.Time, error) {
+	if cfg.Render.LowMemory {
+		log.Println("Low-memory mode enabled")
+		debug.SetGCPercent(lowMemoryGCPercent)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.MaxRunDuration())
+	defer cancel()
+
+	waitForNetwork(runCtx, cfg)
+	if err := resolveLocation(runCtx, cfg); err != nil {
+		return time.Time{}, err
+	}
+
+	result, err := renderAndRecord(runCtx, cfg, o, cfg.Display.Views[0])
+	if err != nil {
+		if runCtx.Err() != context.DeadlineExceeded {
+			return result.wake, err
+		}
+		log.Printf("Warning: run exceeded %s budget, keeping previous image: %v", cfg.MaxRunDuration(), err)
+	}
+	return result.wake, nil
+}
+
+// runResult carries facts about a generate call into the run summary.
+type runResult struct {
+	events int
+	// changes summarizes event changes since the previous run.
+	changes string
+	// battery is the measured level, empty when it wasn't read.
+	battery string
+	// apiErrors names the services whose fetch failed, for the usage
+	// statistics.
+	apiErrors []string
+	// nextEvent and nextReminder are the upcoming event start and Google
+	// notification; wake is the PiSugar alarm, zero when not set by a run.
+	nextEvent    time.Time
+	nextReminder time.Time
+	wake         time.Time
+	// input is what was rendered, nil when the run failed before.
+	input *render.MonthInput
+}
+
+// generate fetches all data and renders the output image. Every blocking
+// call is bound to ctx so the run deadline is honored end to end.
+func generate(ctx context.Context, cfg *config.Config, o options, view string) (runResult, error) {
+	// Every date decision of the run uses this one reference time, so a
+	// run crossing midnight doesn't fetch one month and render another.
+	now := time.Now()
+	t := newTimings()
+	defer func() {
+		log.Printf("Timings: %s", t)
+	}()
+
+	input, result, err := gather(ctx, cfg, o, view, now, t)
+	if err != nil {
+		return result, err
+	}
+	result.input = &input
+
+	meta := runMetadata{
+		nextRefresh: nextRefresh(cfg, o, now, result.wake),
+		battery:     result.battery,
+	}
+	if err := generatePNG(cfg, view, t, meta, input); err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	if err := showOnPanel(cfg, cfg.Output.Path, false); err != nil {
+		return result, err
+	}
+	if cfg.Display.IT8951.Enabled {
+		t.add("panel", time.Since(start))
 	}
+	start = time.Now()
+	if err := publishKindle(ctx, cfg, cfg.Output.Path); err != nil {
+		return result, err
+	}
+	if cfg.Output.Kindle.Enabled {
+		t.add("publish", time.Since(start))
+	}
+
+	logMemoryUsage()
+
+	return result, nil
+}
+
+// gather fetches all data for rendering view at now into the render input.
+// Soft failures are logged and recorded in the result.
+func gather(ctx context.Context, cfg *config.Config, o options, view string, now time.Time, t *timings) (render.MonthInput, runResult, error) {
+	var result runResult
+
+	var calClient *calendar.Client
+	if cfg.Calendar.HasGoogleSources() {
+		log.Println("Connecting to Google Calendar API...")
+		credentials, err := cfg.Calendar.CredentialsJSON()
+		if err != nil {
+			return render.MonthInput{}, result, fmt.Errorf("unable to read calendar credentials: %w", err)
+		}
 
-	log.Printf("Calvin - E-Ink Calendar Generator")
+		done := t.track("auth")
+		calClient, err = calendar.NewClient(ctx, credentials, cfg.Calendar.TokenFile, cfg.Weather.Timezone, calendar.Scope(cfg.Calendar.WriteAccess))
+		done()
+		if err != nil {
+			return render.MonthInput{}, result, fmt.Errorf("failed to create calendar client: %w", err)
+		}
+	}
+
+	log.Printf("Calvin - E-Ink Calendar Generator %s", version.String())
 	log.Printf("Display: %dx%d", cfg.Display.Width, cfg.Display.Height)
 	log.Printf("Output: %s", cfg.Output.Path)
 
 	log.Println("Fetching weather data...")
-	weatherData, weatherErr := weather.Fetch(cfg.Weather.Latitude, cfg.Weather.Longitude, cfg.Weather.Timezone)
+	comparisonCh := make(chan []render.LocationForecast, 1)
+	go func() {
+		defer t.track("comparison")()
+		comparisonCh <- fetchComparison(ctx, cfg)
+	}()
+
+	weatherQuery := weather.Query{
+		Latitude:     cfg.Weather.Latitude,
+		Longitude:    cfg.Weather.Longitude,
+		Timezone:     cfg.Weather.Timezone,
+		ForecastDays: render.ForecastDays(view),
+		Units:        weather.Units(cfg.Weather.Units),
+		Snow:         cfg.Weather.Snow,
+	}
+	done := t.track("weather")
+	weatherData, weatherErr := weather.Fetch(ctx, weatherQuery)
+	done()
 	if weatherErr != nil {
 		log.Printf("Warning: Failed to fetch weather: %v", weatherErr)
+		result.apiErrors = append(result.apiErrors, "weather")
+	}
+
+	var comparison []render.LocationForecast
+	if others := <-comparisonCh; len(others) > 0 && weatherData != nil {
+		comparison = append([]render.LocationForecast{{Label: cfg.Weather.Label, Forecast: weatherData}}, others...)
 	}
 
-	allEvents, err := fetchAllCalendarEvents(cfg, calClient)
+	done = t.track("history")
+	lastYearTemp := fetchLastYearTemperature(ctx, cfg, weatherQuery, now)
+	done()
+
+	done = t.track("alerts")
+	weatherAlerts := fetchAlerts(ctx, cfg)
+	done()
+
+	done = t.track("ticker")
+	tickers, tickerErr := fetchTickers(ctx, cfg, now)
+	done()
+	if tickerErr != nil {
+		result.apiErrors = append(result.apiErrors, "ticker")
+	}
+
+	done = t.track("spot prices")
+	spotPrices, spotErr := fetchSpotPrices(ctx, cfg, now)
+	done()
+	if spotErr != nil {
+		result.apiErrors = append(result.apiErrors, "spot prices")
+	}
+
+	done = t.track("sensors")
+	sensors, sensorsErr := readSensors(ctx, cfg)
+	done()
+	if sensorsErr != nil {
+		result.apiErrors = append(result.apiErrors, "sensors")
+	}
+
+	done = t.track("quote")
+	quoteOfDay, quoteErr := fetchQuote(ctx, cfg, now)
+	done()
+	if quoteErr != nil {
+		result.apiErrors = append(result.apiErrors, "quote")
+	}
+
+	fetched, err := fetchAllCalendarEvents(ctx, cfg, calClient, now, t)
 	if err != nil {
-		return err
+		return render.MonthInput{}, result, err
+	}
+	allEvents := fetched.events
+	result.events = len(allEvents)
+	for _, name := range fetched.failed {
+		result.apiErrors = append(result.apiErrors, "calendar "+name)
+	}
+	if !fetched.changes.Empty() {
+		result.changes = fetched.changes.String()
+	}
+	if next, ok := calendar.Next(allEvents, now, func(ev calendar.Event) bool { return ev.Start.After(now) }); ok {
+		result.nextEvent = next.Start
+	}
+	result.nextReminder, _ = calendar.NextReminder(allEvents, now)
+	if !o.daemon && !o.dryRun {
+		result.wake = wakeTime(cfg, allEvents, now)
 	}
 
 	batteryPercent := "100%"
-	if !noBattery {
-		batteryPercent, err = battery.GetBatteryPercentage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get battery percentage: %w", err)
+	var batteryErr error
+	if !o.noBattery {
+		done := t.track("battery")
+		batteryPercent, batteryErr = battery.GetBatteryPercentage(ctx)
+		done()
+		if batteryErr != nil {
+			log.Printf("Warning: Failed to get battery percentage: %v", batteryErr)
+			batteryPercent = "n/a"
+		} else {
+			result.battery = batteryPercent
 		}
 	}
 	log.Printf("Battery: %s", batteryPercent)
 
-	err = generatePNG(cfg, weatherData, weatherErr, allEvents, batteryPercent)
-	if err != nil {
-		return err
+	// Fetch helpers only log soft failures, so check the budget explicitly
+	// rather than render a calendar with every source missing.
+	if err := ctx.Err(); err != nil {
+		return render.MonthInput{}, result, fmt.Errorf("run budget exhausted before rendering: %w", err)
 	}
 
-	if noShutdown {
-		log.Println("Dry-run or list-calendars mode: skipping alarm and shutdown")
-		return nil
+	if cfg.Render.LowMemory {
+		debug.FreeOSMemory()
 	}
 
-	err = handlePiSugar(ctx)
-	if err != nil {
-		return err
+	var runTime time.Duration
+	if cfg.Render.ShowTiming {
+		runTime = t.elapsed()
 	}
+	return render.MonthInput{
+		Now:                now,
+		Width:              cfg.Display.Width,
+		Height:             cfg.Display.Height,
+		Weather:            weatherData,
+		WeatherErr:         weatherErr,
+		Events:             allEvents,
+		MaxEventsPerDay:    maxEventsPerDay(cfg),
+		TrimOutsideWeeks:   cfg.Calendar.TrimOutsideWeeks,
+		BatteryPercentage:  batteryPercent,
+		BatteryErr:         batteryErr,
+		Alerts:             weatherAlerts,
+		LastYearTemp:       lastYearTemp,
+		Widgets:            append(sensors, fetched.widgets...),
+		Quote:              quoteOfDay,
+		Tickers:            tickers,
+		SpotPrices:         spotPrices,
+		SpotUnit:           cfg.Spot.Unit,
+		SpotCheapest:       cfg.Spot.CheapestHours,
+		Comparison:         comparison,
+		ShowDaylight:       cfg.Weather.Daylight,
+		Images:             loadImages(cfg),
+		QR:                 qrCode(cfg, allEvents, now),
+		Vacations:          dateRanges(cfg, cfg.Calendar.Vacations),
+		Periods:            dateRanges(cfg, cfg.Calendar.Periods),
+		OOOKeywords:        cfg.Calendar.OutOfOfficeKeywords,
+		HolidayCalendars:   holidayCalendars(cfg),
+		Shade:              cfg.Display.Shade,
+		Countdown:          cfg.Calendar.Countdown.Enabled,
+		CountdownCalendars: cfg.Calendar.Countdown.Calendars,
+		People:             people(cfg),
+		TravelWarning:      cfg.Calendar.TravelWarning(),
+		Redactions:         redactions(cfg),
+		ImportantCalendars: importantCalendars(cfg),
+		ImportantKeywords:  cfg.Calendar.ImportantKeywords,
+		CalendarStyles:     calendarStyles(cfg),
+		NewEventKeys:       fetched.newEventKeys(),
+		CancelledEvents:    fetched.cancelled,
+		RunTime:            runTime,
+		Uptime:             monthUptime(cfg, now),
+		UpdatedClock:       cfg.Display.UpdatedClock,
+		Suggestions:        suggestions(cfg),
+		Waste:              wasteSchedules(cfg),
+		CountdownEvents:    fetched.countdowns,
+		CountdownMax:       cfg.Calendar.Countdown.Max,
+		Locale:             displayLocale(cfg),
+	}, result, nil
+}
 
-	log.Println("Shutting down system...")
-	if err := exec.Command("sudo", "shutdown", "-h", "now").Run(); err != nil {
-		return fmt.Errorf("failed to shutdown: %w", err)
+// nextWake returns when the PiSugar alarm wakes the Pi for the next run:
+// the start of the next hour in loc, as UTC for the RTC. It steps forward
+// by what is left of the local hour instead of truncating the absolute
+// time, which lands on the half hour in zones like Asia/Kolkata. DST
+// changes only relabel hours, so each real hour still gets one wake-up:
+// after 01:xx CET on a spring-forward night comes 03:00 CEST, and the
+// repeated 02:00 on a fall-back night is woken for twice.
+func nextWake(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	elapsed := time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+	return now.Add(time.Hour - elapsed).UTC()
+}
+
+// minEventWake is the least time until an event wake, so the Pi has shut
+// down before the alarm fires.
+const minEventWake = 5 * time.Minute
+
+// wakeTime returns when the PiSugar alarm wakes the Pi for the next run:
+// power.wake_before_event_minutes before the next event with a Google
+// reminder when that comes first, and at the next hourly wake otherwise.
+func wakeTime(cfg *config.Config, events []calendar.Event, now time.Time) time.Time {
+	wake := nextWake(now, location(cfg))
+	before := cfg.Power.WakeBeforeEvent()
+	if before == 0 {
+		return wake
 	}
 
-	return nil
+	next, ok := calendar.Next(events, now, func(ev calendar.Event) bool {
+		return len(ev.Reminders) > 0 && ev.Start.Add(-before).After(now.Add(minEventWake))
+	})
+	if ok && next.Start.Add(-before).Before(wake) {
+		log.Printf("Waking %s before %q", before, next.Summary)
+		return next.Start.Add(-before).UTC()
+	}
+	return wake
 }
 
-func handlePiSugar(ctx context.Context) error {
-	nextHour := time.Now().Add(time.Hour).Truncate(time.Hour)
-	alarmTime := nextHour.Format("2006-01-02 15:04:05")
+// location returns the configured timezone, falling back to the system
+// one.
+func location(cfg *config.Config) *time.Location {
+	loc, err := time.LoadLocation(cfg.Weather.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
 
-	log.Printf("Setting PiSugar alarm for: %s", alarmTime)
+// nextRefresh returns when the next render is expected: after the refresh
+// interval in daemon mode, at wake otherwise, and unknown (zero) in dry
+// runs, which set no alarm.
+func nextRefresh(cfg *config.Config, o options, now, wake time.Time) time.Time {
+	switch {
+	case o.daemon:
+		return now.Add(cfg.Server.RefreshInterval())
+	case o.dryRun:
+		return time.Time{}
+	case !wake.IsZero():
+		return wake
+	}
+	return nextWake(now, location(cfg))
+}
 
-	output, err := exec.CommandContext(ctx, "sudo", "pisugar-cli", "--set-alarm", alarmTime).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to set PiSugar alarm: pisugar-cli failed: %w, output: %s", err, string(output))
+// piSugarTimeout bounds alarm scheduling on its own, so the next wake is
+// still set after the run budget has already been spent.
+const piSugarTimeout = 15 * time.Second
+
+// handlePiSugar sets the alarm for wake, or for the next hourly wake when
+// the run didn't get far enough to decide.
+func handlePiSugar(ctx context.Context, cfg *config.Config, pc power.Controller, wake time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, piSugarTimeout)
+	defer cancel()
+
+	loc := location(cfg)
+	if now := time.Now(); !wake.After(now) {
+		wake = nextWake(now, loc)
 	}
+	log.Printf("Setting PiSugar alarm for: %s (%s UTC)", wake.In(loc).Format("2006-01-02 15:04:05 MST"), wake.Format("15:04"))
 
-	log.Printf("PiSugar response: %s", string(output))
+	return pc.SetAlarm(ctx, wake)
+}
 
-	return nil
+// fetchedEvents is the merged result of all calendar sources.
+type fetchedEvents struct {
+	events  []calendar.Event
+	widgets []script.Widget
+	// countdowns are the events of sources marked countdowns.
+	countdowns []calendar.Event
+	// changes compares events with the previous run's event cache.
+	changes     state.EventChanges
+	hadPrevious bool
+	// cancelled are events removed from sources that were fetched
+	// successfully, as opposed to events missing because a fetch failed.
+	cancelled []calendar.Event
+	// failed names the sources that couldn't be fetched.
+	failed []string
 }
 
-func fetchAllCalendarEvents(cfg *config.Config, calClient *calendar.Client) ([]calendar.Event, error) {
+// newEventKeys returns the keys of events added or moved since the previous
+// run. Nothing is new when there is no previous run to compare with.
+func (f fetchedEvents) newEventKeys() []string {
+	if !f.hadPrevious {
+		return nil
+	}
+
+	var keys []string
+	for _, e := range f.changes.Added {
+		keys = append(keys, e.Key())
+	}
+	for _, e := range f.changes.Moved {
+		keys = append(keys, e.Key())
+	}
+	return keys
+}
+
+func fetchAllCalendarEvents(ctx context.Context, cfg *config.Config, calClient *calendar.Client, now time.Time, t *timings) (fetchedEvents, error) {
 	log.Println("Fetching calendar events for month view...")
-	var allEvents []calendar.Event
+	var result fetchedEvents
+
+	loc, err := time.LoadLocation(cfg.Weather.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+
+	store, cache := loadEventCache(cfg)
+	previous := cache.All()
+	result.hadPrevious = !cache.FetchedAt.IsZero()
+	fresh := make(map[string][]calendar.Event)
+	windows := make(map[string]state.Window)
+	// compared holds the windows both this run and the previous one
+	// fetched.
+	compared := make(map[string]state.Window)
+	syncs := make(map[string]*calendar.Sync)
 
 	for _, calCfg := range cfg.Calendar.Calendars {
-		name := calCfg.Name
-		if name == "" {
-			name = calCfg.ID
-		}
+		name := calCfg.DisplayName()
 		log.Printf("  Fetching: %s", name)
+		done := t.track("calendar " + name)
 
-		events, err := calClient.FetchEventsForMonth(calCfg.ID, name)
+		var events []calendar.Event
+		switch calCfg.Type {
+		case config.SourceScript:
+			var scriptResult *script.Result
+			scriptResult, err = script.Run(ctx, calCfg.Command, name, loc)
+			if err == nil {
+				events = scriptResult.Events
+				result.widgets = append(result.widgets, scriptResult.Widgets...)
+			}
+		case config.SourceSports:
+			events, err = fetchFixtures(ctx, cfg, calCfg, name, now, loc)
+		default:
+			if calCfg.Countdowns {
+				events, err = calClient.FetchUpcomingEvents(ctx, calCfg.ID, name, now, cfg.Calendar.Countdown.DaysAhead)
+			} else {
+				var sync *calendar.Sync
+				events, sync, err = calClient.FetchEventsForMonth(ctx, calCfg.ID, name, now, cache.Syncs[calCfg.ID])
+				if err == nil {
+					syncs[calCfg.ID] = sync
+				}
+			}
+		}
+		done()
+		if calCfg.Countdowns {
+			// Countdown targets lie far ahead and aren't shown on their
+			// days, so they bypass the event cache and change tracking.
+			if err != nil {
+				log.Printf("  Warning: Failed to fetch %s: %v", name, err)
+				result.failed = append(result.failed, name)
+				continue
+			}
+			log.Printf("  Found %d countdowns", len(events))
+			result.countdowns = append(result.countdowns, events...)
+			continue
+		}
 		if err != nil {
 			log.Printf("  Warning: Failed to fetch %s: %v", name, err)
+			result.failed = append(result.failed, name)
+			if cached, ok := cache.Sources[name]; ok {
+				log.Printf("  Using %d cached events from %s", len(cached), cache.FetchedAt.Format("2006-01-02 15:04"))
+				result.events = append(result.events, markPrivate(cached, calCfg.Private)...)
+			}
 			continue
 		}
+		events = hideEvents(events, cfg.Calendar.Hide)
 		log.Printf("  Found %d events", len(events))
-		allEvents = append(allEvents, events...)
+		events = markPrivate(events, calCfg.Private)
+		result.events = append(result.events, events...)
+		fresh[name] = events
+		windows[name] = fetchWindow(calCfg, now, loc)
+		compared[name] = cache.Windows[name].Intersect(windows[name])
+	}
+
+	result.changes = state.DiffEvents(previous, result.events, compared)
+	if !result.changes.Empty() {
+		log.Printf("Changes since last fetch: %s", result.changes)
+	}
+	for _, e := range result.changes.Removed {
+		if _, ok := fresh[e.CalendarName]; ok {
+			result.cancelled = append(result.cancelled, e)
+		}
+	}
+
+	if store != nil && len(fresh) > 0 {
+		for name, events := range fresh {
+			cache.Sources[name] = events
+			cache.Windows[name] = windows[name]
+		}
+		for id, sync := range syncs {
+			cache.Syncs[id] = sync
+		}
+		cache.FetchedAt = now
+		if err := store.SaveEvents(cache); err != nil {
+			log.Printf("Warning: Failed to save event cache: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// fetchWindow returns the range src is fetched for at now.
+func fetchWindow(src config.CalendarSource, now time.Time, loc *time.Location) state.Window {
+	var start, end time.Time
+	switch src.Type {
+	case config.SourceScript:
+	case config.SourceSports:
+		start, end = sports.Window(sports.Query{URL: src.URL}, now)
+	default:
+		start, end = calendar.MonthDateRange(now, loc)
+	}
+	return state.Window{Start: start, End: end}
+}
+
+// fetchFixtures returns the fixtures of a sports source.
+func fetchFixtures(ctx context.Context, cfg *config.Config, src config.CalendarSource, name string, now time.Time, loc *time.Location) ([]calendar.Event, error) {
+	q := sports.Query{
+		URL:      src.URL,
+		TeamID:   src.TeamID,
+		HomeOnly: src.HomeOnly,
+		Team:     src.Team,
+	}
+	if q.URL == "" {
+		token, err := cfg.Calendar.FootballDataTokenValue()
+		if err != nil {
+			return nil, err
+		}
+		q.Token = token
+	}
+	return sports.Fetch(ctx, q, name, now, loc)
+}
+
+// hideEvents drops the kinds of events listed in calendar.hide.
+func hideEvents(events []calendar.Event, hide []string) []calendar.Event {
+	if len(hide) == 0 {
+		return events
+	}
+	hidden := make(map[string]bool, len(hide))
+	for _, kind := range hide {
+		hidden[kind] = true
+	}
+
+	kinds := map[string]string{
+		calendar.TypeFocusTime:       config.HideFocusTime,
+		calendar.TypeOutOfOffice:     config.HideOutOfOffice,
+		calendar.TypeWorkingLocation: config.HideWorkingLocation,
+	}
+	result := events[:0]
+	for _, e := range events {
+		if (e.Free && hidden[config.HideFree]) || hidden[kinds[e.Type]] {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// markPrivate flags all events of a private calendar source.
+func markPrivate(events []calendar.Event, private bool) []calendar.Event {
+	if private {
+		for i := range events {
+			events[i].Private = true
+		}
+	}
+	return events
+}
+
+// loadEventCache returns the event cache, or an empty one without a store
+// when the state directory is unusable.
+func loadEventCache(cfg *config.Config) (*state.Store, *state.EventCache) {
+	empty := state.NewEventCache()
+
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		log.Printf("Warning: Event cache unavailable: %v", err)
+		return nil, empty
+	}
+
+	cache, err := store.LoadEvents()
+	if err != nil {
+		log.Printf("Warning: Event cache unavailable: %v", err)
+		return store, empty
+	}
+
+	return store, cache
+}
+
+func fetchAlerts(ctx context.Context, cfg *config.Config) []alerts.Alert {
+	if !cfg.Alerts.Enabled {
+		return nil
+	}
+
+	log.Println("Fetching weather alerts...")
+	result, err := alerts.Fetch(ctx, alerts.Query{
+		FeedURL:     cfg.Alerts.FeedURL,
+		Area:        cfg.Alerts.Area,
+		MinSeverity: alerts.ParseSeverity(cfg.Alerts.MinSeverity),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to fetch weather alerts: %v", err)
+		return nil
+	}
+	log.Printf("  Found %d alerts", len(result))
+
+	return result
+}
+
+func fetchTickers(ctx context.Context, cfg *config.Config, now time.Time) ([]ticker.Quote, error) {
+	if !cfg.Ticker.Enabled {
+		return nil, nil
+	}
+
+	log.Println("Fetching tickers...")
+	quotes, err := ticker.Fetch(ctx, ticker.Query{
+		URL:         cfg.Ticker.URL,
+		PriceField:  cfg.Ticker.PriceField,
+		ChangeField: cfg.Ticker.ChangeField,
+	}, cfg.Ticker.Symbols, cfg.Ticker.CacheFile, cfg.Ticker.CacheDuration(), now)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch tickers: %v", err)
+	}
+	return quotes, err
+}
+
+// fetchSpotPrices returns today's hourly electricity prices, scaled by
+// spot_prices.scale.
+func fetchSpotPrices(ctx context.Context, cfg *config.Config, now time.Time) ([]spotprice.Price, error) {
+	if !cfg.Spot.Enabled {
+		return nil, nil
+	}
+
+	q := spotprice.Query{
+		Area:       cfg.Spot.Area,
+		URL:        cfg.Spot.URL,
+		TimeField:  cfg.Spot.TimeField,
+		PriceField: cfg.Spot.PriceField,
+	}
+	if cfg.Spot.Area != "" {
+		token, err := cfg.Spot.TokenValue()
+		if err == nil && token == "" {
+			err = fmt.Errorf("no ENTSO-E token configured")
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to fetch spot prices: %v", err)
+			return nil, err
+		}
+		q.Token = token
+	}
+
+	log.Println("Fetching spot prices...")
+	prices, err := spotprice.Fetch(ctx, q, now.In(location(cfg)))
+	if err != nil {
+		log.Printf("Warning: Failed to fetch spot prices: %v", err)
+		return nil, err
+	}
+	for i := range prices {
+		prices[i].Price *= cfg.Spot.Scale
+	}
+	return prices, nil
+}
+
+// readSensors reads the configured MQTT sensors as header widgets, in
+// config order. Sensors that sent nothing are left out.
+func readSensors(ctx context.Context, cfg *config.Config) ([]script.Widget, error) {
+	if !cfg.Sensors.Enabled || len(cfg.Sensors.Readings) == 0 {
+		return nil, nil
+	}
+
+	password, err := cfg.Sensors.PasswordValue()
+	if err != nil {
+		log.Printf("Warning: Failed to read sensors: %v", err)
+		return nil, err
+	}
+	topics := make([]string, 0, len(cfg.Sensors.Readings))
+	for _, reading := range cfg.Sensors.Readings {
+		if !slices.Contains(topics, reading.Topic) {
+			topics = append(topics, reading.Topic)
+		}
+	}
+
+	log.Println("Reading sensors...")
+	ctx, cancel := context.WithTimeout(ctx, cfg.Sensors.Wait())
+	defer cancel()
+	values, err := mqtt.Read(ctx, mqtt.Broker{
+		URL:      cfg.Sensors.Broker,
+		Username: cfg.Sensors.Username,
+		Password: password,
+		ClientID: fmt.Sprintf("calvin-%d", os.Getpid()),
+	}, topics)
+	if err != nil {
+		log.Printf("Warning: Failed to read sensors: %v", err)
 	}
 
-	return allEvents, nil
+	var widgets []script.Widget
+	for _, reading := range cfg.Sensors.Readings {
+		payload, ok := values[reading.Topic]
+		if !ok {
+			log.Printf("  No value from %s", reading.Topic)
+			continue
+		}
+		value, ok := sensorValue(payload, reading.Field)
+		if !ok {
+			log.Printf("  No %q in the value of %s", reading.Field, reading.Topic)
+			continue
+		}
+		widgets = append(widgets, script.Widget{Label: reading.Label, Value: value + reading.Unit})
+	}
+	return widgets, err
 }
 
-func generatePNG(cfg *config.Config, weatherData *weather.Forecast, weatherErr error, allEvents []calendar.Event, batteryPercentage string) error {
-	log.Println("Generating PNG...")
+// sensorValue returns the payload, or its JSON field, with numbers
+// rounded to one decimal.
+func sensorValue(payload []byte, field string) (string, bool) {
+	var value any = strings.TrimSpace(string(payload))
+	if field != "" {
+		var object map[string]any
+		if err := json.Unmarshal(payload, &object); err != nil {
+			return "", false
+		}
+		if value = object[field]; value == nil {
+			return "", false
+		}
+	}
 
-	templateData := render.PrepareMonthData(cfg.Display.Width, cfg.Display.Height, weatherData, weatherErr, allEvents, cfg.Calendar.MaxEventsPerDay, batteryPercentage)
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64), true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64), true
+		}
+		return v, v != ""
+	}
+	return fmt.Sprint(value), true
+}
+
+// fetchQuote returns the quote of the day from quote.url, falling back to
+// quote.file. The error reports a failed API even when the file stood in.
+func fetchQuote(ctx context.Context, cfg *config.Config, now time.Time) (*quote.Quote, error) {
+	if !cfg.Quote.Enabled {
+		return nil, nil
+	}
 
-	if err := render.RenderCalendarToPNG(templateData, cfg.Output.Path); err != nil {
+	var quotes []quote.Quote
+	var apiErr error
+	if cfg.Quote.URL != "" {
+		log.Println("Fetching quote...")
+		quotes, apiErr = quote.Fetch(ctx, quote.API{
+			URL:         cfg.Quote.URL,
+			TextField:   cfg.Quote.TextField,
+			AuthorField: cfg.Quote.AuthorField,
+		})
+		if apiErr != nil {
+			log.Printf("Warning: Failed to fetch quote: %v", apiErr)
+		}
+	}
+	if len(quotes) == 0 && cfg.Quote.File != "" {
+		var err error
+		if quotes, err = quote.Load(cfg.Quote.File); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	q, ok := quote.ForDate(quotes, now)
+	if !ok {
+		return nil, apiErr
+	}
+	return &q, apiErr
+}
+
+func fetchLastYearTemperature(ctx context.Context, cfg *config.Config, q weather.Query, now time.Time) *float64 {
+	if !cfg.Weather.LastYear {
+		return nil
+	}
+
+	temp, err := weather.FetchLastYearTemperature(ctx, q, now, cfg.Weather.HistoryCacheFile)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch last year's temperature: %v", err)
+		return nil
+	}
+
+	return &temp
+}
+
+// dateRanges parses configured date ranges in the configured timezone;
+// config.Load has validated the dates.
+func dateRanges(cfg *config.Config, ranges []config.DateRange) []render.DateRange {
+	loc, err := time.LoadLocation(cfg.Weather.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+
+	result := make([]render.DateRange, 0, len(ranges))
+	for _, r := range ranges {
+		start, end, err := r.Dates(loc)
+		if err != nil {
+			continue
+		}
+		result = append(result, render.DateRange{Name: r.Name, Start: start, End: end})
+	}
+	return result
+}
+
+// maxEventsPerDay returns calendar.max_events_per_day, reduced in large
+// print so the bigger lines still fit the day cells.
+func maxEventsPerDay(cfg *config.Config) int {
+	if !cfg.Display.LargePrint {
+		return cfg.Calendar.MaxEventsPerDay
+	}
+	return max(1, int(float64(cfg.Calendar.MaxEventsPerDay)/render.LargePrintZoom))
+}
+
+// displayLocale returns the configured locale with the time format
+// override applied; config.Load has validated both.
+func displayLocale(cfg *config.Config) locale.Locale {
+	l, _ := locale.Parse(cfg.Display.Locale)
+	switch cfg.Display.TimeFormat {
+	case "12h":
+		l.Hour12 = true
+	case "24h":
+		l.Hour12 = false
+	}
+	return l
+}
+
+func wasteSchedules(cfg *config.Config) []waste.Schedule {
+	loc, err := time.LoadLocation(cfg.Weather.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	dates := func(values []string) []time.Time {
+		result := make([]time.Time, 0, len(values))
+		for _, v := range values {
+			if d, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+				result = append(result, d)
+			}
+		}
+		return result
+	}
+
+	result := make([]waste.Schedule, 0, len(cfg.Calendar.Waste))
+	for _, w := range cfg.Calendar.Waste {
+		day, err := w.Day()
+		if err != nil {
+			continue
+		}
+		result = append(result, waste.Schedule{
+			Name:    w.Name,
+			Weekday: day,
+			Weeks:   w.Weeks,
+			Except:  dates(w.Except),
+			Extra:   dates(w.Extra),
+		})
+	}
+	return result
+}
+
+func holidayCalendars(cfg *config.Config) []string {
+	var names []string
+	for _, src := range cfg.Calendar.Calendars {
+		if src.Holidays {
+			names = append(names, src.DisplayName())
+		}
+	}
+	return names
+}
+
+func calendarStyles(cfg *config.Config) map[string]render.CalendarStyle {
+	styles := make(map[string]render.CalendarStyle)
+	for _, src := range cfg.Calendar.Calendars {
+		if src.Style != (config.CalendarStyle{}) {
+			styles[src.DisplayName()] = render.CalendarStyle{
+				Prefix: src.Style.Prefix,
+				Color:  src.Style.Color,
+				Bold:   src.Style.Bold,
+			}
+		}
+	}
+	return styles
+}
+
+func importantCalendars(cfg *config.Config) []string {
+	var names []string
+	for _, src := range cfg.Calendar.Calendars {
+		if src.Important {
+			names = append(names, src.DisplayName())
+		}
+	}
+	return names
+}
+
+// redactions compiles calendar.redact; config.Load has validated the
+// patterns.
+func redactions(cfg *config.Config) []calendar.Redaction {
+	result := make([]calendar.Redaction, 0, len(cfg.Calendar.Redact))
+	for _, r := range cfg.Calendar.Redact {
+		result = append(result, calendar.Redaction{
+			Pattern:     regexp.MustCompile(r.Pattern),
+			Replacement: r.Replacement,
+		})
+	}
+	return result
+}
+
+func suggestions(cfg *config.Config) []weather.Rule {
+	result := make([]weather.Rule, 0, len(cfg.Weather.Suggestions))
+	for _, s := range cfg.Weather.Suggestions {
+		result = append(result, weather.Rule{
+			Offset: s.Offset(),
+			Metric: s.Metric,
+			Op:     s.Op,
+			Value:  s.Value,
+			Text:   s.Text,
+			Icon:   s.Icon,
+		})
+	}
+	return result
+}
+
+func people(cfg *config.Config) []render.Person {
+	result := make([]render.Person, 0, len(cfg.Calendar.People))
+	for _, p := range cfg.Calendar.People {
+		result = append(result, render.Person{
+			Name:      p.Name,
+			Email:     p.Email,
+			Calendars: p.Calendars,
+			Initial:   p.Initial,
+			Color:     p.Color,
+		})
+	}
+	return result
+}
+
+func generatePNG(cfg *config.Config, view string, t *timings, meta runMetadata, input render.MonthInput) error {
+	log.Printf("Generating PNG (%s view)...", view)
+
+	done := t.track("prepare")
+	templateData := render.PrepareData(view, input)
+	done()
+
+	opts := render.Options{
+		LowMemory:  cfg.Render.LowMemory,
+		Scale:      cfg.Render.Scale,
+		LargePrint: cfg.Display.LargePrint,
+		Metadata:   meta.text(cfg, time.Now()),
+		Trace:      t.add,
+	}
+	paths := []string{cfg.Output.Path}
+	for _, v := range cfg.Output.Variants {
+		paths = append(paths, v.Path)
+		variant := render.Variant{
+			Path:    v.Path,
+			Width:   v.Width,
+			Height:  v.Height,
+			Region:  v.Region,
+			Palette: v.Palette,
+			Dither:  v.Dither,
+			Raw:     v.Raw,
+		}
+		if c := v.Crop; c != nil {
+			variant.Crop = image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+		}
+		opts.Variants = append(opts.Variants, variant)
+	}
+
+	if err := render.RenderCalendarToPNG(templateData, cfg.Output.Path, opts); err != nil {
 		return fmt.Errorf("failed to generate PNG: %w", err)
 	}
+	if cfg.Output.SVG != "" {
+		start := time.Now()
+		if err := writeRendering(render.SVGRenderer{Options: opts}, templateData, cfg.Output.SVG); err != nil {
+			log.Printf("Warning: Failed to write SVG: %v", err)
+		} else {
+			paths = append(paths, cfg.Output.SVG)
+		}
+		t.add("svg", time.Since(start))
+	}
 
-	if info, err := os.Stat(cfg.Output.Path); err == nil {
-		log.Printf("Generated: %s (%.1f KB)", cfg.Output.Path, float64(info.Size())/1024)
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			log.Printf("Generated: %s (%.1f KB)", path, float64(info.Size())/1024)
+		}
 	}
 
 	log.Println("Calendar image generated successfully!")
 
 	return nil
 }
+
+// writeRendering renders data with r to path.
+func writeRendering(r render.Renderer, data render.TemplateData, path string) error {
+	out, err := r.Render(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+func logMemoryUsage() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	log.Printf("Memory: heap %.1f MB, total allocated %.1f MB, from OS %.1f MB, GC cycles %d",
+		float64(m.HeapAlloc)/1024/1024,
+		float64(m.TotalAlloc)/1024/1024,
+		float64(m.Sys)/1024/1024,
+		m.NumGC,
+	)
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+// TestMonthBoundary runs two seconds before a month ends: everything the
+// run decides from its reference time must agree on the month, however
+// long fetching takes.
+func TestMonthBoundary(t *testing.T) {
+	loc := mustLoadLocation(t, "Europe/Prague")
+	cfg := &config.Config{}
+	cfg.Weather.Timezone = "Europe/Prague"
+
+	tests := []struct {
+		now   time.Time
+		month string
+	}{
+		{time.Date(2026, 1, 31, 23, 59, 58, 0, loc), "January"},
+		{time.Date(2026, 2, 28, 23, 59, 58, 0, loc), "February"},
+		{time.Date(2026, 12, 31, 23, 59, 58, 0, loc), "December"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			start, end := calendar.MonthDateRange(tt.now, loc)
+			data := render.PrepareMonthData(render.MonthInput{Now: tt.now, Width: 800, Height: 480})
+
+			if data.MonthName != tt.month {
+				t.Errorf("prepared month = %s, want %s", data.MonthName, tt.month)
+			}
+			for _, week := range data.Weeks {
+				for _, day := range week.Days {
+					date, _ := time.ParseInLocation("2006-01-02", day.Date, loc)
+					if date.Before(start) || !date.Before(end) {
+						t.Errorf("day %s is outside the fetched range %s to %s", day.Date, start.Format("2006-01-02"), end.Format("2006-01-02"))
+					}
+				}
+			}
+
+			// The next wake follows from the same reference time, not the
+			// clock after fetching.
+			want := time.Date(tt.now.Year(), tt.now.Month()+1, 1, 0, 0, 0, 0, loc).UTC()
+			if got := wakeTime(cfg, nil, tt.now); !got.Equal(want) {
+				t.Errorf("wakeTime = %s, want %s", got, want)
+			}
+			if got := nextRefresh(cfg, options{}, tt.now, time.Time{}); !got.Equal(want) {
+				t.Errorf("nextRefresh = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestNextWakeDST covers the nights Europe/Prague changes clocks, on the
+// last Sundays of March and October. Times are given in UTC since the
+// local ones are missing or ambiguous.
+func TestNextWakeDST(t *testing.T) {
+	loc := mustLoadLocation(t, "Europe/Prague")
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"spring 00:30 CET", utc(time.March, 28, 23, 30), utc(time.March, 29, 0, 0)},
+		{"spring 01:30 CET", utc(time.March, 29, 0, 30), utc(time.March, 29, 1, 0)},
+		{"spring 01:59 CET", utc(time.March, 29, 0, 59), utc(time.March, 29, 1, 0)},
+		{"spring 03:00 CEST", utc(time.March, 29, 1, 0), utc(time.March, 29, 2, 0)},
+		{"spring 03:30 CEST", utc(time.March, 29, 1, 30), utc(time.March, 29, 2, 0)},
+		{"autumn 01:30 CEST", utc(time.October, 24, 23, 30), utc(time.October, 25, 0, 0)},
+		{"autumn first 02:30 CEST", utc(time.October, 25, 0, 30), utc(time.October, 25, 1, 0)},
+		{"autumn second 02:00 CET", utc(time.October, 25, 1, 0), utc(time.October, 25, 2, 0)},
+		{"autumn second 02:30 CET", utc(time.October, 25, 1, 30), utc(time.October, 25, 2, 0)},
+		{"autumn 03:30 CET", utc(time.October, 25, 2, 30), utc(time.October, 25, 3, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextWake(tt.now, loc); !got.Equal(tt.want) {
+				t.Errorf("nextWake(%s) = %s, want %s", tt.now.In(loc).Format("15:04 MST"), got, tt.want)
+			}
+		})
+	}
+
+	// 02:30 doesn't exist on the spring night; time.Date reads it as 03:30
+	// CEST, which wakes at 04:00 CEST.
+	missing := time.Date(2026, time.March, 29, 2, 30, 0, 0, loc)
+	if got, want := nextWake(missing, loc), utc(time.March, 29, 2, 0); !got.Equal(want) {
+		t.Errorf("nextWake(02:30 on the spring night) = %s, want %s", got, want)
+	}
+}
+
+func TestSampleMemory(t *testing.T) {
+	sampler := sampleMemory()
+	buf := make([]byte, 32<<20)
+	buf[len(buf)-1] = 1
+	time.Sleep(2 * memoryInterval)
+	buf = nil
+
+	m := sampler.finish()
+	if m.Heap == 0 || m.PeakHeap < m.Heap {
+		t.Errorf("heap %d, peak %d", m.Heap, m.PeakHeap)
+	}
+	if m.PeakHeap < 32<<20 {
+		t.Errorf("peak heap %d misses the 32 MB allocation", m.PeakHeap)
+	}
+	if m.RSS > m.PeakRSS {
+		t.Errorf("RSS %d above its peak %d", m.RSS, m.PeakRSS)
+	}
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveljanda/calvin/internal/config"
+)
+
+// RunDir renders every *.yaml config in dir, one after another. Each config
+// runs in its own working directory named after the file (alice.yaml uses
+// alice/), so relative paths such as the default credentials.json,
+// token.json, state and output stay separate per household. Runs never set
+// the alarm or shut down; a failing config doesn't stop the others.
+func RunDir(ctx context.Context, dir string, opts ...Option) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no *.yaml configs in %s", dir)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+
+	opts = append(opts, WithDryRun())
+	var failed []string
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		log.Printf("Rendering config %s", name)
+		if err := runConfig(ctx, path, filepath.Join(dir, name), opts); err != nil {
+			log.Printf("Config %s failed: %v", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d configs failed: %s", len(failed), len(paths), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runConfig(ctx context.Context, path, workDir string, opts []Option) error {
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return fmt.Errorf("unable to create working directory: %w", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		return fmt.Errorf("unable to enter working directory: %w", err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("%s: warning: %s", path, warning)
+	}
+	if err := ConfigureHTTP(cfg); err != nil {
+		return err
+	}
+	return Run(ctx, cfg, opts...)
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+// fetchComparison fetches today's forecast for every weather.compare
+// location concurrently. Locations that fail are logged and left out.
+func fetchComparison(ctx context.Context, cfg *config.Config) []render.LocationForecast {
+	forecasts := make([]*weather.Forecast, len(cfg.Weather.Compare))
+
+	var wg sync.WaitGroup
+	for i, c := range cfg.Weather.Compare {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			q := weather.Query{
+				Latitude:     c.Latitude,
+				Longitude:    c.Longitude,
+				Timezone:     cfg.Weather.Timezone,
+				ForecastDays: 1,
+				Units:        weather.Units(cfg.Weather.Units),
+			}
+			if c.Location != "" {
+				place, err := weather.ResolveLocation(ctx, c.Location, cfg.Weather.GeocodeCacheFile)
+				if err != nil {
+					log.Printf("Warning: Failed to resolve %s: %v", c.Label, err)
+					return
+				}
+				q.Latitude, q.Longitude = place.Latitude, place.Longitude
+			}
+
+			f, err := weather.Fetch(ctx, q)
+			if err != nil {
+				log.Printf("Warning: Failed to fetch weather for %s: %v", c.Label, err)
+				return
+			}
+			forecasts[i] = f
+		}()
+	}
+	wg.Wait()
+
+	result := make([]render.LocationForecast, 0, len(forecasts))
+	for i, f := range forecasts {
+		if f != nil {
+			result = append(result, render.LocationForecast{Label: cfg.Weather.Compare[i].Label, Forecast: f})
+		}
+	}
+	return result
+}
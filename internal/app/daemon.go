@@ -0,0 +1,225 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/gpio"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/server"
+)
+
+// Daemon keeps Calvin running: it renders every refresh interval, when a
+// refresh is requested over HTTP or when a GPIO button is pressed, and never
+// sets alarms or shuts down.
+func Daemon(ctx context.Context, cfg *config.Config, opts ...Option) error {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.daemon = true
+
+	waitForNetwork(ctx, cfg)
+	if err := resolveLocation(ctx, cfg); err != nil {
+		return err
+	}
+
+	refreshToken, err := cfg.Server.RefreshTokenValue()
+	if err != nil {
+		return fmt.Errorf("unable to read refresh token: %w", err)
+	}
+
+	refreshCh := make(chan struct{}, 1)
+	requestRefresh := func() {
+		select {
+		case refreshCh <- struct{}{}:
+		default:
+			// A refresh is already pending.
+		}
+	}
+
+	var trmnl *server.TRMNL
+	if cfg.Server.TRMNL.Enabled {
+		accessToken, err := cfg.Server.TRMNL.AccessTokenValue()
+		if err != nil {
+			return fmt.Errorf("unable to read TRMNL access token: %w", err)
+		}
+		trmnl = &server.TRMNL{
+			AccessToken: accessToken,
+			Width:       cfg.Server.TRMNL.Width,
+			Height:      cfg.Server.TRMNL.Height,
+			RefreshRate: cfg.Server.RefreshInterval(),
+		}
+	}
+
+	access, err := serverAccess(cfg)
+	if err != nil {
+		return err
+	}
+	if trmnl != nil && trmnl.AccessToken == "" && (access.Token != "" || access.Username != "") {
+		return fmt.Errorf("server.trmnl.access_token is required with server.auth, or any device could fetch the image")
+	}
+
+	srvCfg := server.Config{
+		Addr:         cfg.Server.Listen,
+		OutputPath:   cfg.Output.Path,
+		RefreshToken: refreshToken,
+		Refresh:      requestRefresh,
+		TRMNL:        trmnl,
+		Access:       access,
+	}
+	if cfg.Server.RenderOnDemand {
+		srvCfg.Render = onDemandRenderer(cfg, o)
+		srvCfg.RenderCacheTTL = cfg.Server.RenderCacheTTL()
+		srvCfg.RenderConcurrency = cfg.Server.RenderConcurrency
+		srvCfg.RenderQueue = cfg.Server.RenderQueue
+		srvCfg.RenderWait = cfg.Server.RenderWait()
+	}
+	if cfg.Server.QuickAddCalendar != "" {
+		srvCfg.QuickAdd = quickAdder(cfg)
+	}
+	srv := server.New(srvCfg)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe(ctx)
+	}()
+
+	actionCh := make(chan string, 1)
+	if cfg.GPIO.Enabled {
+		go watchButtons(ctx, cfg.GPIO, actionCh)
+	}
+
+	ticker := time.NewTicker(cfg.Server.RefreshInterval())
+	defer ticker.Stop()
+
+	views := cfg.Display.Views
+	viewIdx := 0
+
+	for {
+		if input := renderOnce(ctx, cfg, o, views[viewIdx]); input != nil {
+			srv.SetMonth(render.PrepareMonthData(*input))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-serveErr:
+			return fmt.Errorf("HTTP server failed: %w", err)
+		case <-ticker.C:
+		case <-refreshCh:
+			ticker.Reset(cfg.Server.RefreshInterval())
+		case action := <-actionCh:
+			switch action {
+			case config.ActionNextView:
+				viewIdx = (viewIdx + 1) % len(views)
+			case config.ActionPreviousView:
+				viewIdx = (viewIdx + len(views) - 1) % len(views)
+			}
+			log.Printf("Button: %s, showing %s view", action, views[viewIdx])
+			ticker.Reset(cfg.Server.RefreshInterval())
+		}
+	}
+}
+
+// serverAccess resolves the server credentials and certificate,
+// generating a self-signed one in the state directory when TLS is enabled
+// without cert_file and key_file.
+func serverAccess(cfg *config.Config) (*server.Access, error) {
+	token, err := cfg.Server.Auth.TokenValue()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read server auth token: %w", err)
+	}
+	password, err := cfg.Server.Auth.PasswordValue()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read server auth password: %w", err)
+	}
+	if cfg.Server.Auth.Username != "" && password == "" {
+		return nil, fmt.Errorf("server.auth.username requires a password")
+	}
+	access := &server.Access{
+		Token:    token,
+		Username: cfg.Server.Auth.Username,
+		Password: password,
+	}
+
+	tlsCfg := cfg.Server.TLS
+	if !tlsCfg.Enabled {
+		return access, nil
+	}
+	access.CertFile, access.KeyFile = tlsCfg.CertFile, tlsCfg.KeyFile
+	if access.CertFile == "" {
+		if err := os.MkdirAll(cfg.State.Dir, 0o755); err != nil {
+			return nil, err
+		}
+		access.CertFile = filepath.Join(cfg.State.Dir, "tls-cert.pem")
+		access.KeyFile = filepath.Join(cfg.State.Dir, "tls-key.pem")
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name, name+".local")
+		}
+		fp, err := server.EnsureSelfSignedCert(access.CertFile, access.KeyFile, hosts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create self-signed certificate: %w", err)
+		}
+		log.Printf("Using self-signed certificate %s (SHA-256 %s)", access.CertFile, fp)
+	}
+	return access, nil
+}
+
+func watchButtons(ctx context.Context, cfg config.GPIOConfig, actions chan<- string) {
+	buttons := make([]gpio.Button, 0, len(cfg.Buttons))
+	for _, b := range cfg.Buttons {
+		buttons = append(buttons, gpio.Button{Pin: b.Pin, ActiveLow: b.ActiveLow})
+	}
+
+	err := gpio.Watch(ctx, buttons, cfg.Debounce(), func(index int) {
+		select {
+		case actions <- cfg.Buttons[index].Action:
+		default:
+			// Still busy with the previous press.
+		}
+	})
+	if err != nil {
+		log.Printf("Warning: GPIO buttons disabled: %v", err)
+	}
+}
+
+// renderOnce renders view and returns the input of a successful render.
+func renderOnce(ctx context.Context, cfg *config.Config, o options, view string) *render.MonthInput {
+	lk, err := acquireLock(ctx, cfg)
+	if err != nil {
+		log.Printf("Skipping render: %v", err)
+		return nil
+	}
+	defer lk.Release()
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.MaxRunDuration())
+	defer cancel()
+
+	// A panic shows on the display like any failed render and the daemon
+	// keeps going.
+	var result runResult
+	err = func() (err error) {
+		defer recoverPanic(&err)
+		result, err = renderAndRecord(runCtx, cfg, o, view)
+		return err
+	}()
+	if err == nil {
+		return result.input
+	}
+
+	log.Printf("Error: %v", err)
+	if runCtx.Err() == context.DeadlineExceeded {
+		log.Printf("Warning: run exceeded %s budget, keeping previous image", cfg.MaxRunDuration())
+		return nil
+	}
+	if o.errorRenderer != nil {
+		o.errorRenderer(cfg, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/state"
+)
+
+// Diff writes an image highlighting the pixels that changed between the
+// last two successful renders, or between the two images in paths, next to
+// the output image and prints a summary.
+func Diff(cfg *config.Config, paths []string) error {
+	var prevPath, nextPath string
+	switch len(paths) {
+	case 0:
+		store, err := state.Open(cfg.State.Dir)
+		if err != nil {
+			return err
+		}
+		prevPath, nextPath = store.PreviousPath(), store.LastGoodPath()
+		if _, err := os.Stat(prevPath); err != nil {
+			return fmt.Errorf("no previous render to compare with yet; run twice first")
+		}
+	case 2:
+		prevPath, nextPath = paths[0], paths[1]
+	default:
+		return fmt.Errorf("usage: calvin diff [old.png new.png]")
+	}
+
+	prev, err := decodePNG(prevPath)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", prevPath, err)
+	}
+	next, err := decodePNG(nextPath)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", nextPath, err)
+	}
+
+	result, err := render.Diff(prev, next)
+	if err != nil {
+		return err
+	}
+
+	out := strings.TrimSuffix(cfg.Output.Path, filepath.Ext(cfg.Output.Path)) + "-diff.png"
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, result.Image)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write diff image: %w", err)
+	}
+
+	if result.Changed == 0 {
+		fmt.Printf("No pixels changed between %s and %s\n", prevPath, nextPath)
+	} else {
+		total := result.Image.Bounds().Dx() * result.Image.Bounds().Dy()
+		a := result.Area
+		fmt.Printf("%d pixels changed (%.2f%%) within %dx%d at %d,%d\n",
+			result.Changed, float64(result.Changed)*100/float64(total), a.Dx(), a.Dy(), a.Min.X, a.Min.Y)
+	}
+	fmt.Printf("Diff image: %s\n", out)
+	return nil
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/battery"
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/it8951"
+	"github.com/paveljanda/calvin/internal/kindle"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+// doctorTimeout bounds each check that talks to hardware or the network.
+const doctorTimeout = 30 * time.Second
+
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context, cfg *config.Config) (string, error)
+	// enabled skips the check when the feature isn't configured.
+	enabled func(cfg *config.Config) bool
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "Output directory", run: checkWritable(func(cfg *config.Config) string { return filepath.Dir(cfg.Output.Path) })},
+	{name: "State directory", run: checkWritable(func(cfg *config.Config) string { return cfg.State.Dir })},
+	{name: "Renderer", run: checkRenderer},
+	{name: "Google Calendar", run: checkGoogleCalendar, enabled: func(cfg *config.Config) bool { return cfg.Calendar.HasGoogleSources() }},
+	{name: "Weather API", run: checkWeather},
+	{name: "PiSugar battery", run: checkBattery},
+	{name: "Power operations", run: checkPowerMethod},
+	{name: "IT8951 panel", run: checkIT8951, enabled: func(cfg *config.Config) bool { return cfg.Display.IT8951.Enabled }},
+	{name: "Kindle", run: checkKindle, enabled: func(cfg *config.Config) bool { return cfg.Output.Kindle.Enabled }},
+}
+
+// Doctor checks the hardware and every configured integration, prints a
+// pass/fail table and, with show, renders it to the display. It returns an
+// error when any check failed.
+func Doctor(ctx context.Context, cfg *config.Config, show bool) error {
+	var rows []render.ReportRow
+	for _, check := range doctorChecks {
+		if check.enabled != nil && !check.enabled(cfg) {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+		detail, err := check.run(checkCtx, cfg)
+		cancel()
+		if err != nil {
+			detail = err.Error()
+		}
+		rows = append(rows, render.ReportRow{Name: check.name, OK: err == nil, Detail: detail})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	failed := 0
+	for _, row := range rows {
+		result := "pass"
+		if !row.OK {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", row.Name, result, row.Detail)
+	}
+	w.Flush()
+
+	if show {
+		if err := render.RenderReportToPNG(cfg.Display.Width, cfg.Display.Height, "Calvin Doctor", rows, cfg.Output.Path); err != nil {
+			return fmt.Errorf("unable to render report: %w", err)
+		}
+		if err := showOnPanel(cfg, cfg.Output.Path, true); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := publishKindle(ctx, cfg, cfg.Output.Path); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(rows))
+	}
+	return nil
+}
+
+func checkWritable(dir func(cfg *config.Config) string) func(context.Context, *config.Config) (string, error) {
+	return func(_ context.Context, cfg *config.Config) (string, error) {
+		path := dir(cfg)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return "", err
+		}
+		f, err := os.CreateTemp(path, ".calvin-doctor-*")
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+		os.Remove(f.Name())
+		return path + " is writable", nil
+	}
+}
+
+// checkRenderer renders the first view with no data to a temporary file,
+// which exercises the fonts and layout without touching the output.
+func checkRenderer(_ context.Context, cfg *config.Config) (string, error) {
+	f, err := os.CreateTemp("", "calvin-doctor-*.png")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	scratch := *cfg
+	scratch.Output = config.OutputConfig{Path: f.Name()}
+	start := time.Now()
+	err = generatePNG(&scratch, cfg.Display.Views[0], newTimings(), runMetadata{}, render.MonthInput{
+		Width:           cfg.Display.Width,
+		Height:          cfg.Display.Height,
+		MaxEventsPerDay: maxEventsPerDay(cfg),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s view rendered at %dx%d in %s", cfg.Display.Views[0], cfg.Display.Width, cfg.Display.Height, time.Since(start).Round(time.Millisecond)), nil
+}
+
+func checkGoogleCalendar(ctx context.Context, cfg *config.Config) (string, error) {
+	credentials, err := cfg.Calendar.CredentialsJSON()
+	if err != nil {
+		return "", fmt.Errorf("unable to read credentials: %w", err)
+	}
+	if err := calendar.CheckToken(ctx, credentials, cfg.Calendar.TokenFile); err != nil {
+		return "", err
+	}
+
+	client, err := calendar.NewClient(ctx, credentials, cfg.Calendar.TokenFile, cfg.Weather.Timezone, calendar.Scope(cfg.Calendar.WriteAccess))
+	if err != nil {
+		return "", err
+	}
+	calendars, err := client.ListCalendars(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("token valid, %d calendars visible", len(calendars)), nil
+}
+
+func checkWeather(ctx context.Context, cfg *config.Config) (string, error) {
+	if err := resolveLocation(ctx, cfg); err != nil {
+		return "", err
+	}
+	forecast, err := weather.Fetch(ctx, weather.Query{
+		Latitude:     cfg.Weather.Latitude,
+		Longitude:    cfg.Weather.Longitude,
+		Timezone:     cfg.Weather.Timezone,
+		ForecastDays: 1,
+		Units:        weather.Units(cfg.Weather.Units),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("forecast for %.4f, %.4f (%s), %d days", cfg.Weather.Latitude, cfg.Weather.Longitude, cfg.Weather.Timezone, len(forecast.Daily)), nil
+}
+
+func checkBattery(ctx context.Context, _ *config.Config) (string, error) {
+	percent, err := battery.GetBatteryPercentage(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "battery at " + percent, nil
+}
+
+func checkPowerMethod(ctx context.Context, cfg *config.Config) (string, error) {
+	if err := powerController(cfg).Check(ctx); err != nil {
+		return "", err
+	}
+	return cfg.Power.Method + " can set the alarm and shut down", nil
+}
+
+func checkIT8951(_ context.Context, cfg *config.Config) (string, error) {
+	display, err := it8951.Open(it8951Config(cfg.Display.IT8951))
+	if err != nil {
+		return "", err
+	}
+	w, h := display.Size()
+	if err := display.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("controller responds, panel %dx%d", w, h), nil
+}
+
+func checkKindle(ctx context.Context, cfg *config.Config) (string, error) {
+	if err := kindle.Check(ctx, kindleConfig(cfg)); err != nil {
+		return "", err
+	}
+	return "reachable over SSH at " + cfg.Output.Kindle.Host, nil
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/version"
+)
+
+// RenderErrorPNG draws err with debugging details, and the stack of a
+// panic, to the configured output path so a failed run is visible on the
+// display.
+func RenderErrorPNG(cfg *config.Config, err error) {
+	// Drawing may panic for the same reason the run did, e.g. a broken
+	// font, which must not hide the original error.
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Failed to render error to PNG: %v", p)
+		}
+	}()
+
+	errorDetails := map[string]string{
+		"Error":      err.Error(),
+		"Version":    version.String(),
+		"Time":       time.Now().Format("2006-01-02 15:04:05 MST"),
+		"Args":       fmt.Sprintf("%v", os.Args),
+		"Go Version": runtime.Version(),
+		"OS/Arch":    fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+
+	var stack string
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		stack = panickingFrames(panicErr.Stack)
+	}
+
+	if renderErr := render.RenderErrorToPNG(cfg.Display.Width, cfg.Display.Height, err.Error(), errorDetails, stack, cfg.Output.Path); renderErr != nil {
+		log.Printf("Failed to render error to PNG: %v", renderErr)
+	} else {
+		log.Printf("Error details rendered to: %s", cfg.Output.Path)
+		if panelErr := showOnPanel(cfg, cfg.Output.Path, true); panelErr != nil {
+			log.Printf("Failed to show error on panel: %v", panelErr)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), kindleTimeout)
+		defer cancel()
+		if kindleErr := publishKindle(ctx, cfg, cfg.Output.Path); kindleErr != nil {
+			log.Printf("Failed to show error on Kindle: %v", kindleErr)
+		}
+	}
+}
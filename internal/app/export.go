@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// Export writes the events of the days view shows to path as iCalendar:
+// merged from every source, filtered and redacted as on the display. Like
+// template-data --live it leaves the output and the state directory alone.
+func Export(ctx context.Context, cfg *config.Config, path, view string) error {
+	if view == "" {
+		view = cfg.Display.Views[0]
+	}
+	if !config.ValidView(view) {
+		return fmt.Errorf("invalid view %q: must be month, agenda, board, details or rolling", view)
+	}
+
+	o := defaultOptions()
+	o.dryRun = true
+	o.noBattery = true
+
+	input, err := liveInput(ctx, cfg, o, view)
+	if err != nil {
+		return err
+	}
+	first, last := render.PrepareData(view, input).DateRange()
+	if first == "" {
+		return fmt.Errorf("the %s view shows no days", view)
+	}
+	loc := input.Now.Location()
+	start, _ := time.ParseInLocation("2006-01-02", first, loc)
+	end, _ := time.ParseInLocation("2006-01-02", last, loc)
+	end = end.AddDate(0, 0, 1)
+
+	var events []calendar.Event
+	for _, ev := range input.Events {
+		if ev.Start.Before(end) && ev.End.After(start) {
+			events = append(events, calendar.RedactEvent(ev, input.Redactions))
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("Calvin %s to %s", first, last)
+	if err := calendar.WriteICS(f, name, events, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("Exported %d events from %s to %s to %s", len(events), first, last, path)
+	return nil
+}
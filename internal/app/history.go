@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/state"
+	"github.com/paveljanda/calvin/internal/version"
+)
+
+// renderAndRecord generates the image and records the outcome in the state
+// directory. State failures are logged but never fail the run.
+func renderAndRecord(ctx context.Context, cfg *config.Config, o options, view string) (runResult, error) {
+	startedAt := time.Now()
+	memory := sampleMemory()
+	result, err := generate(ctx, cfg, o, view)
+
+	summary := state.RunSummary{
+		StartedAt: startedAt,
+		Version:   version.String(),
+		Duration:  time.Since(startedAt),
+		View:      view,
+		Success:   err == nil,
+		Events:    result.events,
+		Changes:   result.changes,
+		Battery:   result.battery,
+
+		NextEvent:    result.nextEvent,
+		NextReminder: result.nextReminder,
+
+		Memory: memory.finish(),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	if recordErr := recordRun(cfg, summary, result.apiErrors); recordErr != nil {
+		log.Printf("Warning: Failed to record run state: %v", recordErr)
+	}
+
+	return result, err
+}
+
+func recordRun(cfg *config.Config, summary state.RunSummary, apiErrors []string) error {
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		return err
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	if summary.Success {
+		hash, err := state.HashFile(cfg.Output.Path)
+		if err != nil {
+			return err
+		}
+		summary.OutputHash = hash
+		summary.Changed = hash != st.LastRenderHash
+		st.LastRenderHash = hash
+		st.LastSuccessAt = summary.StartedAt
+
+		if err := store.SaveLastGood(cfg.Output.Path); err != nil {
+			return err
+		}
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(summary.Battery, "%"), 64)
+	hasBattery := err == nil
+
+	if cfg.State.Stats {
+		if err := recordStats(store, st, summary, apiErrors, percent, hasBattery); err != nil {
+			log.Printf("Warning: Failed to record usage statistics: %v", err)
+		}
+	}
+
+	if hasBattery {
+		st.AddBatterySample(state.BatterySample{Time: summary.StartedAt, Percent: percent})
+	}
+
+	st.AddRun(summary)
+
+	return store.Save(st)
+}
+
+// recordStats adds the run to this month's usage statistics. Battery drain
+// is measured against the previous sample in st, so it must run before the
+// new sample is added.
+func recordStats(store *state.Store, st *state.State, summary state.RunSummary, apiErrors []string, percent float64, hasBattery bool) error {
+	stats, err := store.LoadStats()
+	if err != nil {
+		return err
+	}
+
+	month := stats.Month(summary.StartedAt)
+	month.Runs++
+	if summary.Success {
+		month.Successes++
+	}
+	if n := len(st.BatteryHistory); hasBattery && n > 0 {
+		if drain := st.BatteryHistory[n-1].Percent - percent; drain >= 0 {
+			month.BatteryDrain += drain
+			month.DrainSamples++
+		}
+	}
+	for _, name := range apiErrors {
+		if month.APIErrors == nil {
+			month.APIErrors = make(map[string]int)
+		}
+		month.APIErrors[name]++
+	}
+
+	return store.SaveStats(stats)
+}
+
+// monthUptime returns this month's uptime for the header, or nil when
+// statistics are off or there are no runs yet.
+func monthUptime(cfg *config.Config, now time.Time) *float64 {
+	if !cfg.State.Stats {
+		return nil
+	}
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		return nil
+	}
+	stats, err := store.LoadStats()
+	if err != nil {
+		log.Printf("Warning: Failed to load usage statistics: %v", err)
+		return nil
+	}
+	month, ok := stats.Months[now.Format("2006-01")]
+	if !ok || month.Runs == 0 {
+		return nil
+	}
+	uptime := month.Uptime()
+	return &uptime
+}
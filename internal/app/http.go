@@ -0,0 +1,24 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+// ConfigureHTTP applies the http settings to the client shared by all
+// fetchers. Call it after loading a config and before fetching anything.
+func ConfigureHTTP(cfg *config.Config) error {
+	err := httpclient.Configure(httpclient.Settings{
+		Proxy:     cfg.HTTP.Proxy,
+		CAFile:    cfg.HTTP.CAFile,
+		Timeout:   time.Duration(cfg.HTTP.TimeoutSeconds) * time.Second,
+		UserAgent: cfg.HTTP.UserAgent,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid http settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// loadImages decodes display.images. An unreadable image is left out with a
+// warning rather than failing the render.
+func loadImages(cfg *config.Config) []render.ImageData {
+	result := make([]render.ImageData, 0, len(cfg.Display.Images))
+	for _, c := range cfg.Display.Images {
+		img, err := decodeImage(c.Path)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		result = append(result, render.ImageData{
+			Image:  img,
+			Corner: c.Corner,
+			Width:  c.Width,
+			Height: c.Height,
+			Margin: c.Margin,
+		})
+	}
+	return result
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open image: %w", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image %s: %w", path, err)
+	}
+	return img, nil
+}
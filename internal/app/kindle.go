@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/kindle"
+)
+
+// kindleTimeout bounds publishing the error screen, which runs after the
+// run budget may already be spent.
+const kindleTimeout = 30 * time.Second
+
+// publishKindle copies the image at path to the Kindle, if configured.
+func publishKindle(ctx context.Context, cfg *config.Config, path string) error {
+	k := cfg.Output.Kindle
+	if !k.Enabled {
+		return nil
+	}
+
+	err := kindle.Publish(ctx, kindleConfig(cfg), path)
+	if err != nil {
+		return fmt.Errorf("unable to publish to Kindle: %w", err)
+	}
+	log.Printf("Published to Kindle at %s", k.Host)
+	return nil
+}
+
+func kindleConfig(cfg *config.Config) kindle.Config {
+	k := cfg.Output.Kindle
+	return kindle.Config{
+		Host:         k.Host,
+		User:         k.User,
+		Port:         k.Port,
+		IdentityFile: k.IdentityFile,
+		Path:         k.Path,
+		Width:        k.Width,
+		Height:       k.Height,
+		Dither:       k.Dither == "floyd-steinberg",
+		Command:      k.Command,
+	}
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+// resolveLocation fills in coordinates from weather.location and, unless
+// configured, the timezone. Without a timezone every event time would be
+// shifted, so it is taken from the geocoded place, the system or the
+// coordinates, in that order, before falling back to UTC.
+func resolveLocation(ctx context.Context, cfg *config.Config) error {
+	if cfg.Weather.Location != "" {
+		place, err := weather.ResolveLocation(ctx, cfg.Weather.Location, cfg.Weather.GeocodeCacheFile)
+		if err != nil {
+			return fmt.Errorf("unable to resolve weather.location %q: %w", cfg.Weather.Location, err)
+		}
+
+		cfg.Weather.Latitude = place.Latitude
+		cfg.Weather.Longitude = place.Longitude
+		if cfg.Weather.Timezone == "" && place.Timezone != "" {
+			cfg.Weather.Timezone = place.Timezone
+			log.Printf("Timezone: %s (from weather.location)", place.Timezone)
+		}
+
+		log.Printf("Location: %s (%.4f, %.4f)", place, place.Latitude, place.Longitude)
+	}
+
+	if cfg.Weather.Timezone != "" {
+		return nil
+	}
+
+	if tz := systemTimezone(); tz != "" {
+		cfg.Weather.Timezone = tz
+		log.Printf("Timezone: %s (from system)", tz)
+		return nil
+	}
+
+	tz, err := weather.LookupTimezone(ctx, cfg.Weather.Latitude, cfg.Weather.Longitude, cfg.Weather.GeocodeCacheFile)
+	if err != nil && tz == "" {
+		log.Printf("Warning: unable to detect timezone, using UTC (set weather.timezone): %v", err)
+		cfg.Weather.Timezone = "UTC"
+		return nil
+	}
+	cfg.Weather.Timezone = tz
+	log.Printf("Timezone: %s (from coordinates)", tz)
+
+	return nil
+}
+
+// systemTimezone returns the system's IANA timezone name, or "" when it
+// can't be determined or is UTC, which on a fresh Pi usually just means it
+// was never configured.
+func systemTimezone() string {
+	candidates := []string{os.Getenv("TZ")}
+
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(data)))
+	}
+
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			candidates = append(candidates, name)
+		}
+	}
+
+	for _, name := range candidates {
+		name = strings.TrimPrefix(name, ":")
+		if name == "" || name == "UTC" || name == "Etc/UTC" {
+			continue
+		}
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
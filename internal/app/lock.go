@@ -0,0 +1,17 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/lock"
+)
+
+const lockFile = "calvin.lock"
+
+// acquireLock keeps a run from overlapping with a previous one that still
+// renders or is about to shut down. The daemon takes it per render.
+func acquireLock(ctx context.Context, cfg *config.Config) (*lock.Lock, error) {
+	return lock.Acquire(ctx, filepath.Join(cfg.State.Dir, lockFile), cfg.Lock.Policy, cfg.Lock.StaleAfter())
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/state"
+)
+
+// memoryInterval is how often a run's heap is sampled for its peak.
+const memoryInterval = 200 * time.Millisecond
+
+// heapMetric is the heap in use, what MemStats calls HeapAlloc. Unlike
+// ReadMemStats, reading it doesn't stop the world.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// memorySampler tracks the peak heap of a run, which the runtime doesn't
+// report itself.
+type memorySampler struct {
+	stop chan struct{}
+	done chan struct{}
+	peak uint64
+}
+
+// sampleMemory starts sampling the heap until finish.
+func sampleMemory() *memorySampler {
+	s := &memorySampler{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(memoryInterval)
+		defer ticker.Stop()
+		for {
+			s.peak = max(s.peak, heapInUse())
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// finish stops sampling and returns the run's memory usage.
+func (s *memorySampler) finish() state.MemoryUsage {
+	close(s.stop)
+	<-s.done
+
+	heap := heapInUse()
+	usage := state.MemoryUsage{Heap: heap, PeakHeap: max(s.peak, heap)}
+	usage.RSS, usage.PeakRSS = residentSet()
+	return usage
+}
+
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// residentSet returns the process's resident set size and its peak from
+// /proc, zero where there is none. The peak covers the whole process, so
+// in daemon mode it is the highest since start.
+func residentSet() (rss, peak uint64) {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+		// Values read like "  51236 kB".
+		kb, err := strconv.ParseUint(string(bytes.TrimSuffix(bytes.TrimSpace(value), []byte(" kB"))), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch string(key) {
+		case "VmRSS":
+			rss = kb * 1024
+		case "VmHWM":
+			peak = kb * 1024
+		}
+	}
+	return rss, peak
+}
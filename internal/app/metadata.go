@@ -0,0 +1,36 @@
+package app
+
+import (
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/version"
+)
+
+// runMetadata is run information embedded in the output PNG, so tools and
+// the HTTP server can read it without a side file.
+type runMetadata struct {
+	// nextRefresh is when the next render is expected; zero omits it.
+	nextRefresh time.Time
+	// battery is the measured level; empty omits it.
+	battery string
+}
+
+// text returns the PNG tEXt chunks for an image rendered at now.
+func (m runMetadata) text(cfg *config.Config, now time.Time) map[string]string {
+	text := map[string]string{
+		render.MetaCreated:  now.Format(time.RFC3339),
+		render.MetaSoftware: "Calvin " + version.String(),
+	}
+	if !m.nextRefresh.IsZero() {
+		text[render.MetaNextRefresh] = m.nextRefresh.Format(time.RFC3339)
+	}
+	if m.battery != "" {
+		text[render.MetaBattery] = m.battery
+	}
+	if h := cfg.Display.StaleAfterHours; h > 0 {
+		text[render.MetaExpires] = now.Add(time.Duration(h) * time.Hour).Format(time.RFC3339)
+	}
+	return text
+}
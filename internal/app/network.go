@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+)
+
+// networkPollInterval is how often waitForNetwork retries.
+const networkPollInterval = time.Second
+
+// waitForNetwork gives Wi-Fi time to come up after a wake by retrying a TCP
+// connection to network.check_host for up to network.wait_seconds. When the
+// network doesn't come up the run continues anyway and falls back to
+// cached data.
+func waitForNetwork(ctx context.Context, cfg *config.Config) {
+	wait := cfg.Network.Wait()
+	if wait <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	start := time.Now()
+	var d net.Dialer
+	for {
+		dialCtx, dialCancel := context.WithTimeout(ctx, 3*time.Second)
+		conn, err := d.DialContext(dialCtx, "tcp", cfg.Network.CheckHost)
+		dialCancel()
+		if err == nil {
+			conn.Close()
+			if waited := time.Since(start); waited > networkPollInterval {
+				log.Printf("Network up after %s", waited.Round(100*time.Millisecond))
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Warning: network not reachable after %s, continuing with cached data: %v", wait, err)
+			return
+		case <-time.After(networkPollInterval):
+		}
+	}
+}
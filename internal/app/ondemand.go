@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/server"
+)
+
+// renderScratch renders view into a temporary directory and returns the
+// PNG. The output image, the panel, the Kindle and the state directory are
+// left alone, so it can run next to the scheduled renders.
+func renderScratch(ctx context.Context, cfg *config.Config, o options, view string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "calvin-scratch-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	scratch := *cfg
+	scratch.Output = config.OutputConfig{Path: dir + "/calendar.png"}
+	scratch.State.Dir = dir
+	scratch.Display.IT8951.Enabled = false
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.MaxRunDuration())
+	defer cancel()
+	if err := resolveLocation(runCtx, &scratch); err != nil {
+		return nil, err
+	}
+	if _, err := generate(runCtx, &scratch, o, view); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(scratch.Output.Path)
+}
+
+// onDemandRenderer serves GET /render: it applies the request's overrides
+// to a copy of cfg and renders it.
+func onDemandRenderer(cfg *config.Config, o options) server.RenderFunc {
+	return func(ctx context.Context, req server.RenderRequest) ([]byte, error) {
+		c := *cfg
+		view := c.Display.Views[0]
+		if req.View != "" {
+			if !config.ValidView(req.View) {
+				return nil, fmt.Errorf("%w: invalid view %q: must be month, agenda, board, details or rolling", server.ErrBadRequest, req.View)
+			}
+			view = req.View
+		}
+		if req.Width > 0 {
+			c.Display.Width = req.Width
+		}
+		if req.Height > 0 {
+			c.Display.Height = req.Height
+		}
+		if len(req.Calendars) > 0 {
+			for _, name := range req.Calendars {
+				known := slices.ContainsFunc(cfg.Calendar.Calendars, func(src config.CalendarSource) bool {
+					return src.DisplayName() == name
+				})
+				if !known {
+					return nil, fmt.Errorf("%w: unknown calendar %q", server.ErrBadRequest, name)
+				}
+			}
+			c.Calendar.Calendars = slices.DeleteFunc(slices.Clone(cfg.Calendar.Calendars), func(src config.CalendarSource) bool {
+				return !slices.Contains(req.Calendars, src.DisplayName())
+			})
+		}
+		return renderScratch(ctx, &c, o, view)
+	}
+}
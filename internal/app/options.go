@@ -0,0 +1,46 @@
+package app
+
+import "github.com/paveljanda/calvin/internal/config"
+
+// ErrorRenderer presents a failed run, typically by drawing it to the display.
+type ErrorRenderer func(cfg *config.Config, err error)
+
+// Option customizes a Run.
+type Option func(*options)
+
+type options struct {
+	dryRun        bool
+	noBattery     bool
+	errorRenderer ErrorRenderer
+	// daemon is set by Daemon, which renders on its refresh interval
+	// instead of waking by alarm.
+	daemon bool
+}
+
+func defaultOptions() options {
+	return options{
+		errorRenderer: RenderErrorPNG,
+	}
+}
+
+// WithDryRun skips the PiSugar alarm and system shutdown after rendering.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithoutBattery skips reading the PiSugar battery level and shows 100%.
+func WithoutBattery() Option {
+	return func(o *options) {
+		o.noBattery = true
+	}
+}
+
+// WithErrorRenderer replaces the default error PNG renderer. A nil renderer
+// disables error rendering.
+func WithErrorRenderer(r ErrorRenderer) Option {
+	return func(o *options) {
+		o.errorRenderer = r
+	}
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/it8951"
+	"github.com/paveljanda/calvin/internal/state"
+)
+
+// panelFile keeps a copy of what the panel currently shows, to find the
+// changed area for partial updates.
+const panelFile = "panel.png"
+
+var it8951Modes = map[string]it8951.Mode{
+	"gc16": it8951.ModeGC16,
+	"gl16": it8951.ModeGL16,
+	"du":   it8951.ModeDU,
+	"a2":   it8951.ModeA2,
+}
+
+// showOnPanel pushes the image at path to the IT8951 panel, if configured.
+// With partial updates only the area that differs from the panel's current
+// content is refreshed; full forces a whole-panel refresh.
+func showOnPanel(cfg *config.Config, path string, full bool) error {
+	pc := cfg.Display.IT8951
+	if !pc.Enabled {
+		return nil
+	}
+
+	img, err := decodePNG(path)
+	if err != nil {
+		return err
+	}
+
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		return err
+	}
+
+	var area image.Rectangle
+	mode := it8951Modes[pc.Mode]
+	if pc.Partial && !full {
+		if prev, err := decodePNG(store.Path(panelFile)); err == nil && prev.Bounds().Size() == img.Bounds().Size() {
+			area = it8951.Changed(prev, img)
+			if area.Empty() {
+				log.Println("Panel: unchanged, skipping refresh")
+				return nil
+			}
+			mode = it8951Modes[pc.PartialMode]
+		}
+	}
+
+	display, err := it8951.Open(it8951Config(pc))
+	if err != nil {
+		return fmt.Errorf("unable to open IT8951 panel: %w", err)
+	}
+
+	showErr := display.Show(img, area, mode)
+	if err := display.Close(); err != nil && showErr == nil {
+		showErr = err
+	}
+	if showErr != nil {
+		// The panel's content is unknown now, so make the next update a
+		// full one.
+		os.Remove(store.Path(panelFile))
+		return fmt.Errorf("unable to update IT8951 panel: %w", showErr)
+	}
+
+	if area.Empty() {
+		log.Printf("Panel: full refresh (%s)", pc.Mode)
+	} else {
+		log.Printf("Panel: refreshed %dx%d at %d,%d (%s)", area.Dx(), area.Dy(), area.Min.X, area.Min.Y, pc.PartialMode)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(store.Path(panelFile), data, 0644)
+}
+
+func decodePNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func it8951Config(pc config.IT8951Config) it8951.Config {
+	return it8951.Config{
+		Device:   pc.Device,
+		SpeedHz:  pc.SpeedHz,
+		ReadyPin: pc.ReadyPin,
+		ResetPin: pc.ResetPin,
+		VCOM:     pc.VCOM,
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+)
+
+// PanicError is a panic recovered during a run.
+type PanicError struct {
+	Value any
+	// Stack is the panicking goroutine's stack trace.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// recoverPanic turns a panic of the function deferring it into a
+// *PanicError in *err.
+func recoverPanic(err *error) {
+	if p := recover(); p != nil {
+		stack := debug.Stack()
+		log.Printf("Panic: %v\n%s", p, stack)
+		*err = &PanicError{Value: p, Stack: stack}
+	}
+}
+
+// panickingFrames returns stack from the function that panicked on,
+// without the frames of debug.Stack, the recovery and the runtime's panic
+// machinery, so the culprit comes first on a small display.
+func panickingFrames(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "panic(") {
+			continue
+		}
+		lines = lines[min(i+2, len(lines)):]
+		for len(lines) >= 2 && strings.HasPrefix(lines[0], "runtime.") {
+			lines = lines[2:]
+		}
+		return strings.Join(lines, "\n")
+	}
+	return string(stack)
+}
+
+// runRecovered runs run and recovers a panic anywhere in it, so it is
+// shown on the display and the alarm still gets set.
+func runRecovered(ctx context.Context, cfg *config.Config, o options) (wake time.Time, err error) {
+	defer recoverPanic(&err)
+	return run(ctx, cfg, o)
+}
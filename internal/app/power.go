@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/power"
+)
+
+func powerController(cfg *config.Config) power.Controller {
+	return power.Controller{
+		Method: cfg.Power.Method,
+		Socket: cfg.Power.PiSugarSocket,
+		Helper: cfg.Power.Helper,
+	}
+}
+
+// finishRun sets the PiSugar alarm for wake, or the next hour when zero,
+// and shuts the Pi down. Without an alarm the Pi stays on rather than
+// sleeping for good. Dry runs skip both.
+func finishRun(ctx context.Context, cfg *config.Config, o options, wake time.Time) error {
+	if o.dryRun {
+		log.Println("Dry-run mode: skipping alarm and shutdown")
+		return nil
+	}
+
+	pc := powerController(cfg)
+	if err := handlePiSugar(ctx, cfg, pc, wake); err != nil {
+		return fmt.Errorf("failed to set PiSugar alarm: %w", err)
+	}
+
+	log.Println("Shutting down system...")
+	if err := pc.Shutdown(); err != nil {
+		return shutdownFailed(ctx, cfg, o, pc, wake, err)
+	}
+	return nil
+}
+
+// shutdownRetryDelay is the pause before retrying a failed shutdown.
+const shutdownRetryDelay = 30 * time.Second
+
+// shutdownFailed applies power.on_shutdown_failure after the shutdown
+// failed with err, so a Pi that can't power off doesn't sit there
+// draining the battery.
+func shutdownFailed(ctx context.Context, cfg *config.Config, o options, pc power.Controller, wake time.Time, err error) error {
+	log.Printf("Warning: %v", err)
+	switch cfg.Power.OnShutdownFailure {
+	case config.ShutdownFailureRetry:
+		for i := 1; i <= cfg.Power.ShutdownRetries; i++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(shutdownRetryDelay):
+			}
+			log.Printf("Retrying shutdown (%d/%d)...", i, cfg.Power.ShutdownRetries)
+			if err = pc.Shutdown(); err == nil {
+				return nil
+			}
+			log.Printf("Warning: %v", err)
+		}
+	case config.ShutdownFailureSystemctl:
+		log.Println("Powering off with systemctl...")
+		if poweroffErr := power.PowerOff(); poweroffErr != nil {
+			return errors.Join(err, poweroffErr)
+		}
+		return nil
+	case config.ShutdownFailureDaemon:
+		return stayAwake(ctx, cfg, o, pc, wake)
+	}
+	return err
+}
+
+// stayAwake keeps the frame current while the Pi can't power off: it
+// sleeps until each alarm, renders like a woken run and tries to shut down
+// again, until that works or ctx is cancelled.
+func stayAwake(ctx context.Context, cfg *config.Config, o options, pc power.Controller, wake time.Time) error {
+	for {
+		if now := time.Now(); !wake.After(now) {
+			wake = nextWake(now, location(cfg))
+		}
+		log.Printf("Staying awake, next render at %s", wake.In(location(cfg)).Format("2006-01-02 15:04:05 MST"))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(wake)):
+		}
+
+		var err error
+		wake, err = runRecovered(ctx, cfg, o)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			if o.errorRenderer != nil {
+				o.errorRenderer(cfg, err)
+			}
+		}
+		if err = handlePiSugar(ctx, cfg, pc, wake); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		log.Println("Shutting down system...")
+		if err = pc.Shutdown(); err == nil {
+			return nil
+		}
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// checkPower fails the run before rendering when the alarm or shutdown
+// couldn't be performed afterwards, so the error ends up on the display.
+func checkPower(ctx context.Context, cfg *config.Config, o options) error {
+	if o.dryRun {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, piSugarTimeout)
+	defer cancel()
+	if err := powerController(cfg).Check(ctx); err != nil {
+		return fmt.Errorf("power operations unavailable (power.method %s): %w", cfg.Power.Method, err)
+	}
+	return nil
+}
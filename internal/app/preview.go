@@ -0,0 +1,36 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/termimg"
+)
+
+// Preview renders the first view and prints it to the terminal on out. The
+// image, the panel, the Kindle and the state directory are left alone, so
+// it can run next to the scheduled renders.
+func Preview(ctx context.Context, cfg *config.Config, out *os.File, opts ...Option) error {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := renderScratch(ctx, cfg, o, cfg.Display.Views[0])
+	if err != nil {
+		return err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to decode preview: %w", err)
+	}
+
+	protocol := termimg.Detect()
+	log.Printf("Printing preview (%s)", protocol)
+	return termimg.Print(out, img, protocol, termimg.Columns(out))
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// qrCode encodes the display.qr target. It returns nil when the QR code is
+// disabled or there is nothing to link to, e.g. no upcoming video call.
+func qrCode(cfg *config.Config, events []calendar.Event, now time.Time) *render.QRData {
+	qr := cfg.Display.QR
+	if !qr.Enabled {
+		return nil
+	}
+
+	var content string
+	switch qr.Target {
+	case config.QRTargetCalendar:
+		content = fmt.Sprintf("https://calendar.google.com/calendar/r/month/%d/%d/%d", now.Year(), now.Month(), now.Day())
+	case config.QRTargetNextEvent:
+		content = nextVideoCallLink(events, now)
+	case config.QRTargetURL:
+		content = qr.URL
+	}
+	if content == "" {
+		return nil
+	}
+
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		log.Printf("Warning: unable to encode QR code: %v", err)
+		return nil
+	}
+	code.DisableBorder = true
+
+	return &render.QRData{
+		Modules: code.Bitmap(),
+		Label:   qr.Label,
+		Corner:  qr.Corner,
+		Size:    qr.Size,
+		Margin:  qr.Margin,
+	}
+}
+
+// nextVideoCallLink returns the link of the earliest event with a video
+// call that hasn't ended yet. Private events are skipped.
+func nextVideoCallLink(events []calendar.Event, now time.Time) string {
+	next, ok := calendar.Next(events, now, func(ev calendar.Event) bool {
+		return ev.VideoCallLink != "" && !ev.Private
+	})
+	if !ok {
+		return ""
+	}
+	return next.VideoCallLink
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/server"
+)
+
+// quickAdder serves POST /events: it creates the event on
+// server.quick_add_calendar, where the next render picks it up.
+func quickAdder(cfg *config.Config) server.QuickAddFunc {
+	calendarID := cfg.Calendar.GoogleCalendarID(cfg.Server.QuickAddCalendar)
+	return func(ctx context.Context, text string) (server.AddedEvent, error) {
+		credentials, err := cfg.Calendar.CredentialsJSON()
+		if err != nil {
+			return server.AddedEvent{}, fmt.Errorf("unable to read calendar credentials: %w", err)
+		}
+		client, err := calendar.NewClient(ctx, credentials, cfg.Calendar.TokenFile, cfg.Weather.Timezone, calendar.Scope(true))
+		if err != nil {
+			return server.AddedEvent{}, fmt.Errorf("failed to create calendar client: %w", err)
+		}
+
+		event, err := client.QuickAdd(ctx, calendarID, text)
+		if err != nil {
+			return server.AddedEvent{}, err
+		}
+		return server.AddedEvent{
+			ID:      event.ID,
+			Summary: event.Summary,
+			Start:   event.Start,
+			End:     event.End,
+			AllDay:  event.AllDay,
+		}, nil
+	}
+}
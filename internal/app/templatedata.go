@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/alerts"
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/quote"
+	"github.com/paveljanda/calvin/internal/render"
+	"github.com/paveljanda/calvin/internal/script"
+	"github.com/paveljanda/calvin/internal/spotprice"
+	"github.com/paveljanda/calvin/internal/ticker"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+// TemplateData prints what view is drawn from, for layout and theme
+// authors: with asJSON the prepared data as indented JSON, otherwise every
+// field with its JSON path and type. The data is made up unless live is
+// set, which fetches the configured sources like --preview-terminal and
+// leaves the output and the state directory alone.
+func TemplateData(ctx context.Context, cfg *config.Config, out io.Writer, view string, live, asJSON bool, opts ...Option) error {
+	if view == "" {
+		view = cfg.Display.Views[0]
+	}
+	if !config.ValidView(view) {
+		return fmt.Errorf("invalid view %q: must be month, agenda, board, details or rolling", view)
+	}
+	if !asJSON {
+		printFields(out, "", reflect.TypeFor[render.TemplateData]())
+		return nil
+	}
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.dryRun = true
+
+	input := sampleInput(cfg, view, time.Now())
+	if live {
+		var err error
+		if input, err = liveInput(ctx, cfg, o, view); err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(render.PrepareData(view, input))
+}
+
+// liveInput fetches the input for rendering view with the state in a
+// temporary directory, so the event cache and change tracking aren't
+// touched.
+func liveInput(ctx context.Context, cfg *config.Config, o options, view string) (render.MonthInput, error) {
+	dir, err := os.MkdirTemp("", "calvin-scratch-")
+	if err != nil {
+		return render.MonthInput{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	scratch := *cfg
+	scratch.State.Dir = dir
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.MaxRunDuration())
+	defer cancel()
+	if err := resolveLocation(runCtx, &scratch); err != nil {
+		return render.MonthInput{}, err
+	}
+	input, _, err := gather(runCtx, &scratch, o, view, time.Now(), newTimings())
+	return input, err
+}
+
+// printFields lists the JSON path and Go type of every field of t, one per
+// line, descending into structs, slices and pointers.
+func printFields(out io.Writer, prefix string, t reflect.Type) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		path := prefix + name
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice {
+			if ft.Kind() == reflect.Slice {
+				path += "[]"
+			}
+			ft = ft.Elem()
+		}
+		fmt.Fprintf(out, "%-40s %s\n", path, f.Type)
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeFor[time.Time]() {
+			printFields(out, path+".", ft)
+		}
+	}
+}
+
+// sampleConditions are the sample forecast's weather codes, one per day.
+var sampleConditions = []int{0, 2, 61, 3, 71, 95, 45, 80}
+
+// sampleInput is a made-up week around now with every kind of data filled
+// in for view, sized and formatted as configured.
+func sampleInput(cfg *config.Config, view string, now time.Time) render.MonthInput {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	at := func(day, hour, minute int) time.Time {
+		return today.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	forecast := &weather.Forecast{Units: weather.Units(cfg.Weather.Units)}
+	for day := range render.ForecastDays(view) {
+		for hour := range 24 {
+			forecast.Hourly = append(forecast.Hourly, weather.HourlyForecast{
+				Time:        at(day, hour, 0),
+				Temperature: 8 + float64(day%3) + 6*math.Sin(float64(hour-9)*math.Pi/12),
+				WeatherCode: sampleConditions[day%len(sampleConditions)],
+			})
+		}
+		forecast.Daily = append(forecast.Daily, weather.DailyForecast{
+			Date:     at(day, 0, 0),
+			Sunrise:  at(day, 6, 42),
+			Sunset:   at(day, 18, 5),
+			Daylight: 11*time.Hour + 23*time.Minute,
+		})
+	}
+
+	events := []calendar.Event{
+		{ID: "sample-1", Summary: "Dentist", Start: at(-1, 8, 30), End: at(-1, 9, 15), CalendarName: "Family"},
+		{ID: "sample-2", Summary: "Standup", Start: at(0, 9, 0), End: at(0, 9, 15), CalendarName: "Work", VideoCall: true},
+		{ID: "sample-3", Summary: "Swimming lesson", Location: "City pool", Start: at(0, 17, 30), End: at(0, 18, 30), CalendarName: "Family"},
+		{ID: "sample-4", Summary: "Grandma's birthday", Start: at(1, 0, 0), End: at(2, 0, 0), AllDay: true, CalendarName: "Family"},
+		{ID: "sample-5", Summary: "Parent-teacher meeting", Start: at(2, 16, 0), End: at(2, 17, 0), CalendarName: "School"},
+		{ID: "sample-6", Summary: "Ski trip", Start: at(4, 0, 0), End: at(7, 0, 0), AllDay: true, CalendarName: "Family"},
+	}
+	lastYear := 11.5
+
+	var spotPrices []spotprice.Price
+	for hour := range 24 {
+		spotPrices = append(spotPrices, spotprice.Price{
+			Start: at(0, hour, 0),
+			Price: 9 + 4*math.Cos(float64(hour-18)*math.Pi/12),
+		})
+	}
+
+	return render.MonthInput{
+		Now:               now,
+		Width:             cfg.Display.Width,
+		Height:            cfg.Display.Height,
+		Weather:           forecast,
+		Events:            events,
+		MaxEventsPerDay:   maxEventsPerDay(cfg),
+		TrimOutsideWeeks:  cfg.Calendar.TrimOutsideWeeks,
+		BatteryPercentage: "87%",
+		Alerts: []alerts.Alert{{
+			Event:    "Strong wind",
+			Area:     "Praha",
+			Severity: alerts.SeverityModerate,
+			Onset:    at(0, 12, 0),
+			Expires:  at(1, 6, 0),
+		}},
+		LastYearTemp: &lastYear,
+		Widgets:      []script.Widget{{Label: "Living room", Value: "21.4°"}},
+		Quote:        &quote.Quote{Text: "Well begun is half done.", Author: "Aristotle"},
+		Tickers:      []ticker.Quote{{Symbol: "AAPL", Price: 187.2, Change: 1.2, FetchedAt: now}},
+		SpotPrices:   spotPrices,
+		SpotUnit:     "ct/kWh",
+		SpotCheapest: 3,
+		ShowDaylight: true,
+		Shade:        cfg.Display.Shade,
+		Countdown:    true,
+		Suggestions:  suggestions(cfg),
+		CountdownEvents: []calendar.Event{
+			{ID: "sample-7", Summary: "Summer vacation", Start: at(40, 0, 0), End: at(54, 0, 0), AllDay: true, CalendarName: "Family"},
+		},
+		CountdownMax: 1,
+		Locale:       displayLocale(cfg),
+	}
+}
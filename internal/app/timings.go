@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timings records how long each stage of a run took, to see where the
+// wake-to-sleep time goes.
+type timings struct {
+	mu     sync.Mutex
+	start  time.Time
+	stages []stageTiming
+}
+
+type stageTiming struct {
+	name     string
+	duration time.Duration
+}
+
+func newTimings() *timings {
+	return &timings{start: time.Now()}
+}
+
+func (t *timings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stages = append(t.stages, stageTiming{name: name, duration: d})
+}
+
+// track starts timing a stage; call the returned function when it ends.
+func (t *timings) track(name string) func() {
+	start := time.Now()
+	return func() {
+		t.add(name, time.Since(start))
+	}
+}
+
+// elapsed is the time since the run started.
+func (t *timings) elapsed() time.Duration {
+	return time.Since(t.start)
+}
+
+// String lists the stages in the order they finished, e.g.
+// "auth 0.3s, calendar Work 1.2s, weather 0.8s, total 6.2s".
+func (t *timings) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := make([]string, 0, len(t.stages)+1)
+	for _, s := range t.stages {
+		parts = append(parts, fmt.Sprintf("%s %s", s.name, formatSeconds(s.duration)))
+	}
+	parts = append(parts, "total "+formatSeconds(time.Since(t.start)))
+	return strings.Join(parts, ", ")
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
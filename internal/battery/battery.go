@@ -8,12 +8,11 @@ import (
 	"strings"
 )
 
-// GetBatteryPercentage returns the battery percentage from PiSugar 2
-// Returns 100 if noBattery is true or if battery reading fails
+// GetBatteryPercentage returns the battery percentage from PiSugar 2.
+// Callers treat errors as soft failures and show them in the header.
 func GetBatteryPercentage(ctx context.Context) (string, error) {
 	output, err := exec.CommandContext(ctx, "pisugar-cli", "--get-battery-level").CombinedOutput()
 	if err != nil {
-		// If pisugar-cli is not available or fails, return 100%
 		return "", fmt.Errorf("failed to exec pisugar-cli --get-battery-level: %w", err)
 	}
 
@@ -21,7 +20,7 @@ func GetBatteryPercentage(ctx context.Context) (string, error) {
 	outputStr := strings.TrimSpace(string(output))
 	parts := strings.Split(outputStr, ":")
 	if len(parts) != 2 {
-		return "", fmt.Errorf("failed to parse output of pisugar-cli --get-battery-level: %w", err)
+		return "", fmt.Errorf("failed to parse output of pisugar-cli --get-battery-level: %q", outputStr)
 	}
 
 	percentageStr := strings.TrimSpace(parts[1])
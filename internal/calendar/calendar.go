@@ -3,19 +3,35 @@ package calendar
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/paveljanda/calvin/internal/httpclient"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gcal "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
+// minLookaheadDays and minLookbackDays are the minimum number of days from
+// today that fetched events cover, regardless of the month grid. The
+// rolling view needs the most: from the previous week's Monday through
+// three weeks after this one.
+const (
+	minLookaheadDays = 28
+	minLookbackDays  = 13
+)
+
 type Event struct {
+	ID           string
+	Updated      time.Time
 	Summary      string
 	Description  string
 	Location     string
@@ -23,8 +39,34 @@ type Event struct {
 	End          time.Time
 	AllDay       bool
 	CalendarName string
+	// Attendees are the lowercased emails of invitees who haven't
+	// declined.
+	Attendees []string
+	// VideoCall marks events with a Meet, Zoom or Teams link.
+	VideoCall bool
+	// VideoCallLink is the video-call URL, when one could be extracted.
+	VideoCallLink string
+	// Private events are shown as busy time without details.
+	Private bool
+	// Free events don't block time (Google's "transparent" events).
+	Free bool
+	// Type is Google's event type, e.g. TypeFocusTime; empty for ordinary
+	// events.
+	Type string
+	// Fixture marks sports fixtures, drawn with a ball.
+	Fixture bool
+	// Reminders are how long before Start Google shows a notification,
+	// from the event or the calendar's defaults.
+	Reminders []time.Duration
 }
 
+// Google event types besides ordinary events.
+const (
+	TypeFocusTime       = "focusTime"
+	TypeOutOfOffice     = "outOfOffice"
+	TypeWorkingLocation = "workingLocation"
+)
+
 type DayEvents struct {
 	Date   time.Time
 	Events []Event
@@ -33,6 +75,13 @@ type DayEvents struct {
 type CalendarConfig struct {
 	ID   string
 	Name string
+	// AccessRole is owner, writer, reader or freeBusyReader.
+	AccessRole string
+	Primary    bool
+	// Selected calendars are shown in the Google Calendar UI; Hidden ones
+	// are removed from its list.
+	Selected bool
+	Hidden   bool
 }
 
 type Client struct {
@@ -40,13 +89,23 @@ type Client struct {
 	location *time.Location
 }
 
-func NewClient(ctx context.Context, credentialsPath, tokenPath string, timezone string) (*Client, error) {
-	credBytes, err := os.ReadFile(credentialsPath)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+// ErrReadOnlyToken is returned when creating events with a token that was
+// authorized for reading only.
+var ErrReadOnlyToken = errors.New("token lacks write access: delete the token file and run calvin --list-calendars to authorize again")
+
+// Scope returns the OAuth scope to authorize: read-only unless write is
+// set, which also allows creating events.
+func Scope(write bool) string {
+	if write {
+		return gcal.CalendarEventsScope
 	}
+	return gcal.CalendarReadonlyScope
+}
 
-	config, err := google.ConfigFromJSON(credBytes, gcal.CalendarReadonlyScope)
+// NewClient connects with the token at tokenPath, asking for authorization
+// of scope (see Scope) when there is none yet.
+func NewClient(ctx context.Context, credBytes []byte, tokenPath, timezone, scope string) (*Client, error) {
+	config, err := google.ConfigFromJSON(credBytes, scope)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse credentials: %w", err)
 	}
@@ -62,6 +121,9 @@ func NewClient(ctx context.Context, credentialsPath, tokenPath string, timezone
 		}
 	}
 
+	// The oauth2 transport wraps the shared client, so token refreshes
+	// and API calls both go through the configured proxy and CA.
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpclient.New(30*time.Second))
 	httpClient := config.Client(ctx, token)
 	httpClient.Timeout = 30 * time.Second
 
@@ -81,6 +143,25 @@ func NewClient(ctx context.Context, credentialsPath, tokenPath string, timezone
 	}, nil
 }
 
+// CheckToken verifies that a saved token exists and can be refreshed,
+// without ever prompting for authorization.
+func CheckToken(ctx context.Context, credBytes []byte, tokenPath string) error {
+	config, err := google.ConfigFromJSON(credBytes, gcal.CalendarReadonlyScope)
+	if err != nil {
+		return fmt.Errorf("unable to parse credentials: %w", err)
+	}
+
+	token, err := tokenFromFile(tokenPath)
+	if err != nil {
+		return fmt.Errorf("no saved token, run calvin --list-calendars to authorize: %w", err)
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpclient.New(30*time.Second))
+	if _, err := config.TokenSource(ctx, token).Token(); err != nil {
+		return fmt.Errorf("unable to refresh token: %w", err)
+	}
+	return nil
+}
+
 func tokenFromFile(path string) (*oauth2.Token, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -130,15 +211,42 @@ func saveToken(path string, token *oauth2.Token) error {
 	return json.NewEncoder(f).Encode(token)
 }
 
-func (c *Client) FetchEventsForMonth(calendarID string, calendarName string) ([]Event, error) {
-	startDate, endDate := c.getMonthDateRange()
+// FetchEventsForMonth returns the events of the month grid around now,
+// the reference time of the run. When sync, from an earlier fetch, covers
+// the range, only what changed since is asked for; the returned Sync
+// continues from this fetch.
+func (c *Client) FetchEventsForMonth(ctx context.Context, calendarID string, calendarName string, now time.Time, sync *Sync) ([]Event, *Sync, error) {
+	startDate, endDate := MonthDateRange(now, c.location)
+	next, err := c.syncEvents(ctx, calendarID, sync, startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	var result []Event
+	for _, e := range next.Events {
+		if e.Start.Before(endDate) && e.End.After(startDate) {
+			e.CalendarName = calendarName
+			result = append(result, e)
+		}
+	}
+	return SortEvents(result), next, nil
+}
+
+// FetchUpcomingEvents returns the events from now's day until days ahead.
+func (c *Client) FetchUpcomingEvents(ctx context.Context, calendarID string, calendarName string, now time.Time, days int) ([]Event, error) {
+	now = now.In(c.location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, c.location)
+	return c.fetchEvents(ctx, calendarID, calendarName, today, today.AddDate(0, 0, days))
+}
+
+func (c *Client) fetchEvents(ctx context.Context, calendarID, calendarName string, startDate, endDate time.Time) ([]Event, error) {
 	events, err := c.service.Events.List(calendarID).
 		ShowDeleted(false).
 		SingleEvents(true).
 		TimeMin(startDate.Format(time.RFC3339)).
 		TimeMax(endDate.Format(time.RFC3339)).
 		OrderBy("startTime").
+		Context(ctx).
 		Do()
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve events: %w", err)
@@ -146,31 +254,195 @@ func (c *Client) FetchEventsForMonth(calendarID string, calendarName string) ([]
 
 	result := make([]Event, 0, len(events.Items))
 	for _, item := range events.Items {
-		result = append(result, c.parseGoogleEvent(item, calendarName))
+		result = append(result, c.parseGoogleEvent(item, calendarName, events.DefaultReminders))
 	}
 
 	return result, nil
 }
 
-func (c *Client) getMonthDateRange() (time.Time, time.Time) {
-	now := time.Now().In(c.location)
-	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, c.location)
+// syncAheadDays widens full syncs past the requested range, so their
+// token stays usable for a month while the range moves on.
+const syncAheadDays = 31
+
+// Sync is what an incremental fetch of a calendar continues from: Google's
+// sync token and the events as of it from Start to End.
+type Sync struct {
+	Token  string
+	Start  time.Time
+	End    time.Time
+	Events []Event
+}
+
+// covers reports whether s can bring the events from start to end up to
+// date.
+func (s *Sync) covers(start, end time.Time) bool {
+	return s != nil && s.Token != "" && !start.Before(s.Start) && !end.After(s.End)
+}
+
+// syncEvents returns the events from start to end, by an incremental sync
+// from sync where it covers them and a full one otherwise.
+func (c *Client) syncEvents(ctx context.Context, calendarID string, sync *Sync, start, end time.Time) (*Sync, error) {
+	if sync.covers(start, end) {
+		next, err := c.incrementalSync(ctx, calendarID, sync)
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusGone {
+			return next, err
+		}
+		log.Printf("  Sync token of %s expired, fetching all events", calendarID)
+	}
+	return c.fullSync(ctx, calendarID, start, end.AddDate(0, 0, syncAheadDays))
+}
+
+func (c *Client) fullSync(ctx context.Context, calendarID string, start, end time.Time) (*Sync, error) {
+	next := &Sync{Start: start, End: end}
+	err := c.service.Events.List(calendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(start.Format(time.RFC3339)).
+		TimeMax(end.Format(time.RFC3339)).
+		Pages(ctx, func(page *gcal.Events) error {
+			for _, item := range page.Items {
+				next.Events = append(next.Events, c.parseGoogleEvent(item, "", page.DefaultReminders))
+			}
+			next.Token = page.NextSyncToken
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve events: %w", err)
+	}
+	return next, nil
+}
+
+// incrementalSync applies what changed since sync to its events. Changes
+// outside its range, which Google reports too, are left out.
+func (c *Client) incrementalSync(ctx context.Context, calendarID string, sync *Sync) (*Sync, error) {
+	byID := make(map[string]Event, len(sync.Events))
+	for _, e := range sync.Events {
+		byID[e.ID] = e
+	}
+
+	next := &Sync{Start: sync.Start, End: sync.End}
+	err := c.service.Events.List(calendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		SyncToken(sync.Token).
+		Pages(ctx, func(page *gcal.Events) error {
+			for _, item := range page.Items {
+				// Cancelled events come with little more than their ID.
+				if item.Status == "cancelled" {
+					delete(byID, item.Id)
+					continue
+				}
+				e := c.parseGoogleEvent(item, "", page.DefaultReminders)
+				if !e.Start.Before(next.End) || !e.End.After(next.Start) {
+					delete(byID, item.Id)
+					continue
+				}
+				byID[item.Id] = e
+			}
+			next.Token = page.NextSyncToken
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to sync events: %w", err)
+	}
+
+	for _, e := range byID {
+		next.Events = append(next.Events, e)
+	}
+	next.Events = SortEvents(next.Events)
+	return next, nil
+}
+
+// QuickAdd creates an event on calendarID from text such as "Buy milk
+// 18:00", parsed by Google like the quick-add box of its web app.
+func (c *Client) QuickAdd(ctx context.Context, calendarID, text string) (Event, error) {
+	item, err := c.service.Events.QuickAdd(calendarID, text).Context(ctx).Do()
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden && strings.Contains(strings.ToLower(apiErr.Message), "insufficient") {
+			return Event{}, ErrReadOnlyToken
+		}
+		return Event{}, fmt.Errorf("unable to add event: %w", err)
+	}
+	return c.parseGoogleEvent(item, "", nil), nil
+}
+
+// MonthDateRange returns the days FetchEventsForMonth covers at now: the
+// month grid in loc, widened to the lookahead and lookback around today.
+func MonthDateRange(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	now = now.In(loc)
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
 
 	startDate := firstOfMonth.AddDate(0, 0, -(mondayWeekday(firstOfMonth) - 1))
 	endDate := lastOfMonth.AddDate(0, 0, 7-mondayWeekday(lastOfMonth)+1)
 
+	// Views listing upcoming days may reach past the month grid late in
+	// the month, and the rolling view before it early in the month.
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if lookaheadEnd := today.AddDate(0, 0, minLookaheadDays); lookaheadEnd.After(endDate) {
+		endDate = lookaheadEnd
+	}
+	if lookbackStart := today.AddDate(0, 0, -minLookbackDays); lookbackStart.Before(startDate) {
+		startDate = lookbackStart
+	}
+
 	return startDate, endDate
 }
 
-func (c *Client) parseGoogleEvent(item *gcal.Event, calendarName string) Event {
+func (c *Client) parseGoogleEvent(item *gcal.Event, calendarName string, defaultReminders []*gcal.EventReminder) Event {
 	event := Event{
+		ID:           item.Id,
 		Summary:      item.Summary,
 		Description:  item.Description,
 		Location:     item.Location,
 		CalendarName: calendarName,
 	}
 
+	if t, err := time.Parse(time.RFC3339, item.Updated); err == nil {
+		event.Updated = t
+	}
+
+	event.Private = item.Visibility == "private" || item.Visibility == "confidential"
+	event.Free = item.Transparency == "transparent"
+	if item.EventType != "default" {
+		event.Type = item.EventType
+	}
+
+	event.VideoCall = item.HangoutLink != "" || HasVideoCallLink(item.Location) || HasVideoCallLink(item.Description)
+	if item.ConferenceData != nil {
+		for _, ep := range item.ConferenceData.EntryPoints {
+			if ep.EntryPointType == "video" {
+				event.VideoCall = true
+				if event.VideoCallLink == "" {
+					event.VideoCallLink = ep.Uri
+				}
+			}
+		}
+	}
+	for _, link := range []string{item.HangoutLink, VideoCallLink(item.Location), VideoCallLink(item.Description)} {
+		if event.VideoCallLink == "" {
+			event.VideoCallLink = link
+		}
+	}
+
+	reminders := defaultReminders
+	if item.Reminders != nil && !item.Reminders.UseDefault {
+		reminders = item.Reminders.Overrides
+	}
+	for _, r := range reminders {
+		if r.Method == "popup" {
+			event.Reminders = append(event.Reminders, time.Duration(r.Minutes)*time.Minute)
+		}
+	}
+
+	for _, a := range item.Attendees {
+		if a.Email != "" && a.ResponseStatus != "declined" {
+			event.Attendees = append(event.Attendees, strings.ToLower(a.Email))
+		}
+	}
+
 	if item.Start.DateTime != "" {
 		if t, err := time.Parse(time.RFC3339, item.Start.DateTime); err == nil {
 			event.Start = t.In(c.location)
@@ -204,8 +476,8 @@ func mondayWeekday(t time.Time) int {
 	return weekday
 }
 
-func (c *Client) ListCalendars() ([]CalendarConfig, error) {
-	calendarList, err := c.service.CalendarList.List().Do()
+func (c *Client) ListCalendars(ctx context.Context) ([]CalendarConfig, error) {
+	calendarList, err := c.service.CalendarList.List().Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("unable to list calendars: %w", err)
 	}
@@ -213,34 +485,145 @@ func (c *Client) ListCalendars() ([]CalendarConfig, error) {
 	var calendars []CalendarConfig
 	for _, item := range calendarList.Items {
 		calendars = append(calendars, CalendarConfig{
-			ID:   item.Id,
-			Name: item.Summary,
+			ID:         item.Id,
+			Name:       item.Summary,
+			AccessRole: item.AccessRole,
+			Primary:    item.Primary,
+			Selected:   item.Selected,
+			Hidden:     item.Hidden,
 		})
 	}
 
 	return calendars, nil
 }
 
+// videoCallHosts are URL fragments of video-call links.
+var videoCallHosts = []string{
+	"meet.google.com/",
+	"hangouts.google.com/",
+	"zoom.us/j/",
+	"zoom.us/my/",
+	"teams.microsoft.com/l/meetup-join",
+	"teams.live.com/meet",
+}
+
+// HasVideoCallLink reports whether text contains a Meet, Zoom or Teams link.
+func HasVideoCallLink(text string) bool {
+	text = strings.ToLower(text)
+	for _, host := range videoCallHosts {
+		if strings.Contains(text, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// VideoCallLink returns the first Meet, Zoom or Teams URL in text, which
+// may be plain text or the HTML of an event description.
+func VideoCallLink(text string) string {
+	for _, field := range strings.Fields(text) {
+		lower := strings.ToLower(field)
+		for _, host := range videoCallHosts {
+			i := strings.Index(lower, host)
+			if i < 0 {
+				continue
+			}
+			if start := strings.LastIndex(lower[:i], "http"); start >= 0 {
+				i = start
+			}
+			link := field[i:]
+			if end := strings.IndexAny(link, "\"'<>()[]"); end >= 0 {
+				link = link[:end]
+			}
+			link = strings.TrimRight(link, ".,;:")
+			if !strings.HasPrefix(strings.ToLower(link), "http") {
+				link = "https://" + link
+			}
+			return link
+		}
+	}
+	return ""
+}
+
+// Next returns the earliest-starting timed event that hasn't ended by now
+// and satisfies match. A nil match accepts every event.
+func Next(events []Event, now time.Time, match func(Event) bool) (Event, bool) {
+	var next Event
+	found := false
+	for _, ev := range events {
+		if ev.AllDay || !ev.End.After(now) {
+			continue
+		}
+		if match != nil && !match(ev) {
+			continue
+		}
+		if !found || ev.Start.Before(next.Start) {
+			next = ev
+			found = true
+		}
+	}
+	return next, found
+}
+
+// NextReminder returns when the earliest notification of a timed event
+// after now is due.
+func NextReminder(events []Event, now time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, ev := range events {
+		if ev.AllDay {
+			continue
+		}
+		for _, r := range ev.Reminders {
+			at := ev.Start.Add(-r)
+			if at.After(now) && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Key identifies an event across fetches. Sources without stable IDs fall
+// back to the summary and start time.
+func (e Event) Key() string {
+	if e.ID != "" {
+		return e.CalendarName + "/" + e.ID
+	}
+	return fmt.Sprintf("%s/%s@%s", e.CalendarName, e.Summary, e.Start.Format(time.RFC3339))
+}
+
 func SortEvents(events []Event) []Event {
 	sorted := make([]Event, len(events))
 	copy(sorted, events)
 
-	sort.Slice(sorted, func(i, j int) bool {
-		if sorted[i].AllDay && !sorted[j].AllDay {
-			return true
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.AllDay != b.AllDay {
+			return a.AllDay
+		}
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		// Ties are broken by content, so the order doesn't depend on
+		// which source or page returned an event first.
+		if !a.End.Equal(b.End) {
+			return a.End.Before(b.End)
+		}
+		if a.Summary != b.Summary {
+			return a.Summary < b.Summary
 		}
-		if !sorted[i].AllDay && sorted[j].AllDay {
-			return false
+		if a.CalendarName != b.CalendarName {
+			return a.CalendarName < b.CalendarName
 		}
-		return sorted[i].Start.Before(sorted[j].Start)
+		return a.ID < b.ID
 	})
 
 	return sorted
 }
 
-func IsToday(t time.Time) bool {
-	now := time.Now()
-	return t.Year() == now.Year() && t.YearDay() == now.YearDay()
+// SameDay reports whether a and b fall on the same calendar day.
+func SameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
 }
 
 func IsWeekend(t time.Time) bool {
@@ -0,0 +1,83 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsLineLimit is the longest content line RFC 5545 allows, in octets;
+// longer ones are folded.
+const icsLineLimit = 75
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteICS writes events as an iCalendar feed named name, stamped with now.
+// Private events become "Busy" without details and free ones don't block
+// time. Other text is written as given, so callers apply RedactEvent first.
+func WriteICS(w io.Writer, name string, events []Event, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(format string, args ...any) {
+		writeICSLine(bw, fmt.Sprintf(format, args...))
+	}
+	text := func(prop, value string) {
+		if value != "" {
+			line("%s:%s", prop, icsEscaper.Replace(value))
+		}
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Calvin//Calvin//EN")
+	line("CALSCALE:GREGORIAN")
+	text("X-WR-CALNAME", name)
+	for _, ev := range SortEvents(events) {
+		line("BEGIN:VEVENT")
+		text("UID", ev.Key()+"@calvin")
+		line("DTSTAMP:%s", now.UTC().Format("20060102T150405Z"))
+		if ev.AllDay {
+			line("DTSTART;VALUE=DATE:%s", ev.Start.Format("20060102"))
+			line("DTEND;VALUE=DATE:%s", ev.End.Format("20060102"))
+		} else {
+			line("DTSTART:%s", ev.Start.UTC().Format("20060102T150405Z"))
+			line("DTEND:%s", ev.End.UTC().Format("20060102T150405Z"))
+		}
+		if ev.Private {
+			text("SUMMARY", "Busy")
+			line("CLASS:PRIVATE")
+		} else {
+			text("SUMMARY", ev.Summary)
+			text("LOCATION", ev.Location)
+			text("DESCRIPTION", ev.Description)
+		}
+		text("CATEGORIES", ev.CalendarName)
+		if ev.Free {
+			line("TRANSP:TRANSPARENT")
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeICSLine writes s with CRLF, folded into lines of at most
+// icsLineLimit octets without splitting a character.
+func writeICSLine(w *bufio.Writer, s string) {
+	limit := icsLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the folding space.
+		limit = icsLineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
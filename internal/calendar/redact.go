@@ -0,0 +1,26 @@
+package calendar
+
+import "regexp"
+
+// Redaction replaces matches of Pattern in event text.
+type Redaction struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Redact applies every redaction to text in order.
+func Redact(text string, redactions []Redaction) string {
+	for _, r := range redactions {
+		text = r.Pattern.ReplaceAllString(text, r.Replacement)
+	}
+	return text
+}
+
+// RedactEvent applies the redactions to the summary, location and
+// description of ev, everything that describes the event in words.
+func RedactEvent(ev Event, redactions []Redaction) Event {
+	ev.Summary = Redact(ev.Summary, redactions)
+	ev.Location = Redact(ev.Location, redactions)
+	ev.Description = Redact(ev.Description, redactions)
+	return ev
+}
@@ -0,0 +1,34 @@
+package calendar
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactEvent(t *testing.T) {
+	redactions := []Redaction{{Pattern: regexp.MustCompile(`(?i)dr\. novak|dermatology`), Replacement: "Appointment"}}
+	start := time.Date(2026, time.October, 12, 9, 0, 0, 0, time.UTC)
+	ev := RedactEvent(Event{
+		ID:          "a",
+		Summary:     "Dr. Novak",
+		Location:    "Dermatology, Clinic Street 5",
+		Description: "Bring the referral for dr. Novak",
+		Start:       start,
+		End:         start.Add(time.Hour),
+	}, redactions)
+
+	if ev.Summary != "Appointment" || ev.Location != "Appointment, Clinic Street 5" || ev.Description != "Bring the referral for Appointment" {
+		t.Errorf("redacted to %q, %q, %q", ev.Summary, ev.Location, ev.Description)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, "Test", []Event{ev}, start); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(strings.ToLower(out), "novak") || strings.Contains(strings.ToLower(out), "dermatology") {
+		t.Errorf("exported calendar leaks redacted text:\n%s", out)
+	}
+}
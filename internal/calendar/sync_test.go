@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gcal "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeGoogle serves the events list of one calendar: a full sync over two
+// pages ends with token "t1", from which an incremental sync reports
+// changes ending with "t2". Token "expired" gets 410 Gone.
+func fakeGoogle(t *testing.T) (*Client, *[]string) {
+	t.Helper()
+	item := func(id, summary, day string) string {
+		return fmt.Sprintf(`{"id":%q,"status":"confirmed","summary":%q,"start":{"dateTime":"%sT09:00:00Z"},"end":{"dateTime":"%sT10:00:00Z"}}`, id, summary, day, day)
+	}
+	pages := map[string]string{
+		"full":    `{"items":[` + item("a", "A", "2026-10-05") + `,` + item("b", "B", "2026-10-20") + `],"nextPageToken":"p2"}`,
+		"full p2": `{"items":[` + item("c", "C", "2026-11-20") + `],"nextSyncToken":"t1"}`,
+		"t1": `{"items":[{"id":"a","status":"cancelled"},` + item("b", "B moved", "2026-10-21") + `,` +
+			item("d", "D", "2026-10-25") + `,` + item("e", "E", "2027-03-01") + `],"nextSyncToken":"t2"}`,
+	}
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		token := q.Get("syncToken")
+		if token != "" && q.Get("timeMin") != "" {
+			t.Errorf("sync token %s sent with timeMin", token)
+		}
+		key := strings.TrimSpace(cmp.Or(token, "full") + " " + q.Get("pageToken"))
+		requests = append(requests, key)
+		if token == "expired" {
+			http.Error(w, `{"error":{"code":410,"message":"Sync token is no longer valid"}}`, http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pages[key])
+	}))
+	t.Cleanup(srv.Close)
+
+	service, err := gcal.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{service: service, location: time.UTC}, &requests
+}
+
+func summaries(events []Event) []string {
+	var result []string
+	for _, e := range events {
+		result = append(result, e.Summary)
+	}
+	return result
+}
+
+// TestFetchEventsSync fetches October 2026, whose range runs from
+// September 28 to November 9.
+func TestFetchEventsSync(t *testing.T) {
+	client, requests := fakeGoogle(t)
+	ctx := context.Background()
+	now := time.Date(2026, time.October, 12, 10, 0, 0, 0, time.UTC)
+
+	events, sync, err := client.FetchEventsForMonth(ctx, "family", "Family", now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summaries(events); !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("full sync returned %v, want [A B]", got)
+	}
+	if sync.Token != "t1" || len(sync.Events) != 3 {
+		t.Errorf("full sync kept token %q and %d events, want t1 and 3", sync.Token, len(sync.Events))
+	}
+	if events[0].CalendarName != "Family" {
+		t.Errorf("calendar name %q, want Family", events[0].CalendarName)
+	}
+
+	// A day later only the changes are asked for.
+	events, next, err := client.FetchEventsForMonth(ctx, "family", "Family", now.AddDate(0, 0, 1), sync)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summaries(events); !slices.Equal(got, []string{"B moved", "D"}) {
+		t.Errorf("incremental sync returned %v, want [B moved D]", got)
+	}
+	if got := summaries(next.Events); !slices.Equal(got, []string{"B moved", "D", "C"}) {
+		t.Errorf("incremental sync kept %v, want [B moved D C]", got)
+	}
+	if next.Token != "t2" || !next.Start.Equal(sync.Start) || !next.End.Equal(sync.End) {
+		t.Errorf("incremental sync gave token %q for %s to %s", next.Token, next.Start, next.End)
+	}
+	if want := []string{"full", "full p2", "t1"}; !slices.Equal(*requests, want) {
+		t.Errorf("requests %v, want %v", *requests, want)
+	}
+
+	// An expired token and a range beyond the sync both fetch everything.
+	*requests = nil
+	expired := *sync
+	expired.Token = "expired"
+	tests := []struct {
+		name string
+		now  time.Time
+		sync *Sync
+	}{
+		{"after an expired token", now, &expired},
+		{"beyond the synced range", now.AddDate(0, 2, 0), sync},
+	}
+	for _, tt := range tests {
+		_, next, err := client.FetchEventsForMonth(ctx, "family", "Family", tt.now, tt.sync)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if next.Token != "t1" {
+			t.Errorf("%s: token %q, want t1 from a full sync", tt.name, next.Token)
+		}
+	}
+	if want := []string{"expired", "full", "full p2", "full", "full p2"}; !slices.Equal(*requests, want) {
+		t.Errorf("requests %v, want %v", *requests, want)
+	}
+}
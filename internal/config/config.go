@@ -1,43 +1,855 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/paveljanda/calvin/internal/locale"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	// Version is the schema version the file was written for; see
+	// config.Version. Older configs are migrated when loaded.
+	Version int `yaml:"version"`
+
 	Display  DisplayConfig  `yaml:"display"`
 	Weather  WeatherConfig  `yaml:"weather"`
 	Calendar CalendarConfig `yaml:"calendar"`
 	Output   OutputConfig   `yaml:"output"`
+	Render   RenderConfig   `yaml:"render"`
+	Alerts   AlertsConfig   `yaml:"alerts"`
+	Quote    QuoteConfig    `yaml:"quote"`
+	Ticker   TickerConfig   `yaml:"ticker"`
+	Spot     SpotConfig     `yaml:"spot_prices"`
+	Sensors  SensorsConfig  `yaml:"sensors"`
+	Server   ServerConfig   `yaml:"server"`
+	GPIO     GPIOConfig     `yaml:"gpio"`
+	State    StateConfig    `yaml:"state"`
+	Lock     LockConfig     `yaml:"lock"`
+	Power    PowerConfig    `yaml:"power"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Network  NetworkConfig  `yaml:"network"`
+
+	// MaxRunSeconds bounds the whole fetch and render phase so a stuck
+	// network call can't keep the Pi awake and drain the battery.
+	MaxRunSeconds int `yaml:"max_run_seconds"`
+
+	// Warnings lists deprecated settings found while loading.
+	Warnings []string `yaml:"-"`
+}
+
+// ValidView reports whether view is one of the views Calvin renders:
+// month, agenda, board, details or rolling.
+func ValidView(view string) bool {
+	return view == "month" || view == "agenda" || view == "board" || view == "details" || view == "rolling"
 }
 
 type DisplayConfig struct {
 	Width  int `yaml:"width"`
 	Height int `yaml:"height"`
+
+	// Views lists the pages to show. The first one is rendered by default;
+	// GPIO buttons in daemon mode cycle through the rest.
+	Views []string `yaml:"views"`
+
+	// Images are composited over every view, e.g. a family logo or a QR
+	// code for the guest Wi-Fi.
+	Images []ImageConfig `yaml:"images"`
+
+	QR QRConfig `yaml:"qr"`
+
+	// Shade tints day cells: any of vacation, holiday, weekend and busy
+	// (darker the fuller the day).
+	Shade []string `yaml:"shade"`
+
+	// Locale such as "cs-CZ" or "en-US" sets the decimal separator, 12- or
+	// 24-hour times and the date order. Empty keeps 24-hour times,
+	// "2 January" and ISO dates.
+	Locale string `yaml:"locale"`
+	// TimeFormat is 24h or 12h ("2:30 PM"); empty follows Locale.
+	TimeFormat string `yaml:"time_format"`
+	// LargePrint draws everything larger with more contrast and fewer
+	// events per day, for readers who struggle with the default sizes.
+	LargePrint bool `yaml:"large_print"`
+	// UpdatedClock shows "Updated 07:00" in large type in the header.
+	UpdatedClock bool `yaml:"updated_clock"`
+	// StaleAfterHours stamps each image with an expiry; the HTTP server
+	// frames images older than that so a frame that stopped updating is
+	// noticed. 0 disables.
+	StaleAfterHours int `yaml:"stale_after_hours"`
+
+	IT8951 IT8951Config `yaml:"it8951"`
+}
+
+// IT8951Config pushes every render to a panel driven by an IT8951
+// controller board over SPI.
+type IT8951Config struct {
+	Enabled  bool   `yaml:"enabled"`
+	Device   string `yaml:"device"`
+	SpeedHz  int    `yaml:"speed_hz"`
+	ReadyPin int    `yaml:"ready_pin"`
+	ResetPin int    `yaml:"reset_pin"`
+	// VCOM is printed on the panel's flex cable, e.g. -1.48.
+	VCOM float64 `yaml:"vcom"`
+	// Mode is the refresh waveform: gc16 (default), gl16, du or a2.
+	Mode string `yaml:"mode"`
+	// Partial refreshes only the area that changed since the last update,
+	// with PartialMode (defaults to Mode).
+	Partial     bool   `yaml:"partial"`
+	PartialMode string `yaml:"partial_mode"`
+}
+
+// Day cell shadings for DisplayConfig.Shade.
+const (
+	ShadeVacation = "vacation"
+	ShadeHoliday  = "holiday"
+	ShadeWeekend  = "weekend"
+	ShadeBusy     = "busy"
+)
+
+// Event kinds for calendar.hide.
+const (
+	HideFree            = "free"
+	HideFocusTime       = "focus_time"
+	HideOutOfOffice     = "out_of_office"
+	HideWorkingLocation = "working_location"
+)
+
+// QR code targets.
+const (
+	QRTargetCalendar  = "calendar"
+	QRTargetNextEvent = "next_event"
+	QRTargetURL       = "url"
+)
+
+// QRConfig draws a QR code in a corner so visitors can open the calendar,
+// or join the next video call, on their phone.
+type QRConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Target is calendar (the Google Calendar month view), next_event (the
+	// video-call link of the next upcoming event, if any) or url.
+	Target string `yaml:"target"`
+	URL    string `yaml:"url"`
+	// Label is an optional caption under the code.
+	Label  string `yaml:"label"`
+	Corner string `yaml:"corner"`
+	// Size is the width and height of the code in pixels.
+	Size   int `yaml:"size"`
+	Margin int `yaml:"margin"`
+}
+
+// ImageConfig places a local PNG or JPEG image in a corner of the display.
+// With only one of Width and Height set the other keeps the aspect ratio;
+// with neither the image is drawn at its own size.
+type ImageConfig struct {
+	Path   string `yaml:"path"`
+	Corner string `yaml:"corner"`
+	Width  int    `yaml:"width"`
+	Height int    `yaml:"height"`
+	// Margin is the distance from the display edges in pixels.
+	Margin int `yaml:"margin"`
 }
 
 type WeatherConfig struct {
+	// Location is a place name such as "Brno, CZ", resolved to Latitude,
+	// Longitude and, if unset, Timezone at startup.
+	Location         string `yaml:"location"`
+	GeocodeCacheFile string `yaml:"geocode_cache_file"`
+
 	Latitude  float64 `yaml:"latitude"`
 	Longitude float64 `yaml:"longitude"`
 	Timezone  string  `yaml:"timezone"`
+	Units     string  `yaml:"units"`
+
+	LastYear         bool   `yaml:"last_year"`
+	HistoryCacheFile string `yaml:"history_cache_file"`
+
+	// Daylight draws each forecast day's sunrise to sunset span.
+	Daylight bool `yaml:"daylight"`
+	// Snow fetches snowfall and snow depth and marks snowy days.
+	Snow bool `yaml:"snow"`
+
+	// Label names this location in the comparison row shown when Compare
+	// lists further locations.
+	Label   string            `yaml:"label"`
+	Compare []CompareLocation `yaml:"compare"`
+
+	// Suggestions show a line below the header when the forecast meets
+	// their condition, e.g. "Take umbrella" when rain is likely tomorrow.
+	Suggestions []SuggestionConfig `yaml:"suggestions"`
+}
+
+// SuggestionConfig shows Text when Metric on Day compares to Value with Op.
+type SuggestionConfig struct {
+	// Day is today (default) or tomorrow.
+	Day string `yaml:"day"`
+	// Metric is precipitation_probability (highest hourly %),
+	// precipitation (daily total), min_temp, max_temp, wind_speed (highest
+	// hourly) or snowfall (daily total, needs weather.snow).
+	Metric string  `yaml:"metric"`
+	Op     string  `yaml:"op"`
+	Value  float64 `yaml:"value"`
+	Text   string  `yaml:"text"`
+	// Icon is umbrella, snowflake, warning or empty. The fonts have no
+	// emoji, so use an icon rather than a symbol in Text.
+	Icon string `yaml:"icon"`
+}
+
+// Offset returns the number of days after today the suggestion looks at.
+func (s SuggestionConfig) Offset() int {
+	if s.Day == "tomorrow" {
+		return 1
+	}
+	return 0
+}
+
+// RedactRule replaces every match of the regular expression Pattern with
+// Replacement, which may refer to groups as $1.
+type RedactRule struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+// PersonConfig marks events a person is invited to with their initial.
+// A person's events are those they are invited to by Email plus every event
+// of their Calendars (by calendar source name).
+type PersonConfig struct {
+	Name      string   `yaml:"name"`
+	Email     string   `yaml:"email"`
+	Calendars []string `yaml:"calendars"`
+	Initial   string   `yaml:"initial"`
+	// Color is black, red or grey.
+	Color string `yaml:"color"`
+}
+
+// DateRange is a named span of whole days; End is inclusive. Dates are
+// YYYY-MM-DD.
+type DateRange struct {
+	Name  string `yaml:"name"`
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// Dates parses Start and End in loc.
+func (r DateRange) Dates(loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", r.Start, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: %w", r.Start, err)
+	}
+	end := start
+	if r.End != "" {
+		end, err = time.ParseInLocation("2006-01-02", r.End, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: %w", r.End, err)
+		}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end %s is before start %s", r.End, r.Start)
+	}
+	return start, end, nil
+}
+
+// CropRect is a rectangle in pixels of the full image.
+type CropRect struct {
+	X      int `yaml:"x"`
+	Y      int `yaml:"y"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+}
+
+// CountdownConfig shows "Next: Dentist in 2h 15m" in the header.
+type CountdownConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Calendars limits the countdown to these calendar source names; empty
+	// means all calendars.
+	Calendars []string `yaml:"calendars"`
+
+	// Max is the number of "12 days until Vacation" lines shown for the
+	// all-day events of sources marked countdowns; defaults to 3.
+	Max int `yaml:"max"`
+	// DaysAhead is how far ahead those sources are fetched; defaults to
+	// 365.
+	DaysAhead int `yaml:"days_ahead"`
+}
+
+// CompareLocation is an additional place whose forecast is shown next to
+// the main one, e.g. a weekend house.
+type CompareLocation struct {
+	Label     string  `yaml:"label"`
+	Location  string  `yaml:"location"`
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
 }
 
 type CalendarConfig struct {
+	Credentials     string           `yaml:"credentials"`
 	CredentialsFile string           `yaml:"credentials_file"`
 	TokenFile       string           `yaml:"token_file"`
 	Calendars       []CalendarSource `yaml:"calendars"`
 	MaxEventsPerDay int              `yaml:"max_events_per_day"`
+
+	// TrimOutsideWeeks drops the month grid's first week once it has
+	// passed, giving the other rows more height. There is no trailing
+	// week to trim: the grid ends with the week of the month's last day.
+	TrimOutsideWeeks bool `yaml:"trim_outside_weeks"`
+
+	// WriteAccess asks for permission to create events when authorizing,
+	// as server.quick_add_calendar needs. Tokens authorized before must be
+	// deleted and authorized again.
+	WriteAccess bool `yaml:"write_access"`
+
+	// FootballDataToken is the football-data.org API key of "sports"
+	// sources with a team_id; see FootballDataTokenValue.
+	FootballDataToken     string `yaml:"football_data_token"`
+	FootballDataTokenFile string `yaml:"football_data_token_file"`
+
+	Countdown CountdownConfig `yaml:"countdown"`
+
+	// Vacations are school holidays, trips and the like; see
+	// DisplayConfig.Shade.
+	Vacations []DateRange `yaml:"vacations"`
+	// Periods are named spans such as "Spring break" or "Heating
+	// maintenance", drawn as a band across their days.
+	Periods []DateRange `yaml:"periods"`
+
+	// Redact rewrites event summaries, locations and descriptions before
+	// rendering.
+	Redact []RedactRule `yaml:"redact"`
+
+	// TravelWarningMinutes flags a timed event that starts less than this
+	// many minutes after the previous one ends at a different location.
+	// Zero disables the check.
+	TravelWarningMinutes int `yaml:"travel_warning_minutes"`
+
+	// People maps attendee emails to initials shown next to events and
+	// defines the columns of the board view.
+	People []PersonConfig `yaml:"people"`
+
+	// Waste lists recurring waste pickups, marked with a bin on their days.
+	Waste []WasteConfig `yaml:"waste"`
+
+	// Hide drops kinds of events that clutter the day cells: free (marked
+	// "show as available"), focus_time, out_of_office and
+	// working_location.
+	Hide []string `yaml:"hide"`
+
+	// OutOfOfficeKeywords mark events whose summary contains one of them,
+	// ignoring case, as out of office, like Google's out-of-office events.
+	// Those spanning several days are drawn as a band instead.
+	OutOfOfficeKeywords []string `yaml:"out_of_office_keywords"`
+
+	// ImportantKeywords emphasize events whose summary contains one of
+	// them, ignoring case, after redactions. Private events only count as
+	// important by calendar; see CalendarSource.Important.
+	ImportantKeywords []string `yaml:"important_keywords"`
+}
+
+// WasteConfig is a pickup on Weekday of every, odd or even (ISO) week.
+// Except and Extra are YYYY-MM-DD dates that cancel or add pickups.
+type WasteConfig struct {
+	Name    string   `yaml:"name"`
+	Weekday string   `yaml:"weekday"`
+	Weeks   string   `yaml:"weeks"`
+	Except  []string `yaml:"except"`
+	Extra   []string `yaml:"extra"`
 }
 
+// Day parses Weekday, e.g. "tuesday" or "Tue".
+func (w WasteConfig) Day() (time.Weekday, error) {
+	name := strings.ToLower(w.Weekday)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", w.Weekday)
+}
+
+// Calendar source types.
+const (
+	SourceGoogle = "google"
+	SourceScript = "script"
+	SourceSports = "sports"
+)
+
 type CalendarSource struct {
+	Type string `yaml:"type"`
 	ID   string `yaml:"id"`
 	Name string `yaml:"name"`
+
+	// Command is run for "script" sources; it must print JSON to stdout.
+	Command []string `yaml:"command"`
+
+	// URL is the ICS fixture feed of a "sports" source; without it,
+	// TeamID picks the team on football-data.org.
+	URL    string `yaml:"url"`
+	TeamID int    `yaml:"team_id"`
+	// HomeOnly keeps a sports source's home games. ICS feeds need Team,
+	// the team's name as it appears first in "Home - Away" titles.
+	HomeOnly bool   `yaml:"home_only"`
+	Team     string `yaml:"team"`
+
+	// Private shows this source's events as busy time without titles.
+	Private bool `yaml:"private"`
+
+	// Holidays marks the days of this source's events as public holidays,
+	// e.g. Google's "Holidays in Czechia" calendar.
+	Holidays bool `yaml:"holidays"`
+
+	// Countdowns turns this source's upcoming all-day events into
+	// "12 days until Vacation" lines instead of showing them on their days.
+	Countdowns bool `yaml:"countdowns"`
+
+	// Important draws this source's events bold and inverted, also on past
+	// days.
+	Important bool `yaml:"important"`
+
+	// Style tells this source's events apart from the others.
+	Style CalendarStyle `yaml:"style"`
+}
+
+// DisplayName returns the source's name, or its ID when it has none. Its
+// events carry it as their calendar name.
+func (s CalendarSource) DisplayName() string {
+	if s.Name == "" {
+		return s.ID
+	}
+	return s.Name
+}
+
+// CalendarStyle sets how the events of a calendar source are drawn.
+type CalendarStyle struct {
+	// Prefix is put before event titles, e.g. "W" or "•".
+	Prefix string `yaml:"prefix"`
+	// Color is black, red or grey: the title of timed events and the bar
+	// of all-day ones.
+	Color string `yaml:"color"`
+	// Bold draws titles in bold.
+	Bold bool `yaml:"bold"`
+}
+
+// HasGoogleSources reports whether any source needs the Google Calendar API.
+func (c CalendarConfig) HasGoogleSources() bool {
+	for _, src := range c.Calendars {
+		if src.Type == SourceGoogle {
+			return true
+		}
+	}
+	return false
+}
+
+// GoogleCalendarID returns the ID of the Google source named nameOrID, or
+// nameOrID itself when no source has that name.
+func (c CalendarConfig) GoogleCalendarID(nameOrID string) string {
+	for _, src := range c.Calendars {
+		if src.Type == SourceGoogle && src.Name == nameOrID {
+			return src.ID
+		}
+	}
+	return nameOrID
 }
 
 type OutputConfig struct {
 	Path string `yaml:"path"`
+
+	// SVG, when set, also writes the view as vector graphics to this path.
+	SVG string `yaml:"svg"`
+
+	// Variants are downscaled copies of the image for further displays.
+	Variants []OutputVariant `yaml:"variants"`
+
+	Kindle KindleConfig `yaml:"kindle"`
+}
+
+// KindleConfig copies every render to a jailbroken Kindle over SSH (e.g.
+// USBNetwork) and shows it with eips.
+type KindleConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Host         string `yaml:"host"`
+	User         string `yaml:"user"`
+	Port         int    `yaml:"port"`
+	IdentityFile string `yaml:"identity_file"`
+	// Path is where the image is stored on the Kindle.
+	Path string `yaml:"path"`
+	// Width and Height are the panel resolution in portrait, e.g. 758x1024
+	// for a Paperwhite; landscape renders are rotated.
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+	// Dither is none or floyd-steinberg (default) for the 16 grey levels.
+	Dither string `yaml:"dither"`
+	// Command shows the image; defaults to clearing the screen and drawing
+	// Path with eips.
+	Command string `yaml:"command"`
+}
+
+// OutputVariant is a scaled copy of the rendered image, or of a part of it.
+// The aspect ratio is kept; leftover space is white.
+type OutputVariant struct {
+	Path string `yaml:"path"`
+	// Width and Height may be left out for a cropped variant to keep the
+	// cropped size.
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+	// Region crops a named part of the view first: today (today's cell or
+	// row) or header.
+	Region string `yaml:"region"`
+	// Crop crops a fixed rectangle of the full image first.
+	Crop *CropRect `yaml:"crop"`
+	// Palette is bw, bwr or 4color; empty keeps all colors.
+	Palette string `yaml:"palette"`
+	// Dither is none (default) or floyd-steinberg.
+	Dither string `yaml:"dither"`
+	// Raw writes a controller frame buffer instead of a PNG: ssd1680,
+	// it8951 or uc8159. The preset fixes the colors, so Palette must be
+	// empty.
+	Raw string `yaml:"raw"`
+}
+
+type AlertsConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	FeedURL     string `yaml:"feed_url"`
+	Area        string `yaml:"area"`
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// QuoteConfig shows a quote of the day in a footer line. The quote comes
+// from URL when set, falling back to File if the API fails.
+type QuoteConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// File holds one quote per line, optionally ending in " — Author".
+	File string `yaml:"file"`
+	// URL is a JSON API returning a quote object or a list of them;
+	// TextField and AuthorField name their keys (default text and author).
+	URL         string `yaml:"url"`
+	TextField   string `yaml:"text_field"`
+	AuthorField string `yaml:"author_field"`
+}
+
+// TickerConfig shows up to three stock or crypto prices with their daily
+// change in the header.
+type TickerConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Symbols []string `yaml:"symbols"`
+	// URL is requested per symbol with {symbol} replaced. PriceField and
+	// ChangeField (percent) are dotted paths into its JSON response.
+	URL         string `yaml:"url"`
+	PriceField  string `yaml:"price_field"`
+	ChangeField string `yaml:"change_field"`
+	// CacheMinutes reuses prices this long, 60 by default; CacheFile keeps
+	// them between runs.
+	CacheMinutes int    `yaml:"cache_minutes"`
+	CacheFile    string `yaml:"cache_file"`
+}
+
+// CacheDuration returns CacheMinutes as a time.Duration.
+func (t TickerConfig) CacheDuration() time.Duration {
+	return time.Duration(t.CacheMinutes) * time.Minute
+}
+
+// SpotConfig shows today's hourly electricity spot prices as a bar strip
+// below the header, for dynamic tariffs.
+type SpotConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Token (see TokenValue) and Area, an EIC bidding zone code such as
+	// 10YCZ-CEPS-----N, read day-ahead prices from ENTSO-E in EUR/MWh.
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	Area      string `yaml:"area"`
+	// URL is used without a token: a JSON list of objects with an RFC
+	// 3339 time under TimeField and the price under PriceField.
+	URL        string `yaml:"url"`
+	TimeField  string `yaml:"time_field"`
+	PriceField string `yaml:"price_field"`
+	// Scale multiplies the prices, e.g. 0.1 for EUR/MWh to ct/kWh, and
+	// Unit labels them.
+	Scale float64 `yaml:"scale"`
+	Unit  string  `yaml:"unit"`
+	// CheapestHours are highlighted; 3 by default.
+	CheapestHours int `yaml:"cheapest_hours"`
+}
+
+// TokenValue returns the ENTSO-E API token, resolved from
+// CALVIN_SPOT_PRICES_TOKEN, CALVIN_SPOT_PRICES_TOKEN_FILE,
+// spot_prices.token_file or spot_prices.token.
+func (s SpotConfig) TokenValue() (string, error) {
+	return resolveSecret("SPOT_PRICES_TOKEN", s.Token, s.TokenFile)
+}
+
+// SensorsConfig reads sensors such as Zigbee thermometers from an MQTT
+// broker at render time and shows their values in the header.
+type SensorsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Broker is tcp://host:1883 or mqtts://host:8883.
+	Broker       string `yaml:"broker"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	// WaitSeconds bounds the wait for topics without a retained message;
+	// 5 by default.
+	WaitSeconds int             `yaml:"wait_seconds"`
+	Readings    []SensorReading `yaml:"readings"`
+}
+
+// SensorReading is one header value, e.g. "Inside: 21.3°".
+type SensorReading struct {
+	Label string `yaml:"label"`
+	Topic string `yaml:"topic"`
+	// Field picks a key of a JSON payload such as zigbee2mqtt's
+	// {"temperature": 21.3, "humidity": 45}; empty uses the payload.
+	Field string `yaml:"field"`
+	Unit  string `yaml:"unit"`
+}
+
+// PasswordValue returns the MQTT password, resolved from
+// CALVIN_SENSORS_PASSWORD, CALVIN_SENSORS_PASSWORD_FILE,
+// sensors.password_file or sensors.password.
+func (s SensorsConfig) PasswordValue() (string, error) {
+	return resolveSecret("SENSORS_PASSWORD", s.Password, s.PasswordFile)
+}
+
+// Wait returns WaitSeconds as a time.Duration.
+func (s SensorsConfig) Wait() time.Duration {
+	return time.Duration(s.WaitSeconds) * time.Second
+}
+
+// ServerConfig configures daemon mode.
+type ServerConfig struct {
+	Listen                 string      `yaml:"listen"`
+	RefreshIntervalMinutes int         `yaml:"refresh_interval_minutes"`
+	RefreshToken           string      `yaml:"refresh_token"`
+	RefreshTokenFile       string      `yaml:"refresh_token_file"`
+	TRMNL                  TRMNLConfig `yaml:"trmnl"`
+
+	// RenderOnDemand enables GET /render, which renders with the view,
+	// size and calendars from the query for displays configured
+	// differently from the main one.
+	RenderOnDemand bool `yaml:"render_on_demand"`
+	// RenderCacheMinutes reuses an on-demand render for identical requests
+	// until the next scheduled render or this long; defaults to 15, -1
+	// disables it.
+	RenderCacheMinutes int `yaml:"render_cache_minutes"`
+	// RenderConcurrency is how many on-demand renders run at once
+	// (default 1); up to RenderQueue (default 4) more wait at most
+	// RenderWaitSeconds (default 60) before getting 503.
+	RenderConcurrency int `yaml:"render_concurrency"`
+	RenderQueue       int `yaml:"render_queue"`
+	RenderWaitSeconds int `yaml:"render_wait_seconds"`
+
+	// QuickAddCalendar enables POST /events, which creates events on this
+	// Google calendar, given by source name or calendar ID, from text like
+	// "Buy milk 18:00". It needs calendar.write_access.
+	QuickAddCalendar string `yaml:"quick_add_calendar"`
+
+	Auth ServerAuthConfig `yaml:"auth"`
+	TLS  ServerTLSConfig  `yaml:"tls"`
+}
+
+// ServerAuthConfig protects the image and metadata endpoints with a
+// token, basic auth, or both. Empty leaves them open.
+type ServerAuthConfig struct {
+	Token        string `yaml:"token"`
+	TokenFile    string `yaml:"token_file"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// TokenValue returns the token image requests must carry, resolved from
+// CALVIN_SERVER_AUTH_TOKEN, CALVIN_SERVER_AUTH_TOKEN_FILE,
+// server.auth.token_file or server.auth.token.
+func (a ServerAuthConfig) TokenValue() (string, error) {
+	return resolveSecret("SERVER_AUTH_TOKEN", a.Token, a.TokenFile)
+}
+
+// PasswordValue returns the basic-auth password, resolved from
+// CALVIN_SERVER_AUTH_PASSWORD, CALVIN_SERVER_AUTH_PASSWORD_FILE,
+// server.auth.password_file or server.auth.password.
+func (a ServerAuthConfig) PasswordValue() (string, error) {
+	return resolveSecret("SERVER_AUTH_PASSWORD", a.Password, a.PasswordFile)
+}
+
+// ServerTLSConfig serves HTTPS. Without CertFile and KeyFile a self-signed
+// certificate is generated in the state directory on first start.
+type ServerTLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// TRMNLConfig serves the TRMNL device API so TRMNL e-ink terminals can use
+// Calvin as their self-hosted backend.
+type TRMNLConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	AccessToken     string `yaml:"access_token"`
+	AccessTokenFile string `yaml:"access_token_file"`
+	Width           int    `yaml:"width"`
+	Height          int    `yaml:"height"`
+}
+
+// AccessTokenValue returns the token TRMNL devices must send, resolved from
+// CALVIN_SERVER_TRMNL_ACCESS_TOKEN, CALVIN_SERVER_TRMNL_ACCESS_TOKEN_FILE,
+// server.trmnl.access_token_file or server.trmnl.access_token.
+func (t TRMNLConfig) AccessTokenValue() (string, error) {
+	return resolveSecret("SERVER_TRMNL_ACCESS_TOKEN", t.AccessToken, t.AccessTokenFile)
+}
+
+// RefreshInterval returns RefreshIntervalMinutes as a time.Duration.
+func (s ServerConfig) RefreshInterval() time.Duration {
+	return time.Duration(s.RefreshIntervalMinutes) * time.Minute
+}
+
+// RenderCacheTTL returns RenderCacheMinutes as a time.Duration, zero when
+// disabled.
+func (s ServerConfig) RenderCacheTTL() time.Duration {
+	return time.Duration(max(s.RenderCacheMinutes, 0)) * time.Minute
+}
+
+// RenderWait returns RenderWaitSeconds as a time.Duration.
+func (s ServerConfig) RenderWait() time.Duration {
+	return time.Duration(s.RenderWaitSeconds) * time.Second
+}
+
+// RefreshTokenValue returns the shared token for POST /refresh, resolved from
+// CALVIN_SERVER_REFRESH_TOKEN, CALVIN_SERVER_REFRESH_TOKEN_FILE,
+// server.refresh_token_file or server.refresh_token.
+func (s ServerConfig) RefreshTokenValue() (string, error) {
+	return resolveSecret("SERVER_REFRESH_TOKEN", s.RefreshToken, s.RefreshTokenFile)
+}
+
+// Button actions.
+const (
+	ActionNextView     = "next_view"
+	ActionPreviousView = "previous_view"
+	ActionRefresh      = "refresh"
+)
+
+// GPIOConfig configures physical buttons handled in daemon mode.
+type GPIOConfig struct {
+	Enabled    bool           `yaml:"enabled"`
+	DebounceMS int            `yaml:"debounce_ms"`
+	Buttons    []ButtonConfig `yaml:"buttons"`
+}
+
+type ButtonConfig struct {
+	Pin       int    `yaml:"pin"`
+	Action    string `yaml:"action"`
+	ActiveLow bool   `yaml:"active_low"`
+}
+
+// Debounce returns DebounceMS as a time.Duration.
+func (g GPIOConfig) Debounce() time.Duration {
+	return time.Duration(g.DebounceMS) * time.Millisecond
+}
+
+// StateConfig configures where data persisted between runs is kept.
+type StateConfig struct {
+	Dir string `yaml:"dir"`
+
+	// Stats keeps monthly usage statistics (uptime, battery drain, API
+	// errors) in Dir, shown by "calvin stats" and as uptime in the header.
+	// Nothing is sent anywhere.
+	Stats bool `yaml:"stats"`
+}
+
+// HTTPConfig configures the HTTP client shared by the weather, alerts,
+// geocoding and calendar fetchers.
+type HTTPConfig struct {
+	// Proxy is an http(s):// or socks5:// URL; empty uses the
+	// HTTPS_PROXY/HTTP_PROXY/NO_PROXY environment variables.
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM bundle trusted in addition to the system CAs, e.g.
+	// for a TLS-intercepting corporate proxy.
+	CAFile string `yaml:"ca_file"`
+	// TimeoutSeconds replaces the per-request timeouts (10s for weather,
+	// 30s for calendars) when set.
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	UserAgent      string `yaml:"user_agent"`
+}
+
+// NetworkConfig bounds the wait for connectivity before fetching, since
+// Wi-Fi often needs a few seconds after a wake.
+type NetworkConfig struct {
+	// WaitSeconds is the longest wait, counted towards max_run_seconds;
+	// defaults to 30 (at most a quarter of the budget), -1 disables it.
+	WaitSeconds int `yaml:"wait_seconds"`
+	// CheckHost is the host:port that must accept a TCP connection.
+	CheckHost string `yaml:"check_host"`
+}
+
+// Wait returns WaitSeconds as a time.Duration, zero when disabled.
+func (n NetworkConfig) Wait() time.Duration {
+	return time.Duration(max(n.WaitSeconds, 0)) * time.Second
+}
+
+// PowerConfig selects how the PiSugar alarm is set and the Pi shut down.
+type PowerConfig struct {
+	// Method is sudo (default), pisugar-server or helper.
+	Method string `yaml:"method"`
+	// PiSugarSocket is the pisugar-server unix socket path or host:port.
+	PiSugarSocket string `yaml:"pisugar_socket"`
+	// Helper is the root-owned helper binary for the helper method.
+	Helper string `yaml:"helper"`
+
+	// WakeBeforeEventMinutes wakes the Pi this long before the next event
+	// with a Google reminder when that comes before the hourly wake, so
+	// the frame is fresh right before it. Zero disables it.
+	WakeBeforeEventMinutes int `yaml:"wake_before_event_minutes"`
+
+	// OnShutdownFailure is what a run does when the shutdown fails: exit
+	// (default) with the Pi left on, retry up to ShutdownRetries times,
+	// power off with systemctl instead, or stay running as a daemon that
+	// renders at every alarm and tries again.
+	OnShutdownFailure string `yaml:"on_shutdown_failure"`
+	ShutdownRetries   int    `yaml:"shutdown_retries"`
+}
+
+// Behaviors when the shutdown fails.
+const (
+	ShutdownFailureExit      = "exit"
+	ShutdownFailureRetry     = "retry"
+	ShutdownFailureSystemctl = "systemctl"
+	ShutdownFailureDaemon    = "daemon"
+)
+
+// WakeBeforeEvent returns WakeBeforeEventMinutes as a time.Duration.
+func (p PowerConfig) WakeBeforeEvent() time.Duration {
+	return time.Duration(p.WakeBeforeEventMinutes) * time.Minute
+}
+
+// LockConfig controls what a run does when another run still holds the
+// lock file in the state directory.
+type LockConfig struct {
+	// Policy is skip (default), wait or takeover.
+	Policy string `yaml:"policy"`
+	// StaleAfterSeconds is when a held lock counts as hung and is taken
+	// over regardless of Policy; defaults to max_run_seconds plus two
+	// minutes for the alarm and shutdown.
+	StaleAfterSeconds int `yaml:"stale_after_seconds"`
+}
+
+// StaleAfter returns StaleAfterSeconds as a time.Duration.
+func (l LockConfig) StaleAfter() time.Duration {
+	return time.Duration(l.StaleAfterSeconds) * time.Second
+}
+
+type RenderConfig struct {
+	// LowMemory runs the garbage collector more often, frees memory
+	// before rendering and compresses the PNG faster with a smaller write
+	// buffer.
+	LowMemory bool `yaml:"low_memory"`
+	// Scale draws at this multiple of the display size (1-4) and
+	// downsamples, for crisper text on 1-bit panels at the cost of
+	// scale squared the memory; defaults to 1.
+	Scale int `yaml:"scale"`
+	// ShowTiming adds the time from start to render to the header, e.g.
+	// "Render: 6.2s". Per-stage timings are always logged.
+	ShowTiming bool `yaml:"show_timing"`
 }
 
 func Load(path string) (*Config, error) {
@@ -46,10 +858,20 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	warnings, err := migrate(&doc)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := doc.Decode(&cfg); err != nil {
 		return nil, err
 	}
+	cfg.Warnings = warnings
 
 	if cfg.Display.Width == 0 {
 		cfg.Display.Width = 800
@@ -57,10 +879,198 @@ func Load(path string) (*Config, error) {
 	if cfg.Display.Height == 0 {
 		cfg.Display.Height = 480
 	}
+	if len(cfg.Display.Views) == 0 {
+		cfg.Display.Views = []string{"month"}
+	}
+	for _, view := range cfg.Display.Views {
+		if !ValidView(view) {
+			return nil, fmt.Errorf("invalid display view %q: must be month, agenda, board, details or rolling", view)
+		}
+	}
+	for i := range cfg.Display.Images {
+		img := &cfg.Display.Images[i]
+		if img.Path == "" {
+			return nil, fmt.Errorf("display.images[%d]: path is required", i)
+		}
+		if img.Corner == "" {
+			img.Corner = "bottom-right"
+		}
+		if !validCorner(img.Corner) {
+			return nil, fmt.Errorf("display.images[%d]: invalid corner %q: must be top-left, top-right, bottom-left or bottom-right", i, img.Corner)
+		}
+		if img.Width < 0 || img.Height < 0 {
+			return nil, fmt.Errorf("display.images[%d]: width and height must not be negative", i)
+		}
+		if img.Margin == 0 {
+			img.Margin = 16
+		}
+	}
+	if qr := &cfg.Display.QR; qr.Enabled {
+		if qr.Target == "" {
+			qr.Target = QRTargetCalendar
+		}
+		switch qr.Target {
+		case QRTargetCalendar, QRTargetNextEvent:
+		case QRTargetURL:
+			if qr.URL == "" {
+				return nil, fmt.Errorf("display.qr.url is required for target %q", QRTargetURL)
+			}
+		default:
+			return nil, fmt.Errorf("invalid display.qr.target %q: must be calendar, next_event or url", qr.Target)
+		}
+		if qr.Corner == "" {
+			qr.Corner = "bottom-right"
+		}
+		if !validCorner(qr.Corner) {
+			return nil, fmt.Errorf("invalid display.qr.corner %q: must be top-left, top-right, bottom-left or bottom-right", qr.Corner)
+		}
+		if qr.Size == 0 {
+			qr.Size = 120
+		}
+		if qr.Margin == 0 {
+			qr.Margin = 16
+		}
+	}
+	if _, err := locale.Parse(cfg.Display.Locale); err != nil {
+		return nil, fmt.Errorf("invalid display.locale: %w", err)
+	}
+	if cfg.Display.TimeFormat != "" && cfg.Display.TimeFormat != "24h" && cfg.Display.TimeFormat != "12h" {
+		return nil, fmt.Errorf("invalid display.time_format %q: must be 24h or 12h", cfg.Display.TimeFormat)
+	}
+	if cfg.Display.StaleAfterHours < 0 {
+		return nil, fmt.Errorf("display.stale_after_hours must not be negative")
+	}
+	if cfg.Display.Shade == nil {
+		cfg.Display.Shade = []string{ShadeVacation, ShadeHoliday}
+	}
+	for _, shade := range cfg.Display.Shade {
+		switch shade {
+		case ShadeVacation, ShadeHoliday, ShadeWeekend, ShadeBusy:
+		default:
+			return nil, fmt.Errorf("invalid display.shade %q: must be vacation, holiday, weekend or busy", shade)
+		}
+	}
+	for _, v := range cfg.Calendar.Vacations {
+		if _, _, err := v.Dates(time.UTC); err != nil {
+			return nil, fmt.Errorf("calendar.vacations %q: %w", v.Name, err)
+		}
+	}
+	for _, p := range cfg.Calendar.Periods {
+		if p.Name == "" {
+			return nil, fmt.Errorf("calendar.periods entries need a name")
+		}
+		if _, _, err := p.Dates(time.UTC); err != nil {
+			return nil, fmt.Errorf("calendar.periods %q: %w", p.Name, err)
+		}
+	}
+	for i := range cfg.Output.Variants {
+		v := &cfg.Output.Variants[i]
+		if v.Path == "" {
+			return nil, fmt.Errorf("output.variants[%d]: path is required", i)
+		}
+		if v.Region != "" && v.Crop != nil {
+			return nil, fmt.Errorf("output.variants[%d]: set either region or crop, not both", i)
+		}
+		if v.Region != "" && v.Region != "today" && v.Region != "header" {
+			return nil, fmt.Errorf("output.variants[%d]: invalid region %q: must be today or header", i, v.Region)
+		}
+		if c := v.Crop; c != nil && (c.X < 0 || c.Y < 0 || c.Width <= 0 || c.Height <= 0) {
+			return nil, fmt.Errorf("output.variants[%d]: crop needs a positive width and height", i)
+		}
+		cropped := v.Region != "" || v.Crop != nil
+		if (v.Width <= 0 || v.Height <= 0) && !(cropped && v.Width == 0 && v.Height == 0) {
+			return nil, fmt.Errorf("output.variants[%d]: width and height are required", i)
+		}
+		switch v.Palette {
+		case "", "bw", "bwr", "4color":
+		default:
+			return nil, fmt.Errorf("output.variants[%d]: invalid palette %q: must be bw, bwr or 4color", i, v.Palette)
+		}
+		switch v.Raw {
+		case "", "ssd1680", "it8951", "uc8159":
+		default:
+			return nil, fmt.Errorf("output.variants[%d]: invalid raw preset %q: must be ssd1680, it8951 or uc8159", i, v.Raw)
+		}
+		if v.Raw != "" && v.Palette != "" {
+			return nil, fmt.Errorf("output.variants[%d]: raw presets have their own palette, remove palette", i)
+		}
+		if v.Dither == "" {
+			v.Dither = "none"
+		}
+		if v.Dither != "none" && v.Dither != "floyd-steinberg" {
+			return nil, fmt.Errorf("output.variants[%d]: invalid dither %q: must be none or floyd-steinberg", i, v.Dither)
+		}
+	}
+	if k := &cfg.Output.Kindle; k.Enabled {
+		if k.Host == "" {
+			return nil, fmt.Errorf("output.kindle.host is required when the Kindle target is enabled")
+		}
+		if k.User == "" {
+			k.User = "root"
+		}
+		if k.Port == 0 {
+			k.Port = 22
+		}
+		if k.Path == "" {
+			k.Path = "/mnt/us/calvin.png"
+		}
+		if k.Width == 0 {
+			k.Width = 600
+		}
+		if k.Height == 0 {
+			k.Height = 800
+		}
+		if k.Dither == "" {
+			k.Dither = "floyd-steinberg"
+		}
+		if k.Dither != "none" && k.Dither != "floyd-steinberg" {
+			return nil, fmt.Errorf("invalid output.kindle.dither %q: must be none or floyd-steinberg", k.Dither)
+		}
+		if k.Command == "" {
+			k.Command = "eips -c && eips -f -g " + k.Path
+		}
+	}
+	if it := &cfg.Display.IT8951; it.Enabled {
+		if it.Device == "" {
+			it.Device = "/dev/spidev0.0"
+		}
+		if it.SpeedHz == 0 {
+			it.SpeedHz = 12000000
+		}
+		if it.ReadyPin == 0 {
+			it.ReadyPin = 24
+		}
+		if it.ResetPin == 0 {
+			it.ResetPin = 17
+		}
+		if it.VCOM >= 0 || it.VCOM < -5 {
+			return nil, fmt.Errorf("display.it8951.vcom must be the negative voltage from the panel's cable, e.g. -1.48")
+		}
+		if it.Mode == "" {
+			it.Mode = "gc16"
+		}
+		if it.PartialMode == "" {
+			it.PartialMode = it.Mode
+		}
+		for _, mode := range []string{it.Mode, it.PartialMode} {
+			if mode != "gc16" && mode != "gl16" && mode != "du" && mode != "a2" {
+				return nil, fmt.Errorf("invalid display.it8951 mode %q: must be gc16, gl16, du or a2", mode)
+			}
+		}
+	}
 	if cfg.Calendar.MaxEventsPerDay == 0 {
 		cfg.Calendar.MaxEventsPerDay = 10
 	}
-	if cfg.Calendar.CredentialsFile == "" {
+	if cfg.Calendar.Countdown.Max == 0 {
+		cfg.Calendar.Countdown.Max = 3
+	}
+	if cfg.Calendar.Countdown.DaysAhead == 0 {
+		cfg.Calendar.Countdown.DaysAhead = 365
+	}
+	if cfg.Calendar.Countdown.Max < 0 || cfg.Calendar.Countdown.DaysAhead < 0 {
+		return nil, fmt.Errorf("calendar.countdown.max and days_ahead must not be negative")
+	}
+	if cfg.Calendar.CredentialsFile == "" && cfg.Calendar.Credentials == "" {
 		cfg.Calendar.CredentialsFile = "credentials.json"
 	}
 	if cfg.Calendar.TokenFile == "" {
@@ -69,15 +1079,349 @@ func Load(path string) (*Config, error) {
 	if cfg.Output.Path == "" {
 		cfg.Output.Path = "calendar.png"
 	}
-	if cfg.Weather.Timezone == "" {
-		cfg.Weather.Timezone = "UTC"
+	if cfg.Weather.Units == "" {
+		cfg.Weather.Units = "metric"
+	}
+	if cfg.Weather.Units != "metric" && cfg.Weather.Units != "imperial" {
+		return nil, fmt.Errorf("invalid weather.units %q: must be metric or imperial", cfg.Weather.Units)
+	}
+	if cfg.Weather.Location != "" && (cfg.Weather.Latitude != 0 || cfg.Weather.Longitude != 0) {
+		return nil, fmt.Errorf("set either weather.location or weather.latitude/longitude, not both")
+	}
+	if cfg.Weather.Label == "" {
+		cfg.Weather.Label = "Home"
+		if cfg.Weather.Location != "" {
+			name, _, _ := strings.Cut(cfg.Weather.Location, ",")
+			cfg.Weather.Label = strings.TrimSpace(name)
+		}
+	}
+	for _, c := range cfg.Weather.Compare {
+		if c.Label == "" {
+			return nil, fmt.Errorf("weather.compare entries need a label")
+		}
+		if c.Location == "" && c.Latitude == 0 && c.Longitude == 0 {
+			return nil, fmt.Errorf("weather.compare %q: set location or latitude/longitude", c.Label)
+		}
+		if c.Location != "" && (c.Latitude != 0 || c.Longitude != 0) {
+			return nil, fmt.Errorf("weather.compare %q: set either location or latitude/longitude, not both", c.Label)
+		}
+	}
+	for i := range cfg.Weather.Suggestions {
+		sg := &cfg.Weather.Suggestions[i]
+		if sg.Text == "" {
+			return nil, fmt.Errorf("weather.suggestions entries need a text")
+		}
+		if sg.Day == "" {
+			sg.Day = "today"
+		}
+		if sg.Day != "today" && sg.Day != "tomorrow" {
+			return nil, fmt.Errorf("weather.suggestions %q: day must be today or tomorrow", sg.Text)
+		}
+		switch sg.Metric {
+		case "precipitation_probability", "precipitation", "min_temp", "max_temp", "wind_speed":
+		case "snowfall":
+			if !cfg.Weather.Snow {
+				return nil, fmt.Errorf("weather.suggestions %q: snowfall needs weather.snow", sg.Text)
+			}
+		default:
+			return nil, fmt.Errorf("weather.suggestions %q: unknown metric %q", sg.Text, sg.Metric)
+		}
+		switch sg.Op {
+		case "<", "<=", ">", ">=":
+		default:
+			return nil, fmt.Errorf("weather.suggestions %q: op must be <, <=, > or >=", sg.Text)
+		}
+		switch sg.Icon {
+		case "", "umbrella", "snowflake", "warning":
+		default:
+			return nil, fmt.Errorf("weather.suggestions %q: icon must be umbrella, snowflake or warning", sg.Text)
+		}
+	}
+	for i := range cfg.Calendar.Waste {
+		w := &cfg.Calendar.Waste[i]
+		if w.Name == "" {
+			return nil, fmt.Errorf("calendar.waste entries need a name")
+		}
+		if _, err := w.Day(); err != nil {
+			return nil, fmt.Errorf("calendar.waste %q: %w", w.Name, err)
+		}
+		if w.Weeks == "" {
+			w.Weeks = "every"
+		}
+		if w.Weeks != "every" && w.Weeks != "odd" && w.Weeks != "even" {
+			return nil, fmt.Errorf("calendar.waste %q: weeks must be every, odd or even", w.Name)
+		}
+		for _, date := range append(append([]string{}, w.Except...), w.Extra...) {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return nil, fmt.Errorf("calendar.waste %q: invalid date %q", w.Name, date)
+			}
+		}
+	}
+	for _, kind := range cfg.Calendar.Hide {
+		switch kind {
+		case HideFree, HideFocusTime, HideOutOfOffice, HideWorkingLocation:
+		default:
+			return nil, fmt.Errorf("invalid calendar.hide %q: must be free, focus_time, out_of_office or working_location", kind)
+		}
+	}
+	for _, r := range cfg.Calendar.Redact {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("calendar.redact pattern %q: %w", r.Pattern, err)
+		}
+	}
+	for i := range cfg.Calendar.People {
+		p := &cfg.Calendar.People[i]
+		if p.Email == "" && len(p.Calendars) == 0 {
+			return nil, fmt.Errorf("calendar.people entries need an email or calendars")
+		}
+		if p.Initial == "" && p.Name != "" {
+			p.Initial = string([]rune(p.Name)[:1])
+		}
+		if p.Initial == "" {
+			return nil, fmt.Errorf("calendar.people entries need a name or an initial")
+		}
+		if p.Name == "" {
+			p.Name = p.Initial
+		}
+		if p.Color == "" {
+			p.Color = "black"
+		}
+		if p.Color != "black" && p.Color != "red" && p.Color != "grey" {
+			return nil, fmt.Errorf("calendar.people %s: invalid color %q: must be black, red or grey", p.Name, p.Color)
+		}
+	}
+	if cfg.Weather.GeocodeCacheFile == "" {
+		cfg.Weather.GeocodeCacheFile = "geocode.json"
+	}
+	if cfg.Weather.HistoryCacheFile == "" {
+		cfg.Weather.HistoryCacheFile = "weather_history.json"
+	}
+	if cfg.Alerts.MinSeverity == "" {
+		cfg.Alerts.MinSeverity = "moderate"
+	}
+	if cfg.Alerts.Enabled && cfg.Alerts.FeedURL == "" {
+		return nil, fmt.Errorf("alerts.feed_url is required when alerts are enabled")
+	}
+	if cfg.Quote.Enabled && cfg.Quote.File == "" && cfg.Quote.URL == "" {
+		return nil, fmt.Errorf("quote.file or quote.url is required when quotes are enabled")
+	}
+	if cfg.Ticker.Enabled {
+		if !strings.Contains(cfg.Ticker.URL, "{symbol}") {
+			return nil, fmt.Errorf("invalid ticker.url %q: must contain {symbol}", cfg.Ticker.URL)
+		}
+		if n := len(cfg.Ticker.Symbols); n < 1 || n > 3 {
+			return nil, fmt.Errorf("ticker.symbols must list one to three symbols")
+		}
+	}
+	if cfg.Ticker.PriceField == "" {
+		cfg.Ticker.PriceField = "price"
+	}
+	if cfg.Ticker.ChangeField == "" {
+		cfg.Ticker.ChangeField = "change_percent"
+	}
+	if cfg.Ticker.CacheMinutes == 0 {
+		cfg.Ticker.CacheMinutes = 60
+	}
+	if cfg.Ticker.CacheFile == "" {
+		cfg.Ticker.CacheFile = "ticker_cache.json"
+	}
+	if cfg.Spot.Enabled {
+		if cfg.Spot.Area == "" && cfg.Spot.URL == "" {
+			return nil, fmt.Errorf("spot_prices.area or spot_prices.url is required when spot prices are enabled")
+		}
+	}
+	if cfg.Spot.TimeField == "" {
+		cfg.Spot.TimeField = "time"
+	}
+	if cfg.Spot.PriceField == "" {
+		cfg.Spot.PriceField = "price"
+	}
+	if cfg.Spot.Scale == 0 {
+		cfg.Spot.Scale = 1
+	}
+	if cfg.Spot.Unit == "" && cfg.Spot.Area != "" && cfg.Spot.Scale == 1 {
+		cfg.Spot.Unit = "€/MWh"
+	}
+	if cfg.Spot.CheapestHours == 0 {
+		cfg.Spot.CheapestHours = 3
+	}
+	if cfg.Sensors.Enabled {
+		if cfg.Sensors.Broker == "" {
+			return nil, fmt.Errorf("sensors.broker is required when sensors are enabled")
+		}
+		for i, reading := range cfg.Sensors.Readings {
+			if reading.Topic == "" {
+				return nil, fmt.Errorf("sensors.readings[%d]: topic is required", i)
+			}
+		}
+	}
+	if cfg.Sensors.WaitSeconds == 0 {
+		cfg.Sensors.WaitSeconds = 5
+	}
+	if cfg.Quote.TextField == "" {
+		cfg.Quote.TextField = "text"
+	}
+	if cfg.Quote.AuthorField == "" {
+		cfg.Quote.AuthorField = "author"
+	}
+	if cfg.Server.Listen == "" {
+		cfg.Server.Listen = ":8080"
+	}
+	if cfg.Server.RefreshIntervalMinutes == 0 {
+		cfg.Server.RefreshIntervalMinutes = 60
+	}
+	if (cfg.Server.TLS.CertFile == "") != (cfg.Server.TLS.KeyFile == "") {
+		return nil, fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+	if cfg.Server.QuickAddCalendar != "" && !cfg.Calendar.WriteAccess {
+		return nil, fmt.Errorf("server.quick_add_calendar requires calendar.write_access")
+	}
+	if cfg.Server.RenderCacheMinutes == 0 {
+		cfg.Server.RenderCacheMinutes = 15
+	}
+	if cfg.Server.RenderConcurrency == 0 {
+		cfg.Server.RenderConcurrency = 1
+	}
+	if cfg.Server.RenderQueue == 0 {
+		cfg.Server.RenderQueue = 4
+	}
+	if cfg.Server.RenderWaitSeconds == 0 {
+		cfg.Server.RenderWaitSeconds = 60
+	}
+	if cfg.Server.RenderConcurrency < 0 || cfg.Server.RenderQueue < 0 || cfg.Server.RenderWaitSeconds < 0 {
+		return nil, fmt.Errorf("server.render_concurrency, render_queue and render_wait_seconds must not be negative")
+	}
+	if cfg.Server.TRMNL.Width == 0 {
+		cfg.Server.TRMNL.Width = 800
+	}
+	if cfg.Server.TRMNL.Height == 0 {
+		cfg.Server.TRMNL.Height = 480
+	}
+	if cfg.GPIO.DebounceMS == 0 {
+		cfg.GPIO.DebounceMS = 50
+	}
+	for _, b := range cfg.GPIO.Buttons {
+		switch b.Action {
+		case ActionNextView, ActionPreviousView, ActionRefresh:
+		default:
+			return nil, fmt.Errorf("gpio button on pin %d: unknown action %q", b.Pin, b.Action)
+		}
+	}
+	if cfg.State.Dir == "" {
+		cfg.State.Dir = "state"
+	}
+	if cfg.MaxRunSeconds == 0 {
+		cfg.MaxRunSeconds = 120
+	}
+	if cfg.Render.Scale == 0 {
+		cfg.Render.Scale = 1
+	}
+	if cfg.Render.Scale < 1 || cfg.Render.Scale > 4 {
+		return nil, fmt.Errorf("render.scale must be between 1 and 4")
+	}
+	if cfg.HTTP.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("http.timeout_seconds must not be negative")
+	}
+	if cfg.Network.WaitSeconds == 0 {
+		cfg.Network.WaitSeconds = min(30, cfg.MaxRunSeconds/4)
+	}
+	if cfg.Network.WaitSeconds >= cfg.MaxRunSeconds {
+		return nil, fmt.Errorf("network.wait_seconds must be shorter than max_run_seconds, which it counts towards")
+	}
+	if cfg.Network.CheckHost == "" {
+		cfg.Network.CheckHost = "api.open-meteo.com:443"
+	}
+	if _, _, err := net.SplitHostPort(cfg.Network.CheckHost); err != nil {
+		return nil, fmt.Errorf("invalid network.check_host %q: must be host:port", cfg.Network.CheckHost)
+	}
+	if cfg.Power.Method == "" {
+		cfg.Power.Method = "sudo"
+	}
+	switch cfg.Power.Method {
+	case "sudo":
+	case "pisugar-server":
+		if cfg.Power.PiSugarSocket == "" {
+			cfg.Power.PiSugarSocket = "/tmp/pisugar-server.sock"
+		}
+	case "helper":
+		if cfg.Power.Helper == "" {
+			return nil, fmt.Errorf("power.helper is required for the helper method")
+		}
+	default:
+		return nil, fmt.Errorf("invalid power.method %q: must be sudo, pisugar-server or helper", cfg.Power.Method)
+	}
+	if cfg.Power.OnShutdownFailure == "" {
+		cfg.Power.OnShutdownFailure = ShutdownFailureExit
+	}
+	switch cfg.Power.OnShutdownFailure {
+	case ShutdownFailureExit, ShutdownFailureRetry, ShutdownFailureSystemctl, ShutdownFailureDaemon:
+	default:
+		return nil, fmt.Errorf("invalid power.on_shutdown_failure %q: must be exit, retry, systemctl or daemon", cfg.Power.OnShutdownFailure)
+	}
+	if cfg.Power.ShutdownRetries == 0 {
+		cfg.Power.ShutdownRetries = 3
+	}
+	if cfg.Power.WakeBeforeEventMinutes < 0 {
+		return nil, fmt.Errorf("invalid power.wake_before_event_minutes %d: must not be negative", cfg.Power.WakeBeforeEventMinutes)
+	}
+	if cfg.Lock.Policy == "" {
+		cfg.Lock.Policy = "skip"
+	}
+	if p := cfg.Lock.Policy; p != "skip" && p != "wait" && p != "takeover" {
+		return nil, fmt.Errorf("invalid lock.policy %q: must be skip, wait or takeover", p)
+	}
+	if cfg.Lock.StaleAfterSeconds == 0 {
+		cfg.Lock.StaleAfterSeconds = cfg.MaxRunSeconds + 120
 	}
 
 	if len(cfg.Calendar.Calendars) == 0 {
 		cfg.Calendar.Calendars = []CalendarSource{
-			{ID: "primary", Name: "Primary"},
+			{Type: SourceGoogle, ID: "primary", Name: "Primary"},
+		}
+	}
+	for i := range cfg.Calendar.Calendars {
+		src := &cfg.Calendar.Calendars[i]
+		if src.Type == "" {
+			src.Type = SourceGoogle
+		}
+		switch src.Type {
+		case SourceGoogle:
+		case SourceScript:
+			if len(src.Command) == 0 {
+				return nil, fmt.Errorf("calendar source %q: script sources need a command", src.Name)
+			}
+		case SourceSports:
+			if src.URL == "" && src.TeamID == 0 {
+				return nil, fmt.Errorf("calendar source %q: sports sources need a url or a team_id", src.Name)
+			}
+			if src.URL != "" && src.HomeOnly && src.Team == "" {
+				return nil, fmt.Errorf("calendar source %q: home_only with an ICS url needs the team name", src.Name)
+			}
+		default:
+			return nil, fmt.Errorf("calendar source %q: unknown type %q", src.Name, src.Type)
+		}
+		if c := src.Style.Color; c != "" && c != "black" && c != "red" && c != "grey" {
+			return nil, fmt.Errorf("calendar source %q: invalid style color %q: must be black, red or grey", src.Name, c)
 		}
 	}
 
 	return &cfg, nil
 }
+
+// TravelWarning returns TravelWarningMinutes as a time.Duration.
+func (c CalendarConfig) TravelWarning() time.Duration {
+	return time.Duration(c.TravelWarningMinutes) * time.Minute
+}
+
+// MaxRunDuration returns MaxRunSeconds as a time.Duration.
+func (c *Config) MaxRunDuration() time.Duration {
+	return time.Duration(c.MaxRunSeconds) * time.Second
+}
+
+func validCorner(corner string) bool {
+	switch corner {
+	case "top-left", "top-right", "bottom-left", "bottom-right":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a config file changed in place. Edits are spliced into the
+// original text rather than re-encoding the document, so comments, blank
+// lines, alignment and key order survive. It handles the block-style YAML
+// configs are written in; flow-style collections can't be extended.
+type File struct {
+	path  string
+	lines []string
+}
+
+// Field is a key and value of a mapping appended with File.Append.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// OpenFile reads the config file at path for editing. A missing file
+// starts out empty.
+func OpenFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f := &File{path: path}
+	if text := strings.TrimRight(string(data), "\n"); text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+	return f, nil
+}
+
+// Bytes returns the edited text.
+func (f *File) Bytes() []byte {
+	if len(f.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(f.lines, "\n") + "\n")
+}
+
+// Save writes the edited file once Load accepts it.
+func (f *File) Save() error {
+	return WriteFile(f.path, f.Bytes())
+}
+
+// Set sets the value at a dotted key such as "display.width", adding
+// missing keys at the end of their mapping.
+func (f *File) Set(key string, value any) error {
+	parent, indent, after, rest, err := f.walk(key)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		f.insert(after, nestedLines(indent, rest, formatValue(value)))
+		return nil
+	}
+	return f.replaceScalar(key, parent, formatValue(value))
+}
+
+// Append adds a mapping of fields to the sequence at a dotted key such as
+// "calendar.calendars", creating the sequence when it is missing.
+func (f *File) Append(key string, fields ...Field) error {
+	parent, indent, after, rest, err := f.walk(key)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		last := len(rest) - 1
+		f.insert(after, nestedLines(indent, rest, ""))
+		f.insert(after+len(rest), itemLines(indent+2*last+2, fields))
+		return nil
+	}
+
+	keyNode, seq := parent[0], parent[1]
+	switch {
+	case seq.Kind == yaml.SequenceNode && seq.Style&yaml.FlowStyle == 0:
+		f.insert(endLine(seq), itemLines(seq.Column-1, fields))
+	case isEmpty(seq):
+		// "key:", "key: ~" or "key: []" become a block sequence below.
+		if err := f.replaceValueText(keyNode, seq, ""); err != nil {
+			return err
+		}
+		f.insert(keyNode.Line, itemLines(keyNode.Column-1+2, fields))
+	default:
+		return fmt.Errorf("config %s: can't append to a %s", key, kindName(seq))
+	}
+	return nil
+}
+
+// walk finds the dotted key. When it exists, parent holds its key and value
+// nodes. Otherwise rest lists the missing keys, to be inserted as lines
+// indented by indent after line number after.
+func (f *File) walk(key string) (parent [2]*yaml.Node, indent, after int, rest []string, err error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(f.Bytes(), &doc); err != nil {
+		return parent, 0, 0, nil, fmt.Errorf("unable to parse config: %w", err)
+	}
+
+	parts := strings.Split(key, ".")
+	if len(doc.Content) == 0 {
+		return parent, 0, len(f.lines), parts, nil
+	}
+	m := doc.Content[0]
+	if isEmpty(m) {
+		return parent, 0, len(f.lines), parts, nil
+	}
+
+	for i, part := range parts {
+		if m.Kind != yaml.MappingNode || m.Style&yaml.FlowStyle != 0 {
+			return parent, 0, 0, nil, fmt.Errorf("config %s: %s is not a block mapping", key, strings.Join(parts[:i], "."))
+		}
+		k, v := lookup(m, part)
+		if k == nil {
+			return parent, m.Content[0].Column - 1, endLine(m), parts[i:], nil
+		}
+		parent = [2]*yaml.Node{k, v}
+		if i == len(parts)-1 {
+			break
+		}
+		if isEmpty(v) {
+			if err := f.replaceValueText(k, v, ""); err != nil {
+				return parent, 0, 0, nil, err
+			}
+			return parent, k.Column - 1 + 2, k.Line, parts[i+1:], nil
+		}
+		m = v
+	}
+	return parent, 0, 0, nil, nil
+}
+
+// replaceScalar replaces the value of parent, which must be a scalar or
+// empty.
+func (f *File) replaceScalar(key string, parent [2]*yaml.Node, value string) error {
+	keyNode, v := parent[0], parent[1]
+	if v.Kind != yaml.ScalarNode {
+		return fmt.Errorf("config %s: can't set a %s to a single value", key, kindName(v))
+	}
+	return f.replaceValueText(keyNode, v, value)
+}
+
+// replaceValueText replaces the text of the single-line value v of keyNode,
+// keeping a trailing comment in its column where possible.
+func (f *File) replaceValueText(keyNode, v *yaml.Node, value string) error {
+	if !isEmpty(v) && (v.Line != keyNode.Line || v.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0) {
+		return fmt.Errorf("config %s: only single-line values can be replaced", keyNode.Value)
+	}
+
+	line := []rune(f.lines[keyNode.Line-1])
+	start := keyNode.Column - 1
+	for start < len(line) && line[start] != ':' {
+		start++
+	}
+	start++
+	end := start
+	for end < len(line) && line[end] == ' ' {
+		end++
+	}
+	if end < len(line) && line[end] != '#' {
+		end = valueEnd(line, end, v)
+	}
+
+	prefix := string(line[:start])
+	rest := string(line[end:])
+	spaces := len(rest) - len(strings.TrimLeft(rest, " "))
+	comment := rest[spaces:]
+
+	text := prefix
+	if value != "" {
+		text += " " + value
+	}
+	if comment != "" {
+		// Keep the comment in its column when the new value fits.
+		pad := max(1, end+spaces-len([]rune(text)))
+		text += strings.Repeat(" ", pad) + comment
+	}
+	f.lines[keyNode.Line-1] = text
+	return nil
+}
+
+// valueEnd returns where the single-line value v starting at start ends.
+func valueEnd(line []rune, start int, v *yaml.Node) int {
+	switch {
+	case v.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				return i + 1
+			}
+		}
+	case v.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	case v.Kind == yaml.SequenceNode || v.Kind == yaml.MappingNode:
+		// Only empty flow collections ("[]", "{}") are replaced.
+		return start + 2
+	default:
+		end := len(line)
+		if i := strings.Index(string(line[start:]), " #"); i >= 0 {
+			end = start + len([]rune(string(line[start:])[:i]))
+		}
+		for end > start && line[end-1] == ' ' {
+			end--
+		}
+		return end
+	}
+	return len(line)
+}
+
+func (f *File) insert(after int, lines []string) {
+	f.lines = append(f.lines[:after], append(lines, f.lines[after:]...)...)
+}
+
+// nestedLines returns "a:\n  b: value" for keys a, b at indent.
+func nestedLines(indent int, keys []string, value string) []string {
+	lines := make([]string, 0, len(keys))
+	for i, k := range keys {
+		line := strings.Repeat(" ", indent+2*i) + k + ":"
+		if i == len(keys)-1 && value != "" {
+			line += " " + value
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// itemLines returns a sequence item of fields with its dash at indent.
+func itemLines(indent int, fields []Field) []string {
+	lines := make([]string, 0, len(fields))
+	for i, field := range fields {
+		marker := "  "
+		if i == 0 {
+			marker = "- "
+		}
+		lines = append(lines, strings.Repeat(" ", indent)+marker+field.Key+": "+formatValue(field.Value))
+	}
+	return lines
+}
+
+// formatValue writes strings double-quoted like the example config and
+// everything else as is.
+func formatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
+
+func lookup(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// endLine returns the last line of n and its descendants.
+func endLine(n *yaml.Node) int {
+	last := n.Line
+	for _, c := range n.Content {
+		last = max(last, endLine(c))
+	}
+	return last
+}
+
+// isEmpty reports a null value or an empty collection.
+func isEmpty(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Tag == "!!null"
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
+
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	}
+	return "value"
+}
+
+// WriteFile writes data next to path, checks it with Load and only then
+// moves it into place, so a bad edit never replaces a working config.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("unable to write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write config: %w", err)
+	}
+
+	if _, err := Load(tmp.Name()); err != nil {
+		return fmt.Errorf("resulting config is invalid: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// edit applies change to a config file holding before and returns the
+// edited text.
+func edit(t *testing.T, before string, change func(*File) error) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if before != "" {
+		if err := os.WriteFile(path, []byte(before), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := change(f); err != nil {
+		return "", err
+	}
+	return string(f.Bytes()), nil
+}
+
+func TestFileSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		key    string
+		value  any
+		after  string
+	}{
+		{
+			"keeps the comment column",
+			"display:\n  width: 800    # pixels\n  height: 480\n",
+			"display.width", 1024,
+			"display:\n  width: 1024   # pixels\n  height: 480\n",
+		},
+		{
+			"pushes a comment the value reaches",
+			"weather:\n  timezone: UTC # zone\n",
+			"weather.timezone", "Europe/Prague",
+			"weather:\n  timezone: \"Europe/Prague\" # zone\n",
+		},
+		{
+			"double-quoted value with an escaped quote and a hash",
+			"weather:\n  city: \"Prague \\\"#1\\\"\"  # home\n",
+			"weather.city", "Brno",
+			"weather:\n  city: \"Brno\"           # home\n",
+		},
+		{
+			"single-quoted value with a doubled quote",
+			"weather:\n  city: 'it''s # here' # home\n",
+			"weather.city", "Brno",
+			"weather:\n  city: \"Brno\"         # home\n",
+		},
+		{
+			"plain value with a hash inside",
+			"output:\n  path: cal#1.png # image\n",
+			"output.path", "out.png",
+			"output:\n  path: \"out.png\" # image\n",
+		},
+		{
+			"empty value",
+			"server:\n  port:\n  host: x\n",
+			"server.port", 8080,
+			"server:\n  port: 8080\n  host: x\n",
+		},
+		{
+			"missing key at the end of its mapping",
+			"display:\n  width: 800\n\n# Weather\nweather:\n  timezone: UTC\n",
+			"display.height", 480,
+			"display:\n  width: 800\n  height: 480\n\n# Weather\nweather:\n  timezone: UTC\n",
+		},
+		{
+			"missing nested mappings",
+			"display:\n  width: 800\n",
+			"output.kindle.host", "kindle.local",
+			"display:\n  width: 800\noutput:\n  kindle:\n    host: \"kindle.local\"\n",
+		},
+		{
+			"missing key under an existing mapping",
+			"output:\n    path: x.png\n",
+			"output.kindle.host", "kindle.local",
+			"output:\n    path: x.png\n    kindle:\n      host: \"kindle.local\"\n",
+		},
+		{
+			"null parent",
+			"server: ~  # off\n",
+			"server.port", 8080,
+			"server:    # off\n  port: 8080\n",
+		},
+		{
+			"empty file",
+			"",
+			"display.width", 800,
+			"display:\n  width: 800\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := edit(t, tt.before, func(f *File) error { return f.Set(tt.key, tt.value) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.after {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestFileSetErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		key    string
+		want   string
+	}{
+		{"mapping", "display:\n  width: 800\n", "display", "can't set a mapping"},
+		{"flow mapping", "display: {width: 800}\n", "display.width", "not a block mapping"},
+		{"multi-line value", "weather:\n  city: |\n    Prague\n", "weather.city", "only single-line values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := edit(t, tt.before, func(f *File) error { return f.Set(tt.key, 1) })
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileAppend(t *testing.T) {
+	fields := []Field{{Key: "id", Value: "work@example.com"}, {Key: "name", Value: "Work"}}
+	item := "    - id: \"work@example.com\"\n      name: \"Work\"\n"
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			"after the last item",
+			"calendar:\n  calendars:\n    - id: \"primary\"\n      name: \"Personal\"\n    # - id: \"more\"\n  hide: []\n",
+			"calendar:\n  calendars:\n    - id: \"primary\"\n      name: \"Personal\"\n" + item + "    # - id: \"more\"\n  hide: []\n",
+		},
+		{"empty", "calendar:\n  calendars:\n", "calendar:\n  calendars:\n" + item},
+		{"null", "calendar:\n  calendars: ~\n", "calendar:\n  calendars:\n" + item},
+		{"empty flow sequence", "calendar:\n  calendars: []  # none yet\n", "calendar:\n  calendars:     # none yet\n" + item},
+		{"missing sequence", "calendar:\n  hide: []\n", "calendar:\n  hide: []\n  calendars:\n" + item},
+		{"missing parent", "display:\n  width: 800\n", "display:\n  width: 800\ncalendar:\n  calendars:\n" + item},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := edit(t, tt.before, func(f *File) error { return f.Append("calendar.calendars", fields...) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.after {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.after)
+			}
+		})
+	}
+
+	_, err := edit(t, "calendar:\n  calendars: [a]\n", func(f *File) error { return f.Append("calendar.calendars", fields...) })
+	if err == nil || !strings.Contains(err.Error(), "can't append to a list") {
+		t.Errorf("appending to a flow sequence: %v", err)
+	}
+}
+
+// TestFileAppendExample adds a calendar to the example config: the item
+// lands after the configured one, ahead of the commented examples, and
+// nothing else changes.
+func TestFileAppendExample(t *testing.T) {
+	before, err := os.ReadFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := edit(t, string(before), func(f *File) error {
+		return f.Append("calendar.calendars", Field{Key: "id", Value: "work@example.com"}, Field{Key: "name", Value: "Work"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(got, "\n")
+	i := slices.Index(lines, `      name: "Personal"`)
+	if i < 0 || lines[i+1] != `    - id: "work@example.com"` || lines[i+2] != `      name: "Work"` {
+		t.Fatalf("the new calendar isn't after Personal:\n%s", strings.Join(lines[max(0, i-2):min(len(lines), i+5)], "\n"))
+	}
+	if rest := slices.Delete(slices.Clone(lines), i+1, i+3); strings.Join(rest, "\n") != string(before) {
+		t.Error("lines besides the new calendar changed")
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(got), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, src := range cfg.Calendar.Calendars {
+		ids = append(ids, src.ID)
+	}
+	if !slices.Equal(ids, []string{"primary", "work@example.com"}) {
+		t.Errorf("calendars %v, want [primary work@example.com]", ids)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the config schema version this build reads. Configs without a
+// version key predate versioning and are treated as version 0.
+const Version = 1
+
+// rename moves a dotted key, or a whole section, to a new place.
+type rename struct {
+	from, to string
+}
+
+// migration upgrades a config by one version.
+type migration struct {
+	renames []rename
+}
+
+// migrations[i] upgrades version i to i+1. Breaking layout changes append a
+// step here so older configs keep loading after an update.
+var migrations = []migration{
+	// 0 → 1: the version key was introduced; the layout is unchanged.
+	{},
+}
+
+// migrate upgrades the parsed config document to Version in place and
+// returns a deprecation warning for every key it had to move.
+func migrate(doc *yaml.Node) ([]string, error) {
+	return applyMigrations(doc, migrations[:Version])
+}
+
+// applyMigrations upgrades doc by steps, to version len(steps).
+func applyMigrations(doc *yaml.Node, steps []migration) ([]string, error) {
+	latest := len(steps)
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+
+	version := 0
+	if _, v := lookup(root, "version"); v != nil {
+		n, err := strconv.Atoi(v.Value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q: must be a whole number", v.Value)
+		}
+		version = n
+	}
+	if version > latest {
+		return nil, fmt.Errorf("config version %d is newer than this calvin supports (%d); update calvin", version, latest)
+	}
+
+	var warnings []string
+	for v := version; v < latest; v++ {
+		for _, r := range steps[v].renames {
+			moved, err := moveKey(root, r.from, r.to)
+			if err != nil {
+				return nil, err
+			}
+			if moved {
+				warnings = append(warnings, fmt.Sprintf("config key %s is deprecated since version %d; rename it to %s", r.from, v+1, r.to))
+			}
+		}
+	}
+	if len(warnings) > 0 {
+		warnings = append(warnings, fmt.Sprintf("set version: %d once the config is updated", latest))
+	}
+	return warnings, nil
+}
+
+// moveKey moves the dotted key from to the dotted key to, creating mappings
+// as needed. A key already at to wins over the old one.
+func moveKey(root *yaml.Node, from, to string) (bool, error) {
+	fromParts := strings.Split(from, ".")
+	parent := root
+	for _, part := range fromParts[:len(fromParts)-1] {
+		_, v := lookup(parent, part)
+		if v == nil || v.Kind != yaml.MappingNode {
+			return false, nil
+		}
+		parent = v
+	}
+	last := fromParts[len(fromParts)-1]
+	i := keyIndex(parent, last)
+	if i < 0 {
+		return false, nil
+	}
+	value := parent.Content[i+1]
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+
+	toParts := strings.Split(to, ".")
+	target := root
+	for _, part := range toParts[:len(toParts)-1] {
+		_, v := lookup(target, part)
+		if v == nil {
+			v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			target.Content = append(target.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, v)
+		}
+		if v.Kind != yaml.MappingNode {
+			return false, fmt.Errorf("unable to move config key %s: %s is not a mapping", from, part)
+		}
+		target = v
+	}
+	last = toParts[len(toParts)-1]
+	if keyIndex(target, last) < 0 {
+		target.Content = append(target.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: last}, value)
+	}
+	return true, nil
+}
+
+// keyIndex returns the index of key in the content of the mapping m, or -1.
+func keyIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
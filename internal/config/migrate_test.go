@@ -0,0 +1,126 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testMigrations stand in for a layout change: version 2 moves a key and a
+// whole section.
+var testMigrations = []migration{
+	{},
+	{renames: []rename{
+		{from: "output.svg_path", to: "output.svg"},
+		{from: "pisugar", to: "power.pisugar"},
+	}},
+}
+
+// migrateText parses text, migrates it by testMigrations and returns the
+// resulting document and warnings.
+func migrateText(t *testing.T, text string) (string, []string, error) {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := applyMigrations(&doc, testMigrations)
+	if err != nil {
+		return "", nil, err
+	}
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), warnings, nil
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		warnings []string
+	}{
+		{
+			name:   "moves a key and a section",
+			before: "version: 1\noutput:\n    svg_path: out.svg\npisugar:\n    enabled: true\n    host: pi\n",
+			after:  "version: 1\noutput:\n    svg: out.svg\npower:\n    pisugar:\n        enabled: true\n        host: pi\n",
+			warnings: []string{
+				"config key output.svg_path is deprecated since version 2; rename it to output.svg",
+				"config key pisugar is deprecated since version 2; rename it to power.pisugar",
+				"set version: 2 once the config is updated",
+			},
+		},
+		{
+			name:   "existing target wins",
+			before: "output:\n    svg_path: old.svg\n    svg: new.svg\npower:\n    mode: low\n",
+			after:  "output:\n    svg: new.svg\npower:\n    mode: low\n",
+			warnings: []string{
+				"config key output.svg_path is deprecated since version 2; rename it to output.svg",
+				"set version: 2 once the config is updated",
+			},
+		},
+		{
+			name:   "current version is left alone",
+			before: "version: 2\noutput:\n    svg_path: out.svg\n",
+			after:  "version: 2\noutput:\n    svg_path: out.svg\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := migrateText(t, tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.after {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.after)
+			}
+			if !slices.Equal(warnings, tt.warnings) {
+				t.Errorf("warnings %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		want   string
+	}{
+		{"target not a mapping", "pisugar:\n  enabled: true\npower: off\n", "unable to move config key pisugar: power is not a mapping"},
+		{"newer version", "version: 3\n", "config version 3 is newer than this calvin supports (2)"},
+		{"bad version", "version: two\n", `invalid version "two"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := migrateText(t, tt.before)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMigrateCurrent checks this build's migrations load an unversioned
+// config without warnings and refuse a newer one.
+func TestMigrateCurrent(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("display:\n  width: 800\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if warnings, err := migrate(&doc); err != nil || len(warnings) > 0 {
+		t.Errorf("migrate = %q, %v", warnings, err)
+	}
+
+	var newer yaml.Node
+	if err := yaml.Unmarshal([]byte("version: 99\n"), &newer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate(&newer); err == nil {
+		t.Error("a config newer than Version loaded")
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is prepended to every secret's environment variable name.
+const envPrefix = "CALVIN_"
+
+// resolveSecret returns a secret value looked up in this order:
+//
+//  1. environment variable CALVIN_<name>
+//  2. file referenced by environment variable CALVIN_<name>_FILE
+//  3. file referenced by the *_file key in config.yaml
+//  4. inline value from config.yaml
+//
+// This keeps config.yaml free of credentials so it can be committed safely.
+// Values read from files are trimmed of surrounding whitespace.
+func resolveSecret(name, inline, file string) (string, error) {
+	if value, ok := os.LookupEnv(envPrefix + name); ok {
+		return value, nil
+	}
+	if path := os.Getenv(envPrefix + name + "_FILE"); path != "" {
+		return readSecretFile(path)
+	}
+	if file != "" {
+		return readSecretFile(file)
+	}
+	return inline, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// FootballDataTokenValue returns the football-data.org API key, resolved
+// from CALVIN_FOOTBALL_DATA_TOKEN, CALVIN_FOOTBALL_DATA_TOKEN_FILE,
+// calendar.football_data_token_file or calendar.football_data_token.
+func (c CalendarConfig) FootballDataTokenValue() (string, error) {
+	return resolveSecret("FOOTBALL_DATA_TOKEN", c.FootballDataToken, c.FootballDataTokenFile)
+}
+
+// CredentialsJSON returns the Google OAuth client credentials, resolved from
+// CALVIN_CALENDAR_CREDENTIALS, CALVIN_CALENDAR_CREDENTIALS_FILE,
+// calendar.credentials_file or calendar.credentials.
+func (c CalendarConfig) CredentialsJSON() ([]byte, error) {
+	value, err := resolveSecret("CALENDAR_CREDENTIALS", c.Credentials, c.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, fmt.Errorf("no calendar credentials configured")
+	}
+	return []byte(value), nil
+}
@@ -0,0 +1,160 @@
+// Package gpio reads push buttons and drives control lines through the Linux
+// sysfs GPIO interface.
+package gpio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const sysfsRoot = "/sys/class/gpio"
+
+// pollInterval is how often button levels are sampled.
+const pollInterval = 10 * time.Millisecond
+
+// Button is a momentary push button wired to a GPIO pin.
+type Button struct {
+	Pin int
+	// ActiveLow means the pin reads 0 while pressed (button to ground with
+	// a pull-up), which is the usual Raspberry Pi wiring.
+	ActiveLow bool
+}
+
+// Watch samples the buttons until ctx is cancelled and calls onPress with the
+// button's index once per press. A level change must be stable for debounce
+// before it counts.
+func Watch(ctx context.Context, buttons []Button, debounce time.Duration, onPress func(index int)) error {
+	states := make([]*buttonState, 0, len(buttons))
+	for _, b := range buttons {
+		valuePath, err := export(b.Pin, "in")
+		if err != nil {
+			return err
+		}
+		states = append(states, &buttonState{button: b, valuePath: valuePath})
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for i, st := range states {
+				pressed, err := st.read()
+				if err != nil {
+					return err
+				}
+				if st.update(pressed, now, debounce) {
+					onPress(i)
+				}
+			}
+		}
+	}
+}
+
+type buttonState struct {
+	button    Button
+	valuePath string
+
+	stable    bool
+	candidate bool
+	since     time.Time
+}
+
+func (s *buttonState) read() (bool, error) {
+	data, err := os.ReadFile(s.valuePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read GPIO %d: %w", s.button.Pin, err)
+	}
+	high := len(bytes.TrimSpace(data)) > 0 && data[0] == '1'
+	return high != s.button.ActiveLow, nil
+}
+
+// update feeds a raw sample into the debouncer and reports whether it
+// completed a press (a stable transition to pressed).
+func (s *buttonState) update(pressed bool, now time.Time, debounce time.Duration) bool {
+	if pressed != s.candidate {
+		s.candidate = pressed
+		s.since = now
+		return false
+	}
+	if s.candidate == s.stable || now.Sub(s.since) < debounce {
+		return false
+	}
+	s.stable = s.candidate
+	return s.stable
+}
+
+// Pin is a single GPIO line, e.g. a display controller's busy or reset
+// line.
+type Pin struct {
+	pin       int
+	valuePath string
+}
+
+// Input configures pin as an input.
+func Input(pin int) (*Pin, error) {
+	valuePath, err := export(pin, "in")
+	if err != nil {
+		return nil, err
+	}
+	return &Pin{pin: pin, valuePath: valuePath}, nil
+}
+
+// Output configures pin as an output.
+func Output(pin int) (*Pin, error) {
+	valuePath, err := export(pin, "out")
+	if err != nil {
+		return nil, err
+	}
+	return &Pin{pin: pin, valuePath: valuePath}, nil
+}
+
+// Read reports whether the pin is high.
+func (p *Pin) Read() (bool, error) {
+	data, err := os.ReadFile(p.valuePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read GPIO %d: %w", p.pin, err)
+	}
+	return len(bytes.TrimSpace(data)) > 0 && data[0] == '1', nil
+}
+
+// Write drives the pin high or low.
+func (p *Pin) Write(high bool) error {
+	value := []byte("0")
+	if high {
+		value = []byte("1")
+	}
+	if err := os.WriteFile(p.valuePath, value, 0200); err != nil {
+		return fmt.Errorf("failed to write GPIO %d: %w", p.pin, err)
+	}
+	return nil
+}
+
+// export makes pin available in direction ("in" or "out") and returns its
+// value file path.
+func export(pin int, direction string) (string, error) {
+	pinDir := filepath.Join(sysfsRoot, "gpio"+strconv.Itoa(pin))
+
+	if _, err := os.Stat(pinDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(filepath.Join(sysfsRoot, "export"), []byte(strconv.Itoa(pin)), 0200); err != nil {
+			return "", fmt.Errorf("failed to export GPIO %d: %w", pin, err)
+		}
+		// udev needs a moment to fix permissions of the new pin directory.
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := os.WriteFile(filepath.Join(pinDir, "direction"), []byte(direction), 0200); err != nil {
+		return "", fmt.Errorf("failed to configure GPIO %d as %sput: %w", pin, direction, err)
+	}
+
+	return filepath.Join(pinDir, "value"), nil
+}
@@ -0,0 +1,111 @@
+// Package httpclient provides the HTTP client shared by all fetchers, so a
+// proxy, a custom CA bundle, the timeout and the user agent are configured
+// in one place.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/version"
+)
+
+// Settings configure the shared client.
+type Settings struct {
+	// Proxy is an http(s):// or socks5:// proxy URL. Empty uses the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// Timeout replaces the fetchers' own request timeouts when set.
+	Timeout time.Duration
+	// UserAgent is sent with every request; defaults to calvin/<version>.
+	UserAgent string
+}
+
+var (
+	mu        sync.RWMutex
+	transport http.RoundTripper = userAgentTransport{base: http.DefaultTransport, agent: defaultUserAgent()}
+	timeout   time.Duration
+)
+
+// Configure applies s to all clients created afterwards.
+func Configure(s Settings) error {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	if s.Proxy != "" {
+		proxyURL, err := url.Parse(s.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", s.CAFile)
+		}
+		base.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	agent := s.UserAgent
+	if agent == "" {
+		agent = defaultUserAgent()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	transport = userAgentTransport{base: base, agent: agent}
+	timeout = s.Timeout
+	return nil
+}
+
+// New returns a client using the shared transport. defaultTimeout applies
+// unless a timeout was configured.
+func New(defaultTimeout time.Duration) *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	if timeout > 0 {
+		defaultTimeout = timeout
+	}
+	return &http.Client{Transport: transport, Timeout: defaultTimeout}
+}
+
+// Transport returns the shared transport for clients that need their own
+// timeout, such as long downloads.
+func Transport() http.RoundTripper {
+	mu.RLock()
+	defer mu.RUnlock()
+	return transport
+}
+
+func defaultUserAgent() string {
+	return "calvin/" + version.Version
+}
+
+type userAgentTransport struct {
+	base  http.RoundTripper
+	agent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.agent)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,128 @@
+// Package install generates service definitions for running Calvin.
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Systemd modes.
+const (
+	// ModeOneshot renders once per boot, then sets the PiSugar alarm and
+	// shuts down. This is the battery-powered Pi Zero workflow.
+	ModeOneshot = "oneshot"
+	// ModeTimer renders on a schedule on an always-on machine.
+	ModeTimer = "timer"
+	// ModeDaemon keeps Calvin running with --daemon.
+	ModeDaemon = "daemon"
+)
+
+// SystemdOptions parameterize the generated units.
+type SystemdOptions struct {
+	Mode       string
+	User       string
+	Group      string
+	Executable string
+	ConfigPath string
+	WorkingDir string
+	// OnCalendar is the timer schedule in ModeTimer, e.g. "hourly".
+	OnCalendar string
+}
+
+// Unit is a generated unit file.
+type Unit struct {
+	Name    string
+	Content string
+}
+
+var serviceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description=Calvin E-Ink Calendar Generator
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+{{- if eq .Mode "daemon"}}
+Type=simple
+Restart=on-failure
+RestartSec=30
+{{- else}}
+Type=oneshot
+{{- end}}
+User={{.User}}
+Group={{.Group}}
+WorkingDirectory={{.WorkingDir}}
+# Secrets such as CALVIN_CALENDAR_CREDENTIALS_FILE can be set here
+EnvironmentFile=-{{.WorkingDir}}/calvin.env
+ExecStart={{.Executable}} -config {{.ConfigPath}}{{if eq .Mode "timer"}} -no-shutdown{{end}}{{if eq .Mode "daemon"}} -daemon{{end}}
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=calvin
+{{- if ne .Mode "timer"}}
+
+[Install]
+WantedBy=multi-user.target
+{{- end}}
+`))
+
+var timerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description=Render Calvin E-Ink Calendar periodically
+
+[Timer]
+OnCalendar={{.OnCalendar}}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`))
+
+// SystemdUnits returns the units for opts: calvin.service, plus
+// calvin.timer in ModeTimer.
+func SystemdUnits(opts SystemdOptions) ([]Unit, error) {
+	switch opts.Mode {
+	case ModeOneshot, ModeTimer, ModeDaemon:
+	default:
+		return nil, fmt.Errorf("unknown systemd mode %q: must be %s, %s or %s", opts.Mode, ModeOneshot, ModeTimer, ModeDaemon)
+	}
+
+	units := []Unit{}
+
+	service, err := execute(serviceTemplate, opts)
+	if err != nil {
+		return nil, err
+	}
+	units = append(units, Unit{Name: "calvin.service", Content: service})
+
+	if opts.Mode == ModeTimer {
+		timer, err := execute(timerTemplate, opts)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, Unit{Name: "calvin.timer", Content: timer})
+	}
+
+	return units, nil
+}
+
+// WriteUnits writes units into dir and returns the written paths.
+func WriteUnits(dir string, units []Unit) ([]string, error) {
+	paths := make([]string, 0, len(units))
+	for _, u := range units {
+		path := filepath.Join(dir, u.Name)
+		if err := os.WriteFile(path, []byte(u.Content), 0644); err != nil {
+			return paths, fmt.Errorf("unable to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func execute(t *template.Template, opts SystemdOptions) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, opts); err != nil {
+		return "", fmt.Errorf("unable to render %s unit: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
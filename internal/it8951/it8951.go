@@ -0,0 +1,363 @@
+// Package it8951 drives e-paper panels through an IT8951 controller board
+// (e.g. Waveshare's HAT for 6" to 13.3" panels) over SPI.
+package it8951
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/gpio"
+)
+
+// spiMaxTransfer is the spidev driver's default buffer size; larger
+// transfers fail unless the module's bufsiz parameter is raised.
+const spiMaxTransfer = 4096
+
+// SPI preambles that start every transaction.
+const (
+	preambleCommand   = 0x6000
+	preambleWriteData = 0x0000
+	preambleReadData  = 0x1000
+)
+
+// Controller commands.
+const (
+	cmdSysRun     = 0x0001
+	cmdSleep      = 0x0003
+	cmdRegRead    = 0x0010
+	cmdRegWrite   = 0x0011
+	cmdLoadArea   = 0x0021
+	cmdLoadEnd    = 0x0022
+	cmdVCOM       = 0x0039
+	cmdDisplay    = 0x0034
+	cmdDeviceInfo = 0x0302
+)
+
+// Controller registers.
+const (
+	regI80CPCR = 0x0004 // packed write enable
+	regLISAR   = 0x0208 // image buffer address
+	regLUTAFSR = 0x1224 // display engine status, non-zero while busy
+)
+
+// Mode is a refresh waveform. Indices are those of the 10.3" and 13.3"
+// panels; other panels may number A2 differently.
+type Mode uint16
+
+const (
+	// ModeInit clears the panel to white with a long flashing refresh.
+	ModeInit Mode = 0
+	// ModeDU is a fast, non-flashing black/white update.
+	ModeDU Mode = 1
+	// ModeGC16 is a full-quality 16-grey refresh with flashing.
+	ModeGC16 Mode = 2
+	// ModeGL16 is a 16-grey refresh with less flashing on white
+	// backgrounds.
+	ModeGL16 Mode = 3
+	// ModeA2 is the fastest black/white-only update; it ghosts over time.
+	ModeA2 Mode = 6
+)
+
+// readyTimeout bounds waiting for the controller's ready line and display
+// engine; a GC16 refresh of a 13.3" panel takes a few seconds.
+const readyTimeout = 30 * time.Second
+
+// Config describes how the controller board is wired.
+type Config struct {
+	// Device is the spidev node, e.g. /dev/spidev0.0.
+	Device  string
+	SpeedHz int
+	// ReadyPin is the HRDY line, high while the controller accepts a
+	// transaction; ResetPin resets the controller when pulled low.
+	ReadyPin int
+	ResetPin int
+	// VCOM is the panel's common voltage printed on its flex cable, e.g.
+	// -1.48.
+	VCOM float64
+}
+
+// Display is an open IT8951 controller.
+type Display struct {
+	spi     *spiDevice
+	ready   *gpio.Pin
+	width   int
+	height  int
+	bufAddr uint32
+}
+
+// Open resets the controller, wakes it and sets the panel's VCOM.
+func Open(cfg Config) (*Display, error) {
+	ready, err := gpio.Input(cfg.ReadyPin)
+	if err != nil {
+		return nil, err
+	}
+	reset, err := gpio.Output(cfg.ResetPin)
+	if err != nil {
+		return nil, err
+	}
+
+	spi, err := openSPI(cfg.Device, cfg.SpeedHz)
+	if err != nil {
+		return nil, err
+	}
+	d := &Display{spi: spi, ready: ready}
+
+	if err := d.init(reset, cfg.VCOM); err != nil {
+		spi.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Display) init(reset *gpio.Pin, vcom float64) error {
+	if err := reset.Write(false); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := reset.Write(true); err != nil {
+		return err
+	}
+
+	if err := d.command(cmdSysRun); err != nil {
+		return fmt.Errorf("unable to wake controller: %w", err)
+	}
+
+	if err := d.command(cmdDeviceInfo); err != nil {
+		return fmt.Errorf("unable to query controller: %w", err)
+	}
+	info, err := d.readData(20)
+	if err != nil {
+		return fmt.Errorf("unable to query controller: %w", err)
+	}
+	d.width, d.height = int(info[0]), int(info[1])
+	d.bufAddr = uint32(info[2]) | uint32(info[3])<<16
+	if d.width == 0 || d.height == 0 {
+		return fmt.Errorf("controller reported an invalid panel size %dx%d", d.width, d.height)
+	}
+
+	if err := d.writeRegister(regI80CPCR, 1); err != nil {
+		return err
+	}
+
+	mV := uint16(-vcom*1000 + 0.5)
+	if err := d.command(cmdVCOM, 1, mV); err != nil {
+		return fmt.Errorf("unable to set VCOM: %w", err)
+	}
+	return nil
+}
+
+// Size returns the panel resolution reported by the controller.
+func (d *Display) Size() (int, int) {
+	return d.width, d.height
+}
+
+// Show uploads the area of img (at the panel's origin) and refreshes that
+// area with mode. An empty area refreshes the whole image. The area is
+// widened to whole 4-pixel words as the 4 bpp transfer requires.
+func (d *Display) Show(img image.Image, area image.Rectangle, mode Mode) error {
+	bounds := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+	if bounds.Dx() > d.width || bounds.Dy() > d.height {
+		return fmt.Errorf("image %dx%d is larger than the %dx%d panel", bounds.Dx(), bounds.Dy(), d.width, d.height)
+	}
+	if area.Empty() {
+		area = bounds
+	}
+	area.Min.X &^= 3
+	area.Max.X = (area.Max.X + 3) &^ 3
+	area = area.Intersect(image.Rect(0, 0, d.width, d.height))
+
+	if err := d.waitDisplay(); err != nil {
+		return err
+	}
+
+	if err := d.writeRegister(regLISAR+2, uint16(d.bufAddr>>16)); err != nil {
+		return err
+	}
+	if err := d.writeRegister(regLISAR, uint16(d.bufAddr)); err != nil {
+		return err
+	}
+
+	// Little endian, 4 bpp, no rotation.
+	const loadArgs = 0<<8 | 2<<4 | 0
+	if err := d.command(cmdLoadArea, loadArgs, uint16(area.Min.X), uint16(area.Min.Y), uint16(area.Dx()), uint16(area.Dy())); err != nil {
+		return fmt.Errorf("unable to start image upload: %w", err)
+	}
+	if err := d.writePixels(img, area); err != nil {
+		return fmt.Errorf("unable to upload image: %w", err)
+	}
+	if err := d.command(cmdLoadEnd); err != nil {
+		return fmt.Errorf("unable to finish image upload: %w", err)
+	}
+
+	if err := d.command(cmdDisplay, uint16(area.Min.X), uint16(area.Min.Y), uint16(area.Dx()), uint16(area.Dy()), uint16(mode)); err != nil {
+		return fmt.Errorf("unable to refresh panel: %w", err)
+	}
+	return d.waitDisplay()
+}
+
+// Close puts the controller to sleep and releases the SPI device.
+func (d *Display) Close() error {
+	sleepErr := d.command(cmdSleep)
+	if err := d.spi.Close(); err != nil {
+		return err
+	}
+	return sleepErr
+}
+
+// writePixels sends the area as 4 bpp greys, four pixels per 16-bit word
+// with the first pixel in the lowest nibble. Pixels outside img are white.
+func (d *Display) writePixels(img image.Image, area image.Rectangle) error {
+	origin := img.Bounds().Min
+	bounds := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+
+	wordsPerChunk := spiMaxTransfer/2 - 1
+	words := make([]uint16, 0, wordsPerChunk)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x += 4 {
+			var word uint16
+			for i := 0; i < 4; i++ {
+				level := uint16(0xf)
+				if p := image.Pt(x+i, y); p.In(bounds) {
+					grey := color.GrayModel.Convert(img.At(origin.X+p.X, origin.Y+p.Y)).(color.Gray)
+					level = uint16(grey.Y >> 4)
+				}
+				word |= level << (4 * i)
+			}
+
+			words = append(words, word)
+			if len(words) == wordsPerChunk {
+				if err := d.writeData(words...); err != nil {
+					return err
+				}
+				words = words[:0]
+			}
+		}
+	}
+	return d.writeData(words...)
+}
+
+func (d *Display) writeRegister(reg, value uint16) error {
+	if err := d.command(cmdRegWrite, reg, value); err != nil {
+		return fmt.Errorf("unable to write register 0x%04x: %w", reg, err)
+	}
+	return nil
+}
+
+func (d *Display) readRegister(reg uint16) (uint16, error) {
+	if err := d.command(cmdRegRead, reg); err != nil {
+		return 0, err
+	}
+	value, err := d.readData(1)
+	if err != nil {
+		return 0, err
+	}
+	return value[0], nil
+}
+
+// waitDisplay waits until the display engine has finished refreshing.
+func (d *Display) waitDisplay() error {
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		status, err := d.readRegister(regLUTAFSR)
+		if err != nil {
+			return fmt.Errorf("unable to read display status: %w", err)
+		}
+		if status == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("display still busy after %s", readyTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// command sends cmd followed by its arguments.
+func (d *Display) command(cmd uint16, args ...uint16) error {
+	if err := d.transaction(preambleCommand, []uint16{cmd}, nil); err != nil {
+		return err
+	}
+	return d.writeData(args...)
+}
+
+func (d *Display) writeData(words ...uint16) error {
+	if len(words) == 0 {
+		return nil
+	}
+	return d.transaction(preambleWriteData, words, nil)
+}
+
+// readData reads n words. The controller sends a dummy word first.
+func (d *Display) readData(n int) ([]uint16, error) {
+	rx := make([]uint16, n+1)
+	if err := d.transaction(preambleReadData, make([]uint16, n+1), rx); err != nil {
+		return nil, err
+	}
+	return rx[1:], nil
+}
+
+// transaction waits for the ready line and exchanges a preamble and words,
+// big endian, in one chip select cycle. rx receives the words clocked in
+// after the preamble.
+func (d *Display) transaction(preamble uint16, words []uint16, rx []uint16) error {
+	if err := d.waitReady(); err != nil {
+		return err
+	}
+
+	tx := make([]byte, 2+2*len(words))
+	binary.BigEndian.PutUint16(tx, preamble)
+	for i, w := range words {
+		binary.BigEndian.PutUint16(tx[2+2*i:], w)
+	}
+
+	var rxBytes []byte
+	if rx != nil {
+		rxBytes = make([]byte, len(tx))
+	}
+	if err := d.spi.transfer(tx, rxBytes); err != nil {
+		return err
+	}
+	for i := range rx {
+		rx[i] = binary.BigEndian.Uint16(rxBytes[2+2*i:])
+	}
+	return nil
+}
+
+// waitReady waits for the HRDY line to go high.
+func (d *Display) waitReady() error {
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		ready, err := d.ready.Read()
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("controller not ready after %s", readyTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Changed returns the smallest rectangle containing every pixel that
+// differs between prev and next, which must be the same size. It is empty
+// when the images are identical.
+func Changed(prev, next image.Image) image.Rectangle {
+	pb, nb := prev.Bounds(), next.Bounds()
+	var changed image.Rectangle
+	for y := 0; y < nb.Dy(); y++ {
+		for x := 0; x < nb.Dx(); x++ {
+			r1, g1, b1, _ := prev.At(pb.Min.X+x, pb.Min.Y+y).RGBA()
+			r2, g2, b2, _ := next.At(nb.Min.X+x, nb.Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed
+}
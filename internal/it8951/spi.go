@@ -0,0 +1,99 @@
+//go:build linux
+
+package it8951
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// spidev ioctl requests from linux/spi/spidev.h.
+const (
+	spiIOCWrMode        = 0x40016b01
+	spiIOCWrBitsPerWord = 0x40016b03
+	spiIOCWrMaxSpeedHz  = 0x40046b04
+	spiIOCMessage1      = 0x40206b00
+)
+
+// spiIOCTransfer mirrors struct spi_ioc_transfer.
+type spiIOCTransfer struct {
+	txBuf          uint64
+	rxBuf          uint64
+	length         uint32
+	speedHz        uint32
+	delayUsecs     uint16
+	bitsPerWord    uint8
+	csChange       uint8
+	txNbits        uint8
+	rxNbits        uint8
+	wordDelayUsecs uint8
+	pad            uint8
+}
+
+type spiDevice struct {
+	f       *os.File
+	speedHz uint32
+}
+
+func openSPI(path string, speedHz int) (*spiDevice, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open SPI device: %w", err)
+	}
+
+	mode := uint8(0)
+	bits := uint8(8)
+	speed := uint32(speedHz)
+	for _, setting := range []struct {
+		req uintptr
+		arg unsafe.Pointer
+	}{
+		{spiIOCWrMode, unsafe.Pointer(&mode)},
+		{spiIOCWrBitsPerWord, unsafe.Pointer(&bits)},
+		{spiIOCWrMaxSpeedHz, unsafe.Pointer(&speed)},
+	} {
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), setting.req, uintptr(setting.arg)); errno != 0 {
+			f.Close()
+			return nil, fmt.Errorf("unable to configure SPI device %s: %w", path, errno)
+		}
+	}
+
+	return &spiDevice{f: f, speedHz: speed}, nil
+}
+
+// transfer clocks tx out while reading the same number of bytes into rx,
+// which may be nil, with chip select held for the whole transfer.
+func (s *spiDevice) transfer(tx, rx []byte) error {
+	if len(tx) == 0 {
+		return nil
+	}
+	if len(tx) > spiMaxTransfer {
+		return fmt.Errorf("SPI transfer of %d bytes exceeds %d", len(tx), spiMaxTransfer)
+	}
+
+	tr := spiIOCTransfer{
+		txBuf:       uint64(uintptr(unsafe.Pointer(&tx[0]))),
+		length:      uint32(len(tx)),
+		speedHz:     s.speedHz,
+		bitsPerWord: 8,
+	}
+	if rx != nil {
+		tr.rxBuf = uint64(uintptr(unsafe.Pointer(&rx[0])))
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, s.f.Fd(), spiIOCMessage1, uintptr(unsafe.Pointer(&tr)))
+	runtime.KeepAlive(tx)
+	runtime.KeepAlive(rx)
+	if errno != 0 {
+		return fmt.Errorf("SPI transfer failed: %w", errno)
+	}
+	return nil
+}
+
+func (s *spiDevice) Close() error {
+	return s.f.Close()
+}
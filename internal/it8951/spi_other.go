@@ -0,0 +1,19 @@
+//go:build !linux
+
+package it8951
+
+import "errors"
+
+type spiDevice struct{}
+
+func openSPI(path string, speedHz int) (*spiDevice, error) {
+	return nil, errors.New("SPI displays are only supported on Linux")
+}
+
+func (s *spiDevice) transfer(tx, rx []byte) error {
+	return errors.New("SPI displays are only supported on Linux")
+}
+
+func (s *spiDevice) Close() error {
+	return nil
+}
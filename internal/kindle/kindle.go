@@ -0,0 +1,143 @@
+// Package kindle publishes the rendered image to a jailbroken Kindle over
+// SSH and shows it with eips.
+package kindle
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/paveljanda/calvin/internal/render"
+	"golang.org/x/image/draw"
+)
+
+// Config describes how to reach the Kindle and the panel it drives.
+type Config struct {
+	Host         string
+	User         string
+	Port         int
+	IdentityFile string
+	// Path is where the image is stored on the Kindle.
+	Path string
+	// Width and Height are the panel size in its native (portrait)
+	// orientation; a landscape image is rotated to fit.
+	Width  int
+	Height int
+	// Dither diffuses the error when reducing to the panel's 16 greys.
+	Dither bool
+	// Command runs on the Kindle after the upload to show the image.
+	Command string
+}
+
+// Publish converts the PNG at src to an 8-bit greyscale image for the
+// panel, copies it with scp and runs the refresh command over ssh.
+func Publish(ctx context.Context, cfg Config, src string) error {
+	img, err := decode(src)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "calvin-kindle-*.png")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary image: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	err = png.Encode(tmp, prepare(img, cfg))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write Kindle image: %w", err)
+	}
+
+	target := cfg.User + "@" + cfg.Host
+	scp := append([]string{"-P", strconv.Itoa(cfg.Port)}, sshOptions(cfg)...)
+	scp = append(scp, tmp.Name(), target+":"+cfg.Path)
+	if output, err := exec.CommandContext(ctx, "scp", scp...).CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to copy image to Kindle: %w (output: %s)", err, output)
+	}
+
+	ssh := append([]string{"-p", strconv.Itoa(cfg.Port)}, sshOptions(cfg)...)
+	ssh = append(ssh, target, cfg.Command)
+	if output, err := exec.CommandContext(ctx, "ssh", ssh...).CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to refresh Kindle screen: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// Check runs a no-op command on the Kindle to verify SSH access.
+func Check(ctx context.Context, cfg Config) error {
+	ssh := append([]string{"-p", strconv.Itoa(cfg.Port)}, sshOptions(cfg)...)
+	ssh = append(ssh, cfg.User+"@"+cfg.Host, "true")
+	if output, err := exec.CommandContext(ctx, "ssh", ssh...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// sshOptions never prompt, so a missing key fails instead of hanging the
+// run.
+func sshOptions(cfg Config) []string {
+	opts := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if cfg.IdentityFile != "" {
+		opts = append(opts, "-i", cfg.IdentityFile)
+	}
+	return opts
+}
+
+func decode(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open image: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// prepare rotates img to the panel's orientation, scales it to fit and
+// reduces it to 16 grey levels.
+func prepare(img image.Image, cfg Config) *image.Gray {
+	b := img.Bounds()
+	if (b.Dx() > b.Dy()) != (cfg.Width > cfg.Height) {
+		img = rotate(img)
+	}
+	img = render.ScaleToFit(img, cfg.Width, cfg.Height)
+
+	levels := make(color.Palette, 16)
+	for i := range levels {
+		levels[i] = color.Gray{Y: uint8(i * 17)}
+	}
+	reduced := image.NewPaletted(img.Bounds(), levels)
+	if cfg.Dither {
+		draw.FloydSteinberg.Draw(reduced, reduced.Bounds(), img, image.Point{})
+	} else {
+		draw.Draw(reduced, reduced.Bounds(), img, image.Point{}, draw.Src)
+	}
+
+	// The Kindle's image loader wants plain greyscale, not a palette.
+	grey := image.NewGray(reduced.Bounds())
+	draw.Draw(grey, grey.Bounds(), reduced, image.Point{}, draw.Src)
+	return grey
+}
+
+// rotate turns img 90 degrees clockwise.
+func rotate(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.Y-1-y, x-b.Min.X, img.At(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+// Package locale formats times, dates and numbers by regional convention.
+// Month and weekday names stay English; only separators, ordering and the
+// clock differ.
+package locale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locale holds the conventions of one region. The zero value is the
+// original layout: 24-hour times, "2 January" and ISO dates.
+type Locale struct {
+	// Hour12 shows times as "2:30 PM".
+	Hour12 bool `json:"hour12"`
+	// DecimalComma writes "0,5" instead of "0.5".
+	DecimalComma bool `json:"decimal_comma"`
+	// DayMonth is the layout of a day and month name, e.g. "2 January",
+	// "January 2" or "2. January".
+	DayMonth string `json:"day_month"`
+	// Date is the layout of a numeric date, e.g. "02.01.2006".
+	Date string `json:"date"`
+}
+
+var locales = map[string]Locale{
+	"en-us": {Hour12: true, DayMonth: "January 2", Date: "1/2/2006"},
+	"en-ca": {Hour12: true, DayMonth: "January 2", Date: "2006-01-02"},
+	"en-au": {Hour12: true, DayMonth: "2 January", Date: "2/01/2006"},
+	"en-gb": {DayMonth: "2 January", Date: "02/01/2006"},
+	"en-ie": {DayMonth: "2 January", Date: "02/01/2006"},
+	"cs-cz": {DecimalComma: true, DayMonth: "2. January", Date: "2. 1. 2006"},
+	"sk-sk": {DecimalComma: true, DayMonth: "2. January", Date: "2. 1. 2006"},
+	"de-de": {DecimalComma: true, DayMonth: "2. January", Date: "02.01.2006"},
+	"de-at": {DecimalComma: true, DayMonth: "2. January", Date: "02.01.2006"},
+	"de-ch": {DayMonth: "2. January", Date: "02.01.2006"},
+	"pl-pl": {DecimalComma: true, DayMonth: "2 January", Date: "02.01.2006"},
+	"fr-fr": {DecimalComma: true, DayMonth: "2 January", Date: "02/01/2006"},
+	"es-es": {DecimalComma: true, DayMonth: "2 January", Date: "02/01/2006"},
+	"it-it": {DecimalComma: true, DayMonth: "2 January", Date: "02/01/2006"},
+	"pt-pt": {DecimalComma: true, DayMonth: "2 January", Date: "02/01/2006"},
+	"pt-br": {DecimalComma: true, DayMonth: "2 January", Date: "02/01/2006"},
+	"nl-nl": {DecimalComma: true, DayMonth: "2 January", Date: "2-1-2006"},
+	"da-dk": {DecimalComma: true, DayMonth: "2. January", Date: "02.01.2006"},
+	"nb-no": {DecimalComma: true, DayMonth: "2. January", Date: "02.01.2006"},
+	"sv-se": {DecimalComma: true, DayMonth: "2 January", Date: "2006-01-02"},
+	"fi-fi": {DecimalComma: true, DayMonth: "2. January", Date: "2.1.2006"},
+	"hu-hu": {DecimalComma: true, DayMonth: "January 2.", Date: "2006. 01. 02."},
+}
+
+// Parse returns the locale named like "cs-CZ" or "en_US". A bare language
+// such as "de" selects its main region. An empty name is the zero Locale.
+func Parse(name string) (Locale, error) {
+	if name == "" {
+		return Locale{}, nil
+	}
+
+	key := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if l, ok := locales[key]; ok {
+		return l.withDefaults(), nil
+	}
+	if !strings.Contains(key, "-") {
+		if l, ok := locales[key+"-"+key]; ok {
+			return l.withDefaults(), nil
+		}
+		for _, region := range []string{"us", "cz", "se", "dk", "no"} {
+			if l, ok := locales[key+"-"+region]; ok {
+				return l.withDefaults(), nil
+			}
+		}
+	}
+	return Locale{}, fmt.Errorf("unknown locale %q", name)
+}
+
+func (l Locale) withDefaults() Locale {
+	if l.DayMonth == "" {
+		l.DayMonth = "2 January"
+	}
+	if l.Date == "" {
+		l.Date = "2006-01-02"
+	}
+	return l
+}
+
+// Time formats the time of day, "14:30" or "2:30 PM".
+func (l Locale) Time(t time.Time) string {
+	if l.Hour12 {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("15:04")
+}
+
+// TimeRange formats "14:30–15:30", or "2:30–3:30 PM" when both ends share
+// the half of the day.
+func (l Locale) TimeRange(start, end time.Time) string {
+	if l.Hour12 && (start.Hour() < 12) == (end.Hour() < 12) {
+		return start.Format("3:04") + "–" + end.Format("3:04 PM")
+	}
+	return l.Time(start) + "–" + l.Time(end)
+}
+
+// FormatDayMonth formats a day with its month name, e.g. "2 January".
+func (l Locale) FormatDayMonth(t time.Time) string {
+	return t.Format(l.withDefaults().DayMonth)
+}
+
+// FormatDateTime formats a numeric date with the time including seconds.
+func (l Locale) FormatDateTime(t time.Time) string {
+	clock := t.Format("15:04:05")
+	if l.Hour12 {
+		clock = t.Format("3:04:05 PM")
+	}
+	return t.Format(l.withDefaults().Date) + " " + clock
+}
+
+// Decimal replaces the decimal point of a formatted number such as "0.5 in"
+// by the locale's separator.
+func (l Locale) Decimal(s string) string {
+	if l.DecimalComma {
+		return strings.ReplaceAll(s, ".", ",")
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+// Package lock keeps two Calvin runs from rendering and shutting down at
+// the same time, using an OS lock on a file that names the holder's PID.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policies for a lock held by a live, non-stale process.
+const (
+	// PolicySkip gives up immediately with ErrLocked.
+	PolicySkip = "skip"
+	// PolicyWait waits until the holder exits or its lock goes stale.
+	PolicyWait = "wait"
+	// PolicyTakeover terminates the holder and takes the lock.
+	PolicyTakeover = "takeover"
+)
+
+// ErrLocked is returned when another run holds the lock.
+var ErrLocked = errors.New("another run holds the lock")
+
+// terminateTimeout is how long a terminated holder gets to exit before it
+// is killed.
+const terminateTimeout = 10 * time.Second
+
+// pidGrace is how long a holder may take to write its PID after locking.
+// Until then the file still names the previous holder or nothing.
+const pidGrace = 5 * time.Second
+
+// errHeld is returned by tryLock when another process holds the lock.
+var errHeld = errors.New("lock held")
+
+// Lock is a held lock file.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock at path. The OS releases the lock of a process
+// that exits, so the file itself is never removed; a lock older than
+// staleAfter belongs to a hung process, which is terminated whatever the
+// policy. Otherwise policy decides.
+func Acquire(ctx context.Context, path, policy string, staleAfter time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file: %w", err)
+	}
+
+	started := time.Now()
+	for {
+		err := tryLock(f)
+		if err == nil {
+			if err := writePID(f); err != nil {
+				unlock(f)
+				f.Close()
+				return nil, fmt.Errorf("unable to write lock file: %w", err)
+			}
+			return &Lock{f: f}, nil
+		}
+		if !errors.Is(err, errHeld) {
+			f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+		}
+
+		pid, age, err := holder(path)
+		known := err == nil && pid != os.Getpid() && processAlive(pid)
+		switch {
+		case !known && time.Since(started) < pidGrace:
+			// Just taken by a process yet to write its PID.
+		case !known && policy != PolicyWait:
+			f.Close()
+			return nil, ErrLocked
+		case !known:
+			log.Printf("Waiting for the lock holder to finish")
+		case age > staleAfter:
+			log.Printf("Lock held by process %d for %s, terminating it", pid, age.Round(time.Second))
+			terminate(pid)
+			continue
+		case policy == PolicyTakeover:
+			log.Printf("Taking over lock from process %d", pid)
+			terminate(pid)
+			continue
+		case policy == PolicyWait:
+			log.Printf("Waiting for process %d to finish", pid)
+		default:
+			f.Close()
+			return nil, fmt.Errorf("%w (process %d, started %s ago)", ErrLocked, pid, age.Round(time.Second))
+		}
+
+		wait := time.Second
+		if !known {
+			wait = 100 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Release clears the PID and unlocks. The file stays, so a process that
+// opened it meanwhile still contends for the same lock.
+func (l *Lock) Release() error {
+	l.f.Truncate(0)
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// writePID replaces the content of the locked file with our PID, which
+// also stamps when the lock was taken.
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// holder returns the PID in the lock file and how long it has been held.
+func holder(path string) (int, time.Duration, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid lock file content %q", data)
+	}
+	return pid, time.Since(info.ModTime()), nil
+}
+
+// terminate asks pid to exit and kills it if it's still there after
+// terminateTimeout.
+func terminate(pid int) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := interrupt(p); err == nil {
+		deadline := time.Now().Add(terminateTimeout)
+		for time.Now().Before(deadline) && processAlive(pid) {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if processAlive(pid) {
+		p.Kill()
+	}
+}
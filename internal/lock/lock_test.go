@@ -0,0 +1,150 @@
+package lock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary hold a lock in a child process: with
+// CALVIN_LOCK_HOLD set it takes that lock, reports and sleeps.
+func TestMain(m *testing.M) {
+	if path := os.Getenv("CALVIN_LOCK_HOLD"); path != "" {
+		if _, err := Acquire(context.Background(), path, PolicySkip, time.Hour); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// holdLock starts a child process holding the lock at path. The channel is
+// closed when it exits; waiting right away reaps it, so it doesn't linger
+// as a zombie that still looks alive.
+func holdLock(t *testing.T, path string) (*os.Process, <-chan struct{}) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "CALVIN_LOCK_HOLD="+path)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(out).ReadString('\n')
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-done
+	})
+	if strings.TrimSpace(line) != "locked" {
+		t.Fatalf("holder: %s", line)
+	}
+	return cmd.Process, done
+}
+
+func TestAcquireHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calvin.lock")
+	holder, done := holdLock(t, path)
+
+	_, err := Acquire(context.Background(), path, PolicySkip, time.Hour)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Acquire while held = %v, want ErrLocked", err)
+	}
+
+	// The lock goes with the holder; the file doesn't need removing.
+	holder.Kill()
+	<-done
+	lk, err := Acquire(context.Background(), path, PolicySkip, time.Hour)
+	if err != nil {
+		t.Fatalf("Acquire after the holder exited: %v", err)
+	}
+	defer lk.Release()
+	data, _ := os.ReadFile(path)
+	if pid, _ := strconv.Atoi(strings.TrimSpace(string(data))); pid != os.Getpid() {
+		t.Errorf("lock file names %q, want our PID", data)
+	}
+}
+
+func TestAcquireStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calvin.lock")
+	_, done := holdLock(t, path)
+
+	lk, err := Acquire(context.Background(), path, PolicySkip, 0)
+	if err != nil {
+		t.Fatalf("Acquire of a stale lock: %v", err)
+	}
+	defer lk.Release()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("the stale holder wasn't terminated")
+	}
+}
+
+// TestAcquireLeftover takes a lock whose file is left by a crashed run,
+// empty or with a dead PID.
+func TestAcquireLeftover(t *testing.T) {
+	for _, content := range []string{"", "garbage", "999999999\n"} {
+		path := filepath.Join(t.TempDir(), "calvin.lock")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lk, err := Acquire(context.Background(), path, PolicySkip, time.Hour)
+		if err != nil {
+			t.Fatalf("Acquire over %q: %v", content, err)
+		}
+		lk.Release()
+	}
+}
+
+// TestAcquireExclusive has runs contend for the lock and checks no two
+// ever hold it at once.
+func TestAcquireExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calvin.lock")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var holders, runs atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lk, err := Acquire(ctx, path, PolicyWait, time.Hour)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if holders.Add(1) != 1 {
+				t.Error("two runs hold the lock")
+			}
+			time.Sleep(10 * time.Millisecond)
+			holders.Add(-1)
+			runs.Add(1)
+			lk.Release()
+		}()
+	}
+	wg.Wait()
+	if runs.Load() != 8 {
+		t.Errorf("%d of 8 runs got the lock", runs.Load())
+	}
+}
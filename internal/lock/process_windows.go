@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffset is where the locked byte lies, far past the PID, since
+// Windows locks also keep others from reading the locked range.
+const lockOffset = 1 << 30
+
+func processAlive(pid int) bool {
+	// FindProcess opens a handle on Windows and fails for exited processes.
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// interrupt can't deliver SIGTERM on Windows; terminate falls back to Kill.
+func interrupt(p *os.Process) error {
+	return errors.New("not supported")
+}
+
+// tryLock takes an exclusive lock on a byte of f without blocking.
+func tryLock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errHeld
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
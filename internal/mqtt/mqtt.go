@@ -0,0 +1,233 @@
+// Package mqtt reads the current values of MQTT topics with a minimal
+// MQTT 3.1.1 client: it subscribes, collects the retained or next
+// published message of every topic and disconnects.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Packet types, shifted into the high nibble of the fixed header.
+const (
+	packetConnect    = 1 << 4
+	packetConnAck    = 2 << 4
+	packetPublish    = 3 << 4
+	packetSubscribe  = 8<<4 | 2
+	packetSubAck     = 9 << 4
+	packetDisconnect = 14 << 4
+)
+
+// Broker is where to connect: tcp://host:1883 or mqtts://host:8883.
+type Broker struct {
+	URL      string
+	Username string
+	Password string
+	ClientID string
+}
+
+// Read returns the latest payload of each topic, keyed by topic. Brokers
+// send retained messages right after subscribing; topics without one are
+// waited for until ctx is done, and missing from the result when nothing
+// arrived. Wildcards aren't supported.
+func Read(ctx context.Context, b Broker, topics []string) (map[string][]byte, error) {
+	conn, err := dial(ctx, b.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	r := bufio.NewReader(conn)
+	if err := connect(conn, r, b); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(subscribePacket(topics)); err != nil {
+		return nil, fmt.Errorf("mqtt subscribe: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		wanted[t] = true
+	}
+	values := make(map[string][]byte, len(topics))
+	for len(values) < len(wanted) {
+		header, body, err := readPacket(r)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return values, fmt.Errorf("mqtt read: %w", err)
+		}
+		switch header & 0xf0 {
+		case packetSubAck:
+			if len(body) < 2 {
+				return values, fmt.Errorf("mqtt: short subscribe reply")
+			}
+			for _, code := range body[2:] {
+				if code == 0x80 {
+					return values, fmt.Errorf("mqtt subscribe refused")
+				}
+			}
+		case packetPublish:
+			topic, payload, err := parsePublish(header, body)
+			if err != nil {
+				return values, err
+			}
+			if wanted[topic] {
+				values[topic] = payload
+			}
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte{packetDisconnect, 0})
+	return values, nil
+}
+
+func dial(ctx context.Context, broker string) (net.Conn, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt broker %q: %w", broker, err)
+	}
+	var d net.Dialer
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return d.DialContext(ctx, "tcp", hostPort(u, "1883"))
+	case "ssl", "tls", "mqtts":
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+		return td.DialContext(ctx, "tcp", hostPort(u, "8883"))
+	}
+	return nil, fmt.Errorf("invalid mqtt broker %q: scheme must be tcp or mqtts", broker)
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func connect(w io.Writer, r *bufio.Reader, b Broker) error {
+	flags := byte(0x02) // clean session
+	payload := appendString(nil, b.ClientID)
+	if b.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, b.Username)
+		if b.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, b.Password)
+		}
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 30) // protocol level 3.1.1, keep alive 30s
+	body = append(body, payload...)
+	if _, err := w.Write(packet(packetConnect, body)); err != nil {
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+
+	header, ack, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+	if header&0xf0 != packetConnAck || len(ack) < 2 {
+		return fmt.Errorf("mqtt connect: unexpected reply")
+	}
+	switch ack[1] {
+	case 0:
+		return nil
+	case 4, 5:
+		return fmt.Errorf("mqtt connect: not authorized")
+	}
+	return fmt.Errorf("mqtt connect: refused with code %d", ack[1])
+}
+
+func subscribePacket(topics []string) []byte {
+	body := []byte{0, 1} // packet identifier
+	for _, t := range topics {
+		body = appendString(body, t)
+		body = append(body, 0) // QoS 0
+	}
+	return packet(packetSubscribe, body)
+}
+
+func parsePublish(header byte, body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, fmt.Errorf("mqtt: short publish packet")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return "", nil, fmt.Errorf("mqtt: short publish packet")
+	}
+	topic := string(body[2 : 2+n])
+	rest := body[2+n:]
+	if qos := header >> 1 & 0x03; qos > 0 {
+		// Packet identifier; acknowledging is unnecessary as we leave.
+		if len(rest) < 2 {
+			return "", nil, fmt.Errorf("mqtt: short publish packet")
+		}
+		rest = rest[2:]
+	}
+	return topic, rest, nil
+}
+
+func packet(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		p = append(p, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, fmt.Errorf("mqtt: malformed packet length")
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,150 @@
+// Package power sets the PiSugar wake-up alarm and shuts the Pi down,
+// either through sudo or without root privileges.
+package power
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Methods of performing power operations.
+const (
+	// MethodSudo runs pisugar-cli and shutdown through passwordless sudo.
+	MethodSudo = "sudo"
+	// MethodPiSugarServer sets the alarm over the pisugar-server socket and
+	// powers off through systemctl, which polkit can allow for the user.
+	MethodPiSugarServer = "pisugar-server"
+	// MethodHelper runs a root-owned helper (setuid or via a sudoers rule
+	// for that one binary) as "helper set-alarm <RFC 3339 time>" and
+	// "helper shutdown".
+	MethodHelper = "helper"
+)
+
+// Controller performs power operations with one method.
+type Controller struct {
+	Method string
+	// Socket is the pisugar-server address: a unix socket path or
+	// host:port.
+	Socket string
+	Helper string
+}
+
+// Check verifies the method can work, so a misconfigured setup fails at the
+// start of a run instead of leaving the Pi awake after rendering.
+func (c Controller) Check(ctx context.Context) error {
+	switch c.Method {
+	case MethodPiSugarServer:
+		conn, err := c.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("pisugar-server is not reachable at %s: %w", c.Socket, err)
+		}
+		conn.Close()
+		if _, err := exec.LookPath("systemctl"); err != nil {
+			return fmt.Errorf("systemctl is required to power off: %w", err)
+		}
+	case MethodHelper:
+		info, err := os.Stat(c.Helper)
+		if err != nil {
+			return fmt.Errorf("power helper is missing: %w", err)
+		}
+		if info.Mode()&0111 == 0 {
+			return fmt.Errorf("power helper %s is not executable", c.Helper)
+		}
+	default:
+		if _, err := exec.LookPath("pisugar-cli"); err != nil {
+			return fmt.Errorf("pisugar-cli is required to set the alarm: %w", err)
+		}
+		if output, err := exec.CommandContext(ctx, "sudo", "-n", "true").CombinedOutput(); err != nil {
+			return fmt.Errorf("passwordless sudo is not available (%s); allow it or set power.method to pisugar-server or helper", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// SetAlarm schedules the PiSugar to wake the Pi at t. The RTC keeps UTC, so
+// t is sent as UTC wherever the protocol carries an offset; pisugar-cli
+// takes the system's local time.
+func (c Controller) SetAlarm(ctx context.Context, t time.Time) error {
+	var output []byte
+	var err error
+	switch c.Method {
+	case MethodPiSugarServer:
+		// 127 repeats the alarm on every weekday, like pisugar-cli does.
+		var reply string
+		reply, err = c.command(ctx, "rtc_alarm_set "+t.UTC().Format(time.RFC3339)+" 127")
+		output = []byte(reply)
+		if err == nil && !strings.Contains(reply, "done") {
+			err = fmt.Errorf("unexpected reply")
+		}
+	case MethodHelper:
+		output, err = exec.CommandContext(ctx, c.Helper, "set-alarm", t.UTC().Format(time.RFC3339)).CombinedOutput()
+	default:
+		output, err = exec.CommandContext(ctx, "sudo", "pisugar-cli", "--set-alarm", t.Local().Format("2006-01-02 15:04:05")).CombinedOutput()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set PiSugar alarm: %w, output: %s", err, output)
+	}
+	return nil
+}
+
+// Shutdown powers the system off.
+func (c Controller) Shutdown() error {
+	var cmd *exec.Cmd
+	switch c.Method {
+	case MethodPiSugarServer:
+		return PowerOff()
+	case MethodHelper:
+		cmd = exec.Command(c.Helper, "shutdown")
+	default:
+		cmd = exec.Command("sudo", "shutdown", "-h", "now")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to shutdown: %w, output: %s", err, output)
+	}
+	return nil
+}
+
+// PowerOff powers the system off with systemctl, which polkit can allow
+// for the user.
+func PowerOff() error {
+	if output, err := exec.Command("systemctl", "poweroff").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to power off: %w, output: %s", err, output)
+	}
+	return nil
+}
+
+func (c Controller) dial(ctx context.Context) (net.Conn, error) {
+	network := "unix"
+	if !strings.HasPrefix(c.Socket, "/") {
+		network = "tcp"
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, c.Socket)
+}
+
+// command sends one line to pisugar-server and returns its reply.
+func (c Controller) command(ctx context.Context, line string) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply), nil
+}
@@ -0,0 +1,122 @@
+// Package quote picks a quote of the day from a local file or a JSON API.
+package quote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+// Quote is a saying and, if known, who said it.
+type Quote struct {
+	Text   string
+	Author string
+}
+
+// authorSeparators split "text — author" lines of a quotes file.
+var authorSeparators = []string{" — ", " -- ", " ~ "}
+
+// Load reads a quotes file with one quote per line, optionally ending in
+// " — Author" or " -- Author". Blank lines and lines starting with # are
+// skipped.
+func Load(path string) ([]Quote, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read quotes: %w", err)
+	}
+	defer f.Close()
+
+	var quotes []Quote
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		q := Quote{Text: line}
+		for _, sep := range authorSeparators {
+			if i := strings.LastIndex(line, sep); i > 0 {
+				q = Quote{Text: strings.TrimSpace(line[:i]), Author: strings.TrimSpace(line[i+len(sep):])}
+				break
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read quotes: %w", err)
+	}
+	return quotes, nil
+}
+
+// API is a JSON endpoint returning a quote object or a list of them, with
+// the text and author under TextField and AuthorField.
+type API struct {
+	URL         string
+	TextField   string
+	AuthorField string
+}
+
+// Fetch downloads the quotes of api.
+func Fetch(ctx context.Context, api API) ([]Quote, error) {
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", api.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quote: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("quote API returned status %d", resp.StatusCode)
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode quote: %w", err)
+	}
+
+	var objects []map[string]any
+	if err := json.Unmarshal(body, &objects); err != nil {
+		var object map[string]any
+		if err := json.Unmarshal(body, &object); err != nil {
+			return nil, fmt.Errorf("quote API must return an object or a list of objects")
+		}
+		objects = []map[string]any{object}
+	}
+
+	var quotes []Quote
+	for _, object := range objects {
+		text, _ := object[api.TextField].(string)
+		author, _ := object[api.AuthorField].(string)
+		if text = strings.TrimSpace(text); text != "" {
+			quotes = append(quotes, Quote{Text: text, Author: strings.TrimSpace(author)})
+		}
+	}
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("quote API returned no %q field", api.TextField)
+	}
+	return quotes, nil
+}
+
+// ForDate returns the quote of date's day. It stays the same through the
+// day, so hourly refreshes don't shuffle it, and moves on by one each day.
+func ForDate(quotes []Quote, date time.Time) (Quote, bool) {
+	if len(quotes) == 0 {
+		return Quote{}, false
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Unix() / (24 * 60 * 60))
+	return quotes[days%len(quotes)], true
+}
@@ -0,0 +1,249 @@
+package render
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+)
+
+// canvas is a gg.Context that draws at scale times the display size while
+// layout code keeps using display coordinates. Image downsamples the result,
+// which gives smoother shapes and sharper text than drawing at 1x,
+// especially once reduced to a 1-bit palette.
+//
+// With a zoom above 1, a layout unit covers zoom output pixels, so the
+// layout is drawn larger on the same image (large print).
+type canvas struct {
+	*gg.Context
+	// scale is the number of context pixels per layout unit.
+	scale float64
+	// width and height are the output size.
+	width  int
+	height int
+
+	// svg, when set, records fills, strokes, text and images as SVG
+	// instead of rasterizing them; gg still tracks the matrix and
+	// measures text. fonts maps the faces made by face to their fonts.
+	svg   *svgRecorder
+	fonts map[font.Face]svgFont
+}
+
+func newCanvas(width, height int, zoom, scale float64) *canvas {
+	dc := gg.NewContext(int(float64(width)*scale), int(float64(height)*scale))
+	dc.Scale(zoom*scale, zoom*scale)
+	return &canvas{Context: dc, scale: zoom * scale, width: width, height: height}
+}
+
+// newSVGCanvas returns a canvas that records SVG at the output size.
+func newSVGCanvas(width, height int, zoom float64) *canvas {
+	c := newCanvas(width, height, zoom, 1)
+	c.svg = &svgRecorder{lineWidth: 1}
+	c.fonts = make(map[font.Face]svgFont)
+	return c
+}
+
+// face returns font at size display pixels, rasterized for the canvas scale.
+func (c *canvas) face(f *truetype.Font, size float64) font.Face {
+	face := truetype.NewFace(f, &truetype.Options{Size: size * c.scale})
+	if c.svg != nil {
+		c.fonts[face] = svgFont{bold: f == boldFont, size: size * c.scale}
+	}
+	return face
+}
+
+func (c *canvas) SetFontFace(face font.Face) {
+	c.Context.SetFontFace(face)
+	if c.svg != nil {
+		c.svg.font = c.fonts[face]
+	}
+}
+
+func (c *canvas) SetHexColor(x string) {
+	c.Context.SetHexColor(x)
+	if c.svg != nil {
+		c.svg.color = "#" + strings.TrimPrefix(x, "#")
+	}
+}
+
+// SetLineWidth takes the width in display pixels; gg doesn't scale line
+// widths with the matrix.
+func (c *canvas) SetLineWidth(width float64) {
+	c.Context.SetLineWidth(width * c.scale)
+	if c.svg != nil {
+		c.svg.lineWidth = width * c.scale
+	}
+}
+
+func (c *canvas) Clear() {
+	if c.svg != nil {
+		c.svg.clear()
+		return
+	}
+	c.Context.Clear()
+}
+
+func (c *canvas) NewSubPath() {
+	c.Context.NewSubPath()
+	if c.svg != nil {
+		c.svg.current = false
+	}
+}
+
+func (c *canvas) MoveTo(x, y float64) {
+	c.Context.MoveTo(x, y)
+	if c.svg != nil {
+		c.svg.moveTo(c.TransformPoint(x, y))
+	}
+}
+
+func (c *canvas) LineTo(x, y float64) {
+	c.Context.LineTo(x, y)
+	if c.svg != nil {
+		c.svg.lineTo(c.TransformPoint(x, y))
+	}
+}
+
+func (c *canvas) ClosePath() {
+	c.Context.ClosePath()
+	if c.svg != nil {
+		c.svg.closePath()
+	}
+}
+
+func (c *canvas) DrawArc(x, y, r, angle1, angle2 float64) {
+	c.Context.DrawArc(x, y, r, angle1, angle2)
+	if c.svg != nil {
+		arcPoints(x, y, r, angle1, angle2, func(px, py float64) {
+			c.svg.lineTo(c.TransformPoint(px, py))
+		})
+	}
+}
+
+// The shapes below follow gg's own, built from the recorded primitives.
+
+func (c *canvas) DrawLine(x1, y1, x2, y2 float64) {
+	c.MoveTo(x1, y1)
+	c.LineTo(x2, y2)
+}
+
+func (c *canvas) DrawRectangle(x, y, w, h float64) {
+	c.NewSubPath()
+	c.MoveTo(x, y)
+	c.LineTo(x+w, y)
+	c.LineTo(x+w, y+h)
+	c.LineTo(x, y+h)
+	c.ClosePath()
+}
+
+func (c *canvas) DrawRoundedRectangle(x, y, w, h, r float64) {
+	x0, x1, x2, x3 := x, x+r, x+w-r, x+w
+	y0, y1, y2, y3 := y, y+r, y+h-r, y+h
+	c.NewSubPath()
+	c.MoveTo(x1, y0)
+	c.LineTo(x2, y0)
+	c.DrawArc(x2, y1, r, gg.Radians(270), gg.Radians(360))
+	c.LineTo(x3, y2)
+	c.DrawArc(x2, y2, r, gg.Radians(0), gg.Radians(90))
+	c.LineTo(x1, y3)
+	c.DrawArc(x1, y2, r, gg.Radians(90), gg.Radians(180))
+	c.LineTo(x0, y1)
+	c.DrawArc(x1, y1, r, gg.Radians(180), gg.Radians(270))
+	c.ClosePath()
+}
+
+func (c *canvas) DrawCircle(x, y, r float64) {
+	c.NewSubPath()
+	c.DrawArc(x, y, r, 0, 2*math.Pi)
+	c.ClosePath()
+}
+
+func (c *canvas) DrawRegularPolygon(n int, x, y, r, rotation float64) {
+	angle := 2 * math.Pi / float64(n)
+	rotation -= math.Pi / 2
+	if n%2 == 0 {
+		rotation += angle / 2
+	}
+	c.NewSubPath()
+	for i := range n {
+		a := rotation + angle*float64(i)
+		c.LineTo(x+r*math.Cos(a), y+r*math.Sin(a))
+	}
+	c.ClosePath()
+}
+
+func (c *canvas) Fill() {
+	if c.svg != nil {
+		c.svg.fill()
+		c.ClearPath()
+		return
+	}
+	c.Context.Fill()
+}
+
+func (c *canvas) Stroke() {
+	if c.svg != nil {
+		c.svg.stroke()
+		c.ClearPath()
+		return
+	}
+	c.Context.Stroke()
+}
+
+// DrawImage draws img offset by x, y, like gg.
+func (c *canvas) DrawImage(img image.Image, x, y int) {
+	if c.svg == nil {
+		c.Context.DrawImage(img, x, y)
+		return
+	}
+	b := img.Bounds()
+	x0, y0 := c.TransformPoint(float64(x+b.Min.X), float64(y+b.Min.Y))
+	x1, y1 := c.TransformPoint(float64(x+b.Max.X), float64(y+b.Max.Y))
+	c.svg.image(img, x0, y0, x1, y1)
+}
+
+// MeasureString returns the size in display pixels.
+func (c *canvas) MeasureString(s string) (float64, float64) {
+	w, h := c.Context.MeasureString(s)
+	return w / c.scale, h / c.scale
+}
+
+// WordWrap splits s into lines at most width display pixels wide.
+func (c *canvas) WordWrap(s string, width float64) []string {
+	return c.Context.WordWrap(s, width*c.scale)
+}
+
+func (c *canvas) DrawString(s string, x, y float64) {
+	c.DrawStringAnchored(s, x, y, 0, 0)
+}
+
+// DrawStringAnchored draws without the scale matrix: glyphs already come
+// from a scaled face, and gg would otherwise enlarge them a second time.
+func (c *canvas) DrawStringAnchored(s string, x, y, ax, ay float64) {
+	x, y = c.TransformPoint(x, y)
+	if c.svg != nil {
+		w, h := c.Context.MeasureString(s)
+		c.svg.text(s, x-ax*w, y+ay*h)
+		return
+	}
+	c.Push()
+	c.Identity()
+	c.Context.DrawStringAnchored(s, x, y, ax, ay)
+	c.Pop()
+}
+
+// Image returns the drawing at output size.
+func (c *canvas) Image() image.Image {
+	img := c.Context.Image()
+	b := img.Bounds()
+	if b.Dx() == c.width && b.Dy() == c.height {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// DiffResult compares two renders of the same size.
+type DiffResult struct {
+	// Image shows the newer render faded, with changed pixels in red and
+	// the changed area outlined.
+	Image *image.RGBA
+	// Changed counts the differing pixels and Area is the smallest
+	// rectangle containing them.
+	Changed int
+	Area    image.Rectangle
+}
+
+// Diff highlights the pixels that differ between prev and next.
+func Diff(prev, next image.Image) (DiffResult, error) {
+	pb, nb := prev.Bounds(), next.Bounds()
+	if pb.Size() != nb.Size() {
+		return DiffResult{}, fmt.Errorf("images differ in size: %dx%d and %dx%d", pb.Dx(), pb.Dy(), nb.Dx(), nb.Dy())
+	}
+
+	red := color.RGBA{R: 0xdc, G: 0x35, B: 0x45, A: 0xff}
+	result := DiffResult{Image: image.NewRGBA(image.Rect(0, 0, nb.Dx(), nb.Dy()))}
+	for y := 0; y < nb.Dy(); y++ {
+		for x := 0; x < nb.Dx(); x++ {
+			r1, g1, b1, _ := prev.At(pb.Min.X+x, pb.Min.Y+y).RGBA()
+			r2, g2, b2, _ := next.At(nb.Min.X+x, nb.Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 {
+				result.Image.SetRGBA(x, y, red)
+				result.Changed++
+				result.Area = result.Area.Union(image.Rect(x, y, x+1, y+1))
+				continue
+			}
+			// Fade unchanged pixels to a quarter of their contrast so the
+			// changes stand out while the layout stays recognizable.
+			grey := uint8(0xff - (0xffff-(r2*299+g2*587+b2*114)/1000)>>8/4)
+			result.Image.SetRGBA(x, y, color.RGBA{R: grey, G: grey, B: grey, A: 0xff})
+		}
+	}
+
+	if !result.Area.Empty() {
+		outline := result.Area.Inset(-3).Intersect(result.Image.Bounds())
+		for x := outline.Min.X; x < outline.Max.X; x++ {
+			result.Image.SetRGBA(x, outline.Min.Y, red)
+			result.Image.SetRGBA(x, outline.Max.Y-1, red)
+		}
+		for y := outline.Min.Y; y < outline.Max.Y; y++ {
+			result.Image.SetRGBA(outline.Min.X, y, red)
+			result.Image.SetRGBA(outline.Max.X-1, y, red)
+		}
+	}
+	return result, nil
+}
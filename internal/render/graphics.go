@@ -1,8 +1,20 @@
 package render
 
 import (
+	"bufio"
 	_ "embed"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fogleman/gg"
 	"github.com/golang/freetype/truetype"
@@ -26,7 +38,10 @@ var (
 	boldFont    *truetype.Font
 )
 
-func init() {
+// loadFonts parses the embedded fonts on first use rather than in init, so
+// a broken font panics inside a run, where it is recovered and reported,
+// instead of before main. Later calls panic again with the same value.
+var loadFonts = sync.OnceFunc(func() {
 	var err error
 	regularFont, err = truetype.Parse(regularFontData)
 	if err != nil {
@@ -36,22 +51,31 @@ func init() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to parse bold font: %v", err))
 	}
-}
+})
 
 type calendarRenderer struct {
-	dc     *gg.Context
+	dc     *canvas
 	width  int
 	height int
+	// bottom is where the view ends, above the footer if there is one.
+	bottom float64
+	// regions records where named parts of the view were drawn, for
+	// cropped output variants.
+	regions map[string]image.Rectangle
 }
 
-func newCalendarRenderer(width, height int) *calendarRenderer {
-	dc := gg.NewContext(width, height)
+// newCalendarRenderer draws on dc, a canvas with zoom. The layout sees a
+// display zoom times smaller, so everything comes out zoom times larger.
+func newCalendarRenderer(dc *canvas, zoom float64) *calendarRenderer {
+	loadFonts()
 	dc.SetHexColor(colorWhite)
 	dc.Clear()
 	return &calendarRenderer{
-		dc:     dc,
-		width:  width,
-		height: height,
+		dc:      dc,
+		width:   int(float64(dc.width) / zoom),
+		height:  int(float64(dc.height) / zoom),
+		bottom:  float64(dc.height) / zoom,
+		regions: make(map[string]image.Rectangle),
 	}
 }
 
@@ -59,27 +83,488 @@ func (r *calendarRenderer) drawHeader(data TemplateData) {
 	headerHeight := 60.0
 	padding := 24.0
 
+	r.regions[RegionHeader] = image.Rect(0, 0, r.width, int(headerHeight))
+
 	r.dc.SetHexColor(colorGrey)
 	r.dc.DrawLine(0, headerHeight, float64(r.width), headerHeight)
 	r.dc.SetLineWidth(2)
 	r.dc.Stroke()
 
 	r.dc.SetHexColor(colorBlack)
-	r.dc.SetFontFace(truetype.NewFace(boldFont, &truetype.Options{Size: 28}))
+	r.dc.SetFontFace(r.dc.face(boldFont, 28))
 	title := fmt.Sprintf("%s %d", data.MonthName, data.Year)
 	r.dc.DrawString(title, padding, 40)
+	titleWidth, _ := r.dc.MeasureString(title)
+
+	x := r.drawWidgets(data.Widgets, padding+titleWidth+padding, 38)
+	x = r.drawComparison(data.Comparison, x, 38)
+	x = r.drawTickers(data.Tickers, x, 38)
+	r.drawNextEvent(data.NextEvent, x, 38)
+
+	if data.UpdatedAt != "" {
+		r.drawUpdatedClock(data, padding)
+		return
+	}
 
-	r.dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 12}))
+	r.dc.SetFontFace(r.dc.face(regularFont, 12))
 	r.dc.SetHexColor(colorGrey)
 	generatedText := fmt.Sprintf("Generated: %s | Battery: %s", data.GeneratedAt, data.BatteryPercentage)
+	if data.RunTime != "" {
+		generatedText += " | Render: " + data.RunTime
+	}
+	if data.Uptime != "" {
+		generatedText += " | Uptime: " + data.Uptime
+	}
 	textWidth, _ := r.dc.MeasureString(generatedText)
 	r.dc.DrawString(generatedText, float64(r.width)-padding-textWidth, 35)
 
-	if data.WeatherError != "" {
+	softErrors := data.softErrors()
+	if len(softErrors) > 0 {
+		errorText := strings.Join(softErrors, " | ")
+		r.dc.SetHexColor(colorRed)
+		errorWidth, _ := r.dc.MeasureString(errorText)
+		r.dc.DrawString(errorText, float64(r.width)-padding-errorWidth, 50)
+	}
+}
+
+// softErrors lists the weather and battery errors shown in the header.
+func (data TemplateData) softErrors() []string {
+	var errs []string
+	for _, e := range []string{data.WeatherError, data.BatteryError} {
+		if e != "" {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// drawUpdatedClock draws "Updated 07:00" in large type at the right of the
+// header, with the battery level and soft errors on a small line below.
+func (r *calendarRenderer) drawUpdatedClock(data TemplateData, padding float64) {
+	right := float64(r.width) - padding
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	r.dc.SetHexColor(colorGrey)
+	label := "Updated "
+	labelWidth, _ := r.dc.MeasureString(label)
+	r.dc.SetFontFace(r.dc.face(boldFont, 22))
+	clockWidth, _ := r.dc.MeasureString(data.UpdatedAt)
+	r.dc.SetHexColor(colorBlack)
+	r.dc.DrawString(data.UpdatedAt, right-clockWidth, 32)
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawString(label, right-clockWidth-labelWidth, 32)
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 12))
+	status := "Battery: " + data.BatteryPercentage
+	if data.RunTime != "" {
+		status += " | Render: " + data.RunTime
+	}
+	if data.Uptime != "" {
+		status += " | Uptime: " + data.Uptime
+	}
+	statusWidth, _ := r.dc.MeasureString(status)
+	r.dc.DrawString(status, right-statusWidth, 50)
+
+	softErrors := data.softErrors()
+	if len(softErrors) > 0 {
+		errorText := strings.Join(softErrors, " | ") + " | "
+		r.dc.SetHexColor(colorRed)
+		errorWidth, _ := r.dc.MeasureString(errorText)
+		r.dc.DrawString(errorText, right-statusWidth-errorWidth, 50)
+	}
+}
+
+// drawWidgets draws the widget row starting at x and returns where the next
+// header item can start.
+func (r *calendarRenderer) drawWidgets(widgets []WidgetData, x, y float64) float64 {
+	if len(widgets) == 0 {
+		return x
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	for i, w := range widgets {
+		if i > 0 {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawString("·", x, y)
+			sepWidth, _ := r.dc.MeasureString("· ")
+			x += sepWidth
+		}
+
+		if w.Label != "" {
+			label := w.Label + ": "
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawString(label, x, y)
+			labelWidth, _ := r.dc.MeasureString(label)
+			x += labelWidth
+		}
+
+		value := w.Value + " "
+		r.dc.SetHexColor(colorBlack)
+		r.dc.DrawString(value, x, y)
+		valueWidth, _ := r.dc.MeasureString(value)
+		x += valueWidth
+	}
+
+	return x + 16
+}
+
+// drawComparison draws "Praha 21°/12° · Lipno 17°/8°" and returns where the
+// next header item can start.
+func (r *calendarRenderer) drawComparison(locations []ComparisonData, x, y float64) float64 {
+	if len(locations) < 2 {
+		return x
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	for i, l := range locations {
+		if i > 0 {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawString("·", x, y)
+			sepWidth, _ := r.dc.MeasureString("· ")
+			x += sepWidth
+		}
+
+		label := l.Label + " "
+		r.dc.SetHexColor(colorGrey)
+		r.dc.DrawString(label, x, y)
+		labelWidth, _ := r.dc.MeasureString(label)
+		x += labelWidth
+
+		temps := fmt.Sprintf("%s/%s ", l.DayTemp, l.NightTemp)
+		r.dc.SetHexColor(colorBlack)
+		r.dc.DrawString(temps, x, y)
+		tempsWidth, _ := r.dc.MeasureString(temps)
+		x += tempsWidth
+	}
+
+	return x + 16
+}
+
+// drawTickers draws "AAPL 187.20 ▲1.2%" per symbol and returns where the
+// next header item can start.
+func (r *calendarRenderer) drawTickers(tickers []TickerData, x, y float64) float64 {
+	if len(tickers) == 0 {
+		return x
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	for i, t := range tickers {
+		if i > 0 {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawString("·", x, y)
+			sepWidth, _ := r.dc.MeasureString("· ")
+			x += sepWidth
+		}
+
+		label := t.Symbol + " "
+		r.dc.SetHexColor(colorGrey)
+		r.dc.DrawString(label, x, y)
+		labelWidth, _ := r.dc.MeasureString(label)
+		x += labelWidth
+
+		price := t.Price + " "
+		r.dc.SetHexColor(colorBlack)
+		r.dc.DrawString(price, x, y)
+		priceWidth, _ := r.dc.MeasureString(price)
+		x += priceWidth
+
+		change := t.Change + " "
+		if t.Down {
+			r.dc.SetHexColor(colorRed)
+		}
+		r.dc.DrawString(change, x, y)
+		changeWidth, _ := r.dc.MeasureString(change)
+		x += changeWidth
+	}
+
+	return x + 16
+}
+
+// drawNextEvent draws "Next: Dentist in 2h 15m", shortening the summary to
+// stay clear of the generated timestamp on the right.
+func (r *calendarRenderer) drawNextEvent(next *NextEventData, x, y float64) {
+	if next == nil {
+		return
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 14))
+	label := "Next: "
+	in := " in " + next.In
+	labelWidth, _ := r.dc.MeasureString(label)
+	inWidth, _ := r.dc.MeasureString(in)
+	summaryWidth := float64(r.width)*0.75 - x - labelWidth - inWidth
+	if summaryWidth <= 0 {
+		return
+	}
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawString(label, x, y)
+	x += labelWidth
+
+	summary := r.truncateText(next.Summary, summaryWidth)
+	r.dc.SetHexColor(colorBlack)
+	r.dc.DrawString(summary, x, y)
+	w, _ := r.dc.MeasureString(summary)
+	x += w
+
+	r.dc.SetHexColor(colorRed)
+	r.dc.DrawString(in, x, y)
+}
+
+func (r *calendarRenderer) drawAlertBanner(alerts []AlertData, y float64) float64 {
+	if len(alerts) == 0 {
+		return y
+	}
+
+	bannerHeight := 30.0
+	padding := 24.0
+	iconSize := 18.0
+
+	r.dc.SetHexColor(colorRed)
+	r.dc.DrawRectangle(0, y, float64(r.width), bannerHeight)
+	r.dc.Fill()
+
+	r.drawWarningIcon(padding, y+(bannerHeight-iconSize)/2, iconSize, colorWhite, colorRed)
+
+	text := alerts[0].Text
+	if len(alerts) > 1 {
+		text = fmt.Sprintf("%s (+%d more)", text, len(alerts)-1)
+	}
+
+	r.dc.SetHexColor(colorWhite)
+	r.dc.SetFontFace(r.dc.face(boldFont, 15))
+	textX := padding + iconSize + 10
+	r.dc.DrawString(r.truncateText(text, float64(r.width)-textX-padding), textX, y+20)
+
+	return y + bannerHeight
+}
+
+// drawSuggestionBanner lists the matched weather suggestions in one line
+// and returns where the content below can start.
+func (r *calendarRenderer) drawSuggestionBanner(suggestions []SuggestionData, y float64) float64 {
+	if len(suggestions) == 0 {
+		return y
+	}
+
+	bannerHeight := 26.0
+	padding := 24.0
+	iconSize := 16.0
+	right := float64(r.width) - padding
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawLine(0, y+bannerHeight, float64(r.width), y+bannerHeight)
+	r.dc.SetLineWidth(1)
+	r.dc.Stroke()
+
+	r.dc.SetFontFace(r.dc.face(boldFont, 14))
+	iconY := y + (bannerHeight-iconSize)/2
+	x := padding
+	for _, s := range suggestions {
+		if x >= right {
+			break
+		}
+		switch s.Icon {
+		case "umbrella":
+			r.drawUmbrellaIcon(x, iconY, iconSize, colorRed)
+		case "snowflake":
+			r.drawSnowflakeIcon(x, iconY, iconSize, colorRed)
+		case "warning":
+			r.drawWarningIcon(x, iconY, iconSize, colorRed, colorWhite)
+		}
+		if s.Icon != "" {
+			x += iconSize + 6
+		}
+
+		text := r.truncateText(s.Text, right-x)
+		r.dc.SetHexColor(colorBlack)
+		r.dc.DrawString(text, x, y+18)
+		w, _ := r.dc.MeasureString(text)
+		x += w + 24
+	}
+
+	return y + bannerHeight
+}
+
+// drawCountdowns lists "12 days until Vacation" in one line and returns
+// where the content below can start.
+func (r *calendarRenderer) drawCountdowns(countdowns []CountdownData, y float64) float64 {
+	if len(countdowns) == 0 {
+		return y
+	}
+
+	lineHeight := 26.0
+	padding := 24.0
+	right := float64(r.width) - padding
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawLine(0, y+lineHeight, float64(r.width), y+lineHeight)
+	r.dc.SetLineWidth(1)
+	r.dc.Stroke()
+
+	x := padding
+	for _, c := range countdowns {
+		if x >= right {
+			break
+		}
+		r.dc.SetFontFace(r.dc.face(boldFont, 14))
 		r.dc.SetHexColor(colorRed)
-		errorWidth, _ := r.dc.MeasureString(data.WeatherError)
-		r.dc.DrawString(data.WeatherError, float64(r.width)-padding-errorWidth, 50)
+		r.dc.DrawString(c.Days, x, y+18)
+		daysWidth, _ := r.dc.MeasureString(c.Days)
+		x += daysWidth
+
+		r.dc.SetFontFace(r.dc.face(regularFont, 14))
+		r.dc.SetHexColor(colorBlack)
+		text := r.truncateText(" until "+c.Summary, right-x)
+		r.dc.DrawString(text, x, y+18)
+		w, _ := r.dc.MeasureString(text)
+		x += w + 24
 	}
+
+	return y + lineHeight
+}
+
+// drawSpotPrices draws the day's electricity prices as one bar per hour,
+// the cheapest hours in red and the current one in black, and returns
+// where the content below can start.
+func (r *calendarRenderer) drawSpotPrices(spot *SpotPriceData, y float64) float64 {
+	if spot == nil || len(spot.Hours) == 0 {
+		return y
+	}
+
+	stripHeight := 44.0
+	padding := 24.0
+	labelWidth := 220.0
+	barsTop := y + 6
+	barsHeight := 24.0
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawLine(0, y+stripHeight, float64(r.width), y+stripHeight)
+	r.dc.SetLineWidth(1)
+	r.dc.Stroke()
+
+	r.dc.SetFontFace(r.dc.face(boldFont, 14))
+	r.dc.SetHexColor(colorBlack)
+	now := "Power"
+	if spot.Now != "" {
+		now = "Power " + spot.Now
+	}
+	r.dc.DrawString(r.truncateText(now, labelWidth-padding-8), padding, y+20)
+	r.dc.SetFontFace(r.dc.face(regularFont, 12))
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawString(r.truncateText("Low "+spot.Low, labelWidth-padding-8), padding, y+36)
+
+	left := labelWidth
+	slot := (float64(r.width) - padding - left) / 24
+	for _, h := range spot.Hours {
+		x := left + float64(h.Hour)*slot
+		height := math.Max(2, h.Level*barsHeight)
+
+		switch {
+		case h.Cheap:
+			r.dc.SetHexColor(colorRed)
+		case h.Current:
+			r.dc.SetHexColor(colorBlack)
+		default:
+			r.dc.SetHexColor(colorGrey)
+		}
+		r.dc.DrawRectangle(x+1, barsTop+barsHeight-height, slot-2, height)
+		r.dc.Fill()
+
+		if h.Past && !h.Current {
+			// Fade hours that are over with white stripes.
+			r.dc.SetHexColor(colorWhite)
+			for sy := barsTop + barsHeight - height + 1; sy < barsTop+barsHeight; sy += 3 {
+				r.dc.DrawRectangle(x+1, sy, slot-2, 1)
+			}
+			r.dc.Fill()
+		}
+		if h.Current {
+			r.dc.SetHexColor(colorBlack)
+			r.dc.DrawLine(x+1, barsTop+barsHeight+2, x+slot-1, barsTop+barsHeight+2)
+			r.dc.SetLineWidth(2)
+			r.dc.Stroke()
+		}
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 10))
+	r.dc.SetHexColor(colorGrey)
+	for hour := 0; hour < 24; hour += 6 {
+		r.dc.DrawString(fmt.Sprintf("%d", hour), left+float64(hour)*slot+1, y+stripHeight-4)
+	}
+
+	return y + stripHeight
+}
+
+// drawQuote draws the quote of the day at the bottom, shrinking the font
+// and wrapping onto a second line for long quotes, and moves r.bottom
+// above it.
+func (r *calendarRenderer) drawQuote(q *QuoteData) {
+	if q == nil {
+		return
+	}
+
+	padding := 24.0
+	width := float64(r.width) - 2*padding
+
+	author := ""
+	authorWidth := 0.0
+	if q.Author != "" {
+		author = "— " + q.Author
+		r.dc.SetFontFace(r.dc.face(regularFont, 13))
+		authorWidth, _ = r.dc.MeasureString(author)
+		authorWidth += 12
+	}
+
+	text := "\u201c" + q.Text + "\u201d"
+	size, lines := r.fitText(text, regularFont, width-authorWidth, 16, 11, quoteLines)
+	lineHeight := size * 1.35
+	height := float64(len(lines))*lineHeight + 14
+	top := r.bottom - height
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawLine(0, top, float64(r.width), top)
+	r.dc.SetLineWidth(1)
+	r.dc.Stroke()
+
+	r.dc.SetHexColor(colorBlack)
+	r.dc.SetFontFace(r.dc.face(regularFont, size))
+	y := top + 7 + size
+	for _, line := range lines {
+		r.dc.DrawString(line, padding, y)
+		y += lineHeight
+	}
+
+	if author != "" {
+		r.dc.SetHexColor(colorGrey)
+		r.dc.SetFontFace(r.dc.face(regularFont, 13))
+		r.dc.DrawStringAnchored(author, float64(r.width)-padding, y-lineHeight, 1, 0)
+	}
+
+	r.bottom = top
+}
+
+// quoteLines is how many lines a quote may wrap onto.
+const quoteLines = 2
+
+// fitText wraps text to width at the largest font size from maxSize down
+// to minSize that needs at most maxLines lines. Text too long even at
+// minSize is cut off with an ellipsis. The face is left set to the result.
+func (r *calendarRenderer) fitText(text string, font *truetype.Font, width, maxSize, minSize float64, maxLines int) (float64, []string) {
+	size := maxSize
+	var lines []string
+	for ; size >= minSize; size-- {
+		r.dc.SetFontFace(r.dc.face(font, size))
+		lines = r.dc.WordWrap(text, width)
+		if len(lines) <= maxLines {
+			return size, lines
+		}
+	}
+	size = minSize
+	r.dc.SetFontFace(r.dc.face(font, size))
+	lines = lines[:maxLines]
+	lines[maxLines-1] = r.truncateText(lines[maxLines-1]+" ...", width)
+	return size, lines
 }
 
 func (r *calendarRenderer) drawWeekdayHeaders(y float64) float64 {
@@ -93,7 +578,7 @@ func (r *calendarRenderer) drawWeekdayHeaders(y float64) float64 {
 	r.dc.Stroke()
 
 	r.dc.SetHexColor(colorBlack)
-	r.dc.SetFontFace(truetype.NewFace(boldFont, &truetype.Options{Size: 13}))
+	r.dc.SetFontFace(r.dc.face(boldFont, 13))
 	for i, day := range weekdays {
 		x := float64(i)*colWidth + 12
 		r.dc.DrawString(day, x, y+22)
@@ -118,7 +603,7 @@ func (r *calendarRenderer) drawCalendarGrid(data TemplateData, startY float64) {
 	}
 
 	colWidth := float64(r.width) / 7.0
-	rowHeight := (float64(r.height) - startY) / float64(numWeeks)
+	rowHeight := (r.bottom - startY) / float64(numWeeks)
 
 	for weekIdx, week := range data.Weeks {
 		rowY := startY + float64(weekIdx)*rowHeight
@@ -128,6 +613,15 @@ func (r *calendarRenderer) drawCalendarGrid(data TemplateData, startY float64) {
 			cellY := rowY
 
 			r.drawDay(day, cellX, cellY, colWidth, rowHeight)
+			if day.IsToday {
+				r.setRegion(RegionToday, cellX, cellY, colWidth, rowHeight)
+			}
+			bandsBottom := cellY + rowHeight - 4
+			if data.ShowDaylight {
+				r.drawDaylightBar(day, cellX+10, cellY+rowHeight-10, colWidth-20)
+				bandsBottom -= 14
+			}
+			r.drawBands(day.Bands, cellX, bandsBottom, colWidth, dayIdx == 0)
 
 			r.dc.SetHexColor(colorGrey)
 			if dayIdx < 6 {
@@ -149,6 +643,8 @@ func (r *calendarRenderer) drawCalendarGrid(data TemplateData, startY float64) {
 func (r *calendarRenderer) drawDay(day DayData, x, y, width, height float64) {
 	padding := 10.0
 
+	r.drawShade(day.Shade, x, y, width, height)
+
 	dayNumColor := colorBlack
 	if !day.IsCurrentMonth {
 		dayNumColor = colorGrey
@@ -164,17 +660,17 @@ func (r *calendarRenderer) drawDay(day DayData, x, y, width, height float64) {
 	}
 
 	r.dc.SetHexColor(dayNumColor)
-	r.dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 18}))
+	r.dc.SetFontFace(r.dc.face(regularFont, 18))
 	r.dc.DrawString(day.DayNum, x+padding+6, y+12+18)
 
-	if day.DayNum == "1" {
-		r.dc.SetFontFace(truetype.NewFace(boldFont, &truetype.Options{Size: 12}))
+	if day.ShowMonth {
+		r.dc.SetFontFace(r.dc.face(boldFont, 12))
 		r.dc.SetHexColor(colorBlack)
 		r.dc.DrawString(day.MonthShort, x+padding+36, y+8+18)
 	}
 
 	if day.DayTemp != "" {
-		r.dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 13}))
+		r.dc.SetFontFace(r.dc.face(regularFont, 13))
 		r.dc.SetHexColor(colorBlack)
 		dayTempWidth, _ := r.dc.MeasureString(day.DayTemp)
 		r.dc.DrawString(day.DayTemp, x+width-padding-dayTempWidth, y+padding+11)
@@ -182,9 +678,119 @@ func (r *calendarRenderer) drawDay(day DayData, x, y, width, height float64) {
 		r.dc.SetHexColor(colorGrey)
 		nightTempWidth, _ := r.dc.MeasureString(day.NightTemp)
 		r.dc.DrawString(day.NightTemp, x+width-padding-nightTempWidth, y+padding+24)
+
+		if day.LastYearTemp != "" {
+			r.dc.SetFontFace(r.dc.face(regularFont, 11))
+			lastYearWidth, _ := r.dc.MeasureString(day.LastYearTemp)
+			r.dc.DrawString(day.LastYearTemp, x+width-padding-dayTempWidth-8-lastYearWidth, y+padding+11)
+		}
+
+		if day.Snowfall != "" {
+			r.dc.SetFontFace(r.dc.face(regularFont, 11))
+			r.dc.SetHexColor(colorBlack)
+			snowWidth, _ := r.dc.MeasureString(day.Snowfall)
+			snowX := x + width - padding - nightTempWidth - 8 - snowWidth
+			r.dc.DrawString(day.Snowfall, snowX, y+padding+24)
+			r.drawSnowflakeIcon(snowX-13, y+padding+15, 10, colorBlack)
+		}
+	}
+
+	if len(day.Waste) > 0 {
+		wasteX := x + padding + 40
+		if day.ShowMonth {
+			r.dc.SetFontFace(r.dc.face(boldFont, 12))
+			monthWidth, _ := r.dc.MeasureString(day.MonthShort)
+			wasteX += monthWidth + 6
+		}
+		right := x + width - padding
+		if day.DayTemp != "" {
+			r.dc.SetFontFace(r.dc.face(regularFont, 13))
+			tempWidth, _ := r.dc.MeasureString(day.DayTemp)
+			right -= tempWidth + 8
+		}
+		r.drawWaste(day.Waste, wasteX, y+padding+11, right-wasteX, 11)
+	}
+
+	r.drawEvents(day, x, y+40, width, height-40-float64(len(day.Bands))*bandHeight, day.IsPast)
+}
+
+// drawWaste draws a bin followed by the pickup names with the text baseline
+// at y, within maxWidth.
+func (r *calendarRenderer) drawWaste(names []string, x, y, maxWidth, fontSize float64) {
+	iconSize := fontSize
+	if maxWidth < iconSize {
+		return
+	}
+	r.drawBinIcon(x, y-iconSize+1, iconSize, colorBlack)
+
+	r.dc.SetFontFace(r.dc.face(regularFont, fontSize))
+	r.dc.SetHexColor(colorBlack)
+	textX := x + iconSize + 4
+	r.dc.DrawString(r.truncateText(strings.Join(names, ", "), x+maxWidth-textX), textX, y)
+}
+
+const bandHeight = 16.0
+
+// drawBands stacks the day's period bands upwards from bottom. Bands span
+// the whole width so consecutive days join up; the name is written on the
+// period's first day and, with label set, also when it continues from an
+// earlier row.
+func (r *calendarRenderer) drawBands(bands []BandData, x, bottom, width float64, label bool) {
+	r.dc.SetFontFace(r.dc.face(regularFont, 11))
+	for i, band := range bands {
+		y := bottom - float64(i+1)*bandHeight
+		r.dc.SetHexColor(colorGrey)
+		r.dc.DrawRectangle(x, y+1, width, bandHeight-2)
+		r.dc.Fill()
+
+		if band.First || label {
+			r.dc.SetHexColor(colorWhite)
+			r.dc.DrawString(r.truncateText(band.Name, width-12), x+6, y+bandHeight-4)
+		}
 	}
+}
+
+// setRegion records a named region, rounding outwards to whole pixels.
+func (r *calendarRenderer) setRegion(name string, x, y, width, height float64) {
+	r.regions[name] = image.Rect(int(x), int(y), int(math.Ceil(x+width)), int(math.Ceil(y+height)))
+}
+
+// drawShade tints a cell with a grey dot grid that gets denser with shade
+// (0 to 1). Dots stay crisp on 4-color panels where a light fill would be
+// dithered or dropped.
+func (r *calendarRenderer) drawShade(shade, x, y, width, height float64) {
+	if shade <= 0 {
+		return
+	}
+
+	spacing := 10 - 6*min(shade, 1)
+	r.dc.SetHexColor(colorGrey)
+	for dy := spacing / 2; dy < height; dy += spacing {
+		for dx := spacing / 2; dx < width; dx += spacing {
+			r.dc.DrawRectangle(x+dx, y+dy, 1, 1)
+		}
+	}
+	r.dc.Fill()
+}
+
+// drawDaylightBar draws a thin 24-hour track with the sunrise to sunset
+// span filled in.
+func (r *calendarRenderer) drawDaylightBar(day DayData, x, y, width float64) {
+	if day.Sunrise == "" || day.Sunset == "" {
+		return
+	}
+
+	barHeight := 4.0
 
-	r.drawEvents(day, x, y+40, width, height-40, day.IsPast)
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawRectangle(x, y-barHeight/2, width, 1)
+	r.dc.Fill()
+
+	r.dc.SetHexColor(colorBlack)
+	start := x + width*day.SunriseFraction
+	end := x + width*day.SunsetFraction
+	r.dc.DrawRectangle(start, y-barHeight, end-start, barHeight)
+	r.dc.Fill()
 }
 
 func (r *calendarRenderer) drawEvents(day DayData, x, y, width, height float64, isPast bool) {
@@ -196,7 +802,7 @@ func (r *calendarRenderer) drawEvents(day DayData, x, y, width, height float64,
 	gap := 2.0
 	padding := 6.0
 
-	r.dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 13}))
+	r.dc.SetFontFace(r.dc.face(regularFont, 13))
 
 	currentY := y
 	for _, event := range day.Events {
@@ -204,23 +810,61 @@ func (r *calendarRenderer) drawEvents(day DayData, x, y, width, height float64,
 			break
 		}
 
-		if event.AllDay {
+		if event.IsNew {
+			r.dc.SetHexColor(colorRed)
+			r.dc.DrawCircle(x+padding/2, currentY+eventHeight/2, 2.5)
+			r.dc.Fill()
+		}
+
+		if event.Cancelled {
+			r.drawCancelledEvent(event, x+padding+6, currentY+16, width-2*padding-12)
+		} else if event.AllDay {
 			bgColor := colorBlack
-			if isPast {
+			if event.Style.Color != "" {
+				bgColor = personColor(event.Style.Color)
+			}
+			if (isPast || event.Minor) && !event.Important {
 				bgColor = colorGrey
 			}
 			r.dc.SetHexColor(bgColor)
 			r.dc.DrawRoundedRectangle(x+padding, currentY, width-2*padding, eventHeight, 3)
 			r.dc.Fill()
 
+			textX := x + padding + 6
+			textX += r.drawPeople(event.People, textX, currentY+eventHeight/2, true)
+			if event.VideoCall {
+				r.drawCameraIcon(textX, currentY+5, 12, colorWhite)
+				textX += 16
+			}
+			if event.Fixture {
+				r.drawBallIcon(textX, currentY+5, 12, colorWhite, bgColor)
+				textX += 16
+			}
+
+			if event.Style.Bold {
+				r.dc.SetFontFace(r.dc.face(boldFont, 13))
+			}
 			r.dc.SetHexColor(colorWhite)
-			availableWidth := width - 2*padding - 12
+			availableWidth := x + width - padding - 6 - textX
 			truncatedSummary := r.truncateText(event.Summary, availableWidth)
-			r.dc.DrawString(truncatedSummary, x+padding+6, currentY+16)
+			r.dc.DrawString(truncatedSummary, textX, currentY+16)
+			r.dc.SetFontFace(r.dc.face(regularFont, 13))
 		} else {
 			timeColor := colorRed
 			titleColor := colorBlack
-			if isPast {
+			if event.Style.Color != "" {
+				titleColor = personColor(event.Style.Color)
+			}
+			switch {
+			case event.Important:
+				// Inverted like an all-day bar, past days included, so it
+				// stands out from routine entries.
+				r.dc.SetHexColor(colorBlack)
+				r.dc.DrawRoundedRectangle(x+padding, currentY, width-2*padding, eventHeight, 3)
+				r.dc.Fill()
+				timeColor = colorWhite
+				titleColor = colorWhite
+			case isPast, event.Minor:
 				timeColor = colorGrey
 				titleColor = colorGrey
 			}
@@ -230,16 +874,451 @@ func (r *calendarRenderer) drawEvents(day DayData, x, y, width, height float64,
 			r.dc.DrawString(timeText, x+padding+6, currentY+16)
 
 			timeWidth, _ := r.dc.MeasureString(timeText)
+			textX := x + padding + 6 + timeWidth + 6
+			textX += r.drawPeople(event.People, textX, currentY+eventHeight/2, event.Important)
+			if event.TightTravel {
+				r.drawWarningIcon(textX, currentY+5, 12, colorRed, colorWhite)
+				textX += 16
+			}
+			if event.VideoCall {
+				r.drawCameraIcon(textX, currentY+5, 12, titleColor)
+				textX += 16
+			}
+			if event.Fixture {
+				background := colorWhite
+				if event.Important {
+					background = colorBlack
+				}
+				r.drawBallIcon(textX, currentY+5, 12, titleColor, background)
+				textX += 16
+			}
+
+			if event.Important || event.Style.Bold {
+				r.dc.SetFontFace(r.dc.face(boldFont, 13))
+			}
 			r.dc.SetHexColor(titleColor)
-			availableWidth := width - padding - 6 - timeWidth - 6 - padding
+			availableWidth := x + width - padding - textX
 			truncatedSummary := r.truncateText(event.Summary, availableWidth)
-			r.dc.DrawString(truncatedSummary, x+padding+6+timeWidth+6, currentY+16)
+			r.dc.DrawString(truncatedSummary, textX, currentY+16)
+			r.dc.SetFontFace(r.dc.face(regularFont, 13))
 		}
 
 		currentY += eventHeight + gap
 	}
 }
 
+// drawCancelledEvent draws an event struck through in grey followed by a
+// "cancelled" tag, with the text baseline at y.
+// drawPeople draws initial badges vertically centered on cy and returns
+// the width used. On dark all-day bars the badges are inverted.
+func (r *calendarRenderer) drawPeople(people []PersonData, x, cy float64, inverted bool) float64 {
+	if len(people) == 0 {
+		return 0
+	}
+
+	radius := 7.5
+	r.dc.SetFontFace(r.dc.face(boldFont, 10))
+
+	cx := x + radius
+	for _, p := range people {
+		color := personColor(p.Color)
+		circleColor, textColor := color, colorWhite
+		if inverted {
+			circleColor, textColor = colorWhite, color
+		}
+
+		r.dc.SetHexColor(circleColor)
+		r.dc.DrawCircle(cx, cy, radius)
+		r.dc.Fill()
+
+		r.dc.SetHexColor(textColor)
+		r.dc.DrawStringAnchored(p.Initial, cx, cy, 0.5, 0.35)
+
+		cx += 2*radius + 2
+	}
+
+	r.dc.SetFontFace(r.dc.face(regularFont, 13))
+	return cx - radius - x + 2
+}
+
+func personColor(name string) string {
+	switch name {
+	case "red":
+		return colorRed
+	case "grey":
+		return colorGrey
+	}
+	return colorBlack
+}
+
+func (r *calendarRenderer) drawCancelledEvent(event EventData, x, y, maxWidth float64) {
+	tag := "cancelled"
+	tagWidth, _ := r.dc.MeasureString(tag)
+
+	text := event.Summary
+	if event.Time != "" {
+		text = event.Time + " " + text
+	}
+	text = r.truncateText(text, maxWidth-tagWidth-6)
+	textWidth, textHeight := r.dc.MeasureString(text)
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawString(text, x, y)
+	r.dc.SetLineWidth(1)
+	r.dc.DrawLine(x, y-textHeight/3, x+textWidth, y-textHeight/3)
+	r.dc.Stroke()
+
+	r.dc.SetHexColor(colorRed)
+	r.dc.DrawString(tag, x+textWidth+6, y)
+}
+
+func (r *calendarRenderer) drawAgenda(data TemplateData, startY float64) {
+	numDays := len(data.Days)
+	if numDays == 0 {
+		return
+	}
+
+	labelWidth := 200.0
+	padding := 24.0
+	rowHeight := (r.bottom - startY) / float64(numDays)
+
+	for i, day := range data.Days {
+		rowY := startY + float64(i)*rowHeight
+		date, _ := time.Parse("2006-01-02", day.Date)
+
+		r.drawShade(day.Shade, 0, rowY, float64(r.width), rowHeight)
+
+		weekdayColor := colorBlack
+		if day.IsToday {
+			weekdayColor = colorRed
+			r.setRegion(RegionToday, 0, rowY, float64(r.width), rowHeight)
+		}
+		r.dc.SetHexColor(weekdayColor)
+		r.dc.SetFontFace(r.dc.face(boldFont, 20))
+		r.dc.DrawString(date.Format("Monday"), padding, rowY+32)
+
+		r.dc.SetHexColor(colorGrey)
+		r.dc.SetFontFace(r.dc.face(regularFont, 14))
+		dateText := data.Locale.FormatDayMonth(date)
+		r.dc.DrawString(dateText, padding, rowY+52)
+		if len(day.Waste) > 0 {
+			dateWidth, _ := r.dc.MeasureString(dateText)
+			wasteX := padding + dateWidth + 10
+			r.drawWaste(day.Waste, wasteX, rowY+52, labelWidth-padding-wasteX, 13)
+			r.dc.SetHexColor(colorGrey)
+			r.dc.SetFontFace(r.dc.face(regularFont, 14))
+		}
+
+		if day.DayTemp != "" {
+			temps := fmt.Sprintf("%s / %s", day.DayTemp, day.NightTemp)
+			if day.LastYearTemp != "" {
+				temps = fmt.Sprintf("%s (%s)", temps, day.LastYearTemp)
+			}
+			r.dc.DrawString(temps, padding, rowY+72)
+
+			if day.Snowfall != "" {
+				tempsWidth, _ := r.dc.MeasureString(temps)
+				snowX := padding + tempsWidth + 10
+				r.drawSnowflakeIcon(snowX, rowY+61, 12, colorBlack)
+				r.dc.SetHexColor(colorGrey)
+				r.dc.DrawString(day.Snowfall, snowX+16, rowY+72)
+			}
+		}
+
+		if data.ShowDaylight && day.Sunrise != "" {
+			sun := fmt.Sprintf("☼ %s–%s · %s", day.Sunrise, day.Sunset, day.Daylight)
+			r.dc.DrawString(r.truncateText(sun, labelWidth-padding), padding, rowY+92)
+		}
+
+		r.drawBands(day.Bands, padding, rowY+rowHeight-4, labelWidth-2*padding, true)
+
+		r.drawEvents(day, labelWidth, rowY+12, float64(r.width)-labelWidth-padding, rowHeight-12, false)
+
+		if i < numDays-1 {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawLine(0, rowY+rowHeight, float64(r.width), rowY+rowHeight)
+			r.dc.SetLineWidth(1)
+			r.dc.Stroke()
+		}
+	}
+}
+
+// detailsDescriptionLines caps each event's description in the details
+// view, so one long agenda doesn't push the rest of the day off screen.
+const detailsDescriptionLines = 4
+
+// drawDetails lists today's events one below the other with their time
+// span, location, attendees and description. Events that don't fit are
+// counted in a final "+3 more" line.
+func (r *calendarRenderer) drawDetails(data TemplateData, startY float64) {
+	if len(data.Days) == 0 {
+		return
+	}
+	day := data.Days[0]
+	padding := 24.0
+	width := float64(r.width) - 2*padding
+	r.setRegion(RegionToday, 0, startY, float64(r.width), r.bottom-startY)
+
+	date, _ := time.Parse("2006-01-02", day.Date)
+	y := startY + 36
+	r.dc.SetHexColor(colorRed)
+	r.dc.SetFontFace(r.dc.face(boldFont, 22))
+	r.dc.DrawString("Today, "+date.Format("Monday")+" "+data.Locale.FormatDayMonth(date), padding, y)
+	y += 16
+
+	if len(day.Events) == 0 {
+		r.dc.SetHexColor(colorGrey)
+		r.dc.SetFontFace(r.dc.face(regularFont, 16))
+		r.dc.DrawString("Nothing planned", padding, y+28)
+		return
+	}
+
+	bottom := r.bottom - padding
+	for i, event := range day.Events {
+		lines := r.detailLines(event, width)
+		height := 36 + 22*float64(len(lines))
+		if y+height > bottom {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.SetFontFace(r.dc.face(regularFont, 16))
+			r.dc.DrawString(fmt.Sprintf("+%d more", len(day.Events)-i), padding, min(y+28, bottom))
+			return
+		}
+
+		r.dc.SetHexColor(colorGrey)
+		r.dc.DrawLine(padding, y+8, float64(r.width)-padding, y+8)
+		r.dc.SetLineWidth(1)
+		r.dc.Stroke()
+
+		timeColor, titleColor, summary := colorRed, colorBlack, event.Summary
+		if event.Style.Color != "" {
+			titleColor = personColor(event.Style.Color)
+		}
+		if event.Cancelled {
+			timeColor, titleColor, summary = colorGrey, colorGrey, summary+" (cancelled)"
+		}
+
+		y += 36
+		x := padding
+		r.dc.SetFontFace(r.dc.face(boldFont, 18))
+		if when := event.Time; when != "" {
+			if event.EndTime != "" {
+				when += "–" + event.EndTime
+			}
+			r.dc.SetHexColor(timeColor)
+			r.dc.DrawString(when, x, y)
+			w, _ := r.dc.MeasureString(when + "  ")
+			x += w
+		}
+		r.dc.SetHexColor(titleColor)
+		r.dc.DrawString(r.truncateText(summary, padding+width-x), x, y)
+
+		r.dc.SetFontFace(r.dc.face(regularFont, 15))
+		for _, line := range lines {
+			y += 22
+			r.dc.SetHexColor(line.color)
+			r.dc.DrawString(line.text, padding, y)
+		}
+	}
+}
+
+type detailLine struct {
+	text  string
+	color string
+}
+
+// detailLines lays out the location, attendees and description of event
+// below its title, wrapped to width.
+func (r *calendarRenderer) detailLines(event EventData, width float64) []detailLine {
+	r.dc.SetFontFace(r.dc.face(regularFont, 15))
+	var lines []detailLine
+	if event.Location != "" {
+		lines = append(lines, detailLine{r.truncateText("At "+event.Location, width), colorGrey})
+	}
+	if len(event.Attendees) > 0 {
+		lines = append(lines, detailLine{r.truncateText("With "+strings.Join(event.Attendees, ", "), width), colorGrey})
+	}
+
+	var description []string
+	for _, paragraph := range strings.Split(event.Description, "\n") {
+		description = append(description, r.dc.WordWrap(paragraph, width)...)
+	}
+	if len(description) > detailsDescriptionLines {
+		description = description[:detailsDescriptionLines]
+		last := description[len(description)-1]
+		description[len(description)-1] = r.truncateText(last+" ...", width)
+	}
+	for _, line := range description {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, detailLine{line, colorBlack})
+		}
+	}
+	return lines
+}
+
+// drawBoard draws one row per day and one column per person.
+func (r *calendarRenderer) drawBoard(data TemplateData, startY float64) {
+	numDays := len(data.Days)
+	if numDays == 0 {
+		return
+	}
+
+	labelWidth := 160.0
+	headerHeight := 32.0
+	padding := 24.0
+
+	laneWidth := float64(r.width) - labelWidth
+	if len(data.Lanes) > 0 {
+		laneWidth /= float64(len(data.Lanes))
+	}
+
+	r.dc.SetFontFace(r.dc.face(boldFont, 15))
+	for i, lane := range data.Lanes {
+		laneX := labelWidth + float64(i)*laneWidth
+		r.dc.SetHexColor(personColor(lane.Color))
+		r.dc.DrawString(r.truncateText(lane.Name, laneWidth-12), laneX+6, startY+22)
+	}
+	r.dc.SetHexColor(colorGrey)
+	r.dc.DrawLine(0, startY+headerHeight, float64(r.width), startY+headerHeight)
+	r.dc.SetLineWidth(2)
+	r.dc.Stroke()
+
+	rowsY := startY + headerHeight
+	rowHeight := (r.bottom - rowsY) / float64(numDays)
+
+	for i, day := range data.Days {
+		rowY := rowsY + float64(i)*rowHeight
+		date, _ := time.Parse("2006-01-02", day.Date)
+
+		r.drawShade(day.Shade, 0, rowY, float64(r.width), rowHeight)
+
+		weekdayColor := colorBlack
+		if day.IsToday {
+			weekdayColor = colorRed
+			r.setRegion(RegionToday, 0, rowY, float64(r.width), rowHeight)
+		}
+		r.dc.SetHexColor(weekdayColor)
+		r.dc.SetFontFace(r.dc.face(boldFont, 18))
+		r.dc.DrawString(date.Format("Monday"), padding, rowY+26)
+
+		r.dc.SetHexColor(colorGrey)
+		r.dc.SetFontFace(r.dc.face(regularFont, 13))
+		r.dc.DrawString(data.Locale.FormatDayMonth(date), padding, rowY+44)
+		if day.DayTemp != "" {
+			r.dc.DrawString(fmt.Sprintf("%s / %s", day.DayTemp, day.NightTemp), padding, rowY+62)
+		}
+		if len(day.Waste) > 0 {
+			r.drawWaste(day.Waste, padding, rowY+80, labelWidth-2*padding, 12)
+		}
+
+		r.drawBands(day.Bands, padding, rowY+rowHeight-4, labelWidth-padding-12, true)
+
+		for l, events := range day.Lanes {
+			laneX := labelWidth + float64(l)*laneWidth
+			r.drawEvents(DayData{Events: events}, laneX, rowY+6, laneWidth, rowHeight-6, false)
+		}
+
+		if i < numDays-1 {
+			r.dc.SetHexColor(colorGrey)
+			r.dc.DrawLine(0, rowY+rowHeight, float64(r.width), rowY+rowHeight)
+			r.dc.SetLineWidth(1)
+			r.dc.Stroke()
+		}
+	}
+
+	r.dc.SetHexColor(colorGrey)
+	r.dc.SetLineWidth(1)
+	for i := range data.Lanes {
+		laneX := labelWidth + float64(i)*laneWidth
+		r.dc.DrawLine(laneX, startY, laneX, r.bottom)
+		r.dc.Stroke()
+	}
+}
+
+// drawImages composites each image into its corner, scaled to the
+// configured size.
+func (r *calendarRenderer) drawImages(images []ImageData) {
+	for _, img := range images {
+		bounds := img.Image.Bounds()
+		srcW, srcH := float64(bounds.Dx()), float64(bounds.Dy())
+		if srcW == 0 || srcH == 0 {
+			continue
+		}
+
+		w, h := float64(img.Width), float64(img.Height)
+		switch {
+		case w == 0 && h == 0:
+			w, h = srcW, srcH
+		case w == 0:
+			w = srcW * h / srcH
+		case h == 0:
+			h = srcH * w / srcW
+		}
+
+		x, y := r.cornerPosition(img.Corner, w, h, float64(img.Margin))
+		r.dc.Push()
+		r.dc.Translate(x, y)
+		r.dc.Scale(w/srcW, h/srcH)
+		r.dc.DrawImage(img.Image, -bounds.Min.X, -bounds.Min.Y)
+		r.dc.Pop()
+	}
+}
+
+// drawQR draws the QR code on a white tile with a two-module quiet zone
+// and the optional label underneath. Modules are whole pixels so the code
+// stays sharp on e-ink; the tile may come out slightly smaller than Size.
+func (r *calendarRenderer) drawQR(qr *QRData) {
+	if qr == nil || len(qr.Modules) == 0 {
+		return
+	}
+
+	const quietZone = 2
+	n := len(qr.Modules)
+	module := max(1, qr.Size/(n+2*quietZone))
+	size := float64(module * (n + 2*quietZone))
+
+	labelHeight := 0.0
+	if qr.Label != "" {
+		labelHeight = 18
+	}
+
+	x, y := r.cornerPosition(qr.Corner, size, size+labelHeight, float64(qr.Margin))
+
+	r.dc.SetHexColor(colorWhite)
+	r.dc.DrawRectangle(x, y, size, size+labelHeight)
+	r.dc.Fill()
+
+	r.dc.SetHexColor(colorBlack)
+	origin := float64(module * quietZone)
+	for row, modules := range qr.Modules {
+		for col, dark := range modules {
+			if dark {
+				r.dc.DrawRectangle(x+origin+float64(col*module), y+origin+float64(row*module), float64(module), float64(module))
+			}
+		}
+	}
+	r.dc.Fill()
+
+	if qr.Label != "" {
+		r.dc.SetFontFace(r.dc.face(regularFont, 12))
+		r.dc.SetHexColor(colorGrey)
+		label := r.truncateText(qr.Label, size)
+		r.dc.DrawStringAnchored(label, x+size/2, y+size+labelHeight/2-2, 0.5, 0.5)
+	}
+}
+
+// cornerPosition returns the top-left point of a w×h box placed margin
+// pixels from the edges in the given corner.
+func (r *calendarRenderer) cornerPosition(corner string, w, h, margin float64) (float64, float64) {
+	x, y := margin, margin
+	if strings.HasSuffix(corner, "right") {
+		x = float64(r.width) - margin - w
+	}
+	if strings.HasPrefix(corner, "bottom") {
+		y = float64(r.height) - margin - h
+	}
+	return x, y
+}
+
 func (r *calendarRenderer) truncateText(text string, maxWidth float64) string {
 	textWidth, _ := r.dc.MeasureString(text)
 	if textWidth <= maxWidth {
@@ -264,23 +1343,180 @@ func (r *calendarRenderer) truncateText(text string, maxWidth float64) string {
 	return ellipsis
 }
 
-func (r *calendarRenderer) savePNG(outputPath string) error {
-	return r.dc.SavePNG(outputPath)
+// Options tune how an image is produced without affecting its content.
+type Options struct {
+	// LowMemory, for boards like the Pi Zero, encodes the PNG with the
+	// fastest compression through a 4 KB write buffer instead of 64 KB.
+	// The encoder writes to the file either way, so it mostly saves CPU
+	// time at the cost of a larger file.
+	LowMemory bool
+
+	// Variants are scaled copies written next to the main image.
+	Variants []Variant
+
+	// Scale draws at this multiple of the display size and downsamples
+	// the result for crisper text; 0 and 1 draw at display size.
+	Scale int
+
+	// LargePrint draws everything LargePrintZoom times larger and raises
+	// the contrast, for readers who need bigger text.
+	LargePrint bool
+
+	// Metadata is written as PNG tEXt chunks, keyed e.g. by MetaExpires.
+	Metadata map[string]string
+
+	// Trace, when set, receives how long each rendering stage (draw,
+	// encode, variants) took.
+	Trace func(stage string, d time.Duration)
+}
+
+// trace reports a stage that started at start to opts.Trace.
+func (opts Options) trace(stage string, start time.Time) {
+	if opts.Trace != nil {
+		opts.Trace(stage, time.Since(start))
+	}
+}
+
+// writePNG encodes img next to outputPath and renames it into place, so
+// the server never reads a half-written image.
+func writePNG(img image.Image, outputPath string, opts Options) error {
+	f, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// CreateTemp makes the file private; web servers serving it must read it.
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := encodePNG(f, img, opts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), outputPath)
+}
+
+// encodePNG writes img with opts.Metadata, buffered and compressed per
+// opts.LowMemory.
+func encodePNG(out io.Writer, img image.Image, opts Options) error {
+	encoder := &png.Encoder{CompressionLevel: png.DefaultCompression}
+	bufSize := 64 * 1024
+	if opts.LowMemory {
+		encoder.CompressionLevel = png.BestSpeed
+		bufSize = 4 * 1024
+	}
+
+	w := bufio.NewWriterSize(out, bufSize)
+	if err := encoder.Encode(newTextWriter(w, opts.Metadata), img); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// zoom returns how much larger the layout is drawn.
+func (opts Options) zoom() float64 {
+	if opts.LargePrint {
+		return LargePrintZoom
+	}
+	return 1
 }
 
-func RenderCalendarToPNG(data TemplateData, outputPath string) error {
-	renderer := newCalendarRenderer(data.Width, data.Height)
+// draw lays out the whole view.
+func (r *calendarRenderer) draw(data TemplateData) {
+	r.drawHeader(data)
 
-	renderer.drawHeader(data)
+	bannerY := r.drawAlertBanner(data.Alerts, 60)
+	bannerY = r.drawSuggestionBanner(data.Suggestions, bannerY)
+	bannerY = r.drawCountdowns(data.Countdowns, bannerY)
+	bannerY = r.drawSpotPrices(data.SpotPrices, bannerY)
+	r.drawQuote(data.Quote)
 
-	weekdayY := renderer.drawWeekdayHeaders(60)
+	switch data.View {
+	case ViewAgenda:
+		r.drawAgenda(data, bannerY)
+	case ViewBoard:
+		r.drawBoard(data, bannerY)
+	case ViewDetails:
+		r.drawDetails(data, bannerY)
+	default:
+		weekdayY := r.drawWeekdayHeaders(bannerY)
+		r.drawCalendarGrid(data, weekdayY)
+	}
+
+	r.drawImages(data.Images)
+	r.drawQR(data.QR)
+}
 
-	renderer.drawCalendarGrid(data, weekdayY)
+// rasterize draws data and returns the image with where its named parts
+// were drawn, in output pixels.
+func rasterize(data TemplateData, opts Options) (image.Image, map[string]image.Rectangle) {
+	zoom := opts.zoom()
+	renderer := newCalendarRenderer(newCanvas(data.Width, data.Height, zoom, float64(max(opts.Scale, 1))), zoom)
+	renderer.draw(data)
 
-	return renderer.savePNG(outputPath)
+	img := renderer.dc.Image()
+	if opts.LargePrint {
+		img = highContrast(img)
+	}
+	regions := make(map[string]image.Rectangle, len(renderer.regions))
+	for name, rect := range renderer.regions {
+		regions[name] = image.Rect(
+			int(float64(rect.Min.X)*zoom), int(float64(rect.Min.Y)*zoom),
+			int(float64(rect.Max.X)*zoom+0.5), int(float64(rect.Max.Y)*zoom+0.5),
+		)
+	}
+	return img, regions
 }
 
-func RenderErrorToPNG(width, height int, errorMsg string, errorDetails map[string]string, outputPath string) error {
+func RenderCalendarToPNG(data TemplateData, outputPath string, opts Options) error {
+	start := time.Now()
+	img, regions := rasterize(data, opts)
+	opts.trace("draw", start)
+
+	start = time.Now()
+	if err := writePNG(img, outputPath, opts); err != nil {
+		return err
+	}
+	opts.trace("encode", start)
+
+	if len(opts.Variants) == 0 {
+		return nil
+	}
+	start = time.Now()
+	defer opts.trace("variants", start)
+	return writeVariants(img, regions, opts.Variants, opts)
+}
+
+// LargePrintZoom is how much larger large print draws the layout.
+const LargePrintZoom = 1.4
+
+// highContrast stretches every channel around its midpoint, which darkens
+// grey text, sharpens antialiased edges and keeps white and saturated
+// colors, so palette reduction and dithering produce bolder strokes.
+func highContrast(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	stretch := func(v uint32) uint8 {
+		return uint8(min(255, max(0, (int(v>>8)-128)*3/2+128)))
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := src.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			dst.SetRGBA(x, y, color.RGBA{R: stretch(r), G: stretch(g), B: stretch(bl), A: 0xff})
+		}
+	}
+	return dst
+}
+
+// RenderErrorToPNG draws errorMsg with errorDetails and, after a panic, the
+// stack as far as it fits.
+func RenderErrorToPNG(width, height int, errorMsg string, errorDetails map[string]string, stack string, outputPath string) error {
+	loadFonts()
 	dc := gg.NewContext(width, height)
 	dc.SetHexColor(colorWhite)
 	dc.Clear()
@@ -302,13 +1538,32 @@ func RenderErrorToPNG(width, height int, errorMsg string, errorDetails map[strin
 
 	dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 14}))
 	currentY := padding + 220.0
-	for key, value := range errorDetails {
+	keys := make([]string, 0, len(errorDetails))
+	for key := range errorDetails {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
 		dc.SetHexColor(colorBlack)
 		dc.DrawString(fmt.Sprintf("%s:", key), padding+30, currentY)
 		dc.SetHexColor(colorGrey)
-		dc.DrawString(value, padding+150, currentY)
+		dc.DrawString(errorDetails[key], padding+150, currentY)
 		currentY += 25
 	}
 
-	return dc.SavePNG(outputPath)
+	if stack != "" {
+		const lineHeight = 14.0
+		dc.SetFontFace(truetype.NewFace(regularFont, &truetype.Options{Size: 11}))
+		dc.SetHexColor(colorGrey)
+		currentY += 10
+		for _, line := range strings.Split(strings.TrimSpace(stack), "\n") {
+			if currentY > float64(height)-padding-10 {
+				break
+			}
+			dc.DrawString(strings.ReplaceAll(line, "\t", "    "), padding+30, currentY)
+			currentY += lineHeight
+		}
+	}
+
+	return writePNG(dc.Image(), outputPath, Options{})
 }
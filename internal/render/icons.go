@@ -0,0 +1,117 @@
+package render
+
+import "math"
+
+// The embedded fonts have no symbol glyphs, so small icons are drawn with
+// vector primitives. Each helper draws into a size×size box at (x, y).
+
+func (r *calendarRenderer) drawWarningIcon(x, y, size float64, color, markColor string) {
+	r.dc.SetHexColor(color)
+	r.dc.MoveTo(x+size/2, y)
+	r.dc.LineTo(x+size, y+size)
+	r.dc.LineTo(x, y+size)
+	r.dc.ClosePath()
+	r.dc.Fill()
+
+	r.dc.SetHexColor(markColor)
+	r.dc.SetLineWidth(size / 8)
+	r.dc.DrawLine(x+size/2, y+size*0.35, x+size/2, y+size*0.7)
+	r.dc.Stroke()
+	r.dc.DrawCircle(x+size/2, y+size*0.84, size/14)
+	r.dc.Fill()
+}
+
+func (r *calendarRenderer) drawSnowflakeIcon(x, y, size float64, color string) {
+	cx, cy := x+size/2, y+size/2
+	arm := size / 2
+	branch := size / 5
+
+	r.dc.SetHexColor(color)
+	r.dc.SetLineWidth(size / 10)
+	for i := 0; i < 6; i++ {
+		angle := float64(i) * math.Pi / 3
+		ex, ey := cx+arm*math.Cos(angle), cy+arm*math.Sin(angle)
+		r.dc.DrawLine(cx, cy, ex, ey)
+
+		// A small V two thirds along each arm.
+		bx, by := cx+arm*0.6*math.Cos(angle), cy+arm*0.6*math.Sin(angle)
+		for _, side := range []float64{-1, 1} {
+			a := angle + side*math.Pi/4
+			r.dc.DrawLine(bx, by, bx+branch*math.Cos(a), by+branch*math.Sin(a))
+		}
+	}
+	r.dc.Stroke()
+}
+
+// drawCameraIcon draws a video camera: a body with a lens cone on the right.
+func (r *calendarRenderer) drawCameraIcon(x, y, size float64, color string) {
+	bodyWidth := size * 0.65
+	bodyHeight := size * 0.55
+	top := y + (size-bodyHeight)/2
+
+	r.dc.SetHexColor(color)
+	r.dc.DrawRoundedRectangle(x, top, bodyWidth, bodyHeight, size/10)
+	r.dc.Fill()
+
+	r.dc.MoveTo(x+bodyWidth-size*0.05, y+size/2)
+	r.dc.LineTo(x+size, top)
+	r.dc.LineTo(x+size, top+bodyHeight)
+	r.dc.ClosePath()
+	r.dc.Fill()
+}
+
+// drawUmbrellaIcon draws an open umbrella: a half-disc canopy on a hooked
+// shaft.
+func (r *calendarRenderer) drawUmbrellaIcon(x, y, size float64, color string) {
+	cx := x + size/2
+	canopy := y + size*0.5
+
+	r.dc.SetHexColor(color)
+	r.dc.DrawArc(cx, canopy, size/2, math.Pi, 2*math.Pi)
+	r.dc.ClosePath()
+	r.dc.Fill()
+
+	r.dc.SetLineWidth(size / 10)
+	r.dc.DrawLine(cx, canopy, cx, y+size*0.85)
+	r.dc.Stroke()
+	r.dc.DrawArc(cx-size/8, y+size*0.85, size/8, 0, math.Pi)
+	r.dc.Stroke()
+}
+
+// drawBinIcon draws a waste bin: a tapered body under a lid with a handle.
+func (r *calendarRenderer) drawBinIcon(x, y, size float64, color string) {
+	r.dc.SetHexColor(color)
+	r.dc.DrawRectangle(x+size*0.4, y, size*0.2, size*0.1)
+	r.dc.DrawRectangle(x+size*0.1, y+size*0.1, size*0.8, size*0.12)
+	r.dc.MoveTo(x+size*0.18, y+size*0.28)
+	r.dc.LineTo(x+size*0.82, y+size*0.28)
+	r.dc.LineTo(x+size*0.74, y+size)
+	r.dc.LineTo(x+size*0.26, y+size)
+	r.dc.ClosePath()
+	r.dc.Fill()
+}
+
+// drawBallIcon draws a football: a disc with a pentagon patch in the
+// middle and stubs of the seams running to the edge.
+func (r *calendarRenderer) drawBallIcon(x, y, size float64, color, background string) {
+	cx, cy := x+size/2, y+size/2
+	radius := size / 2
+
+	r.dc.SetHexColor(color)
+	r.dc.DrawCircle(cx, cy, radius)
+	r.dc.Fill()
+
+	r.dc.SetHexColor(background)
+	r.dc.DrawCircle(cx, cy, radius*0.8)
+	r.dc.Fill()
+
+	r.dc.SetHexColor(color)
+	r.dc.DrawRegularPolygon(5, cx, cy, radius*0.38, -math.Pi/2)
+	r.dc.Fill()
+	r.dc.SetLineWidth(size / 12)
+	for i := 0; i < 5; i++ {
+		a := -math.Pi/2 + float64(i)*2*math.Pi/5
+		r.dc.DrawLine(cx+radius*0.38*math.Cos(a), cy+radius*0.38*math.Sin(a), cx+radius*math.Cos(a), cy+radius*math.Sin(a))
+	}
+	r.dc.Stroke()
+}
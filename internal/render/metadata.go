@@ -0,0 +1,125 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// tEXt keywords Calvin writes. Times are RFC 3339.
+const (
+	// MetaCreated is when the image was rendered (a standard keyword).
+	MetaCreated = "Creation Time"
+	// MetaSoftware is the Calvin version (a standard keyword).
+	MetaSoftware = "Software"
+	// MetaNextRefresh is when the next render is expected.
+	MetaNextRefresh = "Next Refresh"
+	// MetaBattery is the battery level, when it was read.
+	MetaBattery = "Battery"
+	// MetaExpires is when the image counts as stale.
+	MetaExpires = "Expires"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// ihdrEnd is the offset right after the IHDR chunk, which the PNG encoder
+// always writes first with 13 bytes of data.
+const ihdrEnd = 8 + 4 + 4 + 13 + 4
+
+// textWriter inserts tEXt chunks after the IHDR chunk of a PNG streamed
+// through it, so the encoder keeps writing straight to the file.
+type textWriter struct {
+	w    io.Writer
+	n    int
+	text []byte
+}
+
+func newTextWriter(w io.Writer, metadata map[string]string) io.Writer {
+	if len(metadata) == 0 {
+		return w
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var text bytes.Buffer
+	for _, k := range keys {
+		data := append(append([]byte(k), 0), metadata[k]...)
+		binary.Write(&text, binary.BigEndian, uint32(len(data)))
+		chunk := append([]byte("tEXt"), data...)
+		text.Write(chunk)
+		binary.Write(&text, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	}
+	return &textWriter{w: w, text: text.Bytes()}
+}
+
+func (t *textWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if t.text != nil && t.n+len(p) >= ihdrEnd {
+		k := ihdrEnd - t.n
+		if _, err := t.w.Write(p[:k]); err != nil {
+			return 0, err
+		}
+		if _, err := t.w.Write(t.text); err != nil {
+			return k, err
+		}
+		t.text = nil
+		t.n += k
+		p = p[k:]
+	}
+	n, err := t.w.Write(p)
+	t.n += n
+	return total - len(p) + n, err
+}
+
+// PNGText returns the tEXt chunks of a PNG file. It stops at the image
+// data, since Calvin writes its chunks before it.
+func PNGText(data []byte) map[string]string {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil
+	}
+	text := make(map[string]string)
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		end := pos + 8 + length + 4
+		if typ == "IDAT" || length < 0 || end > len(data) {
+			break
+		}
+		if typ == "tEXt" {
+			if k, v, ok := bytes.Cut(data[pos+8:pos+8+length], []byte{0}); ok {
+				text[string(k)] = string(v)
+			}
+		}
+		pos = end
+	}
+	return text
+}
+
+// MarkStale returns img with a red frame, the sign that the frame shows an
+// image older than its expiry because updates stopped arriving.
+func MarkStale(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+
+	red := image.NewUniform(color.RGBA{R: 0xdc, G: 0x35, B: 0x45, A: 0xff})
+	w := max(3, min(b.Dx(), b.Dy())/100)
+	inner := dst.Bounds().Inset(w)
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, b.Dx(), inner.Min.Y),
+		image.Rect(0, inner.Max.Y, b.Dx(), b.Dy()),
+		image.Rect(0, inner.Min.Y, inner.Min.X, inner.Max.Y),
+		image.Rect(inner.Max.X, inner.Min.Y, b.Dx(), inner.Max.Y),
+	} {
+		draw.Draw(dst, r, red, image.Point{}, draw.Src)
+	}
+	return dst
+}
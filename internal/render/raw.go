@@ -0,0 +1,117 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"os"
+)
+
+// Raw buffer presets for common e-paper controllers.
+const (
+	// RawSSD1680 is two 1 bpp planes, MSB first: black/white (1 = white)
+	// followed by red (1 = red). Used by many small black/white/red tags.
+	RawSSD1680 = "ssd1680"
+	// RawIT8951 is 4 bpp greyscale (0 = black, 15 = white), first pixel in
+	// the low nibble, rows padded to 16-bit words.
+	RawIT8951 = "it8951"
+	// RawUC8159 is 4 bpp 7-color ACeP, first pixel in the high nibble,
+	// using the controller's color indices (0 black, 1 white, 4 red, ...).
+	RawUC8159 = "uc8159"
+)
+
+type rawPreset struct {
+	// palette is ordered so that the palette index is the value encode
+	// writes for a pixel.
+	palette color.Palette
+	encode  func(img *image.Paletted) []byte
+}
+
+var rawPresets = map[string]rawPreset{
+	RawSSD1680: {
+		palette: color.Palette{color.White, color.Black, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		encode:  encodeSSD1680,
+	},
+	RawIT8951: {
+		palette: greyPalette(16),
+		encode:  encodeIT8951,
+	},
+	RawUC8159: {
+		palette: color.Palette{
+			color.Black,
+			color.White,
+			color.RGBA{0x00, 0xff, 0x00, 0xff},
+			color.RGBA{0x00, 0x00, 0xff, 0xff},
+			color.RGBA{0xff, 0x00, 0x00, 0xff},
+			color.RGBA{0xff, 0xff, 0x00, 0xff},
+			color.RGBA{0xff, 0x80, 0x00, 0xff},
+		},
+		encode: encodeUC8159,
+	},
+}
+
+// writeRaw reduces img to the preset's colors and writes the controller's
+// frame buffer layout to path.
+func writeRaw(img image.Image, preset string, dither bool, path string) error {
+	p := rawPresets[preset]
+	return os.WriteFile(path, p.encode(reducePalette(img, p.palette, dither)), 0644)
+}
+
+func encodeSSD1680(img *image.Paletted) []byte {
+	const white, red = 0, 2
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	stride := (w + 7) / 8
+	bw := make([]byte, stride*h)
+	rd := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			bit := byte(0x80) >> (x % 8)
+			switch img.ColorIndexAt(x, y) {
+			case white:
+				bw[y*stride+x/8] |= bit
+			case red:
+				// The red plane wins; keep the black/white plane white
+				// underneath so panels without red show nothing there.
+				bw[y*stride+x/8] |= bit
+				rd[y*stride+x/8] |= bit
+			}
+		}
+	}
+	return append(bw, rd...)
+}
+
+func encodeIT8951(img *image.Paletted) []byte {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	stride := (w + 3) / 4 * 2
+	buf := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			shift := 4 * (x % 2)
+			buf[y*stride+x/2] |= img.ColorIndexAt(x, y) << shift
+		}
+	}
+	return buf
+}
+
+func encodeUC8159(img *image.Paletted) []byte {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	stride := (w + 1) / 2
+	buf := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			shift := 4 * (1 - x%2)
+			buf[y*stride+x/2] |= img.ColorIndexAt(x, y) << shift
+		}
+	}
+	return buf
+}
+
+// greyPalette returns levels evenly spaced greys from black to white.
+func greyPalette(levels int) color.Palette {
+	p := make(color.Palette, levels)
+	for i := range p {
+		v := uint8(i * 255 / (levels - 1))
+		p[i] = color.Gray{Y: v}
+	}
+	return p
+}
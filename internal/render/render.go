@@ -2,79 +2,953 @@ package render
 
 import (
 	"fmt"
+	"html"
+	"image"
+	"math"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/paveljanda/calvin/internal/alerts"
 	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/locale"
+	"github.com/paveljanda/calvin/internal/quote"
+	"github.com/paveljanda/calvin/internal/script"
+	"github.com/paveljanda/calvin/internal/spotprice"
+	"github.com/paveljanda/calvin/internal/ticker"
+	"github.com/paveljanda/calvin/internal/waste"
 	"github.com/paveljanda/calvin/internal/weather"
 )
 
+// Views selectable with display.views.
+const (
+	ViewMonth  = "month"
+	ViewAgenda = "agenda"
+	ViewBoard  = "board"
+	// ViewDetails lists today's events with their location, attendees and
+	// description, e.g. as the second screen of a two-screen frame.
+	ViewDetails = "details"
+	// ViewRolling is a month-like grid of the previous, current and next
+	// three weeks, crossing month boundaries.
+	ViewRolling = "rolling"
+)
+
+// maxWeekRows is how many week rows the month view keeps once the first
+// weeks of a six-row month are over.
+const maxWeekRows = 5
+
+// rollingWeeks is how many week rows the rolling view shows, starting
+// with the previous week.
+const rollingWeeks = 5
+
+// agendaDays is how many days, starting today, the agenda view lists.
+const agendaDays = 7
+
+// boardDays is how many days, starting today, the board view lists.
+const boardDays = 7
+
+// monthForecastDays is how many days, starting today, the month view shows
+// temperatures for.
+const monthForecastDays = 8
+
+// rollingForecastDays is how many days, starting today, the rolling view
+// shows at most: the rest of this week and the three after it.
+const rollingForecastDays = 7 * (rollingWeeks - 1)
+
+// alertWindow is how far ahead an alert may start and still be shown.
+const alertWindow = 24 * time.Hour
+
+// ForecastDays returns how many days, starting today, view shows the
+// forecast for, so the weather request covers exactly the rendered window.
+// Views reaching further, like the rolling view's last weeks, are cut to
+// the weather.MaxForecastDays that Open-Meteo serves.
+func ForecastDays(view string) int {
+	days := monthForecastDays
+	switch view {
+	case ViewAgenda:
+		days = agendaDays
+	case ViewBoard:
+		days = boardDays
+	case ViewDetails:
+		days = 1
+	case ViewRolling:
+		days = rollingForecastDays
+	}
+	return min(days, weather.MaxForecastDays)
+}
+
 type TemplateData struct {
-	Width             int
-	Height            int
-	MonthName         string
-	Year              int
-	GeneratedAt       string
-	BatteryPercentage string
-	WeatherError      string
-	Weeks             []WeekData
+	View              string           `json:"view"`
+	Width             int              `json:"width"`
+	Height            int              `json:"height"`
+	MonthName         string           `json:"month_name"`
+	Year              int              `json:"year"`
+	GeneratedAt       string           `json:"generated_at"`
+	RunTime           string           `json:"run_time"`
+	Uptime            string           `json:"uptime"`
+	BatteryPercentage string           `json:"battery_percentage"`
+	BatteryError      string           `json:"battery_error"`
+	WeatherError      string           `json:"weather_error"`
+	Alerts            []AlertData      `json:"alerts"`
+	Suggestions       []SuggestionData `json:"suggestions"`
+	Countdowns        []CountdownData  `json:"countdowns"`
+	Widgets           []WidgetData     `json:"widgets"`
+	Comparison        []ComparisonData `json:"comparison"`
+	Weeks             []WeekData       `json:"weeks"`
+
+	// ShowDaylight draws the sunrise to sunset span of each forecast day.
+	ShowDaylight bool `json:"show_daylight"`
+
+	// UpdatedAt is the generation time of day, set when the header shows
+	// it as "Updated 07:00".
+	UpdatedAt string `json:"updated_at"`
+
+	// Days lists consecutive days for the agenda and board views.
+	Days []DayData `json:"days"`
+
+	// Lanes are the board view's columns; each day's Lanes line up with
+	// them.
+	Lanes []LaneData `json:"lanes"`
+
+	// Locale formats the dates the renderers draw themselves.
+	Locale locale.Locale `json:"locale"`
+
+	// NextEvent is the header countdown, if enabled and any event is
+	// coming up.
+	NextEvent *NextEventData `json:"next_event"`
+
+	// Images are drawn over the finished view.
+	Images []ImageData `json:"images"`
+	QR     *QRData     `json:"qr"`
+
+	// Quote fills a footer line below the view.
+	Quote *QuoteData `json:"quote"`
+
+	Tickers []TickerData `json:"tickers"`
+
+	// SpotPrices is the electricity price strip below the header.
+	SpotPrices *SpotPriceData `json:"spot_prices"`
+}
+
+// ImageData is a static image placed in a corner of the display.
+type ImageData struct {
+	Image image.Image `json:"-"`
+	// Corner is top-left, top-right, bottom-left or bottom-right.
+	Corner string `json:"corner"`
+	// Width and Height scale the image; zero keeps the aspect ratio, or
+	// the original size when both are zero.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Margin int `json:"margin"`
+}
+
+// QRData is a QR code placed in a corner of the display.
+type QRData struct {
+	// Modules are the dark (true) and light modules without a quiet zone.
+	Modules [][]bool `json:"modules"`
+	Label   string   `json:"label"`
+	Corner  string   `json:"corner"`
+	Size    int      `json:"size"`
+	Margin  int      `json:"margin"`
+}
+
+// NextEventData is "Dentist in 2h 15m" in the header.
+type NextEventData struct {
+	Summary string `json:"summary"`
+	// In is the time until the event starts, e.g. "2h 15m".
+	In string `json:"in"`
+}
+
+// LaneData is a board view column.
+type LaneData struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// SpotPriceData is a day of hourly electricity prices.
+type SpotPriceData struct {
+	Hours []SpotHourData `json:"hours"`
+	// Now is the price of the current hour with its unit, e.g.
+	// "8.2 ct/kWh"; Low is the cheapest hour, e.g. "5.1 at 13:00".
+	Now string `json:"now"`
+	Low string `json:"low"`
+}
+
+// SpotHourData is one bar of the strip.
+type SpotHourData struct {
+	Hour int `json:"hour"`
+	// Level is the bar height from 0 (the day's lowest price, or zero
+	// if lower) to 1 (the highest).
+	Level   float64 `json:"level"`
+	Cheap   bool    `json:"cheap"`
+	Current bool    `json:"current"`
+	Past    bool    `json:"past"`
+}
+
+// TickerData is "AAPL 187.20 ▲1.2%" in the header.
+type TickerData struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+	Change string `json:"change"`
+	// Down marks a falling price, whose change is drawn in red.
+	Down bool `json:"down"`
+}
+
+// QuoteData is the quote of the day.
+type QuoteData struct {
+	Text   string `json:"text"`
+	Author string `json:"author"`
+}
+
+type WidgetData struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// ComparisonData is today's forecast for one location of the weather
+// comparison row.
+type ComparisonData struct {
+	Label     string `json:"label"`
+	DayTemp   string `json:"day_temp"`
+	NightTemp string `json:"night_temp"`
+}
+
+type AlertData struct {
+	Text     string `json:"text"`
+	Severity string `json:"severity"`
+}
+
+// CountdownData is a "12 days until Vacation" line.
+type CountdownData struct {
+	// Days is e.g. "12 days" or "1 day".
+	Days    string `json:"days"`
+	Summary string `json:"summary"`
+}
+
+// SuggestionData is a matched weather suggestion; Icon is umbrella,
+// snowflake, warning or empty.
+type SuggestionData struct {
+	Text string `json:"text"`
+	Icon string `json:"icon"`
 }
 
 type WeekData struct {
-	Days []DayData
+	Days []DayData `json:"days"`
 }
 
 type DayData struct {
-	Date           string
-	DayNum         string
-	MonthShort     string
-	IsToday        bool
-	IsPast         bool
-	IsWeekend      bool
-	IsCurrentMonth bool
-	DayTemp        string
-	NightTemp      string
-	Events         []EventData
+	Date           string `json:"date"`
+	DayNum         string `json:"day_num"`
+	MonthShort     string `json:"month_short"`
+	ShowMonth      bool   `json:"show_month"`
+	IsToday        bool   `json:"is_today"`
+	IsPast         bool   `json:"is_past"`
+	IsWeekend      bool   `json:"is_weekend"`
+	IsCurrentMonth bool   `json:"is_current_month"`
+	DayTemp        string `json:"day_temp"`
+	NightTemp      string `json:"night_temp"`
+	LastYearTemp   string `json:"last_year_temp"`
+	// Sunrise and Sunset are local times such as "7:04" and Daylight is
+	// e.g. "10h 41m"; all empty outside the forecast. The fractions place
+	// sunrise and sunset within the day, from 0 to 1.
+	Sunrise         string  `json:"sunrise"`
+	Sunset          string  `json:"sunset"`
+	Daylight        string  `json:"daylight"`
+	SunriseFraction float64 `json:"sunrise_fraction"`
+	SunsetFraction  float64 `json:"sunset_fraction"`
+	// Snowfall is set on forecast days with snowfall, SnowDepth whenever
+	// there is snow cover; both need weather.snow.
+	Snowfall  string `json:"snowfall"`
+	SnowDepth string `json:"snow_depth"`
+	// WeatherCode is the day's most severe WMO weather code, Icon its icon
+	// name (clear, partly-cloudy, cloudy, fog, drizzle, rain, sleet, snow,
+	// showers, snow-showers or thunderstorm) and Description e.g. "Light
+	// rain"; all zero outside the forecast.
+	WeatherCode int    `json:"weather_code"`
+	Icon        string `json:"icon"`
+	Description string `json:"description"`
+	// EventCount counts the day's events, including those cut by the
+	// per-day limit but not cancelled ones; Busyness is EventCount relative to that limit,
+	// capped at 1.
+	EventCount int     `json:"event_count"`
+	Busyness   float64 `json:"busyness"`
+	// IsHoliday is set when a holiday calendar has an event that day.
+	IsHoliday bool `json:"is_holiday"`
+	// Vacation names the vacation the day falls into, if any.
+	Vacation   string `json:"vacation"`
+	IsVacation bool   `json:"is_vacation"`
+	// Shade is the cell tint from 0 (none) to 1, per display.shade.
+	Shade float64 `json:"shade"`
+	// Waste names the waste pickups of the day.
+	Waste []string `json:"waste"`
+	// Bands are the named periods the day falls into.
+	Bands  []BandData  `json:"bands"`
+	Events []EventData `json:"events"`
+	// Lanes holds the day's events per board column.
+	Lanes [][]EventData `json:"lanes"`
 }
 
 type EventData struct {
-	Time    string
-	Summary string
-	AllDay  bool
+	Time    string `json:"time"`
+	Summary string `json:"summary"`
+	AllDay  bool   `json:"all_day"`
+	// IsNew marks events added or moved since the previous refresh.
+	IsNew bool `json:"is_new"`
+	// Cancelled marks events that disappeared since the previous refresh.
+	Cancelled bool `json:"cancelled"`
+	// People lists the configured people invited to the event.
+	People []PersonData `json:"people"`
+	// VideoCall marks events with a video-call link.
+	VideoCall bool `json:"video_call"`
+	// Fixture marks sports fixtures; Time is the kickoff.
+	Fixture bool `json:"fixture"`
+	// TightTravel marks events at a different location that start too
+	// soon after the previous one ends.
+	TightTravel bool `json:"tight_travel"`
+	// Important events are drawn emphasized, also on past days.
+	Important bool `json:"important"`
+	// Minor events (free time, working locations) are drawn in grey.
+	Minor bool `json:"minor"`
+	// CalendarName is the event's source and Style its look; Summary
+	// already starts with the style's prefix.
+	CalendarName string        `json:"calendar_name"`
+	Style        CalendarStyle `json:"style"`
+	// EndTime, Location, Description (as plain text) and Attendees (names
+	// of configured people, emails otherwise) are shown by the details
+	// view; all empty for private events.
+	EndTime     string   `json:"end_time"`
+	Location    string   `json:"location"`
+	Description string   `json:"description"`
+	Attendees   []string `json:"attendees"`
+}
+
+// CalendarStyle sets how the events of a calendar source are drawn.
+type CalendarStyle struct {
+	Prefix string `json:"prefix"`
+	// Color is black, red or grey; empty keeps the default.
+	Color string `json:"color"`
+	Bold  bool   `json:"bold"`
 }
 
-func PrepareMonthData(width, height int, weatherData *weather.Forecast, weatherErr error, events []calendar.Event, maxEventsPerDay int, batteryPercentage string) TemplateData {
-	now := time.Now()
+// title returns summary with the style's prefix.
+func (s CalendarStyle) title(summary string) string {
+	if s.Prefix == "" {
+		return summary
+	}
+	return s.Prefix + " " + summary
+}
+
+// BandData is a named period, e.g. "Spring break", drawn as a band across
+// its days.
+type BandData struct {
+	Name string `json:"name"`
+	// First marks the period's first day.
+	First bool `json:"first"`
+}
+
+// PersonData is a person's badge next to an event.
+type PersonData struct {
+	Initial string `json:"initial"`
+	// Color is black, red or grey.
+	Color string `json:"color"`
+}
+
+// Person maps an attendee email to a badge and forms a board view lane
+// with the events of Calendars.
+type Person struct {
+	Name      string
+	Email     string
+	Calendars []string
+	Initial   string
+	Color     string
+}
+
+// MonthInput holds everything PrepareMonthData turns into template data.
+type MonthInput struct {
+	// Now is the reference time of the run, taken once so that fetching
+	// and preparing agree on the day and month even when a run crosses
+	// midnight. Zero means time.Now().
+	Now time.Time
+
+	Width             int
+	Height            int
+	Weather           *weather.Forecast
+	WeatherErr        error
+	Events            []calendar.Event
+	MaxEventsPerDay   int
+	BatteryPercentage string
+	BatteryErr        error
+	Alerts            []alerts.Alert
+
+	// LastYearTemp is today's maximum temperature one year ago, if known.
+	LastYearTemp *float64
+
+	Widgets []script.Widget
+
+	// Quote is the quote of the day for the footer, if any.
+	Quote *quote.Quote
+
+	// Tickers are prices shown in the header.
+	Tickers []ticker.Quote
 
+	// SpotPrices are today's hourly electricity prices in SpotUnit; the
+	// SpotCheapest lowest are highlighted.
+	SpotPrices   []spotprice.Price
+	SpotUnit     string
+	SpotCheapest int
+
+	Images []ImageData
+	QR     *QRData
+
+	// Vacations and HolidayCalendars flag days; Shade lists which flags
+	// (vacation, holiday, weekend, busy) tint the day cells.
+	Vacations        []DateRange
+	HolidayCalendars []string
+	Shade            []string
+
+	// Periods are drawn as named bands across their days.
+	Periods []DateRange
+
+	// OOOKeywords mark events as out of office by their summary,
+	// ignoring case, besides Google's out-of-office event type. Such
+	// events spanning several days become a "Pavel OOO" band.
+	OOOKeywords []string
+
+	// TrimOutsideWeeks drops the first week row of the month view once all
+	// its days have passed.
+	TrimOutsideWeeks bool
+
+	// Countdown shows the next upcoming event in the header, limited to
+	// CountdownCalendars when set.
+	Countdown          bool
+	CountdownCalendars []string
+
+	// People maps attendee emails to badges shown next to events.
+	People []Person
+
+	// Redactions rewrite event summaries before display. Event keys are
+	// unaffected.
+	Redactions []calendar.Redaction
+
+	// CalendarStyles maps source names to the look of their events.
+	CalendarStyles map[string]CalendarStyle
+
+	// ImportantCalendars (source names) and ImportantKeywords (matched
+	// case-insensitively in redacted summaries of events that aren't
+	// private) mark events to emphasize.
+	ImportantCalendars []string
+	ImportantKeywords  []string
+
+	// TravelWarning is the shortest gap between events at different
+	// locations that isn't flagged. Zero disables the check.
+	TravelWarning time.Duration
+
+	// ShowDaylight enables the daylight bars; sun times are always set.
+	ShowDaylight bool
+
+	// Comparison lists forecasts for the weather comparison row, the main
+	// location first.
+	Comparison []LocationForecast
+
+	// NewEventKeys lists calendar.Event keys added or moved since the
+	// previous refresh.
+	NewEventKeys []string
+
+	// CancelledEvents were shown by the previous refresh but are gone now.
+	// They are rendered struck through for one refresh.
+	CancelledEvents []calendar.Event
+
+	// RunTime is how long the run took up to rendering, shown in the
+	// header when non-zero.
+	RunTime time.Duration
+
+	// Uptime is the percentage of successful runs this month, shown in the
+	// header when set.
+	Uptime *float64
+
+	// UpdatedClock shows "Updated 07:00" prominently in the header instead
+	// of the small generated timestamp.
+	UpdatedClock bool
+
+	// Suggestions are shown below the header when their forecast condition
+	// holds.
+	Suggestions []weather.Rule
+
+	// Waste marks pickup days with a bin and the pickup names.
+	Waste []waste.Schedule
+
+	// CountdownEvents are the events of countdown sources; up to
+	// CountdownMax upcoming all-day ones are listed below the header.
+	CountdownEvents []calendar.Event
+	CountdownMax    int
+
+	// Locale formats times, dates and decimals.
+	Locale locale.Locale
+}
+
+// DateRange is a named span of whole days; End is inclusive.
+type DateRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// contains reports whether date's calendar day lies within the range.
+func (r DateRange) contains(date time.Time) bool {
+	day := date.Format("2006-01-02")
+	return day >= r.Start.Format("2006-01-02") && day <= r.End.Format("2006-01-02")
+}
+
+// LocationForecast is a labeled forecast for the comparison row.
+type LocationForecast struct {
+	Label    string
+	Forecast *weather.Forecast
+}
+
+// PrepareData builds the template data for the given view.
+func PrepareData(view string, in MonthInput) TemplateData {
+	switch view {
+	case ViewAgenda:
+		return PrepareAgendaData(in)
+	case ViewBoard:
+		return PrepareBoardData(in)
+	case ViewDetails:
+		return PrepareDetailsData(in)
+	case ViewRolling:
+		return PrepareRollingData(in)
+	}
+	return PrepareMonthData(in)
+}
+
+// now returns the reference time of the run.
+func (in MonthInput) now() time.Time {
+	if in.Now.IsZero() {
+		return time.Now()
+	}
+	return in.Now
+}
+
+func PrepareMonthData(in MonthInput) TemplateData {
+	now := in.now()
+
+	data := prepareHeader(now, in)
+	data.View = ViewMonth
+	data.Weeks = buildWeeks(now, newDayBuilder(now, in, ViewMonth), in.TrimOutsideWeeks)
+
+	setLastYearTemp(&data, now, in)
+
+	return data
+}
+
+// PrepareRollingData shows the current week as the second of five rows, so
+// the coming weeks stay visible late in the month. Days of every month are
+// drawn alike, with a month label where one begins.
+func PrepareRollingData(in MonthInput) TemplateData {
+	now := in.now()
+	days := newDayBuilder(now, in, ViewRolling)
+
+	start := days.today.AddDate(0, 0, -(mondayWeekday(days.today)-1)-7)
+	end := start.AddDate(0, 0, 7*rollingWeeks-1)
+
+	data := prepareHeader(now, in)
+	data.View = ViewRolling
+	data.MonthName, data.Year = rollingTitle(start, end)
+	data.Weeks = weekRows(days, start, end)
+	data.forEachDay(func(day *DayData) {
+		day.IsCurrentMonth = true
+	})
+	data.Weeks[0].Days[0].ShowMonth = true
+
+	setLastYearTemp(&data, now, in)
+
+	return data
+}
+
+// rollingTitle returns the header's month name and year for the span, e.g.
+// "October – November" 2026, or "December 2026 – January" 2027 across a
+// year.
+func rollingTitle(start, end time.Time) (string, int) {
+	switch {
+	case start.Year() != end.Year():
+		return fmt.Sprintf("%s %d – %s", start.Month(), start.Year(), end.Month()), end.Year()
+	case start.Month() != end.Month():
+		return fmt.Sprintf("%s – %s", start.Month(), end.Month()), end.Year()
+	}
+	return start.Month().String(), start.Year()
+}
+
+// PrepareAgendaData lists today and the following days one row per day.
+func PrepareAgendaData(in MonthInput) TemplateData {
+	now := in.now()
+	days := newDayBuilder(now, in, ViewAgenda)
+
+	data := prepareHeader(now, in)
+	data.View = ViewAgenda
+	data.Days = make([]DayData, 0, agendaDays)
+	for i := 0; i < agendaDays; i++ {
+		data.Days = append(data.Days, days.build(days.today.AddDate(0, 0, i)))
+	}
+
+	setLastYearTemp(&data, now, in)
+
+	return data
+}
+
+// PrepareDetailsData lists all of today's events with their details.
+func PrepareDetailsData(in MonthInput) TemplateData {
+	now := in.now()
+	days := newDayBuilder(now, in, ViewDetails)
+	// There are no day cells to fit, so list the whole day; drawing stops
+	// where the screen ends.
+	days.maxEventsPerDay = math.MaxInt
+
+	data := prepareHeader(now, in)
+	data.View = ViewDetails
+	data.Days = []DayData{days.build(days.today)}
+
+	return data
+}
+
+// PrepareBoardData lists the coming days as rows with one column per
+// person. Events of nobody in particular go to a final "Everyone" column.
+func PrepareBoardData(in MonthInput) TemplateData {
+	now := in.now()
+	days := newDayBuilder(now, in, ViewBoard)
+
+	data := prepareHeader(now, in)
+	data.View = ViewBoard
+	for _, p := range in.People {
+		data.Lanes = append(data.Lanes, LaneData{Name: p.Name, Color: p.Color})
+	}
+
+	everyone := false
+	data.Days = make([]DayData, 0, boardDays)
+	for i := 0; i < boardDays; i++ {
+		date := days.today.AddDate(0, 0, i)
+		day := days.build(date)
+		day.Lanes = days.lanes(date, in.People)
+		if len(day.Lanes[len(in.People)]) > 0 {
+			everyone = true
+		}
+		data.Days = append(data.Days, day)
+	}
+
+	if everyone {
+		data.Lanes = append(data.Lanes, LaneData{Name: "Everyone", Color: "grey"})
+	} else {
+		for i := range data.Days {
+			data.Days[i].Lanes = data.Days[i].Lanes[:len(in.People)]
+		}
+	}
+
+	setLastYearTemp(&data, now, in)
+
+	return data
+}
+
+func prepareHeader(now time.Time, in MonthInput) TemplateData {
 	weatherError := ""
-	if weatherErr != nil {
-		weatherError = fmt.Sprintf("Weather: %v", weatherErr)
+	if in.WeatherErr != nil {
+		weatherError = fmt.Sprintf("Weather: %v", in.WeatherErr)
+	}
+
+	batteryError := ""
+	if in.BatteryErr != nil {
+		batteryError = fmt.Sprintf("Battery: %v", in.BatteryErr)
+	}
+
+	runTime := ""
+	if in.RunTime > 0 {
+		runTime = in.Locale.Decimal(fmt.Sprintf("%.1fs", in.RunTime.Seconds()))
 	}
 
-	data := TemplateData{
-		Width:             width,
-		Height:            height,
+	uptime := ""
+	if in.Uptime != nil {
+		uptime = in.Locale.Decimal(fmt.Sprintf("%.1f%%", *in.Uptime))
+	}
+
+	updatedAt := ""
+	if in.UpdatedClock {
+		updatedAt = in.Locale.Time(now)
+	}
+
+	return TemplateData{
+		Width:             in.Width,
+		Height:            in.Height,
 		MonthName:         now.Month().String(),
 		Year:              now.Year(),
-		GeneratedAt:       now.Format("2006-01-02 15:04:05"),
-		BatteryPercentage: batteryPercentage,
+		GeneratedAt:       in.Locale.FormatDateTime(now),
+		RunTime:           runTime,
+		Uptime:            uptime,
+		UpdatedAt:         updatedAt,
+		BatteryPercentage: in.BatteryPercentage,
+		BatteryError:      batteryError,
 		WeatherError:      weatherError,
-		Weeks:             buildWeeks(now, buildEventsByDate(events), weatherData, maxEventsPerDay),
+		Alerts:            buildAlerts(now, in.Alerts, in.Locale),
+		Suggestions:       buildSuggestions(now, in.Weather, in.Suggestions),
+		Countdowns:        buildCountdowns(now, in),
+		Widgets:           buildWidgets(in.Widgets),
+		Comparison:        buildComparison(now, in.Comparison),
+		ShowDaylight:      in.ShowDaylight,
+		Images:            in.Images,
+		QR:                in.QR,
+		NextEvent:         nextEvent(now, in),
+		Quote:             buildQuote(in.Quote),
+		Tickers:           buildTickers(in.Tickers),
+		SpotPrices:        buildSpotPrices(now, in),
+		Locale:            in.Locale,
+	}
+}
+
+func buildSpotPrices(now time.Time, in MonthInput) *SpotPriceData {
+	if len(in.SpotPrices) == 0 {
+		return nil
+	}
+
+	lo, hi := math.Min(0, in.SpotPrices[0].Price), in.SpotPrices[0].Price
+	for _, p := range in.SpotPrices {
+		lo = math.Min(lo, p.Price)
+		hi = math.Max(hi, p.Price)
+	}
+
+	format := func(price float64) string {
+		return strings.TrimSpace(fmt.Sprintf("%.1f %s", price, in.SpotUnit))
 	}
 
+	cheap := spotprice.Cheapest(in.SpotPrices, in.SpotCheapest)
+	data := &SpotPriceData{}
+	low := in.SpotPrices[0]
+	for i, p := range in.SpotPrices {
+		level := 1.0
+		if hi > lo {
+			level = (p.Price - lo) / (hi - lo)
+		}
+		current := !now.Before(p.Start) && now.Before(p.Start.Add(time.Hour))
+		if current {
+			data.Now = format(p.Price)
+		}
+		if p.Price < low.Price {
+			low = p
+		}
+		data.Hours = append(data.Hours, SpotHourData{
+			Hour:    p.Start.Hour(),
+			Level:   level,
+			Cheap:   cheap[i],
+			Current: current,
+			Past:    !now.Before(p.Start.Add(time.Hour)),
+		})
+	}
+	data.Low = fmt.Sprintf("%s at %s", format(low.Price), in.Locale.Time(low.Start))
 	return data
 }
 
-func buildEventsByDate(events []calendar.Event) map[string][]calendar.Event {
-	eventsByDate := make(map[string][]calendar.Event)
+func buildTickers(quotes []ticker.Quote) []TickerData {
+	result := make([]TickerData, 0, len(quotes))
+	for _, q := range quotes {
+		price := fmt.Sprintf("%.2f", q.Price)
+		if q.Price >= 1000 {
+			price = fmt.Sprintf("%.0f", q.Price)
+		}
+		arrow := "▲"
+		if q.Change < 0 {
+			arrow = "▼"
+		}
+		result = append(result, TickerData{
+			Symbol: q.Symbol,
+			Price:  price,
+			Change: fmt.Sprintf("%s%.1f%%", arrow, math.Abs(q.Change)),
+			Down:   q.Change < 0,
+		})
+	}
+	return result
+}
 
-	for _, event := range events {
-		startDate := time.Date(event.Start.Year(), event.Start.Month(), event.Start.Day(), 0, 0, 0, 0, event.Start.Location())
-		endDate := time.Date(event.End.Year(), event.End.Month(), event.End.Day(), 0, 0, 0, 0, event.End.Location())
+func buildQuote(q *quote.Quote) *QuoteData {
+	if q == nil {
+		return nil
+	}
+	return &QuoteData{Text: q.Text, Author: q.Author}
+}
+
+// nextEvent returns the next event that hasn't started yet. Private events
+// show as "Busy".
+func nextEvent(now time.Time, in MonthInput) *NextEventData {
+	if !in.Countdown {
+		return nil
+	}
 
-		if event.AllDay && endDate.After(startDate) {
-			endDate = endDate.AddDate(0, 0, -1)
+	ev, ok := calendar.Next(in.Events, now, func(ev calendar.Event) bool {
+		if !ev.Start.After(now) {
+			return false
 		}
+		if len(in.CountdownCalendars) == 0 {
+			return true
+		}
+		for _, name := range in.CountdownCalendars {
+			if ev.CalendarName == name {
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		return nil
+	}
 
+	summary := calendar.Redact(ev.Summary, in.Redactions)
+	if ev.Private {
+		summary = "Busy"
+	}
+	return &NextEventData{Summary: summary, In: formatCountdown(ev.Start.Sub(now))}
+}
+
+// formatCountdown formats d as "45m", "2h 15m" or "3d 4h", rounded up to
+// the minute.
+func formatCountdown(d time.Duration) string {
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes < 24*60:
+		if minutes%60 == 0 {
+			return fmt.Sprintf("%dh", minutes/60)
+		}
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+	hours := minutes / 60
+	if hours%24 == 0 {
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	return fmt.Sprintf("%dd %dh", hours/24, hours%24)
+}
+
+// forEachDay calls fn for every day shown by the view.
+func (d *TemplateData) forEachDay(fn func(day *DayData)) {
+	for w := range d.Weeks {
+		for i := range d.Weeks[w].Days {
+			fn(&d.Weeks[w].Days[i])
+		}
+	}
+	for i := range d.Days {
+		fn(&d.Days[i])
+	}
+}
+
+// DateRange returns the first and last date the view shows, e.g.
+// "2026-09-28"; both are empty for views without days.
+func (d TemplateData) DateRange() (first, last string) {
+	d.forEachDay(func(day *DayData) {
+		if first == "" || day.Date < first {
+			first = day.Date
+		}
+		if day.Date > last {
+			last = day.Date
+		}
+	})
+	return first, last
+}
+
+func buildAlerts(now time.Time, all []alerts.Alert, loc locale.Locale) []AlertData {
+	active := alerts.Active(all, now, alertWindow)
+
+	result := make([]AlertData, 0, len(active))
+	for _, a := range active {
+		expires := a.Expires.In(now.Location())
+		until := loc.Time(expires)
+		if expires.YearDay() != now.YearDay() || expires.Year() != now.Year() {
+			until = expires.Format("Mon ") + until
+		}
+		result = append(result, AlertData{
+			Text:     fmt.Sprintf("%s until %s", a.Event, until),
+			Severity: a.Severity.String(),
+		})
+	}
+	return result
+}
+
+func buildSuggestions(now time.Time, forecast *weather.Forecast, rules []weather.Rule) []SuggestionData {
+	if forecast == nil {
+		return nil
+	}
+
+	matched := forecast.Matching(now, rules)
+	result := make([]SuggestionData, 0, len(matched))
+	for _, rule := range matched {
+		result = append(result, SuggestionData{Text: rule.Text, Icon: rule.Icon})
+	}
+	return result
+}
+
+// buildCountdowns lists the upcoming all-day countdown events, soonest
+// first.
+func buildCountdowns(now time.Time, in MonthInput) []CountdownData {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var upcoming []calendar.Event
+	for _, ev := range in.CountdownEvents {
+		if ev.AllDay && ev.Start.After(today) {
+			upcoming = append(upcoming, ev)
+		}
+	}
+	upcoming = calendar.SortEvents(upcoming)
+	if len(upcoming) > in.CountdownMax {
+		upcoming = upcoming[:in.CountdownMax]
+	}
+
+	result := make([]CountdownData, 0, len(upcoming))
+	for _, ev := range upcoming {
+		start := time.Date(ev.Start.Year(), ev.Start.Month(), ev.Start.Day(), 0, 0, 0, 0, now.Location())
+		days := int(start.Sub(today).Hours()/24 + 0.5)
+		label := fmt.Sprintf("%d days", days)
+		if days == 1 {
+			label = "1 day"
+		}
+		result = append(result, CountdownData{Days: label, Summary: calendar.Redact(ev.Summary, in.Redactions)})
+	}
+	return result
+}
+
+func buildWidgets(widgets []script.Widget) []WidgetData {
+	result := make([]WidgetData, 0, len(widgets))
+	for _, w := range widgets {
+		result = append(result, WidgetData{Label: w.Label, Value: w.Value})
+	}
+	return result
+}
+
+func buildComparison(now time.Time, locations []LocationForecast) []ComparisonData {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	result := make([]ComparisonData, 0, len(locations))
+	for _, l := range locations {
+		dayTemp, nightTemp := getTemperatures(today, today, today.AddDate(0, 0, 1), l.Forecast)
+		if dayTemp == "" {
+			continue
+		}
+		result = append(result, ComparisonData{Label: l.Label, DayTemp: dayTemp, NightTemp: nightTemp})
+	}
+	return result
+}
+
+func setLastYearTemp(data *TemplateData, now time.Time, in MonthInput) {
+	if in.LastYearTemp == nil || in.Weather == nil {
+		return
+	}
+
+	temp := in.Weather.Units.FormatTemperature(*in.LastYearTemp)
+	todayKey := now.Format("2006-01-02")
+	data.forEachDay(func(day *DayData) {
+		if day.Date == todayKey && day.DayTemp != "" {
+			day.LastYearTemp = fmt.Sprintf("last yr %s", temp)
+		}
+	})
+}
+
+func buildEventsByDate(events []calendar.Event) map[string][]calendar.Event {
+	eventsByDate := make(map[string][]calendar.Event)
+
+	for _, event := range events {
+		startDate, endDate := eventDays(event)
 		for currentDate := startDate; currentDate.Before(endDate) || currentDate.Equal(endDate); currentDate = currentDate.AddDate(0, 0, 1) {
 			dateKey := currentDate.Format("2006-01-02")
 			eventsByDate[dateKey] = append(eventsByDate[dateKey], event)
@@ -84,11 +958,40 @@ func buildEventsByDate(events []calendar.Event) map[string][]calendar.Event {
 	return eventsByDate
 }
 
-func buildWeeks(now time.Time, eventsByDate map[string][]calendar.Event, weatherData *weather.Forecast, maxEventsPerDay int) []WeekData {
+// eventDays returns the first and last day an event covers; all-day
+// events end the day before their exclusive end date.
+func eventDays(event calendar.Event) (time.Time, time.Time) {
+	startDate := time.Date(event.Start.Year(), event.Start.Month(), event.Start.Day(), 0, 0, 0, 0, event.Start.Location())
+	endDate := time.Date(event.End.Year(), event.End.Month(), event.End.Day(), 0, 0, 0, 0, event.End.Location())
+
+	if event.AllDay && endDate.After(startDate) {
+		endDate = endDate.AddDate(0, 0, -1)
+	}
+	return startDate, endDate
+}
+
+func buildWeeks(now time.Time, days *dayBuilder, trim bool) []WeekData {
 	startDate, endDate := getMonthGridRange(now)
-	currentMonth := now.Month()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weeks := weekRows(days, startDate, endDate)
 
+	// Six rows leave cells too short to show events, so drop leading weeks
+	// that have passed entirely. Trimming drops the first one in any month.
+	// There is no trailing counterpart: the grid ends with the week of the
+	// month's last day, so no trailing week lies wholly outside the month.
+	rows := maxWeekRows
+	if trim {
+		rows = len(weeks) - 1
+	}
+	for len(weeks) > rows && weeks[0].Days[6].IsPast {
+		weeks = weeks[1:]
+	}
+
+	return weeks
+}
+
+// weekRows builds the week rows from the Monday startDate through the
+// Sunday endDate.
+func weekRows(days *dayBuilder, startDate, endDate time.Time) []WeekData {
 	var weeks []WeekData
 	currentDate := startDate
 
@@ -96,8 +999,7 @@ func buildWeeks(now time.Time, eventsByDate map[string][]calendar.Event, weather
 		week := WeekData{Days: make([]DayData, 0, 7)}
 
 		for i := 0; i < 7; i++ {
-			dayData := buildDayData(currentDate, today, currentMonth, eventsByDate, weatherData, maxEventsPerDay)
-			week.Days = append(week.Days, dayData)
+			week.Days = append(week.Days, days.build(currentDate))
 			currentDate = currentDate.AddDate(0, 0, 1)
 		}
 
@@ -107,46 +1009,455 @@ func buildWeeks(now time.Time, eventsByDate map[string][]calendar.Event, weather
 	return weeks
 }
 
-func buildDayData(date, today time.Time, currentMonth time.Month, eventsByDate map[string][]calendar.Event, weatherData *weather.Forecast, maxEventsPerDay int) DayData {
+// dayBuilder holds everything shared by the day cells of a view.
+type dayBuilder struct {
+	today           time.Time
+	currentMonth    time.Month
+	eventsByDate    map[string][]calendar.Event
+	weather         *weather.Forecast
+	maxEventsPerDay int
+	newEvents       map[string]bool
+	cancelled       map[string]bool
+	people          map[string]PersonData
+	names           map[string]string
+	tightTravel     map[string]bool
+	redactions      []calendar.Redaction
+	important       func(calendar.Event) bool
+	styles          map[string]CalendarStyle
+	vacations       []DateRange
+	periods         []DateRange
+	holidays        map[string]bool
+	shade           map[string]bool
+	waste           []waste.Schedule
+	locale          locale.Locale
+
+	// forecastEnd is the first day past the view's forecast window.
+	forecastEnd time.Time
+}
+
+func newDayBuilder(now time.Time, in MonthInput, view string) *dayBuilder {
+	newEvents := make(map[string]bool, len(in.NewEventKeys))
+	for _, key := range in.NewEventKeys {
+		newEvents[key] = true
+	}
+
+	cancelled := make(map[string]bool, len(in.CancelledEvents))
+	for _, ev := range in.CancelledEvents {
+		cancelled[ev.Key()] = true
+	}
+
+	people := make(map[string]PersonData, len(in.People))
+	names := make(map[string]string, len(in.People))
+	for _, p := range in.People {
+		if p.Email != "" {
+			people[strings.ToLower(p.Email)] = PersonData{Initial: p.Initial, Color: p.Color}
+			names[strings.ToLower(p.Email)] = p.Name
+		}
+	}
+
+	events, absences := splitAbsences(in)
+	events = append(events, in.CancelledEvents...)
+
+	eventsByDate := buildEventsByDate(events)
+
+	holidays := make(map[string]bool, len(in.HolidayCalendars))
+	for _, name := range in.HolidayCalendars {
+		holidays[name] = true
+	}
+
+	shade := make(map[string]bool, len(in.Shade))
+	for _, s := range in.Shade {
+		shade[s] = true
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return &dayBuilder{
+		today:           today,
+		forecastEnd:     today.AddDate(0, 0, ForecastDays(view)),
+		currentMonth:    now.Month(),
+		eventsByDate:    eventsByDate,
+		weather:         in.Weather,
+		maxEventsPerDay: in.MaxEventsPerDay,
+		newEvents:       newEvents,
+		cancelled:       cancelled,
+		people:          people,
+		names:           names,
+		tightTravel:     findTightTravel(eventsByDate, cancelled, in.TravelWarning),
+		redactions:      in.Redactions,
+		important:       importantMatcher(in.ImportantCalendars, in.ImportantKeywords, in.Redactions),
+		styles:          in.CalendarStyles,
+		vacations:       in.Vacations,
+		periods:         append(absences, in.Periods...),
+		holidays:        holidays,
+		shade:           shade,
+		waste:           in.Waste,
+		locale:          in.Locale,
+	}
+}
+
+// splitAbsences separates out-of-office events spanning several days from
+// in.Events and returns them as bands named after their calendar's person,
+// or the calendar itself, e.g. "Pavel OOO".
+func splitAbsences(in MonthInput) ([]calendar.Event, []DateRange) {
+	owners := make(map[string]string)
+	for _, p := range in.People {
+		for _, name := range p.Calendars {
+			owners[name] = p.Name
+		}
+	}
+	keywords := make([]string, 0, len(in.OOOKeywords))
+	for _, k := range in.OOOKeywords {
+		keywords = append(keywords, strings.ToLower(k))
+	}
+
+	events := make([]calendar.Event, 0, len(in.Events)+len(in.CancelledEvents))
+	var absences []DateRange
+	for _, ev := range in.Events {
+		first, last := eventDays(ev)
+		if last.Equal(first) || !outOfOffice(ev, keywords) {
+			events = append(events, ev)
+			continue
+		}
+		owner := owners[ev.CalendarName]
+		if owner == "" {
+			owner = ev.CalendarName
+		}
+		absences = append(absences, DateRange{Name: owner + " OOO", Start: first, End: last})
+	}
+	return events, absences
+}
+
+func outOfOffice(ev calendar.Event, keywords []string) bool {
+	if ev.Type == calendar.TypeOutOfOffice {
+		return true
+	}
+	summary := strings.ToLower(ev.Summary)
+	for _, k := range keywords {
+		if strings.Contains(summary, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// findTightTravel returns the keys of timed events that start less than
+// minGap after the previous event of the day ends at a different location.
+// Locations are compared as text; a routing API could refine this later.
+func findTightTravel(eventsByDate map[string][]calendar.Event, cancelled map[string]bool, minGap time.Duration) map[string]bool {
+	tight := make(map[string]bool)
+	if minGap <= 0 {
+		return tight
+	}
+
+	for _, events := range eventsByDate {
+		var prev *calendar.Event
+		for _, ev := range calendar.SortEvents(events) {
+			if ev.AllDay || cancelled[ev.Key()] || ev.Location == "" {
+				continue
+			}
+			if prev != nil && !strings.EqualFold(strings.TrimSpace(prev.Location), strings.TrimSpace(ev.Location)) &&
+				ev.Start.Sub(prev.End) < minGap {
+				tight[ev.Key()] = true
+			}
+			prev = &ev
+		}
+	}
+	return tight
+}
+
+// minorEvent reports events that don't take up time.
+func minorEvent(ev calendar.Event) bool {
+	return ev.Free || ev.Type == calendar.TypeWorkingLocation
+}
+
+// importantMatcher reports events of the calendars or with one of the
+// keywords in their redacted summary. Private events are only matched by
+// calendar, so their hidden titles don't show through.
+func importantMatcher(calendars, keywords []string, redactions []calendar.Redaction) func(calendar.Event) bool {
+	names := make(map[string]bool, len(calendars))
+	for _, name := range calendars {
+		names[name] = true
+	}
+	lower := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		lower = append(lower, strings.ToLower(k))
+	}
+	return func(ev calendar.Event) bool {
+		if names[ev.CalendarName] {
+			return true
+		}
+		if ev.Private {
+			return false
+		}
+		summary := strings.ToLower(calendar.Redact(ev.Summary, redactions))
+		for _, k := range lower {
+			if strings.Contains(summary, k) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// eventPeople returns the badges of the configured people invited to ev,
+// each initial once.
+func (b *dayBuilder) eventPeople(ev calendar.Event) []PersonData {
+	var result []PersonData
+	seen := make(map[string]bool)
+	for _, email := range ev.Attendees {
+		p, ok := b.people[email]
+		if !ok || seen[p.Initial] {
+			continue
+		}
+		seen[p.Initial] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+func (b *dayBuilder) eventData(ev calendar.Event) EventData {
+	key := ev.Key()
+	style := b.styles[ev.CalendarName]
+
+	if ev.Private {
+		eventData := EventData{
+			Summary:      style.title("Busy"),
+			AllDay:       ev.AllDay,
+			IsNew:        b.newEvents[key],
+			Cancelled:    b.cancelled[key],
+			Important:    b.important(ev),
+			Minor:        minorEvent(ev),
+			CalendarName: ev.CalendarName,
+			Style:        style,
+		}
+		if !ev.AllDay {
+			eventData.Time = b.locale.TimeRange(ev.Start, ev.End)
+		}
+		return eventData
+	}
+	eventData := EventData{
+		Summary:      style.title(calendar.Redact(ev.Summary, b.redactions)),
+		AllDay:       ev.AllDay,
+		IsNew:        b.newEvents[key],
+		Cancelled:    b.cancelled[key],
+		People:       b.eventPeople(ev),
+		VideoCall:    ev.VideoCall,
+		Fixture:      ev.Fixture,
+		TightTravel:  b.tightTravel[key],
+		Important:    b.important(ev),
+		Minor:        minorEvent(ev),
+		CalendarName: ev.CalendarName,
+		Style:        style,
+		Location:     calendar.Redact(ev.Location, b.redactions),
+		Description:  calendar.Redact(plainText(ev.Description), b.redactions),
+	}
+	if !ev.AllDay {
+		eventData.Time = b.locale.Time(ev.Start)
+		eventData.EndTime = b.locale.Time(ev.End)
+	}
+	for _, email := range ev.Attendees {
+		if name := b.names[email]; name != "" {
+			eventData.Attendees = append(eventData.Attendees, name)
+		} else {
+			eventData.Attendees = append(eventData.Attendees, email)
+		}
+	}
+	return eventData
+}
+
+var (
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+	blankLines    = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// plainText turns an event description, which Google stores as HTML, into
+// plain text with one paragraph per line.
+func plainText(description string) string {
+	s := lineBreakTags.ReplaceAllString(description, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLines.ReplaceAllString(s, "\n")
+	return strings.TrimSpace(s)
+}
+
+// lanes splits the day's events by person, followed by a lane of events
+// matching nobody. An event shared by several people is in each lane.
+func (b *dayBuilder) lanes(date time.Time, people []Person) [][]EventData {
+	lanes := make([][]EventData, len(people)+1)
+	for _, ev := range calendar.SortEvents(b.eventsByDate[date.Format("2006-01-02")]) {
+		matched := false
+		for i, p := range people {
+			if personMatches(p, ev) {
+				lanes[i] = append(lanes[i], b.eventData(ev))
+				matched = true
+			}
+		}
+		if !matched {
+			lanes[len(people)] = append(lanes[len(people)], b.eventData(ev))
+		}
+	}
+
+	for i := range lanes {
+		if len(lanes[i]) > b.maxEventsPerDay {
+			lanes[i] = lanes[i][:b.maxEventsPerDay]
+		}
+	}
+	return lanes
+}
+
+func personMatches(p Person, ev calendar.Event) bool {
+	for _, name := range p.Calendars {
+		if name == ev.CalendarName {
+			return true
+		}
+	}
+	for _, email := range ev.Attendees {
+		if p.Email != "" && strings.EqualFold(email, p.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *dayBuilder) build(date time.Time) DayData {
 	dateKey := date.Format("2006-01-02")
-	dayEvents := calendar.SortEvents(eventsByDate[dateKey])
+	dayEvents := calendar.SortEvents(b.eventsByDate[dateKey])
 
-	if len(dayEvents) > maxEventsPerDay {
-		dayEvents = dayEvents[:maxEventsPerDay]
+	if len(dayEvents) > b.maxEventsPerDay {
+		dayEvents = dayEvents[:b.maxEventsPerDay]
 	}
 
 	templateEvents := make([]EventData, 0, len(dayEvents))
 	for _, ev := range dayEvents {
-		eventData := EventData{Summary: ev.Summary, AllDay: ev.AllDay}
-		if !ev.AllDay {
-			eventData.Time = ev.Start.Format("15:04")
-		}
-		templateEvents = append(templateEvents, eventData)
+		templateEvents = append(templateEvents, b.eventData(ev))
 	}
 
-	dayTemp, nightTemp := getTemperatures(date, today, weatherData)
+	dayTemp, nightTemp := getTemperatures(date, b.today, b.forecastEnd, b.weather)
 
-	return DayData{
+	day := DayData{
 		Date:           dateKey,
 		DayNum:         date.Format("2"),
 		MonthShort:     date.Format("Jan"),
-		IsToday:        calendar.IsToday(date),
-		IsPast:         date.Before(today),
+		ShowMonth:      date.Day() == 1,
+		IsToday:        calendar.SameDay(date, b.today),
+		IsPast:         date.Before(b.today),
 		IsWeekend:      calendar.IsWeekend(date),
-		IsCurrentMonth: date.Month() == currentMonth,
+		IsCurrentMonth: date.Month() == b.currentMonth,
 		DayTemp:        dayTemp,
 		NightTemp:      nightTemp,
 		Events:         templateEvents,
+		Waste:          waste.Pickups(b.waste, date),
+	}
+	setSunTimes(&day, date, b.weather, b.locale)
+	setSnow(&day, date, b.today, b.forecastEnd, b.weather, b.locale)
+	setConditions(&day, date, b.today, b.forecastEnd, b.weather)
+	b.setFlags(&day, date)
+
+	return day
+}
+
+// setFlags sets the holiday, vacation and busyness flags of day, the
+// resulting cell shade and the period bands.
+func (b *dayBuilder) setFlags(day *DayData, date time.Time) {
+	for _, ev := range b.eventsByDate[day.Date] {
+		if b.cancelled[ev.Key()] {
+			continue
+		}
+		day.EventCount++
+		if b.holidays[ev.CalendarName] {
+			day.IsHoliday = true
+		}
+	}
+
+	for _, v := range b.vacations {
+		if v.contains(date) {
+			day.IsVacation = true
+			day.Vacation = v.Name
+			break
+		}
+	}
+
+	for _, p := range b.periods {
+		if p.contains(date) {
+			day.Bands = append(day.Bands, BandData{
+				Name:  p.Name,
+				First: date.Format("2006-01-02") == p.Start.Format("2006-01-02"),
+			})
+		}
+	}
+
+	if b.maxEventsPerDay > 0 {
+		day.Busyness = min(1, float64(day.EventCount)/float64(b.maxEventsPerDay))
+	}
+
+	switch {
+	case b.shade["vacation"] && day.IsVacation,
+		b.shade["holiday"] && day.IsHoliday,
+		b.shade["weekend"] && day.IsWeekend:
+		day.Shade = 0.5
+	}
+	if b.shade["busy"] {
+		day.Shade = max(day.Shade, day.Busyness)
+	}
+}
+
+func setSunTimes(day *DayData, date time.Time, weatherData *weather.Forecast, loc locale.Locale) {
+	if weatherData == nil {
+		return
+	}
+	sun := weatherData.GetDay(date)
+	if sun == nil {
+		return
+	}
+
+	if !sun.Sunrise.IsZero() && !sun.Sunset.IsZero() {
+		day.Sunrise = loc.Time(sun.Sunrise)
+		day.Sunset = loc.Time(sun.Sunset)
+		day.SunriseFraction = dayFraction(sun.Sunrise)
+		day.SunsetFraction = dayFraction(sun.Sunset)
+	}
+	minutes := int(sun.Daylight.Round(time.Minute).Minutes())
+	day.Daylight = fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// dayFraction returns how far into its day t is, from 0 to 1.
+func dayFraction(t time.Time) float64 {
+	return float64(t.Hour()*60+t.Minute()) / (24 * 60)
+}
+
+func setSnow(day *DayData, date, today, forecastEnd time.Time, weatherData *weather.Forecast, loc locale.Locale) {
+	if weatherData == nil || date.Before(today) || !date.Before(forecastEnd) {
+		return
+	}
+
+	if snowfall := weatherData.GetSnowfall(date); snowfall > 0 {
+		day.Snowfall = loc.Decimal(weatherData.Units.FormatSnow(snowfall))
+	}
+	if depth := weatherData.GetSnowDepth(date); depth > 0 {
+		day.SnowDepth = loc.Decimal(weatherData.Units.FormatSnow(depth))
+	}
+}
+
+func setConditions(day *DayData, date, today, forecastEnd time.Time, weatherData *weather.Forecast) {
+	if weatherData == nil || date.Before(today) || !date.Before(forecastEnd) {
+		return
+	}
+
+	code, ok := weatherData.GetDayWeatherCode(date)
+	if !ok {
+		return
 	}
+	day.WeatherCode = code
+	day.Icon, day.Description = weather.DescribeCode(code)
 }
 
-func getTemperatures(date, today time.Time, weatherData *weather.Forecast) (string, string) {
+func getTemperatures(date, today, forecastEnd time.Time, weatherData *weather.Forecast) (string, string) {
 	if weatherData == nil {
 		return "", ""
 	}
 
-	eightDaysFromNow := today.AddDate(0, 0, 8)
-	if date.Before(today) || !date.Before(eightDaysFromNow) {
+	if date.Before(today) || !date.Before(forecastEnd) {
 		return "", ""
 	}
 
@@ -157,7 +1468,7 @@ func getTemperatures(date, today time.Time, weatherData *weather.Forecast) (stri
 		return "", ""
 	}
 
-	return fmt.Sprintf("%.0f°", dayTempValue), fmt.Sprintf("%.0f°", nightTempValue)
+	return weatherData.Units.FormatTemperature(dayTempValue), weatherData.Units.FormatTemperature(nightTempValue)
 }
 
 func getMonthGridRange(now time.Time) (time.Time, time.Time) {
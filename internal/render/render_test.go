@@ -0,0 +1,325 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+// weekStarts returns the first date of every row.
+func weekStarts(weeks []WeekData) []string {
+	var starts []string
+	for _, week := range weeks {
+		starts = append(starts, week.Days[0].Date)
+	}
+	return starts
+}
+
+func TestBuildWeeks(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		// February 2024 starts on a Thursday and has 29 days: five rows.
+		{"Feb 2024 first day", date(2024, time.February, 1), []string{"2024-01-29", "2024-02-05", "2024-02-12", "2024-02-19", "2024-02-26"}},
+		{"Feb 2024 leap day", date(2024, time.February, 29), []string{"2024-01-29", "2024-02-05", "2024-02-12", "2024-02-19", "2024-02-26"}},
+		// February 2026 starts on a Sunday: five rows from January 26.
+		{"Feb 2026 first day", date(2026, time.February, 1), []string{"2026-01-26", "2026-02-02", "2026-02-09", "2026-02-16", "2026-02-23"}},
+		{"Feb 2026 last day", date(2026, time.February, 28), []string{"2026-01-26", "2026-02-02", "2026-02-09", "2026-02-16", "2026-02-23"}},
+		// February 2021 starts on a Monday and fills exactly four rows.
+		{"Feb 2021", date(2021, time.February, 15), []string{"2021-02-01", "2021-02-08", "2021-02-15", "2021-02-22"}},
+		// March 2026 spans six weeks; the first row goes once its Sunday,
+		// March 1, has passed.
+		{"Mar 2026 first day", date(2026, time.March, 1), []string{"2026-02-23", "2026-03-02", "2026-03-09", "2026-03-16", "2026-03-23", "2026-03-30"}},
+		{"Mar 2026 second day", date(2026, time.March, 2), []string{"2026-03-02", "2026-03-09", "2026-03-16", "2026-03-23", "2026-03-30"}},
+		{"Mar 2026 last day", date(2026, time.March, 31), []string{"2026-03-02", "2026-03-09", "2026-03-16", "2026-03-23", "2026-03-30"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weeks := buildWeeks(tt.now, newDayBuilder(tt.now, MonthInput{Now: tt.now}, ViewMonth), false)
+			got := weekStarts(weeks)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d rows %v, want %d rows %v", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("row %d starts %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+			for i, week := range weeks {
+				if len(week.Days) != 7 {
+					t.Errorf("row %d has %d days", i, len(week.Days))
+				}
+			}
+		})
+	}
+}
+
+// TestRenderDeterministic renders one input several times, with the
+// events in a different order each time as sources and pages may return
+// them, so nothing such as map order, fetch order or float noise changes
+// the image between runs.
+func TestRenderDeterministic(t *testing.T) {
+	now := time.Date(2026, time.March, 12, 7, 30, 0, 0, time.UTC)
+	forecast := &weather.Forecast{Units: weather.UnitsMetric}
+	for hour := range 7 * 24 {
+		forecast.Hourly = append(forecast.Hourly, weather.HourlyForecast{
+			Time:        now.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour),
+			Temperature: 2.5 + 6*math.Sin(float64(hour)/24*2*math.Pi) + 0.1*float64(hour%7),
+			WeatherCode: []int{0, 3, 61, 71}[hour/24%4],
+		})
+	}
+	piano := now.Add(4 * time.Hour)
+	events := []calendar.Event{
+		{ID: "a", Summary: "Dentist", Start: now.Add(2 * time.Hour), End: now.Add(3 * time.Hour), CalendarName: "Family"},
+		{ID: "b", Summary: "Swimming", Start: now.Add(26 * time.Hour), End: now.Add(27 * time.Hour), CalendarName: "Family"},
+		{ID: "c", Summary: "Trip", Start: now.AddDate(0, 0, 3).Truncate(24 * time.Hour), End: now.AddDate(0, 0, 6).Truncate(24 * time.Hour), AllDay: true, CalendarName: "Work"},
+		// Events starting together, told apart by end, title, calendar
+		// and ID.
+		{ID: "d", Summary: "Piano", Start: piano, End: piano.Add(time.Hour), CalendarName: "Family"},
+		{ID: "e", Summary: "Piano", Start: piano, End: piano.Add(time.Hour), CalendarName: "Work"},
+		{ID: "f", Summary: "Call", Start: piano, End: piano.Add(time.Hour), CalendarName: "Work"},
+		{ID: "g", Summary: "Call", Start: piano, End: piano.Add(30 * time.Minute), CalendarName: "Work"},
+		{ID: "h", Summary: "Call", Start: piano, End: piano.Add(30 * time.Minute), CalendarName: "Work"},
+	}
+
+	r := PNGRenderer{}
+	for _, view := range []string{ViewMonth, ViewDetails} {
+		var first []byte
+		for i := range len(events) {
+			// Rotate the events, reversing every other order.
+			order := append(slices.Clone(events[i:]), events[:i]...)
+			if i%2 == 1 {
+				slices.Reverse(order)
+			}
+			in := MonthInput{Now: now, Width: 800, Height: 480, Weather: forecast, Events: order, MaxEventsPerDay: 8, BatteryPercentage: "80%"}
+			img, err := r.Render(PrepareData(view, in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = img
+			} else if !bytes.Equal(first, img) {
+				t.Errorf("%s view: events in order %d gave a different image", view, i)
+			}
+		}
+	}
+}
+
+// TestRenderErrorDeterministic renders an error image with several details,
+// which come in a map, and expects the same file every time.
+func TestRenderErrorDeterministic(t *testing.T) {
+	details := map[string]string{
+		"View":     "month",
+		"Config":   "config.yaml",
+		"Calendar": "Family",
+		"Weather":  "Prague",
+		"Time":     "2026-03-12 07:30",
+		"Version":  "dev",
+	}
+	dir := t.TempDir()
+	var first []byte
+	for i := range 5 {
+		path := filepath.Join(dir, fmt.Sprintf("error%d.png", i))
+		if err := RenderErrorToPNG(800, 480, "unable to fetch events", details, "", path); err != nil {
+			t.Fatal(err)
+		}
+		img, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = img
+		} else if !bytes.Equal(first, img) {
+			t.Errorf("error image %d differs from the first", i)
+		}
+	}
+}
+
+// TestEventDetailsRedacted checks the detail fields get the same
+// redactions as the title.
+func TestEventDetailsRedacted(t *testing.T) {
+	now := time.Date(2026, time.March, 12, 7, 30, 0, 0, time.UTC)
+	in := MonthInput{
+		Now:    now,
+		Width:  800,
+		Height: 480,
+		Events: []calendar.Event{{
+			ID:           "a",
+			Summary:      "Dr. Novak",
+			Location:     "Dr. Novak's practice",
+			Description:  "<p>Referral for <b>Dr. Novak</b></p>",
+			Start:        now.Add(time.Hour),
+			End:          now.Add(2 * time.Hour),
+			CalendarName: "Family",
+		}},
+		MaxEventsPerDay: 3,
+		Redactions:      []calendar.Redaction{{Pattern: regexp.MustCompile(`Dr\. Novak`), Replacement: "Doctor"}},
+	}
+
+	var found bool
+	data := PrepareData(ViewDetails, in)
+	data.forEachDay(func(day *DayData) {
+		for _, ev := range day.Events {
+			found = true
+			if ev.Summary != "Doctor" || ev.Location != "Doctor's practice" || ev.Description != "Referral for Doctor" {
+				t.Errorf("event shown as %q at %q: %q", ev.Summary, ev.Location, ev.Description)
+			}
+		}
+	})
+	if !found {
+		t.Fatal("the event isn't shown")
+	}
+}
+
+func TestForecastDays(t *testing.T) {
+	tests := map[string]int{
+		ViewMonth:   monthForecastDays,
+		ViewAgenda:  agendaDays,
+		ViewBoard:   boardDays,
+		ViewDetails: 1,
+		// The rolling view reaches four weeks ahead, past what Open-Meteo
+		// forecasts.
+		ViewRolling: weather.MaxForecastDays,
+	}
+	for view, want := range tests {
+		if got := ForecastDays(view); got != want {
+			t.Errorf("ForecastDays(%s) = %d, want %d", view, got, want)
+		}
+	}
+
+	// Temperatures show for exactly the window, however long the forecast.
+	now := time.Date(2026, time.March, 2, 7, 30, 0, 0, time.UTC)
+	forecast := &weather.Forecast{Units: weather.UnitsMetric}
+	for hour := range 30 * 24 {
+		forecast.Hourly = append(forecast.Hourly, weather.HourlyForecast{
+			Time:        now.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour),
+			Temperature: 10,
+		})
+	}
+	for view, want := range tests {
+		data := PrepareData(view, MonthInput{Now: now, Width: 800, Height: 480, Weather: forecast})
+		var got int
+		data.forEachDay(func(day *DayData) {
+			if day.DayTemp != "" {
+				got++
+			}
+		})
+		if got != want {
+			t.Errorf("%s view shows %d days of temperatures, want %d", view, got, want)
+		}
+	}
+}
+
+// TestBuildWeeksTrim covers calendar.trim_outside_weeks, which drops the
+// first week in any month once it has passed.
+func TestBuildWeeksTrim(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		{"Feb 2026 first week current", date(2026, time.February, 1), []string{"2026-01-26", "2026-02-02", "2026-02-09", "2026-02-16", "2026-02-23"}},
+		{"Feb 2026 first week past", date(2026, time.February, 2), []string{"2026-02-02", "2026-02-09", "2026-02-16", "2026-02-23"}},
+		{"Feb 2024 mid-month", date(2024, time.February, 14), []string{"2024-02-05", "2024-02-12", "2024-02-19", "2024-02-26"}},
+		// Six-week months lose their first week anyway, and only that one.
+		{"Mar 2026 mid-month", date(2026, time.March, 18), []string{"2026-03-02", "2026-03-09", "2026-03-16", "2026-03-23", "2026-03-30"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weeks := buildWeeks(tt.now, newDayBuilder(tt.now, MonthInput{Now: tt.now}, ViewMonth), true)
+			if got := weekStarts(weeks); !slices.Equal(got, tt.want) {
+				t.Errorf("rows start %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The last row always holds days of the month, so there is never a
+	// trailing week to trim.
+	for month := range 36 {
+		now := date(2024, time.January+time.Month(month), 20)
+		weeks := buildWeeks(now, newDayBuilder(now, MonthInput{Now: now}, ViewMonth), true)
+		last := weeks[len(weeks)-1]
+		if !slices.ContainsFunc(last.Days, func(d DayData) bool { return d.IsCurrentMonth }) {
+			t.Errorf("%s: the last row %s has no day of the month", now.Format("Jan 2006"), last.Days[0].Date)
+		}
+	}
+}
+
+// TestImportantPrivate checks keywords don't reveal the titles of private
+// or redacted events, while important calendars still apply to them.
+func TestImportantPrivate(t *testing.T) {
+	now := time.Date(2026, time.March, 12, 7, 30, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return now.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour) }
+	in := MonthInput{
+		Now:    now,
+		Width:  800,
+		Height: 480,
+		Events: []calendar.Event{
+			{ID: "private", Summary: "Flight to Oslo", Start: at(9), End: at(10), CalendarName: "Family", Private: true},
+			{ID: "redacted", Summary: "Flight to Oslo", Start: at(11), End: at(12), CalendarName: "Family"},
+			{ID: "plain", Summary: "Flight to Oslo airport", Start: at(13), End: at(14), CalendarName: "Family"},
+			{ID: "calendar", Summary: "Interview", Start: at(15), End: at(16), CalendarName: "Work", Private: true},
+		},
+		MaxEventsPerDay:    5,
+		ImportantCalendars: []string{"Work"},
+		ImportantKeywords:  []string{"OSLO"},
+		Redactions:         []calendar.Redaction{{Pattern: regexp.MustCompile(`to Oslo$`), Replacement: "abroad"}},
+	}
+
+	want := map[string]bool{"09:00": false, "11:00": false, "13:00": true, "15:00": true}
+	got := make(map[string]bool)
+	data := PrepareData(ViewDetails, in)
+	data.forEachDay(func(day *DayData) {
+		for _, ev := range day.Events {
+			// Keyed by start, as Time reads like "09:00–10:00".
+			got[ev.Time[:5]] = ev.Important
+		}
+	})
+	for start, important := range want {
+		if important != got[start] {
+			t.Errorf("event at %s important = %t, want %t", start, got[start], important)
+		}
+	}
+}
+
+// TestWritePNGReplaces checks the image is replaced whole, leaving no
+// temporary files behind.
+func TestWritePNGReplaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calendar.png")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePNG(image.NewGray(image.Rect(0, 0, 8, 8)), path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Errorf("decode the written image: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the output directory, want 1", len(entries))
+	}
+}
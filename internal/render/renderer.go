@@ -0,0 +1,38 @@
+package render
+
+import "bytes"
+
+// Renderer draws prepared data in an output format.
+type Renderer interface {
+	Render(data TemplateData) ([]byte, error)
+}
+
+// PNGRenderer draws the view as it goes to the panel, without the
+// variants RenderCalendarToPNG writes.
+type PNGRenderer struct {
+	Options Options
+}
+
+func (r PNGRenderer) Render(data TemplateData) ([]byte, error) {
+	img, _ := rasterize(data, r.Options)
+	var buf bytes.Buffer
+	if err := encodePNG(&buf, img, r.Options); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVGRenderer draws the view as vector graphics of the display size, for
+// panels larger than a raster is practical for and for inspecting the
+// layout. Text refers to the font by name instead of embedding it. Scale
+// and the large-print contrast don't apply to vectors.
+type SVGRenderer struct {
+	Options Options
+}
+
+func (r SVGRenderer) Render(data TemplateData) ([]byte, error) {
+	zoom := r.Options.zoom()
+	dc := newSVGCanvas(data.Width, data.Height, zoom)
+	newCalendarRenderer(dc, zoom).draw(data)
+	return dc.svg.document(data.Width, data.Height)
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+)
+
+// ReportRow is one line of a check report, e.g. from calvin doctor.
+type ReportRow struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// RenderReportToPNG draws a pass/fail table so checks can be read off the
+// display itself.
+func RenderReportToPNG(width, height int, title string, rows []ReportRow, outputPath string) error {
+	loadFonts()
+	dc := gg.NewContext(width, height)
+	r := &calendarRenderer{dc: &canvas{Context: dc, scale: 1, width: width, height: height}}
+	dc.SetHexColor(colorWhite)
+	dc.Clear()
+
+	padding := 40.0
+	dc.SetFontFace(truetype.NewFace(boldFont, &truetype.Options{Size: 32}))
+	dc.SetHexColor(colorBlack)
+	dc.DrawString(title, padding, padding+32)
+
+	rowHeight := min(36.0, (float64(height)-2*padding-60)/float64(max(1, len(rows))))
+	fontSize := rowHeight * 0.5
+	regular := truetype.NewFace(regularFont, &truetype.Options{Size: fontSize})
+	bold := truetype.NewFace(boldFont, &truetype.Options{Size: fontSize})
+	detailX := padding + 90 + fontSize*12
+	y := padding + 60 + rowHeight*0.7
+	for _, row := range rows {
+		dc.SetFontFace(bold)
+		if row.OK {
+			dc.SetHexColor(colorBlack)
+			dc.DrawString("PASS", padding, y)
+		} else {
+			dc.SetHexColor(colorRed)
+			dc.DrawString("FAIL", padding, y)
+		}
+		dc.SetHexColor(colorBlack)
+		dc.DrawString(row.Name, padding+90, y)
+
+		dc.SetFontFace(regular)
+		dc.SetHexColor(colorGrey)
+		dc.DrawString(r.truncateText(row.Detail, float64(width)-padding-detailX), detailX, y)
+		y += rowHeight
+	}
+
+	return writePNG(dc.Image(), outputPath, Options{})
+}
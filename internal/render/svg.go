@@ -0,0 +1,142 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgFontFamily names Liberation Sans, which the PNG embeds, and its
+// metric-compatible stand-ins, so text measured for the PNG fits.
+const svgFontFamily = "Liberation Sans, Arial, Helvetica, sans-serif"
+
+// svgFont is the font behind a face created by canvas.face.
+type svgFont struct {
+	bold bool
+	// size is in output pixels.
+	size float64
+}
+
+// svgRecorder collects what a canvas draws as SVG elements. Coordinates
+// are in output pixels, already transformed by the canvas matrix.
+type svgRecorder struct {
+	body  strings.Builder
+	path  strings.Builder
+	color string
+	// lineWidth is in output pixels.
+	lineWidth float64
+	font      svgFont
+	// current is set once the path has a current point, so the first
+	// point of an arc starts a new subpath and later ones join it.
+	current bool
+	// err is the first image that couldn't be embedded.
+	err error
+}
+
+func (s *svgRecorder) moveTo(x, y float64) {
+	fmt.Fprintf(&s.path, "M%s %s", svgNum(x), svgNum(y))
+	s.current = true
+}
+
+func (s *svgRecorder) lineTo(x, y float64) {
+	if !s.current {
+		s.moveTo(x, y)
+		return
+	}
+	fmt.Fprintf(&s.path, "L%s %s", svgNum(x), svgNum(y))
+}
+
+func (s *svgRecorder) closePath() {
+	if s.current {
+		s.path.WriteString("Z")
+	}
+}
+
+func (s *svgRecorder) clearPath() {
+	s.path.Reset()
+	s.current = false
+}
+
+func (s *svgRecorder) fill() {
+	if s.path.Len() > 0 {
+		fmt.Fprintf(&s.body, "<path d=\"%s\" fill=\"%s\"/>\n", s.path.String(), s.color)
+	}
+	s.clearPath()
+}
+
+func (s *svgRecorder) stroke() {
+	if s.path.Len() > 0 {
+		fmt.Fprintf(&s.body, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%s\"/>\n",
+			s.path.String(), s.color, svgNum(s.lineWidth))
+	}
+	s.clearPath()
+}
+
+// clear drops everything drawn so far and paints the background.
+func (s *svgRecorder) clear() {
+	s.body.Reset()
+	fmt.Fprintf(&s.body, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", s.color)
+}
+
+// text draws str with its baseline starting at x, y.
+func (s *svgRecorder) text(str string, x, y float64) {
+	weight := ""
+	if s.font.bold {
+		weight = ` font-weight="bold"`
+	}
+	fmt.Fprintf(&s.body, "<text x=\"%s\" y=\"%s\" font-size=\"%s\"%s fill=\"%s\">%s</text>\n",
+		svgNum(x), svgNum(y), svgNum(s.font.size), weight, s.color, html.EscapeString(str))
+}
+
+// image embeds img as a PNG stretched over the rectangle from x0, y0 to
+// x1, y1.
+func (s *svgRecorder) image(img image.Image, x0, y0, x1, y1 float64) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		if s.err == nil {
+			s.err = fmt.Errorf("unable to embed image: %w", err)
+		}
+		return
+	}
+	fmt.Fprintf(&s.body, "<image x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" preserveAspectRatio=\"none\" href=\"data:image/png;base64,%s\"/>\n",
+		svgNum(min(x0, x1)), svgNum(min(y0, y1)), svgNum(math.Abs(x1-x0)), svgNum(math.Abs(y1-y0)),
+		base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// document wraps the recorded elements in an SVG of the output size.
+func (s *svgRecorder) document(width, height int) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(&b, "<g font-family=\"%s\" stroke-linecap=\"round\" stroke-linejoin=\"round\" xml:space=\"preserve\">\n", svgFontFamily)
+	b.WriteString(s.body.String())
+	b.WriteString("</g>\n</svg>\n")
+	return b.Bytes(), nil
+}
+
+// svgNum formats a coordinate with at most two decimals, which is well
+// below a pixel and keeps the file small.
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// arcSegments is how many lines approximate a full circle.
+const arcSegments = 64
+
+// arcPoints approximates the arc of radius r around x, y from angle1 to
+// angle2 as gg draws it, calling to for each point.
+func arcPoints(x, y, r, angle1, angle2 float64, to func(x, y float64)) {
+	n := max(2, int(math.Ceil(math.Abs(angle2-angle1)/(2*math.Pi)*arcSegments)))
+	for i := range n + 1 {
+		a := angle1 + (angle2-angle1)*float64(i)/float64(n)
+		to(x+r*math.Cos(a), y+r*math.Sin(a))
+	}
+}
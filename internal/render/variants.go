@@ -0,0 +1,134 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// Palettes a variant can be reduced to.
+const (
+	PaletteBW     = "bw"
+	PaletteBWR    = "bwr"
+	Palette4Color = "4color"
+)
+
+// Dithering modes for palette reduction.
+const (
+	DitherNone           = "none"
+	DitherFloydSteinberg = "floyd-steinberg"
+)
+
+// Regions of the view a variant can be cropped to.
+const (
+	RegionToday  = "today"
+	RegionHeader = "header"
+)
+
+// Variant is an additional, usually smaller, copy of the rendered image,
+// e.g. for a small tag display next to the main frame.
+type Variant struct {
+	Path string
+	// Width and Height are the output size; zero keeps the source size.
+	Width  int
+	Height int
+	// Region (today or header) or Crop select the part of the image to
+	// use; the whole image is used when neither is set or the region isn't
+	// on screen.
+	Region string
+	Crop   image.Rectangle
+	// Palette reduces the colors to bw, bwr or 4color; empty keeps them.
+	Palette string
+	// Dither is none or floyd-steinberg; it only applies with a Palette
+	// or Raw.
+	Dither string
+	// Raw writes the controller frame buffer of this preset (ssd1680,
+	// it8951 or uc8159) instead of a PNG.
+	Raw string
+}
+
+var palettes = map[string]color.Palette{
+	PaletteBW:     {hexColor(colorWhite), hexColor(colorBlack)},
+	PaletteBWR:    {hexColor(colorWhite), hexColor(colorBlack), hexColor(colorRed)},
+	Palette4Color: {hexColor(colorWhite), hexColor(colorBlack), hexColor(colorRed), hexColor(colorGrey)},
+}
+
+// writeVariants crops and scales src into each variant, keeping the aspect
+// ratio on a white background, and writes it as PNG. regions maps region
+// names to where they were drawn.
+func writeVariants(src image.Image, regions map[string]image.Rectangle, variants []Variant, opts Options) error {
+	for _, v := range variants {
+		var img image.Image = src
+		crop := v.Crop
+		if v.Region != "" {
+			crop = regions[v.Region]
+		}
+		if crop = crop.Intersect(src.Bounds()); !crop.Empty() {
+			img = cropImage(src, crop)
+		}
+
+		if v.Width > 0 && v.Height > 0 {
+			img = ScaleToFit(img, v.Width, v.Height)
+		}
+		dither := v.Dither == DitherFloydSteinberg
+		if v.Raw != "" {
+			if err := writeRaw(img, v.Raw, dither, v.Path); err != nil {
+				return fmt.Errorf("unable to write variant %s: %w", v.Path, err)
+			}
+			continue
+		}
+
+		if v.Palette != "" {
+			img = reducePalette(img, palettes[v.Palette], dither)
+		}
+		if err := writePNG(img, v.Path, opts); err != nil {
+			return fmt.Errorf("unable to write variant %s: %w", v.Path, err)
+		}
+	}
+	return nil
+}
+
+func cropImage(src image.Image, rect image.Rectangle) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// ScaleToFit scales src to fit width x height, keeping the aspect ratio and
+// centering it on a white background.
+func ScaleToFit(src image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+
+	sb := src.Bounds()
+	scale := min(float64(width)/float64(sb.Dx()), float64(height)/float64(sb.Dy()))
+	w := int(float64(sb.Dx())*scale + 0.5)
+	h := int(float64(sb.Dy())*scale + 0.5)
+	x := (width - w) / 2
+	y := (height - h) / 2
+
+	draw.CatmullRom.Scale(dst, image.Rect(x, y, x+w, y+h), src, sb, draw.Over, nil)
+	return dst
+}
+
+// reducePalette maps img to the palette, either to the nearest color or
+// with Floyd-Steinberg error diffusion.
+func reducePalette(img image.Image, palette color.Palette, dither bool) *image.Paletted {
+	dst := image.NewPaletted(img.Bounds(), palette)
+	if dither {
+		draw.FloydSteinberg.Draw(dst, dst.Bounds(), img, img.Bounds().Min)
+	} else {
+		draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	return dst
+}
+
+// hexColor parses a "#rrggbb" color constant.
+func hexColor(hex string) color.RGBA {
+	var c color.RGBA
+	c.A = 0xff
+	fmt.Sscanf(hex, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return c
+}
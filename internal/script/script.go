@@ -0,0 +1,152 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+)
+
+// Timeout bounds a single script run on top of the caller's context.
+const Timeout = 30 * time.Second
+
+// Output is what a script prints to stdout.
+//
+//	{
+//	  "events": [
+//	    {"summary": "Bio bin", "start": "2026-10-20", "all_day": true},
+//	    {"summary": "Parent meeting", "start": "2026-10-21T17:00", "end": "2026-10-21T18:00",
+//	     "attendees": ["anna@example.com"]}
+//	  ],
+//	  "widgets": [{"label": "Waste", "value": "Bio on Tue"}]
+//	}
+//
+// Times are RFC 3339, "2006-01-02T15:04" in the configured timezone, or
+// "2006-01-02" for all-day events. All-day end dates are exclusive, as in
+// iCalendar; when omitted the event lasts one day.
+type Output struct {
+	Events  []ScriptEvent `json:"events"`
+	Widgets []Widget      `json:"widgets"`
+}
+
+type ScriptEvent struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	AllDay      bool   `json:"all_day"`
+	// Attendees are email addresses, matched against calendar.people.
+	Attendees []string `json:"attendees"`
+}
+
+// Widget is a short labelled value shown in the header.
+type Widget struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Result is a script's output converted to calendar events.
+type Result struct {
+	Events  []calendar.Event
+	Widgets []Widget
+}
+
+// Run executes command and parses its stdout. Events are tagged with
+// sourceName so they can be styled like any other calendar.
+func Run(ctx context.Context, command []string, sourceName string, loc *time.Location) (*Result, error) {
+	if len(command) == 0 {
+		return nil, errors.New("script source has no command")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("script %s failed: %w, stderr: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var out Output
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("failed to decode script output: %w", err)
+	}
+
+	result := &Result{
+		Events:  make([]calendar.Event, 0, len(out.Events)),
+		Widgets: out.Widgets,
+	}
+
+	for i, ev := range out.Events {
+		event, err := ev.toEvent(sourceName, loc)
+		if err != nil {
+			return nil, fmt.Errorf("event %d (%q): %w", i, ev.Summary, err)
+		}
+		result.Events = append(result.Events, event)
+	}
+
+	return result, nil
+}
+
+func (e ScriptEvent) toEvent(sourceName string, loc *time.Location) (calendar.Event, error) {
+	start, err := parseTime(e.Start, loc)
+	if err != nil {
+		return calendar.Event{}, fmt.Errorf("invalid start: %w", err)
+	}
+
+	var end time.Time
+	switch {
+	case e.End != "":
+		end, err = parseTime(e.End, loc)
+		if err != nil {
+			return calendar.Event{}, fmt.Errorf("invalid end: %w", err)
+		}
+	case e.AllDay:
+		end = start.AddDate(0, 0, 1)
+	default:
+		end = start
+	}
+
+	attendees := make([]string, 0, len(e.Attendees))
+	for _, a := range e.Attendees {
+		attendees = append(attendees, strings.ToLower(a))
+	}
+
+	videoCallLink := calendar.VideoCallLink(e.Location)
+	if videoCallLink == "" {
+		videoCallLink = calendar.VideoCallLink(e.Description)
+	}
+
+	return calendar.Event{
+		Summary:       e.Summary,
+		Description:   e.Description,
+		Location:      e.Location,
+		Start:         start,
+		End:           end,
+		AllDay:        e.AllDay,
+		CalendarName:  sourceName,
+		Attendees:     attendees,
+		VideoCall:     calendar.HasVideoCallLink(e.Location) || calendar.HasVideoCallLink(e.Description),
+		VideoCallLink: videoCallLink,
+	}, nil
+}
+
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(loc), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, loc); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Access keeps the family's schedule private on a shared network: the
+// image and metadata endpoints require Token or the basic-auth Username
+// and Password, whichever are set, and CertFile and KeyFile switch the
+// server to HTTPS.
+type Access struct {
+	Token    string
+	Username string
+	Password string
+	CertFile string
+	KeyFile  string
+}
+
+// required reports whether any credentials are configured.
+func (a *Access) required() bool {
+	return a != nil && (a.Token != "" || a.Username != "")
+}
+
+// allowed reports whether r carries the token or the basic-auth
+// credentials. Without either configured everyone is allowed.
+func (a *Access) allowed(r *http.Request) bool {
+	if !a.required() {
+		return true
+	}
+	if a.Token != "" && validToken(r, a.Token) {
+		return true
+	}
+	if a.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.Username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.Password)) == 1
+		return ok && userOK && passOK
+	}
+	return false
+}
+
+// protect wraps h so it answers 401 to requests without valid credentials.
+func (s *Server) protect(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.access.allowed(r) {
+			if s.access.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Calvin", charset="UTF-8"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// protectImage is protect that also admits TRMNL devices with their image
+// token.
+func (s *Server) protectImage(h http.HandlerFunc) http.HandlerFunc {
+	protected := s.protect(h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.trmnl != nil && s.devices.validImageToken(r.URL.Query().Get("device_token")) {
+			h(w, r)
+			return
+		}
+		protected(w, r)
+	}
+}
+
+// EnsureSelfSignedCert writes a self-signed certificate and key for hosts
+// unless certFile already exists, and returns the certificate's SHA-256
+// fingerprint for clients that pin it.
+func EnsureSelfSignedCert(certFile, keyFile string, hosts []string) (string, error) {
+	if data, err := os.ReadFile(certFile); err == nil {
+		return fingerprint(data)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Calvin"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", fmt.Errorf("unable to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return "", err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return "", err
+	}
+	return fingerprint(certPEM)
+}
+
+// fingerprint returns the SHA-256 of the first certificate in a PEM file.
+func fingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", fmt.Errorf("no PEM certificate found")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// newTestServer serves a small output image and an empty month with cfg.
+func newTestServer(t *testing.T, cfg Config) (*Server, http.Handler) {
+	t.Helper()
+	cfg.OutputPath = filepath.Join(t.TempDir(), "calendar.png")
+	f, err := os.Create(cfg.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 16, 16))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s := New(cfg)
+	s.SetMonth(render.TemplateData{})
+	return s, s.Handler()
+}
+
+// status returns the status h answers req with.
+func status(h http.Handler, req *http.Request) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAccess(t *testing.T) {
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(user, pass string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, pass) }
+	}
+	tests := []struct {
+		name   string
+		access *Access
+		query  string
+		auth   func(*http.Request)
+		want   int
+	}{
+		{"no auth configured", nil, "", nil, http.StatusOK},
+		{"no credentials", &Access{Token: "secret"}, "", nil, http.StatusUnauthorized},
+		{"bearer token", &Access{Token: "secret"}, "", bearer("secret"), http.StatusOK},
+		{"wrong bearer token", &Access{Token: "secret"}, "", bearer("guess"), http.StatusUnauthorized},
+		{"query token", &Access{Token: "secret"}, "?token=secret", nil, http.StatusOK},
+		{"wrong query token", &Access{Token: "secret"}, "?token=secre", nil, http.StatusUnauthorized},
+		{"basic auth", &Access{Username: "family", Password: "pw"}, "", basic("family", "pw"), http.StatusOK},
+		{"wrong password", &Access{Username: "family", Password: "pw"}, "", basic("family", "guess"), http.StatusUnauthorized},
+		{"wrong user", &Access{Username: "family", Password: "pw"}, "", basic("guest", "pw"), http.StatusUnauthorized},
+		{"token for basic auth", &Access{Username: "family", Password: "pw"}, "?token=pw", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, h := newTestServer(t, Config{Access: tt.access})
+			for _, path := range []string{"/calendar.png", "/calendar.bmp", "/meta.json", "/api/month"} {
+				req := httptest.NewRequest("GET", path+tt.query, nil)
+				if tt.auth != nil {
+					tt.auth(req)
+				}
+				if got := status(h, req); got != tt.want {
+					t.Errorf("%s: status %d, want %d", path, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestDeviceToken checks the image token handed to a TRMNL device opens
+// the BMP it draws and nothing else.
+func TestDeviceToken(t *testing.T) {
+	_, h := newTestServer(t, Config{
+		Access: &Access{Token: "secret"},
+		TRMNL:  &TRMNL{AccessToken: "devtok", Width: 800, Height: 480, RefreshRate: time.Minute},
+	})
+
+	req := httptest.NewRequest("GET", "/api/setup?token=secret", nil)
+	req.Header.Set("ID", "AA:BB")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var setup trmnlSetupResponse
+	if err := json.NewDecoder(rec.Body).Decode(&setup); err != nil {
+		t.Fatalf("setup answered %d: %v", rec.Code, err)
+	}
+	u, err := url.Parse(setup.ImageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := u.Query().Get("device_token")
+	if token == "" || u.Query().Get("token") != "" {
+		t.Fatalf("image URL %s should carry a device token and no server token", setup.ImageURL)
+	}
+
+	if got := status(h, httptest.NewRequest("GET", u.RequestURI(), nil)); got != http.StatusOK {
+		t.Errorf("image URL: status %d, want 200", got)
+	}
+	for _, path := range []string{"/calendar.png", "/meta.json", "/api/month"} {
+		if got := status(h, httptest.NewRequest("GET", path+"?device_token="+token, nil)); got != http.StatusUnauthorized {
+			t.Errorf("%s with a device token: status %d, want 401", path, got)
+		}
+	}
+	if got := status(h, httptest.NewRequest("GET", "/calendar.bmp?device_token=0123", nil)); got != http.StatusUnauthorized {
+		t.Errorf("made-up device token: status %d, want 401", got)
+	}
+}
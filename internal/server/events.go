@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxQuickAddText bounds the text of POST /events; quick-add phrases are
+// short.
+const maxQuickAddText = 1024
+
+// AddedEvent is the event Google created from a quick-add text.
+type AddedEvent struct {
+	ID      string    `json:"id"`
+	Summary string    `json:"summary"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	AllDay  bool      `json:"all_day"`
+}
+
+// QuickAddFunc creates an event from text such as "Buy milk 18:00".
+type QuickAddFunc func(ctx context.Context, text string) (AddedEvent, error)
+
+// handleQuickAdd creates an event from the "text" of a JSON body or form,
+// for keypads and home automations, and re-renders so it shows up right
+// away. Like POST /refresh it requires the refresh token.
+func (s *Server) handleQuickAdd(w http.ResponseWriter, r *http.Request) {
+	if s.refreshToken == "" {
+		http.Error(w, "refresh token not configured", http.StatusForbidden)
+		return
+	}
+	if !validToken(r, s.refreshToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuickAddText)
+	var text string
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		text = body.Text
+	} else {
+		text = r.FormValue("text")
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	event, err := s.quickAdd(r.Context(), text)
+	if err != nil {
+		log.Printf("Error: quick add %q: %v", text, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("Event %q added by %s", event.Summary, r.RemoteAddr)
+	s.refresh()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, event)
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/render"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+)
+
+// maxImageSize bounds the w and h query parameters.
+const maxImageSize = 4096
+
+var imageFormats = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"bmp":  "image/bmp",
+	"bmp1": "image/bmp",
+}
+
+type imageKey struct {
+	width  int
+	height int
+	format string
+	// stale adds the stale frame once the image is past its expiry.
+	stale bool
+}
+
+// imageCache holds the encodings of the current output file for the sizes
+// and formats devices asked for. It is reset whenever the file changes.
+type imageCache struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	hash    string
+	source  []byte
+	text    map[string]string
+	expires time.Time
+	entries map[imageKey][]byte
+}
+
+// load returns the output file's modification time and content hash,
+// rereading the file only when it changed.
+func (c *imageCache) load(path string) (time.Time, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.modTime, c.hash, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	sum := sha256.Sum256(data)
+	c.modTime = info.ModTime()
+	c.size = info.Size()
+	c.hash = hex.EncodeToString(sum[:8])
+	c.source = data
+	c.text = render.PNGText(data)
+	c.expires, _ = time.Parse(time.RFC3339, c.text[render.MetaExpires])
+	c.entries = make(map[imageKey][]byte)
+	return c.modTime, c.hash, nil
+}
+
+// metadata returns the tEXt chunks of the loaded image.
+func (c *imageCache) metadata() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text
+}
+
+// staleSince returns when the loaded image expired, or the zero time while
+// it is current or carries no expiry.
+func (c *imageCache) staleSince(now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expires.IsZero() || now.Before(c.expires) {
+		return time.Time{}
+	}
+	return c.expires
+}
+
+// encode returns the output image scaled and encoded for key. The file
+// must have been loaded with the given hash; a concurrent change makes
+// the caller retry on its next request.
+func (c *imageCache) encode(hash string, key imageKey) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hash != c.hash {
+		return nil, fmt.Errorf("image changed while serving")
+	}
+	if data, ok := c.entries[key]; ok {
+		return data, nil
+	}
+	if key == (imageKey{format: "png"}) {
+		return c.source, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(c.source))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+	if key.width > 0 || key.height > 0 {
+		w, h := fitSize(img.Bounds(), key.width, key.height)
+		img = render.ScaleToFit(img, w, h)
+	}
+	if key.stale {
+		img = render.MarkStale(img)
+	}
+
+	var buf bytes.Buffer
+	switch key.format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "bmp":
+		err = bmp.Encode(&buf, img)
+	case "bmp1":
+		err = encodeBMP1(&buf, img)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode image: %w", err)
+	}
+	c.entries[key] = buf.Bytes()
+	return buf.Bytes(), nil
+}
+
+// fitSize fills in a missing width or height from the source aspect ratio.
+func fitSize(src image.Rectangle, width, height int) (int, int) {
+	switch {
+	case width == 0:
+		width = max(1, height*src.Dx()/src.Dy())
+	case height == 0:
+		height = max(1, width*src.Dy()/src.Dx())
+	}
+	return width, height
+}
+
+// parseImageKey reads the w, h and fmt query parameters. fmt defaults to
+// the extension of the requested path.
+func parseImageKey(r *http.Request, defaultFormat string) (imageKey, error) {
+	q := r.URL.Query()
+	key := imageKey{format: defaultFormat}
+	if f := q.Get("fmt"); f != "" {
+		key.format = f
+	}
+	if _, ok := imageFormats[key.format]; !ok {
+		return key, fmt.Errorf("unsupported format %q (use png, jpeg, bmp or bmp1)", key.format)
+	}
+	if key.format == "jpg" {
+		key.format = "jpeg"
+	}
+
+	for name, dst := range map[string]*int{"w": &key.width, "h": &key.height} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxImageSize {
+			return key, fmt.Errorf("%s must be between 1 and %d", name, maxImageSize)
+		}
+		*dst = n
+	}
+	return key, nil
+}
+
+// serveImage serves the output image in the requested size and format.
+// The ETag covers the image content and the parameters, so devices polling
+// with If-None-Match or If-Modified-Since get 304 until the next render.
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request, defaultFormat string) {
+	key, err := parseImageKey(r, defaultFormat)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	modTime, hash, err := s.images.load(s.outputPath)
+	if err != nil {
+		http.Error(w, "image not available", http.StatusNotFound)
+		return
+	}
+	// A stale image changes at its expiry, so devices fetch the framed one.
+	etag := fmt.Sprintf(`"%s-%dx%d-%s"`, hash, key.width, key.height, key.format)
+	if since := s.images.staleSince(time.Now()); !since.IsZero() {
+		key.stale = true
+		modTime = since
+		etag = fmt.Sprintf(`"%s-%dx%d-%s-stale"`, hash, key.width, key.height, key.format)
+	}
+	w.Header().Set("Content-Type", imageFormats[key.format])
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	data, err := s.images.encode(hash, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	// ServeContent answers If-None-Match and If-Modified-Since with 304.
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
+
+// encodeBMP1 writes img dithered to a 1 bpp black and white BMP, the format
+// TRMNL and similar firmware draw without decoding.
+func encodeBMP1(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	bw := image.NewPaletted(b, color.Palette{color.Black, color.White})
+	draw.FloydSteinberg.Draw(bw, b, img, b.Min)
+
+	width, height := b.Dx(), b.Dy()
+	stride := (width + 31) / 32 * 4
+	const headerSize = 14 + 40 + 2*4
+	pixels := make([]byte, stride*height)
+	for y := 0; y < height; y++ {
+		// BMP rows are stored bottom-up.
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			if bw.ColorIndexAt(b.Min.X+x, b.Min.Y+y) == 1 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+
+	header := []any{
+		[2]byte{'B', 'M'}, uint32(headerSize + len(pixels)), uint32(0), uint32(headerSize),
+		uint32(40), int32(width), int32(height), uint16(1), uint16(1), uint32(0),
+		uint32(len(pixels)), int32(2835), int32(2835), uint32(2), uint32(2),
+		[4]byte{0, 0, 0, 0}, [4]byte{0xff, 0xff, 0xff, 0},
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(pixels)
+	return err
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// minRefreshHint keeps devices from polling in a tight loop when the next
+// render is overdue.
+const minRefreshHint = time.Minute
+
+// metaResponse describes the current image so devices can decide whether
+// to download it and how long to sleep.
+type metaResponse struct {
+	// Hash changes with every new image; it is part of the image ETag.
+	Hash        string `json:"hash"`
+	GeneratedAt string `json:"generated_at,omitempty"`
+	NextRefresh string `json:"next_refresh,omitempty"`
+	// RefreshIn is the number of seconds until the next render is
+	// expected, at least a minute.
+	RefreshIn int    `json:"refresh_in,omitempty"`
+	Battery   string `json:"battery,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Expires   string `json:"expires,omitempty"`
+	Stale     bool   `json:"stale"`
+}
+
+// handleMeta serves the run information embedded in the current image.
+func (s *Server) handleMeta(w http.ResponseWriter, r *http.Request) {
+	_, hash, err := s.images.load(s.outputPath)
+	if err != nil {
+		http.Error(w, "image not available", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	text := s.images.metadata()
+	resp := metaResponse{
+		Hash:        hash,
+		GeneratedAt: text[render.MetaCreated],
+		NextRefresh: text[render.MetaNextRefresh],
+		Battery:     text[render.MetaBattery],
+		Version:     text[render.MetaSoftware],
+		Expires:     text[render.MetaExpires],
+		Stale:       !s.images.staleSince(now).IsZero(),
+	}
+	if next, err := time.Parse(time.RFC3339, resp.NextRefresh); err == nil {
+		resp.RefreshIn = int(max(next.Sub(now), minRefreshHint).Seconds())
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, resp)
+}
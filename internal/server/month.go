@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/paveljanda/calvin/internal/render"
+)
+
+// monthData holds the month view data of the latest render.
+type monthData struct {
+	mu   sync.Mutex
+	data *render.TemplateData
+}
+
+// SetMonth publishes the month view data of a render for GET /api/month.
+func (s *Server) SetMonth(data render.TemplateData) {
+	s.month.mu.Lock()
+	defer s.month.mu.Unlock()
+	s.month.data = &data
+}
+
+// handleMonth serves the prepared month of the latest render: days with
+// their events, temperatures and flags, for dashboards that do their own
+// drawing.
+func (s *Server) handleMonth(w http.ResponseWriter, r *http.Request) {
+	s.month.mu.Lock()
+	data := s.month.data
+	s.month.mu.Unlock()
+	if data == nil {
+		http.Error(w, "data not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, data)
+}
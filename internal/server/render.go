@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBadRequest marks render errors caused by the request parameters,
+// e.g. an unknown view, which are answered with 400.
+var ErrBadRequest = errors.New("bad request")
+
+// RenderRequest overrides the configured display for one render. Zero
+// values keep the configuration.
+type RenderRequest struct {
+	View   string
+	Width  int
+	Height int
+	// Calendars limits the sources to these names.
+	Calendars []string
+}
+
+// RenderFunc renders a fresh image for req and returns it as PNG.
+type RenderFunc func(ctx context.Context, req RenderRequest) ([]byte, error)
+
+// parseRenderRequest reads the view, width, height and calendars query
+// parameters; calendars is a comma-separated list.
+func parseRenderRequest(r *http.Request) (RenderRequest, error) {
+	q := r.URL.Query()
+	req := RenderRequest{View: q.Get("view")}
+	for name, dst := range map[string]*int{"width": &req.Width, "height": &req.Height} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxImageSize {
+			return req, fmt.Errorf("%s must be between 1 and %d", name, maxImageSize)
+		}
+		*dst = n
+	}
+	for _, name := range strings.Split(q.Get("calendars"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Calendars = append(req.Calendars, name)
+		}
+	}
+	return req, nil
+}
+
+// handleRender renders with the parameters of the request, so one Calvin
+// can serve displays of different sizes and views.
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRenderRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Without an output image yet the key just lacks the data version.
+	_, hash, _ := s.images.load(s.outputPath)
+	start := time.Now()
+	data, hit, err := s.renders.get(r.Context(), newRenderKey(req, hash), func() ([]byte, error) {
+		// Requests waiting for the same render must not fail because
+		// the one that started it went away.
+		ctx := context.WithoutCancel(r.Context())
+		return s.queue.run(ctx, func() ([]byte, error) {
+			return s.render(ctx, req)
+		})
+	})
+	if errors.Is(err, errBusy) {
+		retry := s.queue.retryAfter()
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry.Round(time.Second).Seconds())))
+		http.Error(w, "renderer busy, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, ErrBadRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Render for %s failed: %v", r.RemoteAddr, err)
+		http.Error(w, "render failed", http.StatusInternalServerError)
+		return
+	}
+	if hit {
+		log.Printf("Served cached render %s to %s", r.URL.RawQuery, r.RemoteAddr)
+	} else {
+		log.Printf("Rendered %s for %s in %s", r.URL.RawQuery, r.RemoteAddr, time.Since(start).Round(time.Millisecond))
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRenderCacheEntries bounds the memory held by cached renders.
+const maxRenderCacheEntries = 16
+
+// renderKey identifies a render: the request and the hash of the current
+// output image, which changes whenever the scheduled render picked up new
+// data.
+type renderKey struct {
+	view      string
+	width     int
+	height    int
+	calendars string
+	hash      string
+}
+
+func newRenderKey(req RenderRequest, hash string) renderKey {
+	return renderKey{
+		view:      req.View,
+		width:     req.Width,
+		height:    req.Height,
+		calendars: strings.Join(req.Calendars, ","),
+		hash:      hash,
+	}
+}
+
+type renderEntry struct {
+	// done is closed once data and err are set.
+	done    chan struct{}
+	data    []byte
+	err     error
+	expires time.Time
+}
+
+// renderCache keeps on-demand renders for ttl, so devices asking for the
+// same image within a refresh window share one render. Requests arriving
+// while that render runs wait for it instead of starting their own.
+type renderCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[renderKey]*renderEntry
+}
+
+// get returns the cached render for key, or calls render and caches its
+// result. hit reports whether another request did the rendering.
+func (c *renderCache) get(ctx context.Context, key renderKey, render func() ([]byte, error)) (data []byte, hit bool, err error) {
+	if c.ttl <= 0 {
+		data, err = render()
+		return data, false, err
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.data, true, e.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	e := &renderEntry{done: make(chan struct{})}
+	if c.entries == nil {
+		c.entries = make(map[renderKey]*renderEntry)
+	}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.data, e.err = render()
+
+	c.mu.Lock()
+	if e.err != nil {
+		delete(c.entries, key)
+	} else {
+		e.expires = time.Now().Add(c.ttl)
+		c.prune()
+	}
+	c.mu.Unlock()
+	close(e.done)
+	return e.data, false, e.err
+}
+
+// prune drops expired renders, then the ones expiring first while there
+// are too many. The caller holds mu.
+func (c *renderCache) prune() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for len(c.entries) > maxRenderCacheEntries {
+		var oldest renderKey
+		var oldestExpires time.Time
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && (oldestExpires.IsZero() || e.expires.Before(oldestExpires)) {
+				oldest, oldestExpires = k, e.expires
+			}
+		}
+		if oldestExpires.IsZero() {
+			return
+		}
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRenderCacheShared(t *testing.T) {
+	c := renderCache{ttl: time.Minute}
+	key := newRenderKey(RenderRequest{View: "agenda", Width: 600}, "abc")
+
+	var renders, hits atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	render := func() ([]byte, error) {
+		if renders.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return []byte("png"), nil
+	}
+
+	var wg sync.WaitGroup
+	get := func() {
+		defer wg.Done()
+		data, hit, err := c.get(context.Background(), key, render)
+		if err != nil || string(data) != "png" {
+			t.Errorf("get = %q, %v", data, err)
+		}
+		if hit {
+			hits.Add(1)
+		}
+	}
+	wg.Add(1)
+	go get()
+	<-started
+	for range 7 {
+		wg.Add(1)
+		go get()
+	}
+	close(release)
+	wg.Wait()
+
+	if renders.Load() != 1 || hits.Load() != 7 {
+		t.Errorf("%d renders and %d hits for 8 identical requests, want 1 and 7", renders.Load(), hits.Load())
+	}
+
+	// A different request or a new output image renders again.
+	for _, other := range []renderKey{
+		newRenderKey(RenderRequest{View: "agenda", Width: 800}, "abc"),
+		newRenderKey(RenderRequest{View: "agenda", Width: 600}, "def"),
+	} {
+		if _, hit, _ := c.get(context.Background(), other, render); hit {
+			t.Errorf("%+v served from the cache", other)
+		}
+	}
+}
+
+func TestRenderCacheErrors(t *testing.T) {
+	c := renderCache{ttl: time.Minute}
+	key := newRenderKey(RenderRequest{View: "month"}, "abc")
+
+	var renders int
+	fail := errors.New("calendar unreachable")
+	render := func() ([]byte, error) {
+		renders++
+		if renders == 1 {
+			return nil, fail
+		}
+		return []byte("png"), nil
+	}
+	if _, _, err := c.get(context.Background(), key, render); !errors.Is(err, fail) {
+		t.Fatalf("first get: %v, want the render error", err)
+	}
+	data, hit, err := c.get(context.Background(), key, render)
+	if err != nil || hit || string(data) != "png" {
+		t.Errorf("get after an error = %q, hit %t, %v; want a new render", data, hit, err)
+	}
+}
+
+func TestRenderCacheExpires(t *testing.T) {
+	c := renderCache{ttl: 20 * time.Millisecond}
+	key := newRenderKey(RenderRequest{View: "month"}, "abc")
+	render := func() ([]byte, error) { return []byte("png"), nil }
+
+	c.get(context.Background(), key, render)
+	if _, hit, _ := c.get(context.Background(), key, render); !hit {
+		t.Error("render not reused within the TTL")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, hit, _ := c.get(context.Background(), key, render); hit {
+		t.Error("render reused after the TTL")
+	}
+
+	// Without a TTL nothing is kept.
+	off := renderCache{}
+	off.get(context.Background(), key, render)
+	if _, hit, _ := off.get(context.Background(), key, render); hit || len(off.entries) > 0 {
+		t.Error("render cached with the cache disabled")
+	}
+}
+
+func TestRenderCachePrune(t *testing.T) {
+	c := renderCache{ttl: time.Minute}
+	render := func() ([]byte, error) { return []byte("png"), nil }
+	key := func(i int) renderKey {
+		return newRenderKey(RenderRequest{View: "month", Calendars: []string{fmt.Sprint(i)}}, "abc")
+	}
+
+	for i := range maxRenderCacheEntries + 4 {
+		c.get(context.Background(), key(i), render)
+	}
+	if len(c.entries) != maxRenderCacheEntries {
+		t.Errorf("%d cached renders, want %d", len(c.entries), maxRenderCacheEntries)
+	}
+	// The renders expiring first go.
+	if _, ok := c.entries[key(0)]; ok {
+		t.Error("the oldest render is still cached")
+	}
+	if _, ok := c.entries[key(maxRenderCacheEntries+3)]; !ok {
+		t.Error("the newest render isn't cached")
+	}
+
+	// Expired renders go first of all.
+	for _, e := range c.entries {
+		e.expires = time.Now().Add(-time.Second)
+	}
+	c.get(context.Background(), key(100), render)
+	if len(c.entries) != 1 {
+		t.Errorf("%d cached renders after the others expired, want 1", len(c.entries))
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errBusy is returned when the render queue is full or a request waited
+// too long for a slot; it is answered with 503 and Retry-After.
+var errBusy = errors.New("renderer busy")
+
+// renderQueue limits how many on-demand renders run at once, since a Pi
+// Zero struggles with even two, and how many may wait for a slot.
+type renderQueue struct {
+	slots      chan struct{}
+	maxWaiting int
+	wait       time.Duration
+
+	mu      sync.Mutex
+	waiting int
+	// last is how long the latest render took, for Retry-After.
+	last time.Duration
+}
+
+func newRenderQueue(concurrency, maxWaiting int, wait time.Duration) *renderQueue {
+	return &renderQueue{
+		slots:      make(chan struct{}, max(concurrency, 1)),
+		maxWaiting: maxWaiting,
+		wait:       wait,
+	}
+}
+
+// run calls render once a slot is free, waiting at most q.wait.
+func (q *renderQueue) run(ctx context.Context, render func() ([]byte, error)) ([]byte, error) {
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		if err := q.waitForSlot(ctx); err != nil {
+			return nil, err
+		}
+	}
+	defer func() { <-q.slots }()
+
+	start := time.Now()
+	data, err := render()
+	q.mu.Lock()
+	q.last = time.Since(start)
+	q.mu.Unlock()
+	return data, err
+}
+
+func (q *renderQueue) waitForSlot(ctx context.Context) error {
+	q.mu.Lock()
+	if q.waiting >= q.maxWaiting {
+		q.mu.Unlock()
+		return errBusy
+	}
+	q.waiting++
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(q.wait)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return errBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter estimates when a slot frees up: one render for every request
+// ahead, at least a second.
+func (q *renderQueue) retryAfter() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return max(time.Second, q.last*time.Duration(q.waiting+1))
+}
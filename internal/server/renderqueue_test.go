@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRenderQueueBusy has one render block the only slot: the next request
+// waits its turn, the one after finds the queue full, and both are turned
+// away with 503 and Retry-After.
+func TestRenderQueueBusy(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s, h := newTestServer(t, Config{
+		Render: func(ctx context.Context, req RenderRequest) ([]byte, error) {
+			started <- struct{}{}
+			<-release
+			return []byte("png"), nil
+		},
+		RenderConcurrency: 1,
+		RenderQueue:       1,
+		RenderWait:        200 * time.Millisecond,
+	})
+
+	get := func(view string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/render?view="+view, nil))
+		return rec
+	}
+	busy := func(name string, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("%s: status %d, Retry-After %q; want 503 and 1", name, rec.Code, rec.Header().Get("Retry-After"))
+		}
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- get("month") }()
+	<-started
+
+	waiting := make(chan *httptest.ResponseRecorder)
+	go func() { waiting <- get("agenda") }()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		s.queue.mu.Lock()
+		n := s.queue.waiting
+		s.queue.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the second request never queued")
+		}
+	}
+
+	busy("full queue", get("board"))
+	busy("wait timeout", <-waiting)
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusOK || rec.Body.String() != "png" {
+		t.Errorf("first render: status %d, body %q", rec.Code, rec.Body)
+	}
+}
+
+// TestRenderQueueRetryAfter estimates one render per request ahead.
+func TestRenderQueueRetryAfter(t *testing.T) {
+	q := newRenderQueue(1, 4, time.Minute)
+	if got := q.retryAfter(); got != time.Second {
+		t.Errorf("retryAfter before any render = %s, want 1s", got)
+	}
+	q.last = 3 * time.Second
+	q.waiting = 2
+	if got := q.retryAfter(); got != 9*time.Second {
+		t.Errorf("retryAfter with 2 waiting = %s, want 9s", got)
+	}
+}
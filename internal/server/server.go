@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Server exposes the rendered image over HTTP and accepts refresh requests
+// while Calvin runs in daemon mode.
+type Server struct {
+	addr         string
+	outputPath   string
+	refreshToken string
+	refresh      func()
+	images       imageCache
+	trmnl        *TRMNL
+	access       *Access
+	render       RenderFunc
+	renders      renderCache
+	queue        *renderQueue
+	quickAdd     QuickAddFunc
+	month        monthData
+	devices      trmnlDevices
+}
+
+// Config configures a Server.
+type Config struct {
+	Addr         string
+	OutputPath   string
+	RefreshToken string
+	Refresh      func()
+	// TRMNL enables the TRMNL device API when non-nil.
+	TRMNL *TRMNL
+	// Access protects the image endpoints when non-nil.
+	Access *Access
+	// Render enables GET /render when non-nil.
+	Render RenderFunc
+	// RenderCacheTTL is how long renders are reused for identical
+	// requests; zero disables the cache.
+	RenderCacheTTL time.Duration
+	// RenderConcurrency renders may run at once (at least 1), and up to
+	// RenderQueue more wait at most RenderWait for a slot before being
+	// turned away with 503.
+	RenderConcurrency int
+	RenderQueue       int
+	RenderWait        time.Duration
+	// QuickAdd enables POST /events when non-nil.
+	QuickAdd QuickAddFunc
+}
+
+func New(cfg Config) *Server {
+	return &Server{
+		addr:         cfg.Addr,
+		outputPath:   cfg.OutputPath,
+		refreshToken: cfg.RefreshToken,
+		refresh:      cfg.Refresh,
+		trmnl:        cfg.TRMNL,
+		access:       cfg.Access,
+		render:       cfg.Render,
+		renders:      renderCache{ttl: cfg.RenderCacheTTL},
+		queue:        newRenderQueue(cfg.RenderConcurrency, cfg.RenderQueue, cfg.RenderWait),
+		quickAdd:     cfg.QuickAdd,
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendar.png", s.protect(s.handleImage))
+	mux.HandleFunc("GET /calendar.jpg", s.protect(s.handleImage))
+	mux.HandleFunc("GET /calendar.bmp", s.protectImage(s.handleImage))
+	mux.HandleFunc("GET /meta.json", s.protect(s.handleMeta))
+	mux.HandleFunc("GET /api/month", s.protect(s.handleMonth))
+	if s.render != nil {
+		mux.HandleFunc("GET /render", s.protect(s.handleRender))
+	}
+	mux.HandleFunc("POST /refresh", s.handleRefresh)
+	if s.quickAdd != nil {
+		mux.HandleFunc("POST /events", s.handleQuickAdd)
+	}
+	if s.trmnl != nil {
+		mux.HandleFunc("GET /api/setup", s.handleTRMNLSetup)
+		mux.HandleFunc("POST /api/pair", s.handleTRMNLPair)
+		mux.HandleFunc("GET /api/display", s.handleTRMNLDisplay)
+		mux.HandleFunc("POST /api/log", s.handleTRMNLLog)
+	}
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if s.access != nil && s.access.CertFile != "" {
+		log.Printf("HTTPS server listening on %s", s.addr)
+		err = srv.ListenAndServeTLS(s.access.CertFile, s.access.KeyFile)
+	} else {
+		log.Printf("HTTP server listening on %s", s.addr)
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleImage serves the latest image. The format follows the path
+// extension unless ?fmt= overrides it, and ?w=/?h= downscale it for
+// devices such as ESPHome's online_image or Inkplate boards.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	s.serveImage(w, r, strings.TrimPrefix(path.Ext(r.URL.Path), "."))
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if s.refreshToken == "" {
+		http.Error(w, "refresh token not configured", http.StatusForbidden)
+		return
+	}
+	if !validToken(r, s.refreshToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.refresh()
+	log.Printf("Refresh requested by %s", r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validToken accepts the token as "Authorization: Bearer <token>" or as the
+// "token" query parameter for clients that can't set headers.
+func validToken(r *http.Request, expected string) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// trmnlPairWindow is how long POST /api/pair lets one device register.
+const trmnlPairWindow = 5 * time.Minute
+
+// TRMNL configures the TRMNL device API: devices call /api/setup once,
+// then poll /api/display for the image URL and how long to sleep.
+type TRMNL struct {
+	// AccessToken is handed out by /api/setup and required on
+	// /api/display when set.
+	AccessToken string
+	Width       int
+	Height      int
+	// RefreshRate is how long devices sleep between polls.
+	RefreshRate time.Duration
+}
+
+type trmnlSetupResponse struct {
+	Status     int    `json:"status"`
+	APIKey     string `json:"api_key"`
+	FriendlyID string `json:"friendly_id"`
+	ImageURL   string `json:"image_url"`
+	Filename   string `json:"filename"`
+	Message    string `json:"message"`
+}
+
+type trmnlDisplayResponse struct {
+	Status          int     `json:"status"`
+	ImageURL        string  `json:"image_url"`
+	Filename        string  `json:"filename"`
+	RefreshRate     int     `json:"refresh_rate"`
+	ResetFirmware   bool    `json:"reset_firmware"`
+	UpdateFirmware  bool    `json:"update_firmware"`
+	FirmwareURL     *string `json:"firmware_url"`
+	SpecialFunction string  `json:"special_function"`
+}
+
+// trmnlDevices issues the image tokens of TRMNL devices and tracks the
+// pairing window.
+type trmnlDevices struct {
+	mu sync.Mutex
+	// tokens maps device IDs to their image tokens. They live as long as
+	// the process; devices fetch a fresh image URL on every poll.
+	tokens    map[string]string
+	pairUntil time.Time
+}
+
+// imageToken returns the image token of device, issuing one on first use.
+func (d *trmnlDevices) imageToken(device string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if token, ok := d.tokens[device]; ok {
+		return token
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	if d.tokens == nil {
+		d.tokens = make(map[string]string)
+	}
+	d.tokens[device] = token
+	return token
+}
+
+// validImageToken reports whether token was issued to a device.
+func (d *trmnlDevices) validImageToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range d.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// openPairing lets the next device register until trmnlPairWindow passes.
+func (d *trmnlDevices) openPairing(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pairUntil = now.Add(trmnlPairWindow)
+}
+
+// takePairing closes an open pairing window and reports whether it was
+// open.
+func (d *trmnlDevices) takePairing(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	open := now.Before(d.pairUntil)
+	d.pairUntil = time.Time{}
+	return open
+}
+
+// handleTRMNLPair opens the pairing window for a device when the server
+// requires credentials, which the firmware can't send to /api/setup. Like
+// POST /refresh it requires the refresh token.
+func (s *Server) handleTRMNLPair(w http.ResponseWriter, r *http.Request) {
+	if s.refreshToken == "" {
+		http.Error(w, "refresh token not configured", http.StatusForbidden)
+		return
+	}
+	if !validToken(r, s.refreshToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.devices.openPairing(time.Now())
+	log.Printf("TRMNL pairing opened for %s by %s", trmnlPairWindow, r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleTRMNLSetup hands out the access token. With server credentials
+// configured, the request must carry them or come within a pairing window.
+func (s *Server) handleTRMNLSetup(w http.ResponseWriter, r *http.Request) {
+	if s.access.required() && !s.access.allowed(r) && !s.devices.takePairing(time.Now()) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	log.Printf("TRMNL device %s registered from %s", r.Header.Get("ID"), r.RemoteAddr)
+	writeJSON(w, trmnlSetupResponse{
+		Status:     http.StatusOK,
+		APIKey:     s.trmnl.AccessToken,
+		FriendlyID: "CALVIN",
+		ImageURL:   s.trmnlImageURL(r),
+		Filename:   "setup",
+		Message:    "Registered with Calvin",
+	})
+}
+
+func (s *Server) handleTRMNLDisplay(w http.ResponseWriter, r *http.Request) {
+	token := s.trmnl.AccessToken
+	if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Access-Token")), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, hash, err := s.images.load(s.outputPath)
+	if err != nil {
+		http.Error(w, "image not available", http.StatusNotFound)
+		return
+	}
+	if v := r.Header.Get("Battery-Voltage"); v != "" {
+		log.Printf("TRMNL device %s: battery %s V, RSSI %s", r.Header.Get("ID"), v, r.Header.Get("RSSI"))
+	}
+
+	// The device only downloads the image when the filename changes.
+	filename := hash
+	if !s.images.staleSince(time.Now()).IsZero() {
+		filename += "-stale"
+	}
+	writeJSON(w, trmnlDisplayResponse{
+		ImageURL:        s.trmnlImageURL(r),
+		Filename:        filename,
+		RefreshRate:     int(s.trmnl.RefreshRate.Seconds()),
+		SpecialFunction: "sleep",
+	})
+}
+
+// handleTRMNLLog accepts the firmware's diagnostic logs.
+func (s *Server) handleTRMNLLog(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 16<<10))
+	log.Printf("TRMNL device %s log: %s", r.Header.Get("ID"), body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trmnlImageURL points at the 1-bit BMP the firmware can draw, on the host
+// the device used to reach us. The firmware can't set headers, so when the
+// server requires credentials the URL carries the device's own image
+// token instead, which only opens the image.
+func (s *Server) trmnlImageURL(r *http.Request) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     r.Host,
+		Path:     "/calendar.bmp",
+		RawQuery: fmt.Sprintf("fmt=bmp1&w=%d&h=%d", s.trmnl.Width, s.trmnl.Height),
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if s.access.required() {
+		u.RawQuery += "&device_token=" + s.devices.imageToken(r.Header.Get("ID"))
+	}
+	return u.String()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Warning: unable to write response: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTRMNLServer(t *testing.T, access *Access) http.Handler {
+	t.Helper()
+	_, h := newTestServer(t, Config{
+		RefreshToken: "refresh",
+		Access:       access,
+		TRMNL:        &TRMNL{AccessToken: "devtok", Width: 800, Height: 480, RefreshRate: 15 * time.Minute},
+	})
+	return h
+}
+
+func setup(h http.Handler) int {
+	req := httptest.NewRequest("GET", "/api/setup", nil)
+	req.Header.Set("ID", "AA:BB")
+	return status(h, req)
+}
+
+func TestTRMNLSetup(t *testing.T) {
+	if got := setup(newTRMNLServer(t, nil)); got != http.StatusOK {
+		t.Errorf("setup without server auth: status %d, want 200", got)
+	}
+
+	h := newTRMNLServer(t, &Access{Token: "secret"})
+	if got := setup(h); got != http.StatusUnauthorized {
+		t.Errorf("setup without a pairing window: status %d, want 401", got)
+	}
+
+	if got := status(h, httptest.NewRequest("POST", "/api/pair", nil)); got != http.StatusUnauthorized {
+		t.Errorf("pair without the refresh token: status %d, want 401", got)
+	}
+	if got := status(h, httptest.NewRequest("POST", "/api/pair?token=secret", nil)); got != http.StatusUnauthorized {
+		t.Errorf("pair with the server token: status %d, want 401", got)
+	}
+	req := httptest.NewRequest("POST", "/api/pair", nil)
+	req.Header.Set("Authorization", "Bearer refresh")
+	if got := status(h, req); got != http.StatusAccepted {
+		t.Fatalf("pair: status %d, want 202", got)
+	}
+
+	// The window admits exactly one device.
+	if got := setup(h); got != http.StatusOK {
+		t.Errorf("first setup after pairing: status %d, want 200", got)
+	}
+	if got := setup(h); got != http.StatusUnauthorized {
+		t.Errorf("second setup after pairing: status %d, want 401", got)
+	}
+}
+
+func TestTRMNLPairingExpires(t *testing.T) {
+	var d trmnlDevices
+	now := time.Now()
+	d.openPairing(now)
+	if d.takePairing(now.Add(trmnlPairWindow)) {
+		t.Error("pairing still open once the window passed")
+	}
+
+	d.openPairing(now)
+	if !d.takePairing(now.Add(trmnlPairWindow - time.Second)) {
+		t.Error("pairing closed within the window")
+	}
+	if d.takePairing(now.Add(trmnlPairWindow - time.Second)) {
+		t.Error("pairing open for a second device")
+	}
+}
+
+func TestTRMNLDisplay(t *testing.T) {
+	h := newTRMNLServer(t, nil)
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest("GET", "/api/display", nil)
+		req.Header.Set("Access-Token", token)
+		if got := status(h, req); got != http.StatusUnauthorized {
+			t.Errorf("display with access token %q: status %d, want 401", token, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/display", nil)
+	req.Header.Set("Access-Token", "devtok")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var display trmnlDisplayResponse
+	if err := json.NewDecoder(rec.Body).Decode(&display); err != nil {
+		t.Fatalf("display answered %d: %v", rec.Code, err)
+	}
+	if display.ImageURL == "" || display.Filename == "" || display.RefreshRate != 900 {
+		t.Errorf("display = %+v", display)
+	}
+}
@@ -0,0 +1,328 @@
+// Package sports fetches a team's fixtures from an ICS feed or the
+// football-data.org API.
+package sports
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+const footballDataURL = "https://api.football-data.org/v4/teams/%d/matches?dateFrom=%s&dateTo=%s"
+
+// matchLength is the assumed duration of fixtures without an end time.
+const matchLength = 2 * time.Hour
+
+// window is how far back and ahead of now football-data.org fixtures are
+// requested; it covers the month and rolling views.
+const window = 6 * 7 * 24 * time.Hour
+
+// homeSeparators split "Home - Away" fixture titles.
+var homeSeparators = []string{" - ", " – ", " vs. ", " vs ", " v "}
+
+// Query selects a team's fixtures.
+type Query struct {
+	// URL is an ICS fixture feed. Without it, TeamID and Token select the
+	// team on football-data.org.
+	URL    string
+	TeamID int
+	Token  string
+
+	// HomeOnly keeps home games: on football-data.org by team, in ICS
+	// feeds by Team appearing before the separator of "Home - Away".
+	HomeOnly bool
+	Team     string
+}
+
+// Fetch returns the fixtures of q as events of the source name, marked
+// as fixtures.
+func Fetch(ctx context.Context, q Query, name string, now time.Time, loc *time.Location) ([]calendar.Event, error) {
+	var events []calendar.Event
+	var err error
+	if q.URL != "" {
+		events, err = fetchICS(ctx, q.URL, name, loc)
+		if err == nil && q.HomeOnly {
+			events = homeGames(events, q.Team)
+		}
+	} else {
+		events, err = fetchFootballData(ctx, q, name, now, loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		events[i].Fixture = true
+	}
+	return events, nil
+}
+
+// Window returns the days Fetch covers at now, from the start of the first
+// through the end of the last. Both are zero for feeds, which list every
+// fixture they have.
+func Window(q Query, now time.Time) (time.Time, time.Time) {
+	if q.URL != "" {
+		return time.Time{}, time.Time{}
+	}
+	from, to := now.Add(-window), now.Add(window)
+	return time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, now.Location()),
+		time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+// homeGames keeps the events whose title names team as the home side.
+// Titles without a separator can't be told apart and are kept.
+func homeGames(events []calendar.Event, team string) []calendar.Event {
+	team = strings.ToLower(team)
+	result := events[:0]
+	for _, e := range events {
+		home, ok := homeSide(e.Summary)
+		if !ok || strings.Contains(strings.ToLower(home), team) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func homeSide(summary string) (string, bool) {
+	for _, sep := range homeSeparators {
+		if i := strings.Index(summary, sep); i > 0 {
+			return summary[:i], true
+		}
+	}
+	return "", false
+}
+
+func get(ctx context.Context, url string, header http.Header) (io.ReadCloser, error) {
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fixtures: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fixtures source returned status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func fetchICS(ctx context.Context, url, name string, loc *time.Location) ([]calendar.Event, error) {
+	body, err := get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	events, err := parseICS(body, name, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures feed: %w", err)
+	}
+	return events, nil
+}
+
+// parseICS reads the VEVENTs of an iCalendar feed. Cancelled events are
+// skipped.
+func parseICS(r io.Reader, name string, loc *time.Location) ([]calendar.Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []calendar.Event
+	var props map[string]icsProp
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			props = make(map[string]icsProp)
+			continue
+		case "END:VEVENT":
+			if props != nil {
+				if e, ok := icsEvent(props, name, loc); ok {
+					events = append(events, e)
+				}
+			}
+			props = nil
+			continue
+		}
+		if props == nil {
+			continue
+		}
+		if p, ok := parseProp(line); ok {
+			props[p.name] = p
+		}
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines, which start with a space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// icsProp is a content line like DTSTART;TZID=Europe/Prague:20261018T150000.
+type icsProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseProp(line string) (icsProp, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return icsProp{}, false
+	}
+	parts := strings.Split(line[:colon], ";")
+	p := icsProp{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, true
+}
+
+func icsEvent(props map[string]icsProp, name string, loc *time.Location) (calendar.Event, bool) {
+	if strings.EqualFold(props["STATUS"].value, "CANCELLED") {
+		return calendar.Event{}, false
+	}
+	start, allDay, err := icsTime(props["DTSTART"], loc)
+	if err != nil {
+		return calendar.Event{}, false
+	}
+
+	end, _, err := icsTime(props["DTEND"], loc)
+	switch {
+	case err == nil:
+	case allDay:
+		end = start.AddDate(0, 0, 1)
+	default:
+		end = start.Add(matchLength)
+	}
+
+	return calendar.Event{
+		ID:           props["UID"].value,
+		Summary:      unescape(props["SUMMARY"].value),
+		Description:  unescape(props["DESCRIPTION"].value),
+		Location:     unescape(props["LOCATION"].value),
+		Start:        start,
+		End:          end,
+		AllDay:       allDay,
+		CalendarName: name,
+	}, true
+}
+
+// icsTime parses a DATE or DATE-TIME value, in UTC, its TZID or loc.
+func icsTime(p icsProp, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+	if len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.In(loc), false, err
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			t, err := time.ParseInLocation("20060102T150405", value, l)
+			return t.In(loc), false, err
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescape(s string) string {
+	return strings.TrimSpace(icsUnescaper.Replace(s))
+}
+
+type footballDataMatches struct {
+	Matches []struct {
+		ID          int       `json:"id"`
+		UTCDate     time.Time `json:"utcDate"`
+		Status      string    `json:"status"`
+		LastUpdated time.Time `json:"lastUpdated"`
+		Competition struct {
+			Name string `json:"name"`
+		} `json:"competition"`
+		HomeTeam footballDataTeam `json:"homeTeam"`
+		AwayTeam footballDataTeam `json:"awayTeam"`
+	} `json:"matches"`
+}
+
+type footballDataTeam struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+}
+
+func (t footballDataTeam) String() string {
+	if t.ShortName != "" {
+		return t.ShortName
+	}
+	return t.Name
+}
+
+func fetchFootballData(ctx context.Context, q Query, name string, now time.Time, loc *time.Location) ([]calendar.Event, error) {
+	url := fmt.Sprintf(footballDataURL, q.TeamID, now.Add(-window).Format("2006-01-02"), now.Add(window).Format("2006-01-02"))
+	body, err := get(ctx, url, http.Header{"X-Auth-Token": {q.Token}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var data footballDataMatches
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+
+	var events []calendar.Event
+	for _, m := range data.Matches {
+		if m.Status == "CANCELLED" || m.Status == "POSTPONED" {
+			continue
+		}
+		if q.HomeOnly && m.HomeTeam.ID != q.TeamID {
+			continue
+		}
+		start := m.UTCDate.In(loc)
+		events = append(events, calendar.Event{
+			ID:           fmt.Sprintf("football-data-%d", m.ID),
+			Updated:      m.LastUpdated,
+			Summary:      m.HomeTeam.String() + " - " + m.AwayTeam.String(),
+			Description:  m.Competition.Name,
+			Start:        start,
+			End:          start.Add(matchLength),
+			CalendarName: name,
+		})
+	}
+	return events, nil
+}
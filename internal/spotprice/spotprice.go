@@ -0,0 +1,225 @@
+// Package spotprice fetches day-ahead electricity spot prices from the
+// ENTSO-E transparency platform or a configurable JSON endpoint.
+package spotprice
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+const entsoeURL = "https://web-api.tp.entsoe.eu/api"
+
+// Price is the average price of the hour starting at Start.
+type Price struct {
+	Start time.Time
+	Price float64
+}
+
+// Query selects the prices. With Token and Area (an EIC bidding zone
+// code such as 10YCZ-CEPS-----N) they come from ENTSO-E in EUR/MWh;
+// otherwise URL returns a JSON list of objects with an RFC 3339 time
+// under TimeField and the price under PriceField.
+type Query struct {
+	Token string
+	Area  string
+
+	URL        string
+	TimeField  string
+	PriceField string
+}
+
+// Fetch returns the hourly prices of day's local calendar day, in order.
+// Finer resolutions are averaged per hour.
+func Fetch(ctx context.Context, q Query, day time.Time) ([]Price, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	var prices []Price
+	var err error
+	if q.Token != "" {
+		prices, err = fetchENTSOE(ctx, q, start, end)
+	} else {
+		prices, err = fetchJSON(ctx, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := hourlyAverages(prices, start, end)
+	if len(hourly) == 0 {
+		return nil, fmt.Errorf("no spot prices for %s", start.Format("2006-01-02"))
+	}
+	return hourly, nil
+}
+
+// Cheapest returns the indexes of the n lowest prices.
+func Cheapest(prices []Price, n int) map[int]bool {
+	order := make([]int, len(prices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return prices[order[a]].Price < prices[order[b]].Price })
+
+	cheapest := make(map[int]bool, n)
+	for _, i := range order[:min(n, len(order))] {
+		cheapest[i] = true
+	}
+	return cheapest
+}
+
+func hourlyAverages(prices []Price, start, end time.Time) []Price {
+	sums := make(map[time.Time]float64)
+	counts := make(map[time.Time]int)
+	for _, p := range prices {
+		if p.Start.Before(start) || !p.Start.Before(end) {
+			continue
+		}
+		hour := p.Start.Truncate(time.Hour)
+		sums[hour] += p.Price
+		counts[hour]++
+	}
+
+	result := make([]Price, 0, len(sums))
+	for hour, sum := range sums {
+		result = append(result, Price{Start: hour.In(start.Location()), Price: sum / float64(counts[hour])})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
+
+func get(ctx context.Context, u string) (*http.Response, error) {
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch spot prices: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("spot price API returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+type entsoeDocument struct {
+	TimeSeries []struct {
+		Period []struct {
+			TimeInterval struct {
+				Start string `xml:"start"`
+				End   string `xml:"end"`
+			} `xml:"timeInterval"`
+			Resolution string        `xml:"resolution"`
+			Points     []entsoePoint `xml:"Point"`
+		} `xml:"Period"`
+	} `xml:"TimeSeries"`
+}
+
+type entsoePoint struct {
+	Position int     `xml:"position"`
+	Price    float64 `xml:"price.amount"`
+}
+
+func fetchENTSOE(ctx context.Context, q Query, start, end time.Time) ([]Price, error) {
+	params := url.Values{
+		"securityToken": {q.Token},
+		"documentType":  {"A44"},
+		"in_Domain":     {q.Area},
+		"out_Domain":    {q.Area},
+		"periodStart":   {start.UTC().Format("200601021504")},
+		"periodEnd":     {end.UTC().Format("200601021504")},
+	}
+	resp, err := get(ctx, entsoeURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var doc entsoeDocument
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode spot prices: %w", err)
+	}
+
+	var prices []Price
+	for _, ts := range doc.TimeSeries {
+		for _, period := range ts.Period {
+			periodStart, err := time.Parse("2006-01-02T15:04Z", period.TimeInterval.Start)
+			if err != nil {
+				continue
+			}
+			periodEnd, err := time.Parse("2006-01-02T15:04Z", period.TimeInterval.End)
+			if err != nil {
+				continue
+			}
+			step := time.Hour
+			if period.Resolution == "PT15M" {
+				step = 15 * time.Minute
+			}
+			prices = append(prices, entsoePoints(period.Points, periodStart, periodEnd, step)...)
+		}
+	}
+	return prices, nil
+}
+
+// entsoePoints expands a period's points. Positions without a point repeat
+// the previous price, as ENTSO-E omits unchanged values.
+func entsoePoints(points []entsoePoint, start, end time.Time, step time.Duration) []Price {
+	byPosition := make(map[int]float64, len(points))
+	for _, p := range points {
+		byPosition[p.Position] = p.Price
+	}
+
+	var prices []Price
+	var price float64
+	known := false
+	for pos, t := 1, start; t.Before(end); pos, t = pos+1, t.Add(step) {
+		if v, ok := byPosition[pos]; ok {
+			price, known = v, true
+		}
+		if known {
+			prices = append(prices, Price{Start: t, Price: price})
+		}
+	}
+	return prices
+}
+
+func fetchJSON(ctx context.Context, q Query) ([]Price, error) {
+	resp, err := get(ctx, q.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var objects []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("failed to decode spot prices: %w", err)
+	}
+
+	prices := make([]Price, 0, len(objects))
+	for _, object := range objects {
+		at, _ := object[q.TimeField].(string)
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(at))
+		if err != nil {
+			continue
+		}
+		price, ok := object[q.PriceField].(float64)
+		if !ok {
+			continue
+		}
+		prices = append(prices, Price{Start: start, Price: price})
+	}
+	return prices, nil
+}
@@ -0,0 +1,379 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+)
+
+const (
+	eventsDB = "events.db"
+	// legacyEventsFile is the JSON cache of earlier versions, imported
+	// into the database once.
+	legacyEventsFile = "events.json"
+	// eventsDBTimeout bounds waiting for another process to release the
+	// database.
+	eventsDBTimeout = 10 * time.Second
+)
+
+// The database holds the fetch time in the meta bucket and a bucket per
+// source under sources, with its window and an events bucket keyed by
+// event key. The sync bucket holds the same per Google calendar ID, with
+// the sync token.
+var (
+	metaBucket    = []byte("meta")
+	sourcesBucket = []byte("sources")
+	syncBucket    = []byte("sync")
+	eventsBucket  = []byte("events")
+	fetchedAtKey  = []byte("fetched_at")
+	windowKey     = []byte("window")
+	tokenKey      = []byte("token")
+)
+
+// EventCache is the last successfully fetched event set per calendar
+// source. It enables offline rendering and change detection between runs.
+type EventCache struct {
+	FetchedAt time.Time                   `json:"fetched_at"`
+	Sources   map[string][]calendar.Event `json:"sources"`
+	// Windows are the ranges the sources were fetched for.
+	Windows map[string]Window `json:"windows,omitempty"`
+	// Syncs continue incremental fetches of Google calendars, keyed by
+	// calendar ID. Their events are as Google has them, before hiding.
+	Syncs map[string]*calendar.Sync `json:"-"`
+}
+
+// Window is the range a source was fetched for. A zero bound is open, for
+// sources such as scripts that return whatever they have.
+type Window struct {
+	Start time.Time `json:"start,omitzero"`
+	End   time.Time `json:"end,omitzero"`
+}
+
+// Intersect returns the range both w and other cover.
+func (w Window) Intersect(other Window) Window {
+	if other.Start.After(w.Start) {
+		w.Start = other.Start
+	}
+	if !other.End.IsZero() && (w.End.IsZero() || other.End.Before(w.End)) {
+		w.End = other.End
+	}
+	return w
+}
+
+// Overlaps reports whether e lies at least partly within w.
+func (w Window) Overlaps(e calendar.Event) bool {
+	return (w.End.IsZero() || e.Start.Before(w.End)) && (w.Start.IsZero() || e.End.After(w.Start))
+}
+
+// NewEventCache returns an empty event cache.
+func NewEventCache() *EventCache {
+	return &EventCache{
+		Sources: make(map[string][]calendar.Event),
+		Windows: make(map[string]Window),
+		Syncs:   make(map[string]*calendar.Sync),
+	}
+}
+
+// All returns every cached event across sources.
+func (c *EventCache) All() []calendar.Event {
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var all []calendar.Event
+	for _, name := range names {
+		all = append(all, c.Sources[name]...)
+	}
+	return all
+}
+
+func (s *Store) openEvents() (*bolt.DB, error) {
+	db, err := bolt.Open(s.Path(eventsDB), 0644, &bolt.Options{Timeout: eventsDBTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open event cache: %w", err)
+	}
+	return db, nil
+}
+
+// LoadEvents returns the cached events. A missing cache yields an empty
+// one; the JSON cache of earlier versions is imported on first use.
+func (s *Store) LoadEvents() (*EventCache, error) {
+	db, err := s.openEvents()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cache := NewEventCache()
+	err = db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(metaBucket); meta != nil {
+			if v := meta.Get(fetchedAtKey); v != nil {
+				if err := cache.FetchedAt.UnmarshalText(v); err != nil {
+					return err
+				}
+			}
+		}
+		if sources := tx.Bucket(sourcesBucket); sources != nil {
+			err := sources.ForEachBucket(func(name []byte) error {
+				w, events, err := loadEventSet(sources.Bucket(name))
+				cache.Windows[string(name)] = w
+				cache.Sources[string(name)] = events
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if syncs := tx.Bucket(syncBucket); syncs != nil {
+			return syncs.ForEachBucket(func(id []byte) error {
+				b := syncs.Bucket(id)
+				w, events, err := loadEventSet(b)
+				cache.Syncs[string(id)] = &calendar.Sync{
+					Token:  string(b.Get(tokenKey)),
+					Start:  w.Start,
+					End:    w.End,
+					Events: events,
+				}
+				return err
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read event cache: %w", err)
+	}
+
+	if cache.FetchedAt.IsZero() {
+		return s.importLegacyEvents(db, cache)
+	}
+	return cache, nil
+}
+
+// loadEventSet returns the window and events stored in b.
+func loadEventSet(b *bolt.Bucket) (Window, []calendar.Event, error) {
+	var w Window
+	if v := b.Get(windowKey); v != nil {
+		if err := json.Unmarshal(v, &w); err != nil {
+			return w, nil, err
+		}
+	}
+
+	var events []calendar.Event
+	if stored := b.Bucket(eventsBucket); stored != nil {
+		err := stored.ForEach(func(_, v []byte) error {
+			var e calendar.Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			events = append(events, e)
+			return nil
+		})
+		if err != nil {
+			return w, nil, err
+		}
+	}
+	return w, calendar.SortEvents(events), nil
+}
+
+// importLegacyEvents moves the events.json of earlier versions into db,
+// returning cache unchanged when there is none.
+func (s *Store) importLegacyEvents(db *bolt.DB, cache *EventCache) (*EventCache, error) {
+	data, err := os.ReadFile(s.Path(legacyEventsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read event cache: %w", err)
+	}
+
+	legacy := NewEventCache()
+	if err := json.Unmarshal(data, legacy); err != nil {
+		return nil, fmt.Errorf("unable to parse event cache: %w", err)
+	}
+	if legacy.Sources == nil {
+		legacy.Sources = make(map[string][]calendar.Event)
+	}
+	if legacy.Windows == nil {
+		legacy.Windows = make(map[string]Window)
+	}
+	legacy.Syncs = make(map[string]*calendar.Sync)
+	if err := saveEvents(db, legacy); err != nil {
+		return nil, err
+	}
+	os.Remove(s.Path(legacyEventsFile))
+	return legacy, nil
+}
+
+// SaveEvents stores the sources and syncs of cache, leaving the others
+// alone. Only events that changed since the last save are written.
+func (s *Store) SaveEvents(cache *EventCache) error {
+	db, err := s.openEvents()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return saveEvents(db, cache)
+}
+
+func saveEvents(db *bolt.DB, cache *EventCache) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		stamp, err := cache.FetchedAt.MarshalText()
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(fetchedAtKey, stamp); err != nil {
+			return err
+		}
+
+		sources, err := tx.CreateBucketIfNotExists(sourcesBucket)
+		if err != nil {
+			return err
+		}
+		for name, events := range cache.Sources {
+			if _, err := saveEventSet(sources, name, events, cache.Windows[name]); err != nil {
+				return err
+			}
+		}
+
+		syncs, err := tx.CreateBucketIfNotExists(syncBucket)
+		if err != nil {
+			return err
+		}
+		for id, sync := range cache.Syncs {
+			b, err := saveEventSet(syncs, id, sync.Events, Window{Start: sync.Start, End: sync.End})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(tokenKey, []byte(sync.Token)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write event cache: %w", err)
+	}
+	return nil
+}
+
+// saveEventSet brings the bucket name under parent in line with events
+// and w, writing the events that changed and deleting the ones that are
+// gone.
+func saveEventSet(parent *bolt.Bucket, name string, events []calendar.Event, w Window) (*bolt.Bucket, error) {
+	b, err := parent.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+	window, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Put(windowKey, window); err != nil {
+		return nil, err
+	}
+
+	stored, err := b.CreateBucketIfNotExists(eventsBucket)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(events))
+	for _, e := range events {
+		key := []byte(e.Key())
+		keep[string(key)] = true
+		value, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(stored.Get(key), value) {
+			continue
+		}
+		if err := stored.Put(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	var gone [][]byte
+	stored.ForEach(func(k, _ []byte) error {
+		if !keep[string(k)] {
+			gone = append(gone, bytes.Clone(k))
+		}
+		return nil
+	})
+	for _, k := range gone {
+		if err := stored.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// EventChanges lists differences between two event sets.
+type EventChanges struct {
+	Added   []calendar.Event
+	Removed []calendar.Event
+	// Moved events kept their identity but changed start or end.
+	Moved []calendar.Event
+	// Edited events changed in any other way (title, location, ...).
+	Edited []calendar.Event
+}
+
+func (c EventChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Moved) == 0 && len(c.Edited) == 0
+}
+
+func (c EventChanges) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d moved, %d edited", len(c.Added), len(c.Removed), len(c.Moved), len(c.Edited))
+}
+
+// DiffEvents compares previous and current by event key. Moved and Edited
+// hold the current version of the event. Added and Removed only count
+// events within the window of their source in windows, the range both runs
+// fetched, so a window moving on (at month rollover, say) doesn't read as
+// events coming and going. Sources without a window are compared whole.
+func DiffEvents(previous, current []calendar.Event, windows map[string]Window) EventChanges {
+	prevByKey := make(map[string]calendar.Event, len(previous))
+	for _, e := range previous {
+		prevByKey[e.Key()] = e
+	}
+
+	var changes EventChanges
+	seen := make(map[string]bool, len(current))
+	for _, e := range current {
+		key := e.Key()
+		seen[key] = true
+
+		old, ok := prevByKey[key]
+		switch {
+		case !ok:
+			if windows[e.CalendarName].Overlaps(e) {
+				changes.Added = append(changes.Added, e)
+			}
+		case !old.Start.Equal(e.Start) || !old.End.Equal(e.End):
+			changes.Moved = append(changes.Moved, e)
+		case !old.Updated.Equal(e.Updated) || old.Summary != e.Summary || old.Location != e.Location:
+			changes.Edited = append(changes.Edited, e)
+		}
+	}
+
+	for _, e := range previous {
+		if !seen[e.Key()] && windows[e.CalendarName].Overlaps(e) {
+			changes.Removed = append(changes.Removed, e)
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,192 @@
+package state
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+)
+
+func event(id string, start time.Time) calendar.Event {
+	return calendar.Event{ID: id, Summary: id, CalendarName: "Family", Start: start, End: start.Add(time.Hour)}
+}
+
+func keys(events []calendar.Event) []string {
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// TestDiffEventsWindowShift diffs the runs of Oct 31 and Nov 1, between
+// which the fetched range jumps from Sep 28 – Nov 28 to Oct 19 – Dec 7.
+func TestDiffEventsWindowShift(t *testing.T) {
+	loc := time.UTC
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2026, month, d, 10, 0, 0, 0, loc)
+	}
+	before := Window{}
+	before.Start, before.End = calendar.MonthDateRange(day(time.October, 31), loc)
+	after := Window{}
+	after.Start, after.End = calendar.MonthDateRange(day(time.November, 1), loc)
+
+	previous := []calendar.Event{
+		event("left-behind", day(time.October, 5)),
+		event("kept", day(time.November, 10)),
+		event("cancelled", day(time.November, 5)),
+	}
+	current := []calendar.Event{
+		event("kept", day(time.November, 10)),
+		event("came-into-view", day(time.December, 3)),
+		event("new", day(time.November, 15)),
+	}
+
+	changes := DiffEvents(previous, current, map[string]Window{"Family": before.Intersect(after)})
+	if got := keys(changes.Added); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("added %v, want [new]", got)
+	}
+	if got := keys(changes.Removed); !slices.Equal(got, []string{"cancelled"}) {
+		t.Errorf("removed %v, want [cancelled]", got)
+	}
+
+	// Without windows everything counts, as for script sources.
+	changes = DiffEvents(previous, current, nil)
+	if len(changes.Added) != 2 || len(changes.Removed) != 2 {
+		t.Errorf("unbounded diff: %s, want 2 added, 2 removed", changes)
+	}
+}
+
+func TestWindowIntersect(t *testing.T) {
+	at := func(d int) time.Time { return time.Date(2026, time.October, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		a, b Window
+		want Window
+	}{
+		{"overlapping", Window{at(1), at(20)}, Window{at(10), at(30)}, Window{at(10), at(20)}},
+		{"open", Window{}, Window{at(10), at(30)}, Window{at(10), at(30)}},
+		{"both open", Window{}, Window{}, Window{}},
+		{"open end", Window{Start: at(5)}, Window{at(1), at(20)}, Window{at(5), at(20)}},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Intersect(tt.b); !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventCacheRoundTrip(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.October, 12, 9, 0, 0, 0, time.UTC)
+	window := Window{Start: start.AddDate(0, 0, -14), End: start.AddDate(0, 0, 28)}
+
+	cache := NewEventCache()
+	cache.FetchedAt = start
+	cache.Sources["Family"] = []calendar.Event{event("b", start.Add(time.Hour)), event("a", start)}
+	cache.Windows["Family"] = window
+	if err := store.SaveEvents(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later save of one source updates its events and leaves the others.
+	update := NewEventCache()
+	update.FetchedAt = start.Add(time.Hour)
+	update.Sources["Work"] = []calendar.Event{event("w", start)}
+	if err := store.SaveEvents(update); err != nil {
+		t.Fatal(err)
+	}
+	update.Sources = map[string][]calendar.Event{"Family": {event("a", start), event("c", start.Add(2*time.Hour))}}
+	update.Windows["Family"] = window
+	if err := store.SaveEvents(update); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.LoadEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.FetchedAt.Equal(update.FetchedAt) {
+		t.Errorf("fetched at %s, want %s", loaded.FetchedAt, update.FetchedAt)
+	}
+	if got := keys(loaded.Sources["Family"]); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("Family events %v, want [a c]", got)
+	}
+	if got := keys(loaded.Sources["Work"]); !slices.Equal(got, []string{"w"}) {
+		t.Errorf("Work events %v, want [w]", got)
+	}
+	if got := loaded.Windows["Family"]; !got.Start.Equal(window.Start) || !got.End.Equal(window.End) {
+		t.Errorf("Family window %v, want %v", got, window)
+	}
+}
+
+func TestEventCacheSyncs(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.October, 12, 9, 0, 0, 0, time.UTC)
+	sync := &calendar.Sync{
+		Token:  "t1",
+		Start:  start,
+		End:    start.AddDate(0, 2, 0),
+		Events: []calendar.Event{{ID: "a", Start: start, End: start.Add(time.Hour)}, {ID: "b", Start: start, End: start.Add(time.Hour)}},
+	}
+
+	cache := NewEventCache()
+	cache.Syncs["family@group.calendar.google.com"] = sync
+	if err := store.SaveEvents(cache); err != nil {
+		t.Fatal(err)
+	}
+	sync.Token = "t2"
+	sync.Events = sync.Events[1:]
+	if err := store.SaveEvents(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.LoadEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.Syncs["family@group.calendar.google.com"]
+	if got == nil {
+		t.Fatal("the sync wasn't stored")
+	}
+	if got.Token != "t2" || !got.Start.Equal(sync.Start) || !got.End.Equal(sync.End) {
+		t.Errorf("sync token %q for %s to %s, want t2 for %s to %s", got.Token, got.Start, got.End, sync.Start, sync.End)
+	}
+	if ids := keys(got.Events); !slices.Equal(ids, []string{"b"}) {
+		t.Errorf("sync events %v, want [b]", ids)
+	}
+}
+
+func TestEventCacheImportsJSON(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"fetched_at":"2026-10-12T09:00:00Z","sources":{"Family":[{"ID":"a","Summary":"a","CalendarName":"Family","Start":"2026-10-12T09:00:00Z","End":"2026-10-12T10:00:00Z"}]}}`
+	if err := os.WriteFile(dir+"/"+legacyEventsFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		cache, err := store.LoadEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(cache.Sources["Family"]); !slices.Equal(got, []string{"a"}) {
+			t.Errorf("Family events %v, want [a]", got)
+		}
+	}
+	if _, err := os.Stat(store.Path(legacyEventsFile)); !os.IsNotExist(err) {
+		t.Errorf("events.json still exists after the import: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package state persists data between runs in a configurable directory.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	stateFile    = "state.json"
+	lastGoodFile = "last_good.png"
+	previousFile = "previous.png"
+
+	// MaxRuns is how many run summaries are kept.
+	MaxRuns = 20
+	// MaxBatterySamples is how many battery readings are kept.
+	MaxBatterySamples = 500
+)
+
+// RunSummary describes a single render run.
+type RunSummary struct {
+	StartedAt  time.Time     `json:"started_at"`
+	Version    string        `json:"version"`
+	Duration   time.Duration `json:"duration"`
+	View       string        `json:"view"`
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	Events     int           `json:"events"`
+	Changes    string        `json:"changes,omitempty"`
+	Battery    string        `json:"battery,omitempty"`
+	OutputHash string        `json:"output_hash,omitempty"`
+	Changed    bool          `json:"changed"`
+
+	// NextEvent is the start of the earliest upcoming timed event across
+	// calendars and NextReminder when the next Google notification is due;
+	// zero when there is none.
+	NextEvent    time.Time `json:"next_event,omitzero"`
+	NextReminder time.Time `json:"next_reminder,omitzero"`
+
+	Memory MemoryUsage `json:"memory,omitzero"`
+}
+
+// MemoryUsage is the heap and resident set size at the end of a run and
+// their peaks, in bytes; zero where unknown.
+type MemoryUsage struct {
+	Heap     uint64 `json:"heap"`
+	PeakHeap uint64 `json:"peak_heap"`
+	RSS      uint64 `json:"rss,omitempty"`
+	PeakRSS  uint64 `json:"peak_rss,omitempty"`
+}
+
+type BatterySample struct {
+	Time    time.Time `json:"time"`
+	Percent float64   `json:"percent"`
+}
+
+// State is everything Calvin remembers between runs.
+type State struct {
+	LastRenderHash string    `json:"last_render_hash,omitempty"`
+	LastSuccessAt  time.Time `json:"last_success_at,omitempty"`
+
+	BatteryHistory []BatterySample `json:"battery_history,omitempty"`
+	Runs           []RunSummary    `json:"runs,omitempty"`
+}
+
+// AddRun appends a run summary, keeping the newest MaxRuns.
+func (s *State) AddRun(run RunSummary) {
+	s.Runs = append(s.Runs, run)
+	if len(s.Runs) > MaxRuns {
+		s.Runs = s.Runs[len(s.Runs)-MaxRuns:]
+	}
+}
+
+// AddBatterySample appends a battery reading, keeping the newest
+// MaxBatterySamples.
+func (s *State) AddBatterySample(sample BatterySample) {
+	s.BatteryHistory = append(s.BatteryHistory, sample)
+	if len(s.BatteryHistory) > MaxBatterySamples {
+		s.BatteryHistory = s.BatteryHistory[len(s.BatteryHistory)-MaxBatterySamples:]
+	}
+}
+
+// Store reads and writes state in a directory.
+type Store struct {
+	dir string
+}
+
+// Open returns a store for dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create state directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of name inside the state directory.
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// Load returns the stored state. A missing state file yields empty state.
+func (s *Store) Load() (*State, error) {
+	st := &State{}
+
+	data, err := os.ReadFile(s.Path(stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("unable to parse state: %w", err)
+	}
+
+	return st, nil
+}
+
+// Save writes st atomically so a power cut can't leave a truncated file.
+func (s *Store) Save(st *State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(stateFile), data)
+}
+
+// LastGoodPath returns where the last successfully rendered image is kept.
+func (s *Store) LastGoodPath() string {
+	return s.Path(lastGoodFile)
+}
+
+// PreviousPath returns where the image before the last good one is kept.
+func (s *Store) PreviousPath() string {
+	return s.Path(previousFile)
+}
+
+// SaveLastGood copies the rendered image at outputPath into the store,
+// keeping the one it replaces as the previous image.
+func (s *Store) SaveLastGood(outputPath string) error {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(s.LastGoodPath(), s.PreviousPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return writeFileAtomic(s.LastGoodPath(), data)
+}
+
+// HashFile returns the hex SHA-256 of the file at path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const statsFile = "stats.json"
+
+// MaxStatsMonths is how many months of usage statistics are kept.
+const MaxStatsMonths = 12
+
+// MonthStats counts the runs of one calendar month.
+type MonthStats struct {
+	Runs      int `json:"runs"`
+	Successes int `json:"successes"`
+	// BatteryDrain sums the battery percentage lost between consecutive
+	// runs over DrainSamples refreshes; charging refreshes aren't counted.
+	BatteryDrain float64 `json:"battery_drain"`
+	DrainSamples int     `json:"drain_samples"`
+	// APIErrors counts failed fetches by service, e.g. "weather" or
+	// "calendar Work".
+	APIErrors map[string]int `json:"api_errors,omitempty"`
+}
+
+// Uptime returns the percentage of successful runs.
+func (m *MonthStats) Uptime() float64 {
+	if m.Runs == 0 {
+		return 0
+	}
+	return 100 * float64(m.Successes) / float64(m.Runs)
+}
+
+// AverageDrain returns the mean battery percentage lost per refresh, or
+// zero without samples.
+func (m *MonthStats) AverageDrain() float64 {
+	if m.DrainSamples == 0 {
+		return 0
+	}
+	return m.BatteryDrain / float64(m.DrainSamples)
+}
+
+// Stats are local usage statistics keyed by month ("2006-01"). They never
+// leave the state directory.
+type Stats struct {
+	Months map[string]*MonthStats `json:"months"`
+}
+
+// Month returns the statistics of t's month, creating them if needed.
+func (s *Stats) Month(t time.Time) *MonthStats {
+	key := t.Format("2006-01")
+	m, ok := s.Months[key]
+	if !ok {
+		m = &MonthStats{}
+		s.Months[key] = m
+		s.prune()
+	}
+	return m
+}
+
+// Keys returns the months, oldest first.
+func (s *Stats) Keys() []string {
+	keys := make([]string, 0, len(s.Months))
+	for k := range s.Months {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prune drops all but the newest MaxStatsMonths months.
+func (s *Stats) prune() {
+	keys := s.Keys()
+	for len(keys) > MaxStatsMonths {
+		delete(s.Months, keys[0])
+		keys = keys[1:]
+	}
+}
+
+// LoadStats returns the usage statistics. A missing file yields empty ones.
+func (s *Store) LoadStats() (*Stats, error) {
+	stats := &Stats{Months: make(map[string]*MonthStats)}
+
+	data, err := os.ReadFile(s.Path(statsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stats: %w", err)
+	}
+
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("unable to parse stats: %w", err)
+	}
+	if stats.Months == nil {
+		stats.Months = make(map[string]*MonthStats)
+	}
+
+	return stats, nil
+}
+
+func (s *Store) SaveStats(stats *Stats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path(statsFile), data)
+}
@@ -0,0 +1,398 @@
+package support
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/paveljanda/calvin/internal/calendar"
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/weather"
+)
+
+type displayPreset struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+var displayPresets = []displayPreset{
+	{"Waveshare 7.5\" V2 (800x480)", 800, 480},
+	{"Waveshare 7.5\" HD (880x528)", 880, 528},
+	{"Waveshare 12.48\" (1304x984)", 1304, 984},
+	{"Waveshare 13.3\" (1600x1200)", 1600, 1200},
+	{"Waveshare 10.3\" IT8951 (1872x1404)", 1872, 1404},
+	{"Inkplate 10 (1200x825)", 1200, 825},
+}
+
+// initConfig is the subset of config.Config the wizard writes, so the
+// generated file stays short and the remaining defaults apply.
+type initConfig struct {
+	Version int `yaml:"version"`
+	Display struct {
+		Width  int `yaml:"width"`
+		Height int `yaml:"height"`
+	} `yaml:"display"`
+	Weather struct {
+		Latitude  float64 `yaml:"latitude"`
+		Longitude float64 `yaml:"longitude"`
+		Timezone  string  `yaml:"timezone"`
+		Units     string  `yaml:"units"`
+	} `yaml:"weather"`
+	Calendar struct {
+		CredentialsFile string         `yaml:"credentials_file"`
+		TokenFile       string         `yaml:"token_file"`
+		Calendars       []initCalendar `yaml:"calendars"`
+	} `yaml:"calendar"`
+	Output struct {
+		Path string `yaml:"path"`
+	} `yaml:"output"`
+}
+
+type initCalendar struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Init interactively asks for the essential settings and writes a validated
+// config file to configPath.
+func Init(ctx context.Context, configPath string, force bool) error {
+	_, err := os.Stat(configPath)
+	exists := err == nil
+	if exists && !force {
+		return fmt.Errorf("%s already exists (use -force to update it)", configPath)
+	}
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	cfg := initConfig{Version: config.Version}
+
+	fmt.Fprintln(p.out, "Calvin setup")
+	fmt.Fprintln(p.out)
+
+	place, err := askLocation(ctx, p)
+	if err != nil {
+		return err
+	}
+	cfg.Weather.Latitude = place.Latitude
+	cfg.Weather.Longitude = place.Longitude
+	cfg.Weather.Timezone = place.Timezone
+
+	units, err := p.choose("Units", []string{"metric (°C, km/h, mm)", "imperial (°F, mph, inches)"}, 0)
+	if err != nil {
+		return err
+	}
+	cfg.Weather.Units = []string{"metric", "imperial"}[units]
+
+	presetNames := make([]string, 0, len(displayPresets)+1)
+	for _, preset := range displayPresets {
+		presetNames = append(presetNames, preset.Name)
+	}
+	presetNames = append(presetNames, "Custom size")
+	preset, err := p.choose("Display", presetNames, 0)
+	if err != nil {
+		return err
+	}
+	if preset < len(displayPresets) {
+		cfg.Display.Width = displayPresets[preset].Width
+		cfg.Display.Height = displayPresets[preset].Height
+	} else {
+		if cfg.Display.Width, err = p.askInt("Width in pixels", 800); err != nil {
+			return err
+		}
+		if cfg.Display.Height, err = p.askInt("Height in pixels", 480); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Calendar.CredentialsFile, err = p.ask("Google OAuth credentials file", "credentials.json"); err != nil {
+		return err
+	}
+	if cfg.Calendar.TokenFile, err = p.ask("Token file", "token.json"); err != nil {
+		return err
+	}
+	if cfg.Calendar.Calendars, err = askCalendars(ctx, p, cfg.Calendar.CredentialsFile, cfg.Calendar.TokenFile, cfg.Weather.Timezone); err != nil {
+		return err
+	}
+
+	if cfg.Output.Path, err = p.ask("Output image", "calendar.png"); err != nil {
+		return err
+	}
+
+	write := writeValidatedConfig
+	if exists {
+		write = updateConfig
+	}
+	if err := write(configPath, cfg); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out)
+	fmt.Fprintf(p.out, "Wrote %s. Try it with:\n", configPath)
+	fmt.Fprintf(p.out, "  ./calvin -config %s --no-shutdown --no-battery\n", configPath)
+	return nil
+}
+
+func askLocation(ctx context.Context, p *prompter) (weather.Place, error) {
+	for {
+		name, err := p.ask("City (empty to enter coordinates)", "")
+		if err != nil {
+			return weather.Place{}, err
+		}
+		if name == "" {
+			return askCoordinates(p)
+		}
+
+		places, err := weather.Geocode(ctx, name, 5)
+		if err != nil {
+			fmt.Fprintf(p.out, "Lookup failed: %v\n", err)
+			continue
+		}
+		if len(places) == 0 {
+			fmt.Fprintf(p.out, "No place named %q found.\n", name)
+			continue
+		}
+
+		options := make([]string, 0, len(places))
+		for _, place := range places {
+			options = append(options, fmt.Sprintf("%s (%.4f, %.4f, %s)", place, place.Latitude, place.Longitude, place.Timezone))
+		}
+		i, err := p.choose("Location", options, 0)
+		if err != nil {
+			return weather.Place{}, err
+		}
+		return places[i], nil
+	}
+}
+
+func askCoordinates(p *prompter) (weather.Place, error) {
+	var place weather.Place
+	var err error
+	if place.Latitude, err = p.askFloat("Latitude"); err != nil {
+		return place, err
+	}
+	if place.Longitude, err = p.askFloat("Longitude"); err != nil {
+		return place, err
+	}
+	if place.Timezone, err = p.ask("Timezone (empty to detect at startup)", ""); err != nil {
+		return place, err
+	}
+	return place, nil
+}
+
+// askCalendars runs the Google authorization and lets the user pick
+// calendars. Without a credentials file it falls back to the primary
+// calendar so the config can be completed later.
+func askCalendars(ctx context.Context, p *prompter, credentialsFile, tokenFile, timezone string) ([]initCalendar, error) {
+	primary := []initCalendar{{ID: "primary", Name: "Personal"}}
+
+	credentials, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		fmt.Fprintf(p.out, "Cannot read %s (%v); using the primary calendar. See README for creating credentials.\n", credentialsFile, err)
+		return primary, nil
+	}
+
+	authorize, err := p.confirm("Authorize Google Calendar access now", true)
+	if err != nil || !authorize {
+		return primary, err
+	}
+
+	client, err := calendar.NewClient(ctx, credentials, tokenFile, timezone, calendar.Scope(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar client: %w", err)
+	}
+	calendars, err := client.ListCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendars: %w", err)
+	}
+	if len(calendars) == 0 {
+		return primary, nil
+	}
+
+	fmt.Fprintln(p.out, "Calendars:")
+	for i, cal := range calendars {
+		fmt.Fprintf(p.out, "  %d) %s (%s)\n", i+1, cal.Name, cal.ID)
+	}
+	for {
+		answer, err := p.ask("Calendars to show (comma-separated numbers)", "1")
+		if err != nil {
+			return nil, err
+		}
+
+		var selected []initCalendar
+		for _, field := range strings.Split(answer, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || n < 1 || n > len(calendars) {
+				selected = nil
+				break
+			}
+			selected = append(selected, initCalendar{ID: calendars[n-1].ID, Name: calendars[n-1].Name})
+		}
+		if len(selected) > 0 {
+			return selected, nil
+		}
+		fmt.Fprintf(p.out, "Enter numbers between 1 and %d.\n", len(calendars))
+	}
+}
+
+// writeValidatedConfig writes cfg next to path, checks it with config.Load
+// and only then moves it into place.
+func writeValidatedConfig(path string, cfg initConfig) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by calvin init. See config.example.yaml for all options.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return fmt.Errorf("unable to encode config: %w", err)
+	}
+	return config.WriteFile(path, buf.Bytes())
+}
+
+// updateConfig sets the answers in the existing config at path, keeping
+// its comments and other settings, and adds calendars it doesn't list yet.
+func updateConfig(path string, cfg initConfig) error {
+	f, err := config.OpenFile(path)
+	if err != nil {
+		return err
+	}
+
+	var current initConfig
+	if err := yaml.Unmarshal(f.Bytes(), &current); err != nil {
+		return fmt.Errorf("unable to parse config: %w", err)
+	}
+
+	values := []struct {
+		key   string
+		value any
+	}{
+		{"display.width", cfg.Display.Width},
+		{"display.height", cfg.Display.Height},
+		{"weather.latitude", cfg.Weather.Latitude},
+		{"weather.longitude", cfg.Weather.Longitude},
+		{"weather.timezone", cfg.Weather.Timezone},
+		{"weather.units", cfg.Weather.Units},
+		{"calendar.credentials_file", cfg.Calendar.CredentialsFile},
+		{"calendar.token_file", cfg.Calendar.TokenFile},
+		{"output.path", cfg.Output.Path},
+	}
+	for _, v := range values {
+		if v.value == "" {
+			continue
+		}
+		if err := f.Set(v.key, v.value); err != nil {
+			return err
+		}
+	}
+
+	configured := make(map[string]bool, len(current.Calendar.Calendars))
+	for _, cal := range current.Calendar.Calendars {
+		configured[cal.ID] = true
+	}
+	for _, cal := range cfg.Calendar.Calendars {
+		if configured[cal.ID] {
+			continue
+		}
+		if err := f.Append("calendar.calendars", config.Field{Key: "id", Value: cal.ID}, config.Field{Key: "name", Value: cal.Name}); err != nil {
+			return err
+		}
+	}
+
+	return f.Save()
+}
+
+// prompter reads answers line by line.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("unable to read answer: %w", err)
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func (p *prompter) askInt(question string, def int) (int, error) {
+	for {
+		answer, err := p.ask(question, strconv.Itoa(def))
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n > 0 {
+			return n, nil
+		}
+		fmt.Fprintln(p.out, "Enter a positive whole number.")
+	}
+}
+
+func (p *prompter) askFloat(question string) (float64, error) {
+	for {
+		answer, err := p.ask(question, "")
+		if err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(answer, 64)
+		if err == nil {
+			return f, nil
+		}
+		fmt.Fprintln(p.out, "Enter a number, e.g. 50.0755.")
+	}
+}
+
+// choose lists options and returns the index of the selected one.
+func (p *prompter) choose(question string, options []string, def int) (int, error) {
+	fmt.Fprintf(p.out, "%s:\n", question)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, option)
+	}
+	for {
+		n, err := p.askInt("Choice", def+1)
+		if err != nil {
+			return 0, err
+		}
+		if n <= len(options) {
+			return n - 1, nil
+		}
+		fmt.Fprintf(p.out, "Enter a number between 1 and %d.\n", len(options))
+	}
+}
+
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	hint := "y"
+	if !def {
+		hint = "n"
+	}
+	for {
+		answer, err := p.ask(question+" (y/n)", hint)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package support
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/paveljanda/calvin/internal/install"
+)
+
+// Install handles `calvin install`, which has its own flags.
+func Install(args []string, configPath string) error {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	systemd := fs.Bool("systemd", false, "Generate and install systemd units")
+	mode := fs.String("mode", install.ModeOneshot, "oneshot (render, set alarm, shut down), timer (periodic render) or daemon")
+	onCalendar := fs.String("on-calendar", "hourly", "Timer schedule for -mode timer (systemd OnCalendar syntax)")
+	unitDir := fs.String("unit-dir", "/etc/systemd/system", "Directory to write units to")
+	dryRun := fs.Bool("dry-run", false, "Print the units instead of writing them")
+	fs.StringVar(&configPath, "config", configPath, "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*systemd {
+		return errors.New("nothing to install: pass -systemd")
+	}
+
+	opts, err := systemdOptions(*mode, configPath, *onCalendar)
+	if err != nil {
+		return err
+	}
+
+	units, err := install.SystemdUnits(opts)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, u := range units {
+			fmt.Printf("# %s\n%s\n", filepath.Join(*unitDir, u.Name), u.Content)
+		}
+		return nil
+	}
+
+	paths, err := install.WriteUnits(*unitDir, units)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		log.Printf("Wrote %s", p)
+	}
+
+	enable := "calvin.service"
+	if opts.Mode == install.ModeTimer {
+		enable = "calvin.timer"
+	}
+	log.Println("Now run:")
+	log.Println("  sudo systemctl daemon-reload")
+	log.Printf("  sudo systemctl enable --now %s", enable)
+
+	return nil
+}
+
+// systemdOptions derives unit parameters from the environment: the user
+// invoking sudo, the running executable and the config file location.
+func systemdOptions(mode, configPath, onCalendar string) (install.SystemdOptions, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return install.SystemdOptions{}, fmt.Errorf("unable to locate executable: %w", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return install.SystemdOptions{}, fmt.Errorf("unable to resolve executable: %w", err)
+	}
+
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		return install.SystemdOptions{}, fmt.Errorf("unable to resolve config path: %w", err)
+	}
+	if _, err := os.Stat(absConfig); err != nil {
+		return install.SystemdOptions{}, fmt.Errorf("config file: %w", err)
+	}
+
+	u, err := serviceUser()
+	if err != nil {
+		return install.SystemdOptions{}, err
+	}
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		return install.SystemdOptions{}, fmt.Errorf("unable to look up group: %w", err)
+	}
+
+	return install.SystemdOptions{
+		Mode:       mode,
+		User:       u.Username,
+		Group:      g.Name,
+		Executable: exe,
+		ConfigPath: absConfig,
+		WorkingDir: filepath.Dir(absConfig),
+		OnCalendar: onCalendar,
+	}, nil
+}
+
+// serviceUser returns the user who invoked sudo, so units don't run as root
+// just because installing them needs it.
+func serviceUser() (*user.User, error) {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return user.Lookup(name)
+	}
+	return user.Current()
+}
@@ -0,0 +1,26 @@
+package support
+
+import (
+	"context"
+	"log"
+
+	"github.com/paveljanda/calvin/internal/update"
+)
+
+// SelfUpdate installs the latest release over the running binary.
+func SelfUpdate(ctx context.Context, currentVersion string, force bool) error {
+	log.Printf("Checking for updates (current: %s, asset: %s)...", currentVersion, update.AssetName())
+
+	result, err := update.SelfUpdate(ctx, currentVersion, force)
+	if err != nil {
+		return err
+	}
+
+	if !result.Updated {
+		log.Printf("Already up to date (%s)", result.LatestVersion)
+		return nil
+	}
+
+	log.Printf("Updated %s from %s to %s", result.Path, result.CurrentVersion, result.LatestVersion)
+	return nil
+}
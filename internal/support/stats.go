@@ -0,0 +1,68 @@
+package support
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/state"
+)
+
+// PrintStats prints the monthly usage statistics, newest month first.
+func PrintStats(cfg *config.Config) error {
+	if !cfg.State.Stats {
+		fmt.Println("Usage statistics are off; set state.stats: true to collect them.")
+	}
+
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		return err
+	}
+
+	stats, err := store.LoadStats()
+	if err != nil {
+		return err
+	}
+	if len(stats.Months) == 0 {
+		fmt.Println("No statistics recorded yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MONTH\tRUNS\tUPTIME\tDRAIN/REFRESH\tAPI ERRORS")
+	keys := stats.Keys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		month := stats.Months[keys[i]]
+		drain := "-"
+		if month.DrainSamples > 0 {
+			drain = fmt.Sprintf("%.2f%%", month.AverageDrain())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\t%s\n",
+			keys[i],
+			month.Runs,
+			month.Uptime(),
+			drain,
+			orNone(formatAPIErrors(month.APIErrors)),
+		)
+	}
+
+	return w.Flush()
+}
+
+// formatAPIErrors returns e.g. "calendar Work 2, weather 5".
+func formatAPIErrors(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,103 @@
+package support
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/config"
+	"github.com/paveljanda/calvin/internal/state"
+)
+
+// PrintStatus prints what the state directory remembers about past runs.
+func PrintStatus(cfg *config.Config) error {
+	store, err := state.Open(cfg.State.Dir)
+	if err != nil {
+		return err
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("State directory:   %s\n", store.Dir())
+	fmt.Printf("Last success:      %s\n", formatTime(st.LastSuccessAt))
+	fmt.Printf("Last render hash:  %s\n", orNone(st.LastRenderHash))
+	if _, err := os.Stat(store.LastGoodPath()); err == nil {
+		fmt.Printf("Last good image:   %s\n", store.LastGoodPath())
+	}
+	if cache, err := store.LoadEvents(); err == nil {
+		fmt.Printf("Sync tokens:       %d\n", len(cache.Syncs))
+	}
+
+	if n := len(st.BatteryHistory); n > 0 {
+		last := st.BatteryHistory[n-1]
+		fmt.Printf("Battery:           %.0f%% at %s (%d samples)\n", last.Percent, formatTime(last.Time), n)
+	}
+
+	if n := len(st.Runs); n > 0 {
+		last := st.Runs[n-1]
+		if !last.NextEvent.IsZero() {
+			fmt.Printf("Next event:        %s\n", formatTime(last.NextEvent))
+		}
+		if !last.NextReminder.IsZero() {
+			fmt.Printf("Next reminder:     %s\n", formatTime(last.NextReminder))
+		}
+		if m := last.Memory; m != (state.MemoryUsage{}) {
+			fmt.Printf("Memory:            heap %s (peak %s), RSS %s (peak %s)\n",
+				formatMB(m.Heap), formatMB(m.PeakHeap), formatMB(m.RSS), formatMB(m.PeakRSS))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Recent runs:")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  STARTED\tVERSION\tDURATION\tVIEW\tRESULT\tEVENTS\tEVENT CHANGES\tBATTERY\tPEAK HEAP\tPEAK RSS\tCHANGED")
+	for i := len(st.Runs) - 1; i >= 0; i-- {
+		run := st.Runs[i]
+		result := "ok"
+		if !run.Success {
+			result = "error: " + run.Error
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%t\n",
+			formatTime(run.StartedAt),
+			orNone(run.Version),
+			run.Duration.Round(100*time.Millisecond),
+			run.View,
+			result,
+			run.Events,
+			orNone(run.Changes),
+			orNone(run.Battery),
+			formatMB(run.Memory.PeakHeap),
+			formatMB(run.Memory.PeakRSS),
+			run.Changed,
+		)
+	}
+
+	return w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// formatMB formats a byte count in megabytes; zero is unknown.
+func formatMB(bytes uint64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/1024/1024)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
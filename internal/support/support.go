@@ -4,20 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/paveljanda/calvin/internal/calendar"
 	"github.com/paveljanda/calvin/internal/config"
 )
 
 func ListCalendars(ctx context.Context, cfg *config.Config) error {
-	calClient, err := calendar.NewClient(ctx, cfg.Calendar.CredentialsFile, cfg.Calendar.TokenFile, cfg.Weather.Timezone)
+	calendars, err := googleCalendars(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create calendar client: %w", err)
+		return err
 	}
 
-	calendars, err := calClient.ListCalendars()
-	if err != nil {
-		return fmt.Errorf("failed to list calendars: %w", err)
+	configured := make(map[string]bool, len(cfg.Calendar.Calendars))
+	for _, src := range cfg.Calendar.Calendars {
+		configured[src.ID] = true
 	}
 
 	log.Println("\nAvailable calendars:")
@@ -25,8 +26,81 @@ func ListCalendars(ctx context.Context, cfg *config.Config) error {
 	for _, cal := range calendars {
 		log.Printf("  ID:    %s\n", cal.ID)
 		log.Printf("  Name:  %s\n", cal.Name)
+		log.Printf("  Role:  %s\n", cal.AccessRole)
+		var flags []string
+		if cal.Primary {
+			flags = append(flags, "primary")
+		}
+		if cal.Selected {
+			flags = append(flags, "selected")
+		}
+		if cal.Hidden {
+			flags = append(flags, "hidden")
+		}
+		if configured[cal.ID] {
+			flags = append(flags, "in config")
+		}
+		if len(flags) > 0 {
+			log.Printf("  Flags: %s\n", strings.Join(flags, ", "))
+		}
 		log.Println("─────────────────────────────────────────────────────────────")
 	}
 
 	return nil
 }
+
+// AddCalendar appends the Google calendar id, named as in Google, to the
+// calendar sources of the config file at path.
+func AddCalendar(ctx context.Context, cfg *config.Config, path, id string) error {
+	for _, src := range cfg.Calendar.Calendars {
+		if src.ID == id {
+			return fmt.Errorf("calendar %s is already configured as %q", id, src.Name)
+		}
+	}
+
+	calendars, err := googleCalendars(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	var found *calendar.CalendarConfig
+	for i := range calendars {
+		if calendars[i].ID == id {
+			found = &calendars[i]
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("calendar %s not found; see calvin --list-calendars", id)
+	}
+
+	f, err := config.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Append("calendar.calendars", config.Field{Key: "id", Value: found.ID}, config.Field{Key: "name", Value: found.Name}); err != nil {
+		return err
+	}
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	log.Printf("Added %q (%s) to %s", found.Name, found.ID, path)
+	return nil
+}
+
+func googleCalendars(ctx context.Context, cfg *config.Config) ([]calendar.CalendarConfig, error) {
+	credentials, err := cfg.Calendar.CredentialsJSON()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read calendar credentials: %w", err)
+	}
+
+	calClient, err := calendar.NewClient(ctx, credentials, cfg.Calendar.TokenFile, cfg.Weather.Timezone, calendar.Scope(cfg.Calendar.WriteAccess))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar client: %w", err)
+	}
+
+	calendars, err := calClient.ListCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendars: %w", err)
+	}
+	return calendars, nil
+}
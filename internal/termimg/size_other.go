@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package termimg
+
+import "os"
+
+func terminalColumns(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package termimg
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func terminalColumns(f *os.File) int {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
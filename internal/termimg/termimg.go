@@ -0,0 +1,233 @@
+// Package termimg prints images to a terminal with the kitty, iTerm2 or
+// sixel inline image protocols, falling back to colored block characters.
+package termimg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Protocols understood by Print.
+const (
+	ProtocolKitty  = "kitty"
+	ProtocolITerm  = "iterm"
+	ProtocolSixel  = "sixel"
+	ProtocolBlocks = "blocks"
+)
+
+// maxSixelWidth keeps sixel output, which has no scaling of its own, within
+// typical terminal windows.
+const maxSixelWidth = 1000
+
+// Detect guesses the protocol from the environment. CALVIN_TERMINAL_GRAPHICS
+// overrides the guess, since terminals can't be asked over a plain pipe.
+// The variables checked survive SSH where possible: TERM, and LC_TERMINAL,
+// which iTerm2 sets for this purpose.
+func Detect() string {
+	if p := os.Getenv("CALVIN_TERMINAL_GRAPHICS"); p != "" {
+		return p
+	}
+
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", program == "ghostty":
+		return ProtocolKitty
+	case program == "iTerm.app", program == "WezTerm", os.Getenv("LC_TERMINAL") == "iTerm2":
+		return ProtocolITerm
+	case strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"), strings.Contains(term, "sixel"):
+		return ProtocolSixel
+	}
+	return ProtocolBlocks
+}
+
+// Print writes img to w using protocol. columns is the terminal width in
+// character cells, used to size the image.
+func Print(w io.Writer, img image.Image, protocol string, columns int) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch protocol {
+	case ProtocolKitty:
+		err = printKitty(bw, img, columns)
+	case ProtocolITerm:
+		err = printITerm(bw, img)
+	case ProtocolSixel:
+		err = printSixel(bw, img)
+	case ProtocolBlocks:
+		err = printBlocks(bw, img, columns)
+	default:
+		return fmt.Errorf("unknown terminal graphics protocol %q: use kitty, iterm, sixel or blocks", protocol)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Columns returns the width of the terminal on f in cells, COLUMNS or 80.
+func Columns(f *os.File) int {
+	if cols := terminalColumns(f); cols > 0 {
+		return cols
+	}
+	var cols int
+	if _, err := fmt.Sscan(os.Getenv("COLUMNS"), &cols); err == nil && cols > 0 {
+		return cols
+	}
+	return 80
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// printKitty sends the PNG in 4096 byte base64 chunks, scaled to the
+// terminal width.
+func printKitty(w *bufio.Writer, img image.Image, columns int) error {
+	data, err := encodePNG(img)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	for first := true; len(encoded) > 0; first = false {
+		chunk := encoded[:min(4096, len(encoded))]
+		encoded = encoded[len(chunk):]
+		more := 0
+		if len(encoded) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_Gf=100,a=T,c=%d,m=%d;%s\x1b\\", columns, more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	_, err = w.WriteString("\n")
+	return err
+}
+
+func printITerm(w *bufio.Writer, img image.Image) error {
+	data, err := encodePNG(img)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;width=100%%;preserveAspectRatio=1:%s\a\n",
+		len(data), base64.StdEncoding.EncodeToString(data))
+	return err
+}
+
+// printSixel reduces img to the 256 color Plan 9 palette and writes it six
+// rows at a time, one color per pass.
+func printSixel(w *bufio.Writer, img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() > maxSixelWidth {
+		img = resize(img, maxSixelWidth, b.Dy()*maxSixelWidth/b.Dx())
+	}
+	b = img.Bounds()
+	pal := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.Plan9)
+	draw.Draw(pal, pal.Bounds(), img, b.Min, draw.Src)
+
+	width, height := pal.Bounds().Dx(), pal.Bounds().Dy()
+	fmt.Fprintf(w, "\x1bPq\"1;1;%d;%d", width, height)
+	for i, c := range pal.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	used := make([]bool, len(pal.Palette))
+	row := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		for i := range used {
+			used[i] = false
+		}
+		for y := top; y < min(top+6, height); y++ {
+			for x := 0; x < width; x++ {
+				used[pal.ColorIndexAt(x, y)] = true
+			}
+		}
+
+		first := true
+		for c := range pal.Palette {
+			if !used[c] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if int(pal.ColorIndexAt(x, top+dy)) == c {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = '?' + bits
+			}
+			if !first {
+				w.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(w, "#%d", c)
+			writeSixelRow(w, row)
+		}
+		w.WriteByte('-')
+	}
+	_, err := w.WriteString("\x1b\\\n")
+	return err
+}
+
+// writeSixelRow writes row with runs of the same sixel compressed.
+func writeSixelRow(w *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, row[i])
+		} else {
+			for k := i; k < j; k++ {
+				w.WriteByte(row[i])
+			}
+		}
+		i = j
+	}
+}
+
+// printBlocks draws two pixels per cell with the upper half block and
+// 24-bit foreground and background colors.
+func printBlocks(w *bufio.Writer, img image.Image, columns int) error {
+	b := img.Bounds()
+	width := min(columns, b.Dx())
+	height := b.Dy() * width / b.Dx()
+	height += height % 2
+	small := resize(img, width, height)
+
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x++ {
+			top := color.RGBAModel.Convert(small.At(x, y)).(color.RGBA)
+			bottom := color.RGBAModel.Convert(small.At(x, y+1)).(color.RGBA)
+			fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		w.WriteString("\x1b[0m\n")
+	}
+	return nil
+}
+
+func resize(img image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
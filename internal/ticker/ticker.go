@@ -0,0 +1,163 @@
+// Package ticker fetches stock or crypto prices with their daily change
+// from a configurable JSON endpoint.
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+// Quote is a symbol's latest price and its change since the previous
+// close, in percent.
+type Quote struct {
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Change    float64   `json:"change"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Query describes the endpoint. URL contains {symbol}, replaced for every
+// symbol; PriceField and ChangeField are dotted paths into the JSON
+// response, with numbers indexing lists (e.g. "data.0.price").
+type Query struct {
+	URL         string
+	PriceField  string
+	ChangeField string
+}
+
+// Fetch returns the quotes of symbols in order. Quotes younger than maxAge
+// are served from the cache at cachePath; when a fetch fails, an older
+// cached quote stands in. The error reports the first failure.
+func Fetch(ctx context.Context, q Query, symbols []string, cachePath string, maxAge time.Duration, now time.Time) ([]Quote, error) {
+	cache := loadCache(cachePath)
+
+	var quotes []Quote
+	var firstErr error
+	fetched := false
+	for _, symbol := range symbols {
+		cached, ok := cache[symbol]
+		if ok && now.Sub(cached.FetchedAt) < maxAge {
+			quotes = append(quotes, cached)
+			continue
+		}
+
+		quote, err := fetchQuote(ctx, q, symbol, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", symbol, err)
+			}
+			if ok {
+				quotes = append(quotes, cached)
+			}
+			continue
+		}
+		cache[symbol] = quote
+		quotes = append(quotes, quote)
+		fetched = true
+	}
+
+	if fetched && cachePath != "" {
+		if err := saveCache(cachePath, cache); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to save ticker cache: %w", err)
+		}
+	}
+	return quotes, firstErr
+}
+
+func fetchQuote(ctx context.Context, q Query, symbol string, now time.Time) (Quote, error) {
+	client := httpclient.New(10 * time.Second)
+
+	u := strings.ReplaceAll(q.URL, "{symbol}", url.QueryEscape(symbol))
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to fetch price: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Quote{}, fmt.Errorf("price API returned status %d", resp.StatusCode)
+	}
+
+	var body any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Quote{}, fmt.Errorf("failed to decode price: %w", err)
+	}
+
+	price, err := number(body, q.PriceField)
+	if err != nil {
+		return Quote{}, err
+	}
+	change, err := number(body, q.ChangeField)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Symbol: symbol, Price: price, Change: change, FetchedAt: now}, nil
+}
+
+// number returns the number at the dotted path. Numbers given as strings,
+// as some APIs do, are parsed.
+func number(body any, path string) (float64, error) {
+	value := body
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return 0, fmt.Errorf("price API response has no %s", path)
+			}
+			value = v[i]
+		default:
+			return 0, fmt.Errorf("price API response has no %s", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("price API response has no number at %s", path)
+}
+
+func loadCache(path string) map[string]Quote {
+	cache := make(map[string]Quote)
+	if path == "" {
+		return cache
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	// A corrupt cache is simply refetched.
+	_ = json.Unmarshal(data, &cache)
+
+	return cache
+}
+
+func saveCache(path string, cache map[string]Quote) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,229 @@
+// Package update replaces the running binary with the latest GitHub release.
+package update
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+const (
+	latestReleaseURL = "https://api.github.com/repos/paveljanda/calvin/releases/latest"
+	checksumsAsset   = "checksums.txt"
+)
+
+type release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []asset `json:"assets"`
+}
+
+type asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Result describes what SelfUpdate did.
+type Result struct {
+	CurrentVersion string
+	LatestVersion  string
+	Updated        bool
+	Path           string
+}
+
+// AssetName returns the release asset built for this platform, e.g.
+// "calvin_linux_armv6" for a Pi Zero.
+func AssetName() string {
+	arch := runtime.GOARCH
+	if arch == "arm" {
+		arch += "v" + goarm()
+	}
+	return fmt.Sprintf("calvin_%s_%s", runtime.GOOS, arch)
+}
+
+func goarm() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "GOARM" && s.Value != "" {
+				return s.Value
+			}
+		}
+	}
+	return "6"
+}
+
+// SelfUpdate downloads the latest release for this platform, verifies it
+// against the release's SHA-256 checksums and atomically replaces the running
+// executable. Nothing happens when currentVersion is already the latest
+// unless force is set.
+func SelfUpdate(ctx context.Context, currentVersion string, force bool) (*Result, error) {
+	client := &http.Client{Transport: httpclient.Transport(), Timeout: 5 * time.Minute}
+
+	rel, err := fetchLatestRelease(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{CurrentVersion: currentVersion, LatestVersion: rel.TagName}
+	if !force && strings.TrimPrefix(rel.TagName, "v") == strings.TrimPrefix(currentVersion, "v") {
+		return result, nil
+	}
+
+	name := AssetName()
+	binaryAsset, ok := rel.findAsset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary for this platform (%s)", rel.TagName, name)
+	}
+	checksums, ok := rel.findAsset(checksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.TagName, checksumsAsset)
+	}
+
+	expected, err := fetchChecksum(ctx, client, checksums.BrowserDownloadURL, name)
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate running executable: %w", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve executable path: %w", err)
+	}
+
+	// The temporary file lives next to the executable so the final rename
+	// stays on one filesystem and is atomic.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".calvin-update-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	actual, err := download(ctx, client, binaryAsset.BrowserDownloadURL, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if actual != expected {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return nil, fmt.Errorf("unable to make update executable: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return nil, fmt.Errorf("unable to replace %s: %w", exe, err)
+	}
+
+	result.Updated = true
+	result.Path = exe
+	return result, nil
+}
+
+func (r *release) findAsset(name string) (asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return asset{}, false
+}
+
+func fetchLatestRelease(ctx context.Context, client *http.Client) (*release, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", latestReleaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	var rel release
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	return &rel, nil
+}
+
+// fetchChecksum returns the SHA-256 for name from a sha256sum-style file.
+func fetchChecksum(ctx context.Context, client *http.Client, url, name string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksums: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checksums download returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	return "", errors.New("no checksum listed for " + name)
+}
+
+// download writes url to w and returns the hex SHA-256 of the content.
+func download(ctx context.Context, client *http.Client, url string, w io.Writer) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download update: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("update download returned status %d", resp.StatusCode)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download update: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,51 @@
+// Package version holds build information set with -ldflags:
+//
+//	go build -ldflags "-X github.com/paveljanda/calvin/internal/version.Version=v1.2.3 \
+//	  -X github.com/paveljanda/calvin/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/paveljanda/calvin/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Commit and Date fall back to the VCS info Go embeds in module builds.
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if Commit == "" && len(s.Value) >= 7 {
+				Commit = s.Value[:7]
+			}
+		case "vcs.time":
+			if Date == "" {
+				Date = s.Value
+			}
+		}
+	}
+}
+
+// String returns e.g. "v1.2.3 (abc1234, 2026-01-02T03:04:05Z)".
+func String() string {
+	commit := Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	date := Date
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("%s (%s, %s)", Version, commit, date)
+}
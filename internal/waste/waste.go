@@ -0,0 +1,65 @@
+// Package waste works out municipal waste pickup days from recurring
+// schedules such as "bio every odd Tuesday".
+package waste
+
+import "time"
+
+// Week parities a Schedule can be limited to, by ISO week number.
+const (
+	WeeksEvery = "every"
+	WeeksOdd   = "odd"
+	WeeksEven  = "even"
+)
+
+// Schedule is one kind of pickup on a fixed weekday. Except cancels
+// regular pickups and Extra adds pickups, e.g. when a holiday moves the
+// collection by a day.
+type Schedule struct {
+	Name    string
+	Weekday time.Weekday
+	Weeks   string
+	Except  []time.Time
+	Extra   []time.Time
+}
+
+// On reports whether s has a pickup on date.
+func (s Schedule) On(date time.Time) bool {
+	for _, d := range s.Extra {
+		if sameDay(d, date) {
+			return true
+		}
+	}
+	for _, d := range s.Except {
+		if sameDay(d, date) {
+			return false
+		}
+	}
+	if date.Weekday() != s.Weekday {
+		return false
+	}
+
+	_, week := date.ISOWeek()
+	switch s.Weeks {
+	case WeeksOdd:
+		return week%2 == 1
+	case WeeksEven:
+		return week%2 == 0
+	}
+	return true
+}
+
+// Pickups returns the names of the schedules with a pickup on date, in
+// schedule order.
+func Pickups(schedules []Schedule, date time.Time) []string {
+	var names []string
+	for _, s := range schedules {
+		if s.On(date) {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
@@ -0,0 +1,78 @@
+package weather
+
+import "time"
+
+// GetDayWeatherCode returns the most severe WMO weather code forecast for
+// the daytime hours of date, as Open-Meteo does for its daily code; ok is
+// false when the forecast doesn't cover them.
+func (f *Forecast) GetDayWeatherCode(date time.Time) (code int, ok bool) {
+	for _, h := range f.Hourly {
+		if !sameDay(h.Time, date) || h.Time.Hour() < 6 || h.Time.Hour() >= 22 {
+			continue
+		}
+		if !ok || h.WeatherCode > code {
+			code, ok = h.WeatherCode, true
+		}
+	}
+	return code, ok
+}
+
+// DescribeCode returns an icon name and an English description of a WMO
+// weather code. Icons are clear, partly-cloudy, cloudy, fog, drizzle, rain,
+// sleet, snow, showers, snow-showers and thunderstorm; unknown codes have
+// neither.
+func DescribeCode(code int) (icon, description string) {
+	switch code {
+	case 0:
+		return "clear", "Clear sky"
+	case 1:
+		return "clear", "Mainly clear"
+	case 2:
+		return "partly-cloudy", "Partly cloudy"
+	case 3:
+		return "cloudy", "Overcast"
+	case 45:
+		return "fog", "Fog"
+	case 48:
+		return "fog", "Rime fog"
+	case 51:
+		return "drizzle", "Light drizzle"
+	case 53:
+		return "drizzle", "Drizzle"
+	case 55:
+		return "drizzle", "Dense drizzle"
+	case 56, 57:
+		return "sleet", "Freezing drizzle"
+	case 61:
+		return "rain", "Light rain"
+	case 63:
+		return "rain", "Rain"
+	case 65:
+		return "rain", "Heavy rain"
+	case 66, 67:
+		return "sleet", "Freezing rain"
+	case 71:
+		return "snow", "Light snow"
+	case 73:
+		return "snow", "Snow"
+	case 75:
+		return "snow", "Heavy snow"
+	case 77:
+		return "snow", "Snow grains"
+	case 80:
+		return "showers", "Light showers"
+	case 81:
+		return "showers", "Showers"
+	case 82:
+		return "showers", "Violent showers"
+	case 85:
+		return "snow-showers", "Snow showers"
+	case 86:
+		return "snow-showers", "Heavy snow showers"
+	case 95:
+		return "thunderstorm", "Thunderstorm"
+	case 96, 99:
+		return "thunderstorm", "Thunderstorm with hail"
+	}
+	return "", ""
+}
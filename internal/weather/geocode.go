@@ -0,0 +1,201 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+// Place is a geocoding result.
+type Place struct {
+	Name    string `json:"name"`
+	Admin1  string `json:"admin1"`
+	Country string `json:"country"`
+	// CountryCode is the ISO 3166-1 alpha-2 code, e.g. "CZ".
+	CountryCode string  `json:"country_code"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Timezone    string  `json:"timezone"`
+}
+
+// String describes the place as "Brno, South Moravian, Czechia".
+func (p Place) String() string {
+	s := p.Name
+	if p.Admin1 != "" && p.Admin1 != p.Name {
+		s += ", " + p.Admin1
+	}
+	if p.Country != "" {
+		s += ", " + p.Country
+	}
+	return s
+}
+
+type geocodingResponse struct {
+	Results []Place `json:"results"`
+}
+
+// Geocode looks up places matching name via the Open-Meteo geocoding API,
+// best match first.
+func Geocode(ctx context.Context, name string, count int) ([]Place, error) {
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("count", fmt.Sprint(count))
+	params.Set("format", "json")
+
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://geocoding-api.open-meteo.com/v1/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoding API returned status %d", resp.StatusCode)
+	}
+
+	var data geocodingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode geocoding response: %w", err)
+	}
+
+	return data.Results, nil
+}
+
+// ResolveLocation turns a location such as "Brno, CZ" into a place. The part
+// after the first comma narrows the search by country code, country or
+// region. Resolved places are cached in cachePath; an empty cachePath
+// disables caching.
+func ResolveLocation(ctx context.Context, location, cachePath string) (Place, error) {
+	key := strings.ToLower(strings.TrimSpace(location))
+
+	cache := loadGeocodeCache(cachePath)
+	if place, ok := cache[key]; ok {
+		return place, nil
+	}
+
+	name, qualifier, _ := strings.Cut(location, ",")
+	name = strings.TrimSpace(name)
+	qualifier = strings.TrimSpace(qualifier)
+
+	places, err := Geocode(ctx, name, 10)
+	if err != nil {
+		return Place{}, err
+	}
+
+	var place *Place
+	for i := range places {
+		if qualifier == "" || places[i].matches(qualifier) {
+			place = &places[i]
+			break
+		}
+	}
+	if place == nil {
+		return Place{}, fmt.Errorf("no place found for %q", location)
+	}
+
+	if cachePath != "" {
+		cache[key] = *place
+		if err := saveGeocodeCache(cachePath, cache); err != nil {
+			return *place, fmt.Errorf("failed to save geocoding cache: %w", err)
+		}
+	}
+
+	return *place, nil
+}
+
+func (p Place) matches(qualifier string) bool {
+	return strings.EqualFold(p.CountryCode, qualifier) ||
+		strings.EqualFold(p.Country, qualifier) ||
+		strings.EqualFold(p.Admin1, qualifier)
+}
+
+func loadGeocodeCache(path string) map[string]Place {
+	cache := make(map[string]Place)
+	if path == "" {
+		return cache
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	// A corrupt cache is simply refetched.
+	_ = json.Unmarshal(data, &cache)
+
+	return cache
+}
+
+func saveGeocodeCache(path string, cache map[string]Place) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+type timezoneResponse struct {
+	Timezone string `json:"timezone"`
+}
+
+// LookupTimezone returns the IANA timezone at the coordinates, letting the
+// forecast API detect it. Results share the geocoding cache in cachePath.
+func LookupTimezone(ctx context.Context, latitude, longitude float64, cachePath string) (string, error) {
+	key := fmt.Sprintf("timezone|%.4f|%.4f", latitude, longitude)
+
+	cache := loadGeocodeCache(cachePath)
+	if place, ok := cache[key]; ok && place.Timezone != "" {
+		return place.Timezone, nil
+	}
+
+	url := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&timezone=auto&forecast_days=1",
+		latitude, longitude,
+	)
+
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up timezone: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("weather API returned status %d", resp.StatusCode)
+	}
+
+	var data timezoneResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("failed to decode timezone response: %w", err)
+	}
+	if data.Timezone == "" {
+		return "", fmt.Errorf("weather API returned no timezone")
+	}
+
+	if cachePath != "" {
+		cache[key] = Place{Latitude: latitude, Longitude: longitude, Timezone: data.Timezone}
+		if err := saveGeocodeCache(cachePath, cache); err != nil {
+			return data.Timezone, fmt.Errorf("failed to save geocoding cache: %w", err)
+		}
+	}
+
+	return data.Timezone, nil
+}
@@ -0,0 +1,119 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
+)
+
+// historyCacheRetention is how long cached archive values are kept. Archive
+// data never changes, so entries only expire to keep the file small.
+const historyCacheRetention = 30 * 24 * time.Hour
+
+type openMeteoArchiveResponse struct {
+	Daily struct {
+		Time          []string   `json:"time"`
+		Temperature2m []*float64 `json:"temperature_2m_max"`
+	} `json:"daily"`
+}
+
+type historyCacheEntry struct {
+	Value     float64   `json:"value"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// FetchLastYearTemperature returns the maximum temperature measured on the
+// same calendar day one year before date, using the Open-Meteo archive API.
+// Results are cached in cachePath; an empty cachePath disables caching.
+func FetchLastYearTemperature(ctx context.Context, q Query, date time.Time, cachePath string) (float64, error) {
+	units, err := ParseUnits(string(q.Units))
+	if err != nil {
+		return 0, err
+	}
+
+	day := date.AddDate(-1, 0, 0).Format("2006-01-02")
+	key := fmt.Sprintf("%s|%.4f|%.4f|%s", day, q.Latitude, q.Longitude, units)
+
+	cache := loadHistoryCache(cachePath)
+	if entry, ok := cache[key]; ok {
+		return entry.Value, nil
+	}
+
+	url := fmt.Sprintf(
+		"https://archive-api.open-meteo.com/v1/archive?latitude=%.4f&longitude=%.4f&start_date=%s&end_date=%s&daily=temperature_2m_max&timezone=%s%s",
+		q.Latitude, q.Longitude, day, day, q.Timezone, units.queryParams(),
+	)
+
+	client := httpclient.New(10 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch weather history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("weather archive API returned status %d", resp.StatusCode)
+	}
+
+	var data openMeteoArchiveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("failed to decode weather history response: %w", err)
+	}
+
+	if len(data.Daily.Temperature2m) == 0 || data.Daily.Temperature2m[0] == nil {
+		return 0, errors.New("weather archive has no data for that day")
+	}
+	value := *data.Daily.Temperature2m[0]
+
+	if cachePath != "" {
+		cache[key] = historyCacheEntry{Value: value, FetchedAt: time.Now()}
+		if err := saveHistoryCache(cachePath, cache); err != nil {
+			return value, fmt.Errorf("failed to save weather history cache: %w", err)
+		}
+	}
+
+	return value, nil
+}
+
+func loadHistoryCache(path string) map[string]historyCacheEntry {
+	cache := make(map[string]historyCacheEntry)
+	if path == "" {
+		return cache
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	// A corrupt cache is simply refetched.
+	_ = json.Unmarshal(data, &cache)
+
+	return cache
+}
+
+func saveHistoryCache(path string, cache map[string]historyCacheEntry) error {
+	cutoff := time.Now().Add(-historyCacheRetention)
+	for key, entry := range cache {
+		if entry.FetchedAt.Before(cutoff) {
+			delete(cache, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
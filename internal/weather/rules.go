@@ -0,0 +1,93 @@
+package weather
+
+import (
+	"math"
+	"time"
+)
+
+// Metrics a Rule can test, each aggregated over one day of the hourly
+// forecast.
+const (
+	MetricPrecipitationProbability = "precipitation_probability" // highest hourly chance, %
+	MetricPrecipitation            = "precipitation"             // daily total
+	MetricMinTemp                  = "min_temp"
+	MetricMaxTemp                  = "max_temp"
+	MetricWindSpeed                = "wind_speed" // highest hourly speed
+	MetricSnowfall                 = "snowfall"   // daily total, needs Query.Snow
+)
+
+// Rule suggests Text when Metric on the day Offset days from today compares
+// to Value with Op (<, <=, > or >=).
+type Rule struct {
+	Offset int
+	Metric string
+	Op     string
+	Value  float64
+	Text   string
+	Icon   string
+}
+
+// Matching returns the rules whose condition holds, in their original
+// order. Rules for days outside the forecast never match.
+func (f *Forecast) Matching(now time.Time, rules []Rule) []Rule {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var matched []Rule
+	for _, rule := range rules {
+		value, ok := f.dayMetric(today.AddDate(0, 0, rule.Offset), rule.Metric)
+		if ok && compare(value, rule.Op, rule.Value) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+func (f *Forecast) dayMetric(date time.Time, metric string) (float64, bool) {
+	var value float64
+	found := false
+	for _, h := range f.Hourly {
+		if !sameDay(h.Time, date) {
+			continue
+		}
+		var v float64
+		switch metric {
+		case MetricPrecipitationProbability:
+			v = h.PrecipitationProbability
+		case MetricPrecipitation:
+			v = h.Precipitation
+		case MetricMinTemp, MetricMaxTemp:
+			v = h.Temperature
+		case MetricWindSpeed:
+			v = h.WindSpeed
+		case MetricSnowfall:
+			v = h.Snowfall
+		}
+
+		switch {
+		case !found:
+			value = v
+		case metric == MetricPrecipitation || metric == MetricSnowfall:
+			value += v
+		case metric == MetricMinTemp:
+			value = math.Min(value, v)
+		default:
+			value = math.Max(value, v)
+		}
+		found = true
+	}
+	return value, found
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Units selects the measurement system for API requests and formatting.
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+)
+
+// ParseUnits validates a units name. An empty name means metric.
+func ParseUnits(name string) (Units, error) {
+	switch Units(strings.ToLower(name)) {
+	case "", UnitsMetric:
+		return UnitsMetric, nil
+	case UnitsImperial:
+		return UnitsImperial, nil
+	}
+	return "", fmt.Errorf("unknown units %q: must be metric or imperial", name)
+}
+
+// queryParams returns the Open-Meteo unit parameters. Metric values are the
+// API defaults, so nothing is sent for them.
+func (u Units) queryParams() string {
+	if u == UnitsImperial {
+		return "&temperature_unit=fahrenheit&wind_speed_unit=mph&precipitation_unit=inch"
+	}
+	return ""
+}
+
+// temperatureEpsilon is the nudge away from zero before rounding.
+const temperatureEpsilon = 1e-9
+
+// FormatTemperature formats a temperature for a day cell, e.g. "18°",
+// rounded to whole degrees with halves away from zero. The scale is implied
+// by the configured units to keep cells compact. A nudge well below any
+// measured precision keeps float noise such as 2.4999999999999996 for an
+// average of 2.5 from flipping the result between renders, and -0.3 prints
+// as 0°, not -0°.
+func (u Units) FormatTemperature(value float64) string {
+	rounded := math.Round(value + math.Copysign(temperatureEpsilon, value))
+	if rounded == 0 {
+		rounded = 0 // drop the sign of -0
+	}
+	return fmt.Sprintf("%.0f°", rounded)
+}
+
+// TemperatureSymbol returns the full temperature unit, e.g. "°C" or "°F".
+func (u Units) TemperatureSymbol() string {
+	if u == UnitsImperial {
+		return "°F"
+	}
+	return "°C"
+}
+
+func (u Units) FormatWindSpeed(value float64) string {
+	if u == UnitsImperial {
+		return fmt.Sprintf("%.0f mph", value)
+	}
+	return fmt.Sprintf("%.0f km/h", value)
+}
+
+func (u Units) FormatPrecipitation(value float64) string {
+	if u == UnitsImperial {
+		return fmt.Sprintf("%.2f in", value)
+	}
+	return fmt.Sprintf("%.1f mm", value)
+}
+
+// FormatSnow formats a snowfall or snow depth, e.g. "12 cm". Amounts too
+// small to round to the display precision show as "<1 cm" or "<0.1 in".
+func (u Units) FormatSnow(value float64) string {
+	if u == UnitsImperial {
+		if value < 0.05 {
+			return "<0.1 in"
+		}
+		return fmt.Sprintf("%.1f in", value)
+	}
+	if value < 0.5 {
+		return "<1 cm"
+	}
+	return fmt.Sprintf("%.0f cm", value)
+}
@@ -0,0 +1,25 @@
+package weather
+
+import "testing"
+
+func TestFormatTemperature(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{18, "18°"},
+		{2.46, "2°"},
+		{2.5, "3°"},
+		{(2.4 + 2.6) / 2, "3°"},
+		{2.4999999999999996, "3°"},
+		{2.49, "2°"},
+		{-0.3, "0°"},
+		{-2.5, "-3°"},
+		{-2.46, "-2°"},
+	}
+	for _, tt := range tests {
+		if got := UnitsMetric.FormatTemperature(tt.value); got != tt.want {
+			t.Errorf("FormatTemperature(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -6,41 +6,97 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/paveljanda/calvin/internal/httpclient"
 )
 
+// MaxForecastDays is the longest forecast the Open-Meteo API serves.
+const MaxForecastDays = 16
+
 type HourlyForecast struct {
 	Time          time.Time
 	Temperature   float64
 	WeatherCode   int
 	Precipitation float64
-	WindSpeed     float64
+	// PrecipitationProbability is the chance of precipitation in percent.
+	PrecipitationProbability float64
+	WindSpeed                float64
+	// Snowfall and SnowDepth are in cm (metric) or inches (imperial) and
+	// only filled in when the query asked for snow.
+	Snowfall  float64
+	SnowDepth float64
+}
+
+// DailyForecast holds per-day sun times.
+type DailyForecast struct {
+	Date     time.Time
+	Sunrise  time.Time
+	Sunset   time.Time
+	Daylight time.Duration
 }
 
 type Forecast struct {
+	Units  Units
 	Hourly []HourlyForecast
+	Daily  []DailyForecast
+}
+
+// Query describes a forecast request.
+type Query struct {
+	Latitude     float64
+	Longitude    float64
+	Timezone     string
+	ForecastDays int
+	Units        Units
+	// Snow additionally requests snowfall and snow depth.
+	Snow bool
 }
 
 type openMeteoResponse struct {
 	Hourly struct {
-		Time          []string  `json:"time"`
-		Temperature2m []float64 `json:"temperature_2m"`
-		WeatherCode   []int     `json:"weather_code"`
-		Precipitation []float64 `json:"precipitation"`
-		WindSpeed10m  []float64 `json:"wind_speed_10m"`
+		Time                     []string  `json:"time"`
+		Temperature2m            []float64 `json:"temperature_2m"`
+		WeatherCode              []int     `json:"weather_code"`
+		Precipitation            []float64 `json:"precipitation"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		WindSpeed10m             []float64 `json:"wind_speed_10m"`
+		Snowfall                 []float64 `json:"snowfall"`
+		SnowDepth                []float64 `json:"snow_depth"`
 	} `json:"hourly"`
+	HourlyUnits struct {
+		Snowfall  string `json:"snowfall"`
+		SnowDepth string `json:"snow_depth"`
+	} `json:"hourly_units"`
+	Daily struct {
+		Time             []string  `json:"time"`
+		Sunrise          []string  `json:"sunrise"`
+		Sunset           []string  `json:"sunset"`
+		DaylightDuration []float64 `json:"daylight_duration"`
+	} `json:"daily"`
 }
 
-func Fetch(lat, lon float64, timezone string) (*Forecast, error) {
+func Fetch(ctx context.Context, q Query) (*Forecast, error) {
+	if q.ForecastDays < 1 || q.ForecastDays > MaxForecastDays {
+		return nil, fmt.Errorf("forecast days must be between 1 and %d, got %d", MaxForecastDays, q.ForecastDays)
+	}
+	units, err := ParseUnits(string(q.Units))
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := "temperature_2m,weather_code,precipitation,precipitation_probability,wind_speed_10m"
+	if q.Snow {
+		hourly += ",snowfall,snow_depth"
+	}
+
 	url := fmt.Sprintf(
-		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=temperature_2m,weather_code,precipitation,wind_speed_10m&timezone=%s&forecast_days=8",
-		lat, lon, timezone,
+		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=%s&daily=sunrise,sunset,daylight_duration&timezone=%s&forecast_days=%d%s",
+		q.Latitude, q.Longitude, hourly, q.Timezone, q.ForecastDays, units.queryParams(),
 	)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	client := httpclient.New(10 * time.Second)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -64,6 +120,7 @@ func Fetch(lat, lon float64, timezone string) (*Forecast, error) {
 	}
 
 	forecast := &Forecast{
+		Units:  units,
 		Hourly: make([]HourlyForecast, 0, len(data.Hourly.Time)),
 	}
 
@@ -73,18 +130,56 @@ func Fetch(lat, lon float64, timezone string) (*Forecast, error) {
 			continue
 		}
 
-		forecast.Hourly = append(forecast.Hourly, HourlyForecast{
+		hour := HourlyForecast{
 			Time:          t,
 			Temperature:   data.Hourly.Temperature2m[i],
 			WeatherCode:   data.Hourly.WeatherCode[i],
 			Precipitation: data.Hourly.Precipitation[i],
 			WindSpeed:     data.Hourly.WindSpeed10m[i],
+		}
+		if i < len(data.Hourly.PrecipitationProbability) {
+			hour.PrecipitationProbability = data.Hourly.PrecipitationProbability[i]
+		}
+		if i < len(data.Hourly.Snowfall) {
+			hour.Snowfall = toSnowUnit(data.Hourly.Snowfall[i], data.HourlyUnits.Snowfall)
+		}
+		if i < len(data.Hourly.SnowDepth) {
+			hour.SnowDepth = toSnowUnit(data.Hourly.SnowDepth[i], data.HourlyUnits.SnowDepth)
+		}
+		forecast.Hourly = append(forecast.Hourly, hour)
+	}
+
+	for i, dateStr := range data.Daily.Time {
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil || i >= len(data.Daily.Sunrise) || i >= len(data.Daily.Sunset) || i >= len(data.Daily.DaylightDuration) {
+			continue
+		}
+		// Polar days and nights have no sunrise or sunset.
+		sunrise, _ := time.Parse("2006-01-02T15:04", data.Daily.Sunrise[i])
+		sunset, _ := time.Parse("2006-01-02T15:04", data.Daily.Sunset[i])
+
+		forecast.Daily = append(forecast.Daily, DailyForecast{
+			Date:     date,
+			Sunrise:  sunrise,
+			Sunset:   sunset,
+			Daylight: time.Duration(data.Daily.DaylightDuration[i] * float64(time.Second)),
 		})
 	}
 
 	return forecast, nil
 }
 
+// GetDay returns the sun times for date, or nil if the forecast has none.
+func (f *Forecast) GetDay(date time.Time) *DailyForecast {
+	for i := range f.Daily {
+		d := f.Daily[i].Date
+		if d.Year() == date.Year() && d.Month() == date.Month() && d.Day() == date.Day() {
+			return &f.Daily[i]
+		}
+	}
+	return nil
+}
+
 func (f *Forecast) GetDayTemperature(date time.Time) float64 {
 	return f.getAverageTemperature(date, 12, 18)
 }
@@ -93,6 +188,44 @@ func (f *Forecast) GetNightTemperature(date time.Time) float64 {
 	return f.getAverageTemperature(date, 0, 6)
 }
 
+// GetSnowfall returns the total snowfall on date.
+func (f *Forecast) GetSnowfall(date time.Time) float64 {
+	var sum float64
+	for _, h := range f.Hourly {
+		if sameDay(h.Time, date) {
+			sum += h.Snowfall
+		}
+	}
+	return sum
+}
+
+// GetSnowDepth returns the deepest snow cover on date.
+func (f *Forecast) GetSnowDepth(date time.Time) float64 {
+	var depth float64
+	for _, h := range f.Hourly {
+		if sameDay(h.Time, date) && h.SnowDepth > depth {
+			depth = h.SnowDepth
+		}
+	}
+	return depth
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
+
+// toSnowUnit converts an API snow value to cm or inches. The API reports
+// snowfall in cm or inches but snow depth in meters or feet.
+func toSnowUnit(value float64, unit string) float64 {
+	switch unit {
+	case "m":
+		return value * 100
+	case "ft":
+		return value * 12
+	}
+	return value
+}
+
 func (f *Forecast) getAverageTemperature(date time.Time, startHour, endHour int) float64 {
 	var sum float64
 	var count int
@@ -6,28 +6,128 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
-	"time"
+	"syscall"
 
 	"github.com/paveljanda/calvin/internal/app"
 	"github.com/paveljanda/calvin/internal/config"
-	"github.com/paveljanda/calvin/internal/render"
 	"github.com/paveljanda/calvin/internal/support"
+	"github.com/paveljanda/calvin/internal/version"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
+	configDir := flag.String("config-dir", "", "Render every *.yaml config in this directory, each in its own subdirectory")
 	listCalendars := flag.Bool("list-calendars", false, "List available calendars and exit")
 	noShutdown := flag.Bool("no-shutdown", false, "Don't shutdown or set alarm (for testing) after app run")
 	noBattery := flag.Bool("no-battery", false, "Don't read battery level (shows 100%)")
+	daemon := flag.Bool("daemon", false, "Keep running: re-render periodically and serve the image over HTTP")
+	previewTerminal := flag.Bool("preview-terminal", false, "Render without touching the output, panel or state and print the image to the terminal")
+	show := flag.Bool("show", false, "doctor: also render the results to the display")
+	force := flag.Bool("force", false, "self-update: reinstall even when already up to date; init: update an existing config")
+	jsonOut := flag.Bool("json", false, "template-data: print the data as JSON instead of the field list")
+	live := flag.Bool("live", false, "template-data: fetch the configured sources instead of using sample data")
+	icsPath := flag.String("ics", "", "export: write the displayed events to this iCalendar file")
 	flag.Parse()
 
+	// Subcommands may be followed by the same flags as the main command,
+	// except for those with their own flag set.
+	command := flag.Arg(0)
+	if command == "install" {
+		if err := support.Install(flag.Args()[1:], *configPath); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	if command != "" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Commands that don't need a config file.
+	switch command {
+	case "version":
+		fmt.Printf("calvin %s %s/%s %s\n", version.String(), runtime.GOOS, runtime.GOARCH, runtime.Version())
+		return
+	case "init":
+		if err := support.Init(ctx, *configPath, *force); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "self-update":
+		if err := support.SelfUpdate(ctx, version.Version, *force); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if *configDir != "" {
+		if command != "" || *daemon || *listCalendars || *previewTerminal {
+			log.Fatalf("--config-dir can't be combined with commands, --daemon, --list-calendars or --preview-terminal")
+		}
+		if err := app.RunDir(ctx, *configDir, runOptions(*noBattery)...); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	if err := app.ConfigureHTTP(cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-	ctx := context.Background()
+	switch command {
+	case "":
+	case "status":
+		if err := support.PrintStatus(cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "calendars":
+		if err := calendarsCommand(ctx, cfg, *configPath, flag.Args()); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "stats":
+		if err := support.PrintStats(cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "diff":
+		if err := app.Diff(cfg, flag.Args()); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "doctor":
+		if err := app.Doctor(ctx, cfg, *show); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "export":
+		if *icsPath == "" {
+			log.Fatalf("usage: calvin export --ics <file> [view]")
+		}
+		if err := app.Export(ctx, cfg, *icsPath, flag.Arg(0)); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "template-data":
+		if err := app.TemplateData(ctx, cfg, os.Stdout, flag.Arg(0), *live, *jsonOut, runOptions(*noBattery)...); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
 
 	if *listCalendars {
 		err = support.ListCalendars(ctx, cfg)
@@ -37,25 +137,42 @@ func main() {
 		return
 	}
 
-	err = app.Run(ctx, cfg, *noShutdown, *noBattery)
+	opts := runOptions(*noBattery)
+	if *previewTerminal {
+		if err := app.Preview(ctx, cfg, os.Stdout, opts...); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	if *noShutdown {
+		opts = append(opts, app.WithDryRun())
+	}
+
+	if *daemon {
+		err = app.Daemon(ctx, cfg, opts...)
+	} else {
+		err = app.Run(ctx, cfg, opts...)
+	}
 	if err != nil {
-		renderError(cfg, err)
 		log.Fatalf("Error: %v", err)
 	}
 }
 
-func renderError(cfg *config.Config, err error) {
-	errorDetails := map[string]string{
-		"Error":      err.Error(),
-		"Time":       time.Now().Format("2006-01-02 15:04:05 MST"),
-		"Args":       fmt.Sprintf("%v", os.Args),
-		"Go Version": runtime.Version(),
-		"OS/Arch":    fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+// calendarsCommand runs "calendars" (list) and "calendars add <id>".
+func calendarsCommand(ctx context.Context, cfg *config.Config, configPath string, args []string) error {
+	switch {
+	case len(args) == 0:
+		return support.ListCalendars(ctx, cfg)
+	case args[0] == "add" && len(args) == 2:
+		return support.AddCalendar(ctx, cfg, configPath, args[1])
 	}
+	return fmt.Errorf("usage: calvin calendars [add <calendar-id>]")
+}
 
-	if renderErr := render.RenderErrorToPNG(cfg.Display.Width, cfg.Display.Height, err.Error(), errorDetails, cfg.Output.Path); renderErr != nil {
-		log.Printf("Failed to render error to PNG: %v", renderErr)
-	} else {
-		log.Printf("Error details rendered to: %s", cfg.Output.Path)
+func runOptions(noBattery bool) []app.Option {
+	var opts []app.Option
+	if noBattery {
+		opts = append(opts, app.WithoutBattery())
 	}
+	return opts
 }